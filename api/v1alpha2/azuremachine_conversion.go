@@ -38,6 +38,8 @@ func (src *AzureMachine) ConvertTo(dstRaw conversion.Hub) error { // nolint
 	}
 
 	restoreAzureMachineSpec(&restored.Spec, &dst.Spec)
+	dst.Status.VMExtensions = restored.Status.VMExtensions
+	dst.Status.RoleAssignmentIDs = restored.Status.RoleAssignmentIDs
 	return nil
 }
 
@@ -48,6 +50,9 @@ func restoreAzureMachineSpec(restored, dst *infrav1alpha3.AzureMachineSpec) {
 	if len(restored.UserAssignedIdentities) > 0 {
 		dst.UserAssignedIdentities = restored.UserAssignedIdentities
 	}
+	if len(restored.SystemAssignedIdentityRoles) > 0 {
+		dst.SystemAssignedIdentityRoles = restored.SystemAssignedIdentityRoles
+	}
 	if restored.AcceleratedNetworking != nil {
 		dst.AcceleratedNetworking = restored.AcceleratedNetworking
 	}
@@ -57,6 +62,14 @@ func restoreAzureMachineSpec(restored, dst *infrav1alpha3.AzureMachineSpec) {
 	if restored.SpotVMOptions != nil {
 		dst.SpotVMOptions = restored.SpotVMOptions.DeepCopy()
 	}
+
+	if restored.BootDiagnostics != nil {
+		dst.BootDiagnostics = restored.BootDiagnostics.DeepCopy()
+	}
+
+	if len(restored.VMExtensions) > 0 {
+		dst.VMExtensions = restored.VMExtensions
+	}
 }
 
 // ConvertFrom converts from the Hub version (v1alpha3) to this version.
@@ -17,6 +17,7 @@ limitations under the License.
 package v1alpha2
 
 import (
+	"fmt"
 	"testing"
 
 	fuzz "github.com/google/gofuzz"
@@ -34,12 +35,12 @@ func TestFuzzyConversion(t *testing.T) {
 	g.Expect(AddToScheme(scheme)).To(Succeed())
 	g.Expect(v1alpha3.AddToScheme(scheme)).To(Succeed())
 
-	t.Run("for AzureCluster", utilconversion.FuzzTestFunc(scheme, &v1alpha3.AzureCluster{}, &AzureCluster{}, overrideImageFuncs))
-	t.Run("for AzureMachine", utilconversion.FuzzTestFunc(scheme, &v1alpha3.AzureMachine{}, &AzureMachine{}, overrideImageFuncs))
-	t.Run("for AzureMachineTemplate", utilconversion.FuzzTestFunc(scheme, &v1alpha3.AzureMachineTemplate{}, &AzureMachineTemplate{}, overrideImageFuncs))
+	t.Run("for AzureCluster", utilconversion.FuzzTestFunc(scheme, &v1alpha3.AzureCluster{}, &AzureCluster{}, overrideFuzzFuncs))
+	t.Run("for AzureMachine", utilconversion.FuzzTestFunc(scheme, &v1alpha3.AzureMachine{}, &AzureMachine{}, overrideFuzzFuncs))
+	t.Run("for AzureMachineTemplate", utilconversion.FuzzTestFunc(scheme, &v1alpha3.AzureMachineTemplate{}, &AzureMachineTemplate{}, overrideFuzzFuncs))
 }
 
-func overrideImageFuncs(codecs runtimeserializer.CodecFactory) []interface{} {
+func overrideFuzzFuncs(codecs runtimeserializer.CodecFactory) []interface{} {
 	return []interface{}{
 		func(image *v1alpha3.Image, c fuzz.Continue) {
 			image.Marketplace = &v1alpha3.AzureMarketplaceImage{
@@ -49,5 +50,10 @@ func overrideImageFuncs(codecs runtimeserializer.CodecFactory) []interface{} {
 				Version:   "1.0.0",
 			}
 		},
+		func(settings *runtime.RawExtension, c fuzz.Continue) {
+			// The settings are restored from the conversion annotation in canonical JSON form, so only fuzz a
+			// simple value.
+			settings.Raw = []byte(fmt.Sprintf(`{"commandToExecute":"./script-%d.sh"}`, c.Int63()))
+		},
 	}
 }
@@ -17,6 +17,7 @@ limitations under the License.
 package v1alpha3
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 )
@@ -36,6 +37,11 @@ type AzureClusterSpec struct {
 
 	SubscriptionID string `json:"subscriptionID,omitempty"`
 
+	// IdentityRef references the AzureClusterIdentity whose credentials are used to manage the Azure resources of
+	// the cluster. If unset, the credentials of the controller's environment are used.
+	// +optional
+	IdentityRef *corev1.ObjectReference `json:"identityRef,omitempty"`
+
 	Location string `json:"location"`
 
 	// ControlPlaneEndpoint represents the endpoint used to communicate with the control plane.
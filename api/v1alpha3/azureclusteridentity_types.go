@@ -0,0 +1,112 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// IdentityType is the type of the credentials of an AzureClusterIdentity.
+type IdentityType string
+
+const (
+	// ServicePrincipal authenticates as a service principal with a client secret.
+	ServicePrincipal IdentityType = "ServicePrincipal"
+	// ServicePrincipalCertificate authenticates as a service principal with a PKCS#12 client certificate.
+	ServicePrincipalCertificate IdentityType = "ServicePrincipalCertificate"
+	// ManagedIdentity authenticates as a managed identity assigned to the nodes running the controller.
+	ManagedIdentity IdentityType = "ManagedIdentity"
+)
+
+const (
+	// ClientSecretKey is the key of the client secret in the Secret of a ServicePrincipal identity.
+	ClientSecretKey = "clientSecret"
+	// CertificateKey is the key of the PKCS#12 client certificate in the Secret of a ServicePrincipalCertificate
+	// identity.
+	CertificateKey = "certificate"
+	// CertificatePasswordKey is the key of the optional password of the client certificate in the Secret of a
+	// ServicePrincipalCertificate identity.
+	CertificatePasswordKey = "password"
+)
+
+// AzureClusterIdentitySpec defines the credentials the provider uses to manage the Azure resources of a cluster.
+type AzureClusterIdentitySpec struct {
+	// Type is the type of the credentials.
+	// +kubebuilder:validation:Enum=ServicePrincipal;ServicePrincipalCertificate;ManagedIdentity
+	Type IdentityType `json:"type"`
+
+	// ClientID is the client ID of the service principal, or of a user-assigned managed identity. A
+	// ManagedIdentity without a client ID uses the system-assigned identity of the node.
+	// +optional
+	ClientID string `json:"clientID,omitempty"`
+
+	// TenantID is the ID of the Azure AD tenant of the service principal.
+	// +optional
+	TenantID string `json:"tenantID,omitempty"`
+
+	// SecretRef references the Secret holding the client secret or the client certificate of the service principal,
+	// under the clientSecret, or the certificate and password keys. The namespace defaults to the namespace of the
+	// AzureClusterIdentity.
+	// +optional
+	SecretRef *corev1.SecretReference `json:"secretRef,omitempty"`
+
+	// AllowedNamespaces are the namespaces of the clusters that may use this identity, besides its own namespace.
+	// If unset, only clusters in the namespace of the AzureClusterIdentity may use it. An empty allowedNamespaces
+	// allows clusters in any namespace.
+	// +optional
+	AllowedNamespaces *AllowedNamespaces `json:"allowedNamespaces,omitempty"`
+}
+
+// AllowedNamespaces selects the namespaces of the clusters that may use an AzureClusterIdentity. A namespace is
+// allowed if it is listed or matches the selector.
+type AllowedNamespaces struct {
+	// NamespaceList is a list of namespaces.
+	// +optional
+	NamespaceList []string `json:"list,omitempty"`
+
+	// Selector selects namespaces by their labels.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:printcolumn:name="Type",type="string",JSONPath=".spec.type",description="Type of the credentials"
+// +kubebuilder:printcolumn:name="ClientID",type="string",priority=1,JSONPath=".spec.clientID"
+// +kubebuilder:printcolumn:name="TenantID",type="string",priority=1,JSONPath=".spec.tenantID"
+// +kubebuilder:resource:path=azureclusteridentities,scope=Namespaced,categories=cluster-api
+
+// AzureClusterIdentity is the Schema for the azureclusteridentities API
+type AzureClusterIdentity struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec AzureClusterIdentitySpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AzureClusterIdentityList contains a list of AzureClusterIdentity
+type AzureClusterIdentityList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AzureClusterIdentity `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AzureClusterIdentity{}, &AzureClusterIdentityList{})
+}
@@ -52,7 +52,8 @@ type AzureMachineSpec struct {
 
 	// Identity is the type of identity used for the virtual machine.
 	// The type 'SystemAssigned' is an implicitly created identity.
-	// The generated identity will be assigned a Subscription contributor role.
+	// The generated identity will be assigned the Contributor role on the resource group of the cluster unless
+	// SystemAssignedIdentityRoles are given.
 	// The type 'UserAssigned' is a standalone Azure resource provided by the user
 	// and assigned to the VM
 	// +kubebuilder:default=None
@@ -65,6 +66,12 @@ type AzureMachineSpec struct {
 	// +optional
 	UserAssignedIdentities []UserAssignedIdentity `json:"userAssignedIdentities,omitempty"`
 
+	// SystemAssignedIdentityRoles is a list of roles assigned to the system-assigned identity of the VM.
+	// If omitted, the identity is assigned the Contributor role on the resource group of the cluster.
+	// Only valid with the 'SystemAssigned' identity type.
+	// +optional
+	SystemAssignedIdentityRoles []SystemAssignedIdentityRole `json:"systemAssignedIdentityRoles,omitempty"`
+
 	OSDisk OSDisk `json:"osDisk"`
 
 	Location string `json:"location"`
@@ -91,6 +98,15 @@ type AzureMachineSpec struct {
 	// SpotVMOptions allows the ability to specify the Machine should use a Spot VM
 	// +optional
 	SpotVMOptions *SpotVMOptions `json:"spotVMOptions,omitempty"`
+
+	// BootDiagnostics enables boot diagnostics for the Virtual Machine. When set, the serial console log
+	// of a Virtual Machine that fails to join the cluster is captured to help with debugging.
+	// +optional
+	BootDiagnostics *BootDiagnostics `json:"bootDiagnostics,omitempty"`
+
+	// VMExtensions is a list of virtual machine extensions to install on the VM once it is running.
+	// +optional
+	VMExtensions []VMExtension `json:"vmExtensions,omitempty"`
 }
 
 // SpotVMOptions defines the options relevant to running the Machine on Spot VMs
@@ -114,6 +130,15 @@ type AzureMachineStatus struct {
 	// +optional
 	VMState *VMState `json:"vmState,omitempty"`
 
+	// VMExtensions is the observed state of the virtual machine extensions installed on the VM.
+	// +optional
+	VMExtensions []VMExtensionStatus `json:"vmExtensions,omitempty"`
+
+	// RoleAssignmentIDs are the IDs of the role assignments made for the system-assigned identity of the VM. They
+	// are kept so the assignments of roles removed from SystemAssignedIdentityRoles can be deleted.
+	// +optional
+	RoleAssignmentIDs []string `json:"roleAssignmentIDs,omitempty"`
+
 	// ErrorReason will be set in the event that there is a terminal problem
 	// reconciling the Machine and will contain a succinct value suitable
 	// for machine interpretation.
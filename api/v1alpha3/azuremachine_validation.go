@@ -18,9 +18,12 @@ package v1alpha3
 
 import (
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"net/url"
+	"strings"
 
-	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2021-07-01/compute"
 	"golang.org/x/crypto/ssh"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 )
@@ -54,6 +57,26 @@ func ValidateUserAssignedIdentity(identityType VMIdentity, userAssignedIdentetie
 	return allErrs
 }
 
+// ValidateSystemAssignedIdentityRoles validates the roles assigned to the system-assigned identity
+func ValidateSystemAssignedIdentityRoles(identityType VMIdentity, roles []SystemAssignedIdentityRole, fldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if len(roles) > 0 && identityType != VMIdentitySystemAssigned {
+		allErrs = append(allErrs, field.Forbidden(fldPath, "can only be specified for the 'SystemAssigned' identity type"))
+	}
+
+	for i, role := range roles {
+		if role.DefinitionID == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Index(i).Child("DefinitionID"), "the role definition ID cannot be empty"))
+		}
+		if role.Scope != "" && !strings.HasPrefix(role.Scope, "/subscriptions/") {
+			allErrs = append(allErrs, field.Invalid(fldPath.Index(i).Child("Scope"), role.Scope, "the scope must be a resource ID starting with /subscriptions/"))
+		}
+	}
+
+	return allErrs
+}
+
 // ValidateOSDisk validates the OSDisk spec
 func ValidateOSDisk(osDisk OSDisk, fieldPath *field.Path) field.ErrorList {
 	allErrs := field.ErrorList{}
@@ -71,6 +94,56 @@ func ValidateOSDisk(osDisk OSDisk, fieldPath *field.Path) field.ErrorList {
 	return allErrs
 }
 
+// ValidateBootDiagnostics validates the boot diagnostics settings
+func ValidateBootDiagnostics(bootDiagnostics *BootDiagnostics, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if bootDiagnostics == nil || bootDiagnostics.StorageAccountURI == "" {
+		return allErrs
+	}
+
+	uri, err := url.Parse(bootDiagnostics.StorageAccountURI)
+	if err != nil || uri.Scheme != "https" || uri.Host == "" {
+		allErrs = append(allErrs, field.Invalid(fieldPath.Child("StorageAccountURI"), bootDiagnostics.StorageAccountURI, "the storage account URI must be a valid https URL"))
+	}
+
+	return allErrs
+}
+
+// ValidateVMExtensions validates the VM extensions list
+func ValidateVMExtensions(extensions []VMExtension, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	names := make(map[string]struct{}, len(extensions))
+	for i, extension := range extensions {
+		extensionPath := fieldPath.Index(i)
+		if extension.Name == "" {
+			allErrs = append(allErrs, field.Required(extensionPath.Child("Name"), "the extension name cannot be empty"))
+		} else if _, ok := names[extension.Name]; ok {
+			allErrs = append(allErrs, field.Duplicate(extensionPath.Child("Name"), extension.Name))
+		}
+		names[extension.Name] = struct{}{}
+
+		if extension.Publisher == "" {
+			allErrs = append(allErrs, field.Required(extensionPath.Child("Publisher"), "the extension publisher cannot be empty"))
+		}
+		if extension.Type == "" {
+			allErrs = append(allErrs, field.Required(extensionPath.Child("Type"), "the extension type cannot be empty"))
+		}
+		if extension.Version == "" {
+			allErrs = append(allErrs, field.Required(extensionPath.Child("Version"), "the extension version cannot be empty"))
+		}
+		if extension.Settings != nil && len(extension.Settings.Raw) > 0 {
+			settings := map[string]interface{}{}
+			if err := json.Unmarshal(extension.Settings.Raw, &settings); err != nil {
+				allErrs = append(allErrs, field.Invalid(extensionPath.Child("Settings"), string(extension.Settings.Raw), "the extension settings must be a JSON object"))
+			}
+		}
+	}
+
+	return allErrs
+}
+
 func validateStorageAccountType(storageAccountType string, fieldPath *field.Path) field.ErrorList {
 	allErrs := field.ErrorList{}
 	storageAccTypeChildPath := fieldPath.Child("ManagedDisk").Child("StorageAccountType")
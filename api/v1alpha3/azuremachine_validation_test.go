@@ -24,6 +24,7 @@ import (
 
 	. "github.com/onsi/gomega"
 	"golang.org/x/crypto/ssh"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 )
 
@@ -158,3 +159,198 @@ func generateValidOSDisk() OSDisk {
 		},
 	}
 }
+
+func TestAzureMachine_ValidateBootDiagnostics(t *testing.T) {
+	g := NewWithT(t)
+
+	tests := []struct {
+		name            string
+		bootDiagnostics *BootDiagnostics
+		wantErr         bool
+	}{
+		{
+			name:            "boot diagnostics not set",
+			bootDiagnostics: nil,
+			wantErr:         false,
+		},
+		{
+			name:            "managed storage account",
+			bootDiagnostics: &BootDiagnostics{},
+			wantErr:         false,
+		},
+		{
+			name: "valid user storage account URI",
+			bootDiagnostics: &BootDiagnostics{
+				StorageAccountURI: "https://mystorageaccount.blob.core.windows.net/",
+			},
+			wantErr: false,
+		},
+		{
+			name: "storage account URI without https",
+			bootDiagnostics: &BootDiagnostics{
+				StorageAccountURI: "http://mystorageaccount.blob.core.windows.net/",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid storage account URI",
+			bootDiagnostics: &BootDiagnostics{
+				StorageAccountURI: "mystorageaccount",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateBootDiagnostics(tc.bootDiagnostics, field.NewPath("bootDiagnostics"))
+			if tc.wantErr {
+				g.Expect(err).ToNot(HaveLen(0))
+			} else {
+				g.Expect(err).To(HaveLen(0))
+			}
+		})
+	}
+}
+
+func TestAzureMachine_ValidateVMExtensions(t *testing.T) {
+	g := NewWithT(t)
+
+	validExtension := func(name string) VMExtension {
+		return VMExtension{
+			Name:      name,
+			Publisher: "Microsoft.Azure.Extensions",
+			Type:      "CustomScript",
+			Version:   "2.1",
+		}
+	}
+
+	tests := []struct {
+		name       string
+		extensions []VMExtension
+		wantErr    bool
+	}{
+		{
+			name:       "no extensions",
+			extensions: nil,
+			wantErr:    false,
+		},
+		{
+			name:       "valid extensions",
+			extensions: []VMExtension{validExtension("hardening"), validExtension("monitoring")},
+			wantErr:    false,
+		},
+		{
+			name:       "duplicate extension names",
+			extensions: []VMExtension{validExtension("hardening"), validExtension("hardening")},
+			wantErr:    true,
+		},
+		{
+			name: "missing extension fields",
+			extensions: []VMExtension{
+				{
+					Name: "hardening",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "settings that are not a JSON object",
+			extensions: []VMExtension{
+				{
+					Name:      "hardening",
+					Publisher: "Microsoft.Azure.Extensions",
+					Type:      "CustomScript",
+					Version:   "2.1",
+					Settings:  &runtime.RawExtension{Raw: []byte(`["./harden.sh"]`)},
+				},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateVMExtensions(tc.extensions, field.NewPath("vmExtensions"))
+			if tc.wantErr {
+				g.Expect(err).ToNot(HaveLen(0))
+			} else {
+				g.Expect(err).To(HaveLen(0))
+			}
+		})
+	}
+}
+
+func TestAzureMachine_ValidateSystemAssignedIdentityRoles(t *testing.T) {
+	g := NewWithT(t)
+
+	tests := []struct {
+		name         string
+		identityType VMIdentity
+		roles        []SystemAssignedIdentityRole
+		wantErr      bool
+	}{
+		{
+			name:         "no roles",
+			identityType: VMIdentitySystemAssigned,
+			roles:        nil,
+			wantErr:      false,
+		},
+		{
+			name:         "valid roles",
+			identityType: VMIdentitySystemAssigned,
+			roles: []SystemAssignedIdentityRole{
+				{
+					DefinitionID: "acdd72a7-3385-48ef-bd42-f606fba81ae7",
+				},
+				{
+					DefinitionID: "/subscriptions/123/providers/Microsoft.Authorization/roleDefinitions/b24988ac-6180-42a0-ab88-20f7382dd24c",
+					Scope:        "/subscriptions/123/resourceGroups/my-rg",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:         "roles without a system-assigned identity",
+			identityType: VMIdentityNone,
+			roles: []SystemAssignedIdentityRole{
+				{
+					DefinitionID: "acdd72a7-3385-48ef-bd42-f606fba81ae7",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name:         "role without definition ID",
+			identityType: VMIdentitySystemAssigned,
+			roles: []SystemAssignedIdentityRole{
+				{
+					Scope: "/subscriptions/123/resourceGroups/my-rg",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name:         "role with invalid scope",
+			identityType: VMIdentitySystemAssigned,
+			roles: []SystemAssignedIdentityRole{
+				{
+					DefinitionID: "acdd72a7-3385-48ef-bd42-f606fba81ae7",
+					Scope:        "my-rg",
+				},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateSystemAssignedIdentityRoles(tc.identityType, tc.roles, field.NewPath("systemAssignedIdentityRoles"))
+			if tc.wantErr {
+				g.Expect(err).ToNot(HaveLen(0))
+			} else {
+				g.Expect(err).To(HaveLen(0))
+			}
+		})
+	}
+}
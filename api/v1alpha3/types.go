@@ -18,6 +18,7 @@ package v1alpha3
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 const (
@@ -301,6 +302,19 @@ type UserAssignedIdentity struct {
 	ProviderID string `json:"providerID"`
 }
 
+// SystemAssignedIdentityRole defines a role assigned to the system-assigned identity of a VM.
+type SystemAssignedIdentityRole struct {
+	// DefinitionID is the ID of the role definition to assign. It is either the full resource ID of the role definition,
+	// e.g. '/subscriptions/{subscriptionId}/providers/Microsoft.Authorization/roleDefinitions/{roleDefinitionId}',
+	// or the ID of an Azure built-in role, e.g. 'acdd72a7-3385-48ef-bd42-f606fba81ae7' for Reader.
+	DefinitionID string `json:"definitionID"`
+
+	// Scope is the scope the role assignment applies to, e.g. '/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}'.
+	// If omitted, the role assignment is scoped to the resource group of the cluster.
+	// +optional
+	Scope string `json:"scope,omitempty"`
+}
+
 // OSDisk defines the operating system disk for a VM.
 type OSDisk struct {
 	OSType      string      `json:"osType"`
@@ -313,6 +327,52 @@ type ManagedDisk struct {
 	StorageAccountType string `json:"storageAccountType"`
 }
 
+// BootDiagnostics defines the boot diagnostics settings for a VM. Boot diagnostics capture the serial
+// console output and a screenshot of the VM to a blob storage account.
+type BootDiagnostics struct {
+	// StorageAccountURI is the URI of a user managed storage account used to store the boot diagnostics
+	// data, e.g. https://mystorageaccount.blob.core.windows.net/.
+	// If omitted, a storage account managed by the provider is created in the cluster resource group.
+	// +optional
+	StorageAccountURI string `json:"storageAccountURI,omitempty"`
+}
+
+// VMExtension specifies the parameters of a virtual machine extension installed on a VM.
+type VMExtension struct {
+	// Name is the name of the extension.
+	Name string `json:"name"`
+
+	// Publisher is the name of the extension handler publisher, e.g. Microsoft.Azure.Extensions.
+	Publisher string `json:"publisher"`
+
+	// Type is the type of the extension handler, e.g. CustomScript.
+	Type string `json:"type"`
+
+	// Version is the version of the extension handler, e.g. 2.1.
+	Version string `json:"version"`
+
+	// Settings are the public settings passed to the extension, as a JSON object. Values may be nested objects,
+	// arrays, numbers or booleans, as the extension expects them.
+	// +optional
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Settings *runtime.RawExtension `json:"settings,omitempty"`
+
+	// ProtectedSettingsSecretName is the name of a Secret in the same namespace whose data is passed
+	// to the extension as protected settings. Protected settings are encrypted and never returned by Azure.
+	// +optional
+	ProtectedSettingsSecretName *string `json:"protectedSettingsSecretName,omitempty"`
+}
+
+// VMExtensionStatus describes the observed state of a virtual machine extension.
+type VMExtensionStatus struct {
+	// Name is the name of the extension.
+	Name string `json:"name"`
+
+	// ProvisioningState is the provisioning state of the extension, e.g. Succeeded or Failed.
+	// +optional
+	ProvisioningState string `json:"provisioningState,omitempty"`
+}
+
 // SubnetRole defines the unique role of a subnet.
 type SubnetRole string
 
@@ -0,0 +1,37 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package converters
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ExtensionSettingsToMap decodes the settings of a VM extension into the JSON object passed to Azure. It returns
+// nil if there are no settings.
+func ExtensionSettingsToMap(settings *runtime.RawExtension) (map[string]interface{}, error) {
+	if settings == nil || len(settings.Raw) == 0 {
+		return nil, nil
+	}
+	decoded := map[string]interface{}{}
+	if err := json.Unmarshal(settings.Raw, &decoded); err != nil {
+		return nil, errors.Wrap(err, "extension settings must be a JSON object")
+	}
+	return decoded, nil
+}
@@ -17,15 +17,19 @@ limitations under the License.
 package converters
 
 import (
-	"github.com/Azure/azure-sdk-for-go/profiles/latest/compute/mgmt/compute"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2021-07-01/compute"
 	"github.com/Azure/go-autorest/autorest/to"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
 	infrav1exp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1alpha3"
 )
 
-// SDKToVMSS converts an Azure SDK VirtualMachineScaleSet to the AzureMachinePool type.
-func SDKToVMSS(sdkvmss compute.VirtualMachineScaleSet, sdkinstances []compute.VirtualMachineScaleSetVM) *infrav1exp.VMSS {
+// SDKToVMSS converts an Azure SDK VirtualMachineScaleSet to the AzureMachinePool type. The instances of a scale set
+// in the Uniform orchestration mode are VM scale set VMs, while the instances of a scale set in the Flexible
+// orchestration mode are virtual machines referencing the scale set.
+func SDKToVMSS(sdkvmss compute.VirtualMachineScaleSet, sdkinstances []compute.VirtualMachineScaleSetVM, sdkvms []compute.VirtualMachine) *infrav1exp.VMSS {
 	vmss := &infrav1exp.VMSS{
 		ID:    to.String(sdkvmss.ID),
 		Name:  to.String(sdkvmss.Name),
@@ -45,6 +49,19 @@ func SDKToVMSS(sdkvmss compute.VirtualMachineScaleSet, sdkinstances []compute.Vi
 		vmss.Tags = MapToTags(sdkvmss.Tags)
 	}
 
+	if sdkvmss.VirtualMachineScaleSetProperties != nil && sdkvmss.VirtualMachineProfile != nil &&
+		sdkvmss.VirtualMachineProfile.ExtensionProfile != nil && sdkvmss.VirtualMachineProfile.ExtensionProfile.Extensions != nil {
+		for _, extension := range *sdkvmss.VirtualMachineProfile.ExtensionProfile.Extensions {
+			status := infrav1.VMExtensionStatus{
+				Name: to.String(extension.Name),
+			}
+			if extension.VirtualMachineScaleSetExtensionProperties != nil {
+				status.ProvisioningState = to.String(extension.ProvisioningState)
+			}
+			vmss.VMExtensions = append(vmss.VMExtensions, status)
+		}
+	}
+
 	if len(sdkinstances) > 0 {
 		vmss.Instances = make([]infrav1exp.VMSSVM, len(sdkinstances))
 		for i, vm := range sdkinstances {
@@ -55,6 +72,13 @@ func SDKToVMSS(sdkvmss compute.VirtualMachineScaleSet, sdkinstances []compute.Vi
 				State:      infrav1.VMState(to.String(vm.ProvisioningState)),
 			}
 
+			if vm.VirtualMachineScaleSetVMProperties != nil {
+				instance.LatestModelApplied = to.Bool(vm.LatestModelApplied)
+				if vm.InstanceView != nil && vm.InstanceView.VMHealth != nil && vm.InstanceView.VMHealth.Status != nil {
+					instance.HealthState = getHealthState(to.String(vm.InstanceView.VMHealth.Status.Code))
+				}
+			}
+
 			if vm.Zones != nil && len(*vm.Zones) > 0 {
 				instance.AvailabilityZone = to.StringSlice(vm.Zones)[0]
 			}
@@ -62,5 +86,32 @@ func SDKToVMSS(sdkvmss compute.VirtualMachineScaleSet, sdkinstances []compute.Vi
 		}
 	}
 
+	if len(sdkvms) > 0 {
+		// the instances of a scale set in the Flexible orchestration mode run the latest model when they were created
+		// from the model the scale set is tagged with
+		model := to.String(sdkvmss.Tags[infrav1exp.ModelTagKey])
+		for _, vm := range sdkvms {
+			instance := infrav1exp.VMSSVM{
+				ID:                 to.String(vm.ID),
+				InstanceID:         to.String(vm.Name),
+				Name:               to.String(vm.Name),
+				LatestModelApplied: model != "" && to.String(vm.Tags[infrav1exp.ModelTagKey]) == model,
+			}
+			if vm.VirtualMachineProperties != nil {
+				instance.State = infrav1.VMState(to.String(vm.ProvisioningState))
+			}
+			if vm.Zones != nil && len(*vm.Zones) > 0 {
+				instance.AvailabilityZone = to.StringSlice(vm.Zones)[0]
+			}
+			vmss.Instances = append(vmss.Instances, instance)
+		}
+	}
+
 	return vmss
 }
+
+// getHealthState returns the health state of an instance from the code of its Application Health extension status,
+// e.g. "HealthState/unhealthy" is returned as "Unhealthy".
+func getHealthState(code string) string {
+	return strings.Title(strings.TrimPrefix(code, "HealthState/"))
+}
@@ -20,10 +20,11 @@ import (
 	"fmt"
 	"testing"
 
-	"github.com/Azure/azure-sdk-for-go/profiles/latest/compute/mgmt/compute"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2021-07-01/compute"
 	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/onsi/gomega"
 
+	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
 	"sigs.k8s.io/cluster-api-provider-azure/cloud/converters"
 	infrav1exp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1alpha3"
 )
@@ -72,7 +73,15 @@ func Test_SDKToVMSS(t *testing.T) {
 							Name:       to.StringPtr("vm1"),
 							Zones:      to.StringSlicePtr([]string{"zone1"}),
 							VirtualMachineScaleSetVMProperties: &compute.VirtualMachineScaleSetVMProperties{
-								ProvisioningState: to.StringPtr(string(compute.ProvisioningState1Succeeded)),
+								ProvisioningState:  to.StringPtr(string(compute.ProvisioningState1Succeeded)),
+								LatestModelApplied: to.BoolPtr(true),
+								InstanceView: &compute.VirtualMachineScaleSetVMInstanceView{
+									VMHealth: &compute.VirtualMachineHealthStatus{
+										Status: &compute.InstanceViewStatus{
+											Code: to.StringPtr("HealthState/unhealthy"),
+										},
+									},
+								},
 							},
 						},
 					}
@@ -93,16 +102,50 @@ func Test_SDKToVMSS(t *testing.T) {
 
 				for i := 0; i < 2; i++ {
 					expected.Instances[i] = infrav1exp.VMSSVM{
-						ID:               fmt.Sprintf("vm/%d", i),
-						InstanceID:       fmt.Sprintf("%d", i),
-						Name:             fmt.Sprintf("vm%d", i),
-						AvailabilityZone: fmt.Sprintf("zone%d", i),
-						State:            "Succeeded",
+						ID:                 fmt.Sprintf("vm/%d", i),
+						InstanceID:         fmt.Sprintf("%d", i),
+						Name:               fmt.Sprintf("vm%d", i),
+						AvailabilityZone:   fmt.Sprintf("zone%d", i),
+						State:              "Succeeded",
+						LatestModelApplied: i == 1,
 					}
 				}
+				expected.Instances[1].HealthState = "Unhealthy"
 				g.Expect(actual).To(gomega.Equal(&expected))
 			},
 		},
+		{
+			Name: "ShouldPopulateVMExtensions",
+			SubjectFactory: func(g *gomega.GomegaWithT) (compute.VirtualMachineScaleSet, []compute.VirtualMachineScaleSetVM) {
+				return compute.VirtualMachineScaleSet{
+					ID:   to.StringPtr("vmssID"),
+					Name: to.StringPtr("vmssName"),
+					VirtualMachineScaleSetProperties: &compute.VirtualMachineScaleSetProperties{
+						ProvisioningState: to.StringPtr(string(compute.ProvisioningState1Succeeded)),
+						VirtualMachineProfile: &compute.VirtualMachineScaleSetVMProfile{
+							ExtensionProfile: &compute.VirtualMachineScaleSetExtensionProfile{
+								Extensions: &[]compute.VirtualMachineScaleSetExtension{
+									{
+										Name: to.StringPtr("hardening"),
+										VirtualMachineScaleSetExtensionProperties: &compute.VirtualMachineScaleSetExtensionProperties{
+											ProvisioningState: to.StringPtr(string(compute.ProvisioningState1Succeeded)),
+										},
+									},
+								},
+							},
+						},
+					},
+				}, nil
+			},
+			Expect: func(g *gomega.GomegaWithT, actual *infrav1exp.VMSS) {
+				g.Expect(actual.VMExtensions).To(gomega.Equal([]infrav1.VMExtensionStatus{
+					{
+						Name:              "hardening",
+						ProvisioningState: "Succeeded",
+					},
+				}))
+			},
+		},
 	}
 
 	for _, c := range cases {
@@ -111,8 +154,71 @@ func Test_SDKToVMSS(t *testing.T) {
 			t.Parallel()
 			g := gomega.NewGomegaWithT(t)
 			vmss, instances := c.SubjectFactory(g)
-			subject := converters.SDKToVMSS(vmss, instances)
+			subject := converters.SDKToVMSS(vmss, instances, nil)
 			c.Expect(g, subject)
 		})
 	}
 }
+
+func Test_SDKToVMSSFlexible(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	vmss := compute.VirtualMachineScaleSet{
+		ID:   to.StringPtr("vmssID"),
+		Name: to.StringPtr("vmssName"),
+		Tags: map[string]*string{
+			infrav1exp.ModelTagKey: to.StringPtr("model-1"),
+		},
+		VirtualMachineScaleSetProperties: &compute.VirtualMachineScaleSetProperties{
+			ProvisioningState: to.StringPtr(string(compute.ProvisioningState1Succeeded)),
+			OrchestrationMode: compute.OrchestrationModeFlexible,
+		},
+	}
+	vms := []compute.VirtualMachine{
+		{
+			ID:    to.StringPtr("vm/vmssName-0"),
+			Name:  to.StringPtr("vmssName-0"),
+			Zones: to.StringSlicePtr([]string{"1"}),
+			Tags: map[string]*string{
+				infrav1exp.ModelTagKey: to.StringPtr("model-0"),
+			},
+			VirtualMachineProperties: &compute.VirtualMachineProperties{
+				ProvisioningState: to.StringPtr(string(compute.ProvisioningState1Succeeded)),
+			},
+		},
+		{
+			ID:   to.StringPtr("vm/vmssName-1"),
+			Name: to.StringPtr("vmssName-1"),
+			Tags: map[string]*string{
+				infrav1exp.ModelTagKey: to.StringPtr("model-1"),
+			},
+			VirtualMachineProperties: &compute.VirtualMachineProperties{
+				ProvisioningState: to.StringPtr("Creating"),
+			},
+		},
+	}
+
+	g.Expect(converters.SDKToVMSS(vmss, nil, vms)).To(gomega.Equal(&infrav1exp.VMSS{
+		ID:    "vmssID",
+		Name:  "vmssName",
+		State: "Succeeded",
+		Tags: map[string]string{
+			infrav1exp.ModelTagKey: "model-1",
+		},
+		Instances: []infrav1exp.VMSSVM{
+			{
+				ID:               "vm/vmssName-0",
+				InstanceID:       "vmssName-0",
+				Name:             "vmssName-0",
+				AvailabilityZone: "1",
+				State:            "Succeeded",
+			},
+			{
+				ID:                 "vm/vmssName-1",
+				InstanceID:         "vmssName-1",
+				Name:               "vmssName-1",
+				State:              "Creating",
+				LatestModelApplied: true,
+			},
+		},
+	}))
+}
@@ -18,8 +18,10 @@ package azure
 
 import (
 	"fmt"
+	"hash/fnv"
 
 	"github.com/blang/semver"
+	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
 	"sigs.k8s.io/cluster-api-provider-azure/version"
@@ -62,6 +64,26 @@ var SupportedAvailabilityZoneLocations = []string{
 	"southeastasia",
 }
 
+// SubnetID returns the Azure resource ID of a subnet.
+func SubnetID(subscriptionID, resourceGroup, vnetName, subnetName string) string {
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Network/virtualNetworks/%s/subnets/%s", subscriptionID, resourceGroup, vnetName, subnetName)
+}
+
+// ManagedClusterID returns the Azure resource ID of an AKS cluster.
+func ManagedClusterID(subscriptionID, resourceGroup, clusterName string) string {
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.ContainerService/managedClusters/%s", subscriptionID, resourceGroup, clusterName)
+}
+
+// ScaleSetID returns the Azure resource ID of a VM scale set.
+func ScaleSetID(subscriptionID, resourceGroup, scaleSetName string) string {
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Compute/virtualMachineScaleSets/%s", subscriptionID, resourceGroup, scaleSetName)
+}
+
+// NetworkInterfaceID returns the Azure resource ID of a network interface.
+func NetworkInterfaceID(subscriptionID, resourceGroup, nicName string) string {
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Network/networkInterfaces/%s", subscriptionID, resourceGroup, nicName)
+}
+
 // GenerateInternalLBName generates a internal load balancer name, based on the cluster name.
 func GenerateInternalLBName(clusterName string) string {
 	return fmt.Sprintf("%s-%s", clusterName, "internal-lb")
@@ -97,6 +119,22 @@ func GenerateOSDiskName(machineName string) string {
 	return fmt.Sprintf("%s_OSDisk", machineName)
 }
 
+// GenerateBootDiagnosticsStorageAccountName generates the name of the storage account used for boot diagnostics,
+// based on a hash of the subscription, resource group and cluster name. Storage account names must be globally unique
+// and consist of 3 to 24 lowercase letters and numbers.
+func GenerateBootDiagnosticsStorageAccountName(subscriptionID, resourceGroup, clusterName string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(fmt.Sprintf("%s/%s/%s", subscriptionID, resourceGroup, clusterName)))
+	return fmt.Sprintf("bootdiag%08x", h.Sum32())
+}
+
+// GenerateRoleAssignmentName generates the name of a role assignment, based on a hash of the resource holding the
+// identity, the scope and the role definition. Role assignment names must be GUIDs; generating them deterministically
+// makes role assignments idempotent and allows deleting them with the resource.
+func GenerateRoleAssignmentName(resourceID, scope, roleDefinitionID string) string {
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(fmt.Sprintf("%s|%s|%s", resourceID, scope, roleDefinitionID))).String()
+}
+
 // GetDefaultImageSKUID gets the SKU ID of the image to use for the provided version of Kubernetes.
 func getDefaultImageSKUID(k8sVersion string) (string, error) {
 	version, err := semver.ParseTolerant(k8sVersion)
@@ -85,3 +85,22 @@ func TestGetDefaultImageSKUID(t *testing.T) {
 		})
 	}
 }
+
+func TestGenerateBootDiagnosticsStorageAccountName(t *testing.T) {
+	g := NewWithT(t)
+
+	name := GenerateBootDiagnosticsStorageAccountName("123", "my-rg", "my-cluster")
+	g.Expect(name).To(MatchRegexp("^bootdiag[0-9a-f]{8}$"))
+	g.Expect(GenerateBootDiagnosticsStorageAccountName("123", "my-rg", "my-cluster")).To(Equal(name))
+	g.Expect(GenerateBootDiagnosticsStorageAccountName("123", "my-rg", "other-cluster")).NotTo(Equal(name))
+}
+
+func TestGenerateRoleAssignmentName(t *testing.T) {
+	g := NewWithT(t)
+
+	vmID := "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm"
+	name := GenerateRoleAssignmentName(vmID, "/subscriptions/123/resourceGroups/my-rg", "/subscriptions/123/providers/Microsoft.Authorization/roleDefinitions/acdd72a7-3385-48ef-bd42-f606fba81ae7")
+	g.Expect(name).To(MatchRegexp("^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$"))
+	g.Expect(GenerateRoleAssignmentName(vmID, "/subscriptions/123/resourceGroups/my-rg", "/subscriptions/123/providers/Microsoft.Authorization/roleDefinitions/acdd72a7-3385-48ef-bd42-f606fba81ae7")).To(Equal(name))
+	g.Expect(GenerateRoleAssignmentName(vmID, "/subscriptions/123", "/subscriptions/123/providers/Microsoft.Authorization/roleDefinitions/acdd72a7-3385-48ef-bd42-f606fba81ae7")).NotTo(Equal(name))
+}
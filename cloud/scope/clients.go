@@ -17,11 +17,14 @@ limitations under the License.
 package scope
 
 import (
+	"context"
 	"os"
 
 	"github.com/Azure/go-autorest/autorest"
 	"github.com/Azure/go-autorest/autorest/azure/auth"
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 const (
@@ -43,21 +46,53 @@ type AzureClients struct {
 	Authorizer                 autorest.Authorizer
 }
 
+// setCredentialsForIdentity sets the credentials of the clients from the AzureClusterIdentity a cluster in the given
+// namespace references, or from the environment of the controller if it references none.
+func (c *AzureClients) setCredentialsForIdentity(ctx context.Context, kubeClient client.Client, subscriptionID string, identityRef *corev1.ObjectReference, namespace string) error {
+	if identityRef == nil {
+		return c.setCredentials(subscriptionID)
+	}
+	credentialsProvider, err := NewAzureCredentialsProvider(ctx, kubeClient, identityRef, namespace)
+	if err != nil {
+		return errors.Wrap(err, "failed to init credentials provider")
+	}
+	return c.setCredentialsWithProvider(ctx, subscriptionID, credentialsProvider)
+}
+
 func (c *AzureClients) setCredentials(subscriptionID string) error {
-	subID, err := getSubscriptionID(subscriptionID)
+	settings, err := c.getSettingsFromEnvironment(subscriptionID)
+	if err != nil {
+		return err
+	}
+	c.Authorizer, err = settings.GetAuthorizer()
+	return err
+}
+
+// setCredentialsWithProvider sets the credentials of the clients from an AzureClusterIdentity. The cloud environment
+// is still taken from the environment of the controller.
+func (c *AzureClients) setCredentialsWithProvider(ctx context.Context, subscriptionID string, credentialsProvider *AzureCredentialsProvider) error {
+	settings, err := c.getSettingsFromEnvironment(subscriptionID)
 	if err != nil {
 		return err
 	}
+	c.Authorizer, err = credentialsProvider.GetAuthorizer(ctx, settings.Environment)
+	return err
+}
+
+func (c *AzureClients) getSettingsFromEnvironment(subscriptionID string) (auth.EnvironmentSettings, error) {
+	subID, err := getSubscriptionID(subscriptionID)
+	if err != nil {
+		return auth.EnvironmentSettings{}, err
+	}
 	c.SubscriptionID = subID
 	settings, err := auth.GetSettingsFromEnvironment()
 	if err != nil {
-		return err
+		return settings, err
 	}
 	c.ResourceManagerEndpoint = settings.Environment.ResourceManagerEndpoint
 	c.ResourceManagerVMDNSSuffix = GetAzureDNSZoneForEnvironment(settings.Environment.Name)
 	settings.Values[auth.SubscriptionID] = subscriptionID
-	c.Authorizer, err = settings.GetAuthorizer()
-	return err
+	return settings, nil
 }
 
 func getSubscriptionID(subscriptionID string) (string, error) {
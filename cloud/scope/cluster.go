@@ -54,8 +54,11 @@ func NewClusterScope(params ClusterScopeParams) (*ClusterScope, error) {
 		params.Logger = klogr.New()
 	}
 
-	err := params.AzureClients.setCredentials(params.AzureCluster.Spec.SubscriptionID)
-	if err != nil {
+	if params.Context == nil {
+		params.Context = context.TODO()
+	}
+
+	if err := params.AzureClients.setCredentialsForIdentity(params.Context, params.Client, params.AzureCluster.Spec.SubscriptionID, params.AzureCluster.Spec.IdentityRef, params.AzureCluster.Namespace); err != nil {
 		return nil, errors.Wrap(err, "failed to create Azure session")
 	}
 
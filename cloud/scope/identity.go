@@ -0,0 +1,199 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scope
+
+import (
+	"context"
+	"crypto/rsa"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/adal"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/Azure/go-autorest/autorest/azure/auth"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/pkcs12"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
+)
+
+// AzureClusterIdentityKind is the kind of the resource an identityRef references.
+const AzureClusterIdentityKind = "AzureClusterIdentity"
+
+// AzureCredentialsProvider provides the credentials of an AzureClusterIdentity.
+type AzureCredentialsProvider struct {
+	Client   client.Client
+	Identity *infrav1.AzureClusterIdentity
+}
+
+// NewAzureCredentialsProvider fetches the AzureClusterIdentity referenced by a cluster in the given namespace, and
+// checks that the namespace is allowed to use it.
+func NewAzureCredentialsProvider(ctx context.Context, kubeClient client.Client, identityRef *corev1.ObjectReference, namespace string) (*AzureCredentialsProvider, error) {
+	if identityRef.Kind != "" && identityRef.Kind != AzureClusterIdentityKind {
+		return nil, errors.Errorf("identityRef must reference an %s, not a %s", AzureClusterIdentityKind, identityRef.Kind)
+	}
+
+	key := client.ObjectKey{Name: identityRef.Name, Namespace: identityRef.Namespace}
+	if key.Namespace == "" {
+		key.Namespace = namespace
+	}
+	identity := &infrav1.AzureClusterIdentity{}
+	if err := kubeClient.Get(ctx, key, identity); err != nil {
+		return nil, errors.Wrapf(err, "failed to get AzureClusterIdentity %s/%s", key.Namespace, key.Name)
+	}
+
+	allowed, err := isNamespaceAllowed(ctx, kubeClient, identity, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, errors.Errorf("AzureClusterIdentity %s/%s does not allow clusters in namespace %s", key.Namespace, key.Name, namespace)
+	}
+
+	return &AzureCredentialsProvider{
+		Client:   kubeClient,
+		Identity: identity,
+	}, nil
+}
+
+// GetAuthorizer returns an authorizer for the Azure Resource Manager endpoint of the given environment, using the
+// credentials of the AzureClusterIdentity.
+func (p *AzureCredentialsProvider) GetAuthorizer(ctx context.Context, env azure.Environment) (autorest.Authorizer, error) {
+	spec := p.Identity.Spec
+	switch spec.Type {
+	case infrav1.ServicePrincipal:
+		secret, err := p.getSecret(ctx)
+		if err != nil {
+			return nil, err
+		}
+		clientSecret, err := getSecretValue(secret, infrav1.ClientSecretKey)
+		if err != nil {
+			return nil, err
+		}
+		config := auth.NewClientCredentialsConfig(spec.ClientID, string(clientSecret), spec.TenantID)
+		config.AADEndpoint = env.ActiveDirectoryEndpoint
+		config.Resource = env.ResourceManagerEndpoint
+		authorizer, err := config.Authorizer()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create an authorizer from the client secret")
+		}
+		return authorizer, nil
+
+	case infrav1.ServicePrincipalCertificate:
+		secret, err := p.getSecret(ctx)
+		if err != nil {
+			return nil, err
+		}
+		data, err := getSecretValue(secret, infrav1.CertificateKey)
+		if err != nil {
+			return nil, err
+		}
+		// the password is optional, as the certificate may not be encrypted
+		privateKey, certificate, err := pkcs12.Decode(data, string(secret.Data[infrav1.CertificatePasswordKey]))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to decode the client certificate")
+		}
+		rsaPrivateKey, ok := privateKey.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("the client certificate must contain an RSA private key")
+		}
+		oauthConfig, err := adal.NewOAuthConfig(env.ActiveDirectoryEndpoint, spec.TenantID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get the OAuth configuration of tenant %s", spec.TenantID)
+		}
+		token, err := adal.NewServicePrincipalTokenFromCertificate(*oauthConfig, spec.ClientID, certificate, rsaPrivateKey, env.ResourceManagerEndpoint)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create a service principal token from the client certificate")
+		}
+		return autorest.NewBearerAuthorizer(token), nil
+
+	case infrav1.ManagedIdentity:
+		config := auth.NewMSIConfig()
+		config.ClientID = spec.ClientID
+		config.Resource = env.ResourceManagerEndpoint
+		authorizer, err := config.Authorizer()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create an authorizer from the managed identity")
+		}
+		return authorizer, nil
+
+	default:
+		return nil, errors.Errorf("unsupported identity type %q", spec.Type)
+	}
+}
+
+// getSecret returns the Secret holding the credentials of a service principal.
+func (p *AzureCredentialsProvider) getSecret(ctx context.Context) (*corev1.Secret, error) {
+	ref := p.Identity.Spec.SecretRef
+	if ref == nil {
+		return nil, errors.Errorf("AzureClusterIdentity %s/%s of type %s requires a secretRef", p.Identity.Namespace, p.Identity.Name, p.Identity.Spec.Type)
+	}
+	key := client.ObjectKey{Name: ref.Name, Namespace: ref.Namespace}
+	if key.Namespace == "" {
+		key.Namespace = p.Identity.Namespace
+	}
+	secret := &corev1.Secret{}
+	if err := p.Client.Get(ctx, key, secret); err != nil {
+		return nil, errors.Wrapf(err, "failed to get Secret %s/%s of AzureClusterIdentity %s/%s", key.Namespace, key.Name, p.Identity.Namespace, p.Identity.Name)
+	}
+	return secret, nil
+}
+
+// getSecretValue returns the value of a key of the Secret holding the credentials of a service principal.
+func getSecretValue(secret *corev1.Secret, key string) ([]byte, error) {
+	value, ok := secret.Data[key]
+	if !ok || len(value) == 0 {
+		return nil, errors.Errorf("Secret %s/%s does not contain the %s key", secret.Namespace, secret.Name, key)
+	}
+	return value, nil
+}
+
+// isNamespaceAllowed returns whether clusters in the given namespace may use the identity. Clusters in the namespace
+// of the identity always may.
+func isNamespaceAllowed(ctx context.Context, kubeClient client.Client, identity *infrav1.AzureClusterIdentity, namespace string) (bool, error) {
+	if namespace == identity.Namespace {
+		return true, nil
+	}
+	allowed := identity.Spec.AllowedNamespaces
+	if allowed == nil {
+		return false, nil
+	}
+	if len(allowed.NamespaceList) == 0 && allowed.Selector == nil {
+		return true, nil
+	}
+	for _, ns := range allowed.NamespaceList {
+		if ns == namespace {
+			return true, nil
+		}
+	}
+	if allowed.Selector == nil {
+		return false, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(allowed.Selector)
+	if err != nil {
+		return false, errors.Wrapf(err, "invalid namespace selector of AzureClusterIdentity %s/%s", identity.Namespace, identity.Name)
+	}
+	ns := &corev1.Namespace{}
+	if err := kubeClient.Get(ctx, client.ObjectKey{Name: namespace}, ns); err != nil {
+		return false, errors.Wrapf(err, "failed to get namespace %s", namespace)
+	}
+	return selector.Matches(labels.Set(ns.Labels)), nil
+}
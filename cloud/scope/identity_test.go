@@ -0,0 +1,331 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scope
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/Azure/go-autorest/autorest/azure"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
+)
+
+func TestNewAzureCredentialsProvider(t *testing.T) {
+	tests := map[string]struct {
+		identityRef          *corev1.ObjectReference
+		namespace            string
+		allowedNamespaces    *infrav1.AllowedNamespaces
+		expectedErrorMessage string
+	}{
+		"identity in the namespace of the cluster": {
+			identityRef: &corev1.ObjectReference{Kind: AzureClusterIdentityKind, Name: "my-identity"},
+			namespace:   "identities",
+		},
+		"identity in another namespace": {
+			identityRef:          &corev1.ObjectReference{Name: "my-identity", Namespace: "identities"},
+			namespace:            "tenant-a",
+			expectedErrorMessage: "AzureClusterIdentity identities/my-identity does not allow clusters in namespace tenant-a",
+		},
+		"all namespaces allowed": {
+			identityRef:       &corev1.ObjectReference{Name: "my-identity", Namespace: "identities"},
+			namespace:         "tenant-a",
+			allowedNamespaces: &infrav1.AllowedNamespaces{},
+		},
+		"namespace listed": {
+			identityRef: &corev1.ObjectReference{Name: "my-identity", Namespace: "identities"},
+			namespace:   "tenant-a",
+			allowedNamespaces: &infrav1.AllowedNamespaces{
+				NamespaceList: []string{"tenant-b", "tenant-a"},
+			},
+		},
+		"namespace not listed": {
+			identityRef: &corev1.ObjectReference{Name: "my-identity", Namespace: "identities"},
+			namespace:   "tenant-a",
+			allowedNamespaces: &infrav1.AllowedNamespaces{
+				NamespaceList: []string{"tenant-b"},
+			},
+			expectedErrorMessage: "AzureClusterIdentity identities/my-identity does not allow clusters in namespace tenant-a",
+		},
+		"namespace selected": {
+			identityRef: &corev1.ObjectReference{Name: "my-identity", Namespace: "identities"},
+			namespace:   "tenant-a",
+			allowedNamespaces: &infrav1.AllowedNamespaces{
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"tenant": "a"}},
+			},
+		},
+		"namespace not selected": {
+			identityRef: &corev1.ObjectReference{Name: "my-identity", Namespace: "identities"},
+			namespace:   "tenant-a",
+			allowedNamespaces: &infrav1.AllowedNamespaces{
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"tenant": "b"}},
+			},
+			expectedErrorMessage: "AzureClusterIdentity identities/my-identity does not allow clusters in namespace tenant-a",
+		},
+		"identity not found": {
+			identityRef:          &corev1.ObjectReference{Name: "other-identity", Namespace: "identities"},
+			namespace:            "identities",
+			expectedErrorMessage: "failed to get AzureClusterIdentity identities/other-identity",
+		},
+		"reference to another kind": {
+			identityRef:          &corev1.ObjectReference{Kind: "Secret", Name: "my-identity"},
+			namespace:            "identities",
+			expectedErrorMessage: "identityRef must reference an AzureClusterIdentity, not a Secret",
+		},
+	}
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+			identity := &infrav1.AzureClusterIdentity{
+				ObjectMeta: metav1.ObjectMeta{Name: "my-identity", Namespace: "identities"},
+				Spec: infrav1.AzureClusterIdentitySpec{
+					Type:              infrav1.ServicePrincipal,
+					AllowedNamespaces: test.allowedNamespaces,
+				},
+			}
+			namespace := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: "tenant-a", Labels: map[string]string{"tenant": "a"}},
+			}
+			kubeClient := fake.NewFakeClientWithScheme(newIdentityScheme(g), identity, namespace)
+
+			provider, err := NewAzureCredentialsProvider(context.TODO(), kubeClient, test.identityRef, test.namespace)
+			if test.expectedErrorMessage != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(test.expectedErrorMessage))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(provider.Identity.Name).To(Equal("my-identity"))
+		})
+	}
+}
+
+// rsaCertificate is a PKCS#12 bundle of a self-signed certificate with an RSA private key, encrypted with the
+// password "my-password".
+const rsaCertificate = `
+MIIGAQIBAzCCBccGCSqGSIb3DQEHAaCCBbgEggW0MIIFsDCCAq8GCSqGSIb3DQEHBqCCAqAwggKcAgEAMIIClQYJKoZIhvcNAQcB
+MBwGCiqGSIb3DQEMAQYwDgQIZFFey07xtZMCAggAgIICaDfpkrOQ+LA36roP40BRn/tlwWB9h7svTZiPebJECwFORnvmVqajt25S
+1givjPuJWmEyKtUw7Plqqf/s4uogfjmwSGs1lRHVtQQGIsi5Gk+59rAO51Qp6drfetib67qHrv8k4p2fwWsPgWuRU/8K3S31qJMq
+j3DhgyAewtslT8PXlVZ+uZDYqf17thnkzE9FgYuYZQGDP5ch7m+Btz7xpAdy1qxmfUpKzCvU9NVvkg0ycHq0P2CeJ+hFOxhM7cQP
+I5RnSPmKDISxZAJ5h/A/j2sNC6yR4aEysiRGYZmZWeN678T5Pzw8Omzta7GckjweOP2KGdDwluLYsg2CVETEY7+JWp/f/7LAS4TD
+YSunjVXW4xmoqelU8IzZ5CjISgbzVLkrXsV8IiFbyrhhE29r7z3emBNn1tNLxnFijjJCewop5AW/e+HtAkJbyfPLv7eVHb/k5HN5
+uKPKkkfF9o+7gjqk7pk3GC4SuSGF4eghPPg/xD3vZViiaV4EEadDnVE5u9InxIX1uDmzeaP+NjujL2OrWeEWrmd0Z00AKWei7eMX
+Or++tbri1k0i8j1klRzjmUNoAWNEI1yuvG/1Sf+zTPAQN8HvnaMTnxtSGBjbO/ujePqJt96RIso1N6BoaqyGbhx8rsiQK5JdHPHN
+ZRCc2ianu1N1hnp/jdYhkolFZeXCdW7MPseYdpGUSztsuvqOdZ5NeHNIj+CoMHVmoTULdHXKb6cHSH8vQ8KqolY/HDspp5E/FIbh
+EDnpx2lMRowypQl8m0ikutWCrDCNhWEm5coBXZA0VCtFGOe5bFHG4O8HjA5H5Jdh4okwggL5BgkqhkiG9w0BBwGgggLqBIIC5jCC
+AuIwggLeBgsqhkiG9w0BDAoBAqCCAqYwggKiMBwGCiqGSIb3DQEMAQMwDgQIGBDHXNxvilICAggABIICgEZpXiJ6myiaKa6QBraT
+wClpDZg4nYx/WWX1i75dse+c8p87m0d0oMD0359U1RUT20S0E/H8P5AtaRTryngN+0jvT9Tywyj5qZ60n3p+ooitSdWo0PBlc56E
+FyPze9YdWWbxPzuVul41BIggDtVxzNpAMR138nbUL7TM/Zsuf4X11VT7kLUbO94qSzR+DAErYIVbaeENTeOUQlb9LX3w9TdczLL6
++CxFsRftoM4tZsOuVnaA2nRRneaJOpots7otloyBZqDy9g5beMxUDXzvpYeF8ruBAKyiPC1V5lHyyet4k2rJmCFm0I98ldVGRbHc
+/DSPhqypgqQTq+kbyqehMDzRlUahvNP4JKRP/6WZ5jg1eajA5+0KgoquFt+yM6P7bVIxJSqWq/jSwH18yyXSJAqWK3m6IoND5CrY
+e9Aebi1RYJ0cVFSNYnqCZOHkUEGNxkoKWw+9mlrWFFErt95lXijX068Bc389UGgJZ2aPsGqlg54DNs/lQTSqkixcVH49SuMJ8SEO
+zuklnZrCLXglEndnFxbPZLo1XsyoQ9YVdQ2ojz/93nxMD44RnBEat92zezJBKMVDvvs/uJZfn5KR0CAPeJpYlSoZjTeR65voln+V
+8CbJlW1dqWJ9HnOY9jsL+M5MoA5COPAljsBAB/f1uEa5TJzbecloVHBYuC7QqLPu736wVdnT+IYaRm2MOlHERrx2sgLgpefyvMWp
+VQe7tljIEVSmasu7IX0zN9/QaP/iUDxE2MfQ5VCcezX5Q43fwe82tpCLM391VbOZG2BykZrXogkx2lfQtPpKnAhZ2SFw/vfE4Y+m
+tj3KEmDAbqfd5cM7cF2hoirMobVzKNd2DyQxJTAjBgkqhkiG9w0BCRUxFgQUBGK00IzzE90BxbzhFv9QrP10thgwMTAhMAkGBSsO
+AwIaBQAEFDvuNh8WUnddCkf4KiB0bAI0PpkoBAjYkBDHQgwaBAICCAA=
+`
+
+// ecCertificate is a PKCS#12 bundle of a self-signed certificate with an ECDSA private key, encrypted with the
+// password "my-password".
+const ecCertificate = `
+MIIDggIBAzCCA0gGCSqGSIb3DQEHAaCCAzkEggM1MIIDMTCCAicGCSqGSIb3DQEHBqCCAhgwggIUAgEAMIICDQYJKoZIhvcNAQcB
+MBwGCiqGSIb3DQEMAQYwDgQIZLVUJ862FIECAggAgIIB4CfYT8zeXgSGiwhfXJCNTioo7b+UxftTKY818aXZ40ggoB9padBXlBCA
+IMBR6CE6O5b1odeZLHP7Rpfoe7sszS0/B2QMLfafwqPc7MwqnfVFnoWJo+3J8komhUdQV3F/6cLbCfbV3KE97eMj+BO8w64cdf7d
+qg7Haolp7ZsVGrCNXlox1kEy2L3RNpq3cSMH90IIC2FomyHVhjvyKi7S/a47z9ZfKc2n9oGefOYjeSeL2tYNeV+2g93usB3RwzxF
+YvdBYOi+52Ijm7cfhe+OGkBM9RdUVoCuOH1q0sfkE8uOgyM9KhWm5Cq+5zFPho12dwSfkMtHW0dn5z5A7rxL28yE60D5EJTgNl37
+PNV7gAzQ91UlNGjxkiiBerPQgB64PuqMZ7qUSwtnYJ3FBo+GEAvw9AgJvJrw4ze0KkxxR4twV/BgKz9lEB9zIqiasUrPtCShKYKn
+k1o+F2JFb8++O0WHC9BgF57bFTKUW8uHkt9Kt9hImVL33sJoVK0sDwofkGuuZmLOZpEuZCIhC7jxgtBCMKWZsn36IbwcJkIBoTlf
+3mv2pnaLDph0ENXHrPS5WBUH8c8166E7Bx30h8C7g+BMgE+tKhgLSZMa0K1frdu2KBn0Ty6YsuTa0ggr3WAgVTCCAQIGCSqGSIb3
+DQEHAaCB9ASB8TCB7jCB6wYLKoZIhvcNAQwKAQKggbQwgbEwHAYKKoZIhvcNAQwBAzAOBAhZP/CGksdOWwICCAAEgZDF2u2ocXaW
+cLjKRvIeqlXvBOXub3eOpRRSPCBICoISBjm0TBnwGtAwjrXCxVblys0pDFacNQjl+wOrxYuhg/N6HJW/G8iuac2uyT7auTOiHsoB
+NgyBXCnTylfyZvJVWKgROh/E2TAGLKGmkGRtyfDICY8mXaPnbTWUjBB9Qtcz0Xd3KxKqg12wGaEezvgEpYUxJTAjBgkqhkiG9w0B
+CRUxFgQURAoDocNvjyr2OSTZfuMsFfzV3oMwMTAhMAkGBSsOAwIaBQAEFPH9pGENpgBor16cQmXc28EEoAnlBAgxUTqIAeCohAIC
+CAA=
+`
+
+func TestAzureCredentialsProviderGetAuthorizer(t *testing.T) {
+	g := NewWithT(t)
+	rsaPFX, err := base64.StdEncoding.DecodeString(rsaCertificate)
+	g.Expect(err).NotTo(HaveOccurred())
+	ecPFX, err := base64.StdEncoding.DecodeString(ecCertificate)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	servicePrincipal := infrav1.AzureClusterIdentitySpec{
+		Type:      infrav1.ServicePrincipal,
+		ClientID:  "my-client-id",
+		TenantID:  "my-tenant-id",
+		SecretRef: &corev1.SecretReference{Name: "my-credentials"},
+	}
+	servicePrincipalCertificate := infrav1.AzureClusterIdentitySpec{
+		Type:      infrav1.ServicePrincipalCertificate,
+		ClientID:  "my-client-id",
+		TenantID:  "my-tenant-id",
+		SecretRef: &corev1.SecretReference{Name: "my-credentials"},
+	}
+
+	tests := map[string]struct {
+		spec                    infrav1.AzureClusterIdentitySpec
+		secretData              map[string][]byte
+		activeDirectoryEndpoint string
+		expectedErrorMessage    string
+	}{
+		"service principal with client secret": {
+			spec:       servicePrincipal,
+			secretData: map[string][]byte{infrav1.ClientSecretKey: []byte("my-client-secret")},
+		},
+		"service principal without secret": {
+			spec: infrav1.AzureClusterIdentitySpec{
+				Type:     infrav1.ServicePrincipal,
+				ClientID: "my-client-id",
+				TenantID: "my-tenant-id",
+			},
+			expectedErrorMessage: "AzureClusterIdentity identities/my-identity of type ServicePrincipal requires a secretRef",
+		},
+		"secret not found": {
+			spec: infrav1.AzureClusterIdentitySpec{
+				Type:      infrav1.ServicePrincipal,
+				ClientID:  "my-client-id",
+				TenantID:  "my-tenant-id",
+				SecretRef: &corev1.SecretReference{Name: "other-credentials", Namespace: "secrets"},
+			},
+			expectedErrorMessage: "failed to get Secret secrets/other-credentials of AzureClusterIdentity identities/my-identity",
+		},
+		"service principal without client secret key": {
+			spec:                 servicePrincipal,
+			secretData:           map[string][]byte{infrav1.CertificateKey: rsaPFX},
+			expectedErrorMessage: "Secret identities/my-credentials does not contain the clientSecret key",
+		},
+		"service principal with empty client secret": {
+			spec:                 servicePrincipal,
+			secretData:           map[string][]byte{infrav1.ClientSecretKey: {}},
+			expectedErrorMessage: "Secret identities/my-credentials does not contain the clientSecret key",
+		},
+		"service principal with client certificate": {
+			spec: servicePrincipalCertificate,
+			secretData: map[string][]byte{
+				infrav1.CertificateKey:         rsaPFX,
+				infrav1.CertificatePasswordKey: []byte("my-password"),
+			},
+		},
+		"service principal without certificate key": {
+			spec: servicePrincipalCertificate,
+			secretData: map[string][]byte{
+				infrav1.ClientSecretKey:        []byte("my-client-secret"),
+				infrav1.CertificatePasswordKey: []byte("my-password"),
+			},
+			expectedErrorMessage: "Secret identities/my-credentials does not contain the certificate key",
+		},
+		"invalid client certificate": {
+			spec:                 servicePrincipalCertificate,
+			secretData:           map[string][]byte{infrav1.CertificateKey: []byte("not a certificate")},
+			expectedErrorMessage: "failed to decode the client certificate",
+		},
+		"client certificate with a wrong password": {
+			spec: servicePrincipalCertificate,
+			secretData: map[string][]byte{
+				infrav1.CertificateKey:         rsaPFX,
+				infrav1.CertificatePasswordKey: []byte("other-password"),
+			},
+			expectedErrorMessage: "failed to decode the client certificate: pkcs12: decryption password incorrect",
+		},
+		"client certificate without password": {
+			spec:                 servicePrincipalCertificate,
+			secretData:           map[string][]byte{infrav1.CertificateKey: rsaPFX},
+			expectedErrorMessage: "failed to decode the client certificate: pkcs12: decryption password incorrect",
+		},
+		"client certificate with an ECDSA private key": {
+			spec: servicePrincipalCertificate,
+			secretData: map[string][]byte{
+				infrav1.CertificateKey:         ecPFX,
+				infrav1.CertificatePasswordKey: []byte("my-password"),
+			},
+			expectedErrorMessage: "the client certificate must contain an RSA private key",
+		},
+		"client certificate with an invalid Active Directory endpoint": {
+			spec: servicePrincipalCertificate,
+			secretData: map[string][]byte{
+				infrav1.CertificateKey:         rsaPFX,
+				infrav1.CertificatePasswordKey: []byte("my-password"),
+			},
+			activeDirectoryEndpoint: "://login.microsoftonline.com/",
+			expectedErrorMessage:    "failed to get the OAuth configuration of tenant my-tenant-id",
+		},
+		"system-assigned managed identity": {
+			spec: infrav1.AzureClusterIdentitySpec{
+				Type: infrav1.ManagedIdentity,
+			},
+		},
+		"user-assigned managed identity": {
+			spec: infrav1.AzureClusterIdentitySpec{
+				Type:     infrav1.ManagedIdentity,
+				ClientID: "my-client-id",
+			},
+		},
+		"unsupported type": {
+			spec: infrav1.AzureClusterIdentitySpec{
+				Type: "WorkloadIdentity",
+			},
+			expectedErrorMessage: "unsupported identity type \"WorkloadIdentity\"",
+		},
+	}
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "my-credentials", Namespace: "identities"},
+				Data:       test.secretData,
+			}
+			provider := &AzureCredentialsProvider{
+				Client: fake.NewFakeClientWithScheme(newIdentityScheme(g), secret),
+				Identity: &infrav1.AzureClusterIdentity{
+					ObjectMeta: metav1.ObjectMeta{Name: "my-identity", Namespace: "identities"},
+					Spec:       test.spec,
+				},
+			}
+
+			env := azure.PublicCloud
+			if test.activeDirectoryEndpoint != "" {
+				env.ActiveDirectoryEndpoint = test.activeDirectoryEndpoint
+			}
+			authorizer, err := provider.GetAuthorizer(context.TODO(), env)
+			if test.expectedErrorMessage != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err.Error()).To(ContainSubstring(test.expectedErrorMessage))
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(authorizer).NotTo(BeNil())
+		})
+	}
+}
+
+func newIdentityScheme(g *WithT) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	g.Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	return scheme
+}
@@ -204,6 +204,16 @@ func (m *MachineScope) SetVMState(v infrav1.VMState) {
 	m.AzureMachine.Status.VMState = &v
 }
 
+// SetVMExtensions sets the AzureMachine VM extensions status.
+func (m *MachineScope) SetVMExtensions(v []infrav1.VMExtensionStatus) {
+	m.AzureMachine.Status.VMExtensions = v
+}
+
+// SetRoleAssignmentIDs sets the IDs of the role assignments of the AzureMachine VM system-assigned identity.
+func (m *MachineScope) SetRoleAssignmentIDs(v []string) {
+	m.AzureMachine.Status.RoleAssignmentIDs = v
+}
+
 // SetReady sets the AzureMachine Ready Status to true.
 func (m *MachineScope) SetReady() {
 	m.AzureMachine.Status.Ready = true
@@ -277,3 +287,26 @@ func (m *MachineScope) GetBootstrapData(ctx context.Context) (string, error) {
 	}
 	return base64.StdEncoding.EncodeToString(value), nil
 }
+
+// GetVMExtensionProtectedSettings returns the protected settings of a VM extension from the secret
+// referenced by the extension's protectedSettingsSecretName.
+func (m *MachineScope) GetVMExtensionProtectedSettings(ctx context.Context, extension infrav1.VMExtension) (map[string]string, error) {
+	return getVMExtensionProtectedSettings(ctx, m.client, m.Namespace(), extension)
+}
+
+func getVMExtensionProtectedSettings(ctx context.Context, c client.Client, namespace string, extension infrav1.VMExtension) (map[string]string, error) {
+	if extension.ProtectedSettingsSecretName == nil {
+		return nil, nil
+	}
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{Namespace: namespace, Name: *extension.ProtectedSettingsSecretName}
+	if err := c.Get(ctx, key, secret); err != nil {
+		return nil, errors.Wrapf(err, "failed to retrieve protected settings secret for VM extension %s", extension.Name)
+	}
+
+	settings := make(map[string]string, len(secret.Data))
+	for k, v := range secret.Data {
+		settings[k] = string(v)
+	}
+	return settings, nil
+}
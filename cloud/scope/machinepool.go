@@ -210,3 +210,9 @@ func (m *MachinePoolScope) GetBootstrapData(ctx context.Context) (string, error)
 	}
 	return base64.StdEncoding.EncodeToString(value), nil
 }
+
+// GetVMExtensionProtectedSettings returns the protected settings of a VM extension from the secret
+// referenced by the extension's protectedSettingsSecretName.
+func (m *MachinePoolScope) GetVMExtensionProtectedSettings(ctx context.Context, extension infrav1.VMExtension) (map[string]string, error) {
+	return getVMExtensionProtectedSettings(ctx, m.client, m.AzureMachinePool.Namespace, extension)
+}
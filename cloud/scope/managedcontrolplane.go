@@ -21,8 +21,11 @@ import (
 	"github.com/Azure/go-autorest/autorest"
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog/klogr"
+	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
 	infrav1exp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1alpha3"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	expv1 "sigs.k8s.io/cluster-api/exp/api/v1alpha3"
@@ -38,9 +41,11 @@ type ManagedControlPlaneScopeParams struct {
 	Logger           logr.Logger
 	Cluster          *clusterv1.Cluster
 	ControlPlane     *infrav1exp.AzureManagedControlPlane
+	InfraCluster     *infrav1exp.AzureManagedCluster
 	InfraMachinePool *infrav1exp.AzureManagedMachinePool
 	MachinePool      *expv1.MachinePool
 	PatchTarget      runtime.Object
+	Context          context.Context
 }
 
 // NewManagedControlPlaneScope creates a new Scope from the supplied parameters.
@@ -58,7 +63,11 @@ func NewManagedControlPlaneScope(params ManagedControlPlaneScopeParams) (*Manage
 		params.Logger = klogr.New()
 	}
 
-	if err := params.AzureClients.setCredentials(params.ControlPlane.Spec.SubscriptionID); err != nil {
+	if params.Context == nil {
+		params.Context = context.TODO()
+	}
+
+	if err := params.AzureClients.setCredentialsForIdentity(params.Context, params.Client, params.ControlPlane.Spec.SubscriptionID, params.ControlPlane.Spec.IdentityRef, params.ControlPlane.Namespace); err != nil {
 		return nil, errors.Wrap(err, "failed to create Azure session")
 	}
 
@@ -73,6 +82,7 @@ func NewManagedControlPlaneScope(params ManagedControlPlaneScopeParams) (*Manage
 		AzureClients:     params.AzureClients,
 		Cluster:          params.Cluster,
 		ControlPlane:     params.ControlPlane,
+		InfraCluster:     params.InfraCluster,
 		MachinePool:      params.MachinePool,
 		InfraMachinePool: params.InfraMachinePool,
 		PatchTarget:      params.PatchTarget,
@@ -90,8 +100,12 @@ type ManagedControlPlaneScope struct {
 	Cluster          *clusterv1.Cluster
 	MachinePool      *expv1.MachinePool
 	ControlPlane     *infrav1exp.AzureManagedControlPlane
+	InfraCluster     *infrav1exp.AzureManagedCluster
 	InfraMachinePool *infrav1exp.AzureManagedMachinePool
 	PatchTarget      runtime.Object
+
+	vnet       *infrav1.VnetSpec
+	nodeSubnet *infrav1.SubnetSpec
 }
 
 // SubscriptionID returns the Azure client Subscription ID.
@@ -109,6 +123,93 @@ func (s *ManagedControlPlaneScope) Authorizer() autorest.Authorizer {
 	return s.AzureClients.Authorizer
 }
 
+// ResourceGroup returns the resource group of the AKS cluster.
+func (s *ManagedControlPlaneScope) ResourceGroup() string {
+	return s.ControlPlane.Spec.ResourceGroup
+}
+
+// ClusterName returns the cluster name.
+func (s *ManagedControlPlaneScope) ClusterName() string {
+	return s.Cluster.Name
+}
+
+// Location returns the location of the AKS cluster.
+func (s *ManagedControlPlaneScope) Location() string {
+	return s.ControlPlane.Spec.Location
+}
+
+// AdditionalTags returns AdditionalTags from the ControlPlane spec.
+func (s *ManagedControlPlaneScope) AdditionalTags() infrav1.Tags {
+	tags := make(infrav1.Tags)
+	for k, v := range s.ControlPlane.Spec.AdditionalTags {
+		tags[k] = v
+	}
+	return tags
+}
+
+// Vnet returns the virtual network of the AKS cluster, or nil if AKS manages the virtual network.
+func (s *ManagedControlPlaneScope) Vnet() *infrav1.VnetSpec {
+	s.initNetwork()
+	return s.vnet
+}
+
+// ControlPlaneSubnet returns nil, as the control plane of an AKS cluster isn't placed in a subnet of the cluster.
+func (s *ManagedControlPlaneScope) ControlPlaneSubnet() *infrav1.SubnetSpec {
+	return nil
+}
+
+// NodeSubnet returns the subnet of the virtual network of the AKS cluster, or nil if AKS manages the virtual network.
+func (s *ManagedControlPlaneScope) NodeSubnet() *infrav1.SubnetSpec {
+	s.initNetwork()
+	return s.nodeSubnet
+}
+
+// initNetwork builds the virtual network and subnet specs from the ControlPlane spec. The specs are kept for the
+// lifetime of the scope, so that the services can record the state of existing resources in them.
+func (s *ManagedControlPlaneScope) initNetwork() {
+	vnet := s.ControlPlane.Spec.VirtualNetwork
+	if s.vnet != nil || vnet == nil {
+		return
+	}
+
+	s.vnet = &infrav1.VnetSpec{
+		ResourceGroup: vnet.ResourceGroup,
+		Name:          vnet.Name,
+		CidrBlock:     vnet.CIDRBlock,
+	}
+	if s.vnet.ResourceGroup == "" {
+		s.vnet.ResourceGroup = s.ControlPlane.Spec.ResourceGroup
+	}
+	s.nodeSubnet = &infrav1.SubnetSpec{
+		Role:      infrav1.SubnetNode,
+		Name:      vnet.Subnet.Name,
+		CidrBlock: vnet.Subnet.CIDRBlock,
+	}
+}
+
+// GetWindowsAdminCredentials returns the username and password of the administrator account of the Windows nodes
+// from the secret referenced by the control plane's windowsProfile.
+func (s *ManagedControlPlaneScope) GetWindowsAdminCredentials(ctx context.Context) (string, string, error) {
+	if s.ControlPlane.Spec.WindowsProfile == nil {
+		return "", "", nil
+	}
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{Namespace: s.ControlPlane.Namespace, Name: s.ControlPlane.Spec.WindowsProfile.AdminCredentialsSecretName}
+	if err := s.Client.Get(ctx, key, secret); err != nil {
+		return "", "", errors.Wrapf(err, "failed to retrieve Windows admin credentials secret")
+	}
+
+	username, ok := secret.Data["username"]
+	if !ok {
+		return "", "", errors.New("error retrieving Windows admin credentials: secret username key is missing")
+	}
+	password, ok := secret.Data["password"]
+	if !ok {
+		return "", "", errors.New("error retrieving Windows admin credentials: secret password key is missing")
+	}
+	return string(username), string(password), nil
+}
+
 // PatchObject persists the cluster configuration and status.
 func (s *ManagedControlPlaneScope) PatchObject(ctx context.Context) error {
 	return s.patchHelper.Patch(ctx, s.PatchTarget)
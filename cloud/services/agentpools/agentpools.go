@@ -19,7 +19,8 @@ package agentpools
 import (
 	"context"
 
-	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2020-02-01/containerservice"
+	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2021-07-01/containerservice"
+	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/google/go-cmp/cmp"
 	"github.com/pkg/errors"
 	"k8s.io/klog"
@@ -35,6 +36,56 @@ type Spec struct {
 	SKU           string
 	Replicas      int32
 	OSDiskSizeGB  int32
+	VnetSubnetID  string
+
+	OSType            string
+	Mode              string
+	EnableAutoScaling bool
+	MinCount          *int32
+	MaxCount          *int32
+	NodeLabels        map[string]string
+	NodeTaints        []string
+	MaxPods           *int32
+	AvailabilityZones []string
+	MaxSurge          *string
+}
+
+// Get fetches an agent pool from Azure.
+func (s *Service) Get(ctx context.Context, spec interface{}) (interface{}, error) {
+	agentPoolSpec, ok := spec.(*Spec)
+	if !ok {
+		return nil, errors.New("invalid agent pool specification")
+	}
+	return s.Client.Get(ctx, agentPoolSpec.ResourceGroup, agentPoolSpec.Cluster, agentPoolSpec.Name)
+}
+
+// GetLatestNodeImageVersion returns the latest node image version AKS offers for an agent pool.
+func (s *Service) GetLatestNodeImageVersion(ctx context.Context, spec interface{}) (string, error) {
+	agentPoolSpec, ok := spec.(*Spec)
+	if !ok {
+		return "", errors.New("invalid agent pool specification")
+	}
+	profile, err := s.Client.GetUpgradeProfile(ctx, agentPoolSpec.ResourceGroup, agentPoolSpec.Cluster, agentPoolSpec.Name)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get upgrade profile for agent pool %s", agentPoolSpec.Name)
+	}
+	if profile.AgentPoolUpgradeProfileProperties == nil {
+		return "", nil
+	}
+	return to.String(profile.LatestNodeImageVersion), nil
+}
+
+// UpgradeNodeImageVersion starts upgrading the nodes of an agent pool to the latest node image version.
+func (s *Service) UpgradeNodeImageVersion(ctx context.Context, spec interface{}) error {
+	agentPoolSpec, ok := spec.(*Spec)
+	if !ok {
+		return errors.New("invalid agent pool specification")
+	}
+	klog.V(2).Infof("upgrading node image of agent pool %s", agentPoolSpec.Name)
+	if err := s.Client.UpgradeNodeImageVersion(ctx, agentPoolSpec.ResourceGroup, agentPoolSpec.Cluster, agentPoolSpec.Name); err != nil {
+		return errors.Wrapf(err, "failed to upgrade node image of agent pool %s", agentPoolSpec.Name)
+	}
+	return nil
 }
 
 // Reconcile idempotently creates or updates a agent pool, if possible.
@@ -44,15 +95,60 @@ func (s *Service) Reconcile(ctx context.Context, spec interface{}) error {
 		return errors.New("invalid agent pool specification")
 	}
 
+	if err := validateAutoScaling(agentPoolSpec); err != nil {
+		return err
+	}
+	if err := validateOSType(agentPoolSpec); err != nil {
+		return err
+	}
+
+	count := agentPoolSpec.Replicas
+	if agentPoolSpec.EnableAutoScaling {
+		// Start the autoscaler within its bounds, it owns the node count from then on.
+		if count < *agentPoolSpec.MinCount {
+			count = *agentPoolSpec.MinCount
+		}
+		if count > *agentPoolSpec.MaxCount {
+			count = *agentPoolSpec.MaxCount
+		}
+	}
+
 	profile := containerservice.AgentPool{
 		ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
-			VMSize:              containerservice.VMSizeTypes(agentPoolSpec.SKU),
+			VMSize:              to.StringPtr(agentPoolSpec.SKU),
 			OsDiskSizeGB:        &agentPoolSpec.OSDiskSizeGB,
-			Count:               &agentPoolSpec.Replicas,
-			Type:                containerservice.VirtualMachineScaleSets,
+			Count:               &count,
+			Type:                containerservice.AgentPoolTypeVirtualMachineScaleSets,
 			OrchestratorVersion: agentPoolSpec.Version,
+			OsType:              containerservice.OSType(agentPoolSpec.OSType),
+			Mode:                containerservice.AgentPoolMode(agentPoolSpec.Mode),
+			EnableAutoScaling:   to.BoolPtr(agentPoolSpec.EnableAutoScaling),
+			MaxPods:             agentPoolSpec.MaxPods,
 		},
 	}
+	if agentPoolSpec.VnetSubnetID != "" {
+		profile.VnetSubnetID = &agentPoolSpec.VnetSubnetID
+	}
+	if agentPoolSpec.EnableAutoScaling {
+		profile.MinCount = agentPoolSpec.MinCount
+		profile.MaxCount = agentPoolSpec.MaxCount
+	}
+	if len(agentPoolSpec.NodeLabels) > 0 {
+		profile.NodeLabels = *to.StringMapPtr(agentPoolSpec.NodeLabels)
+	}
+	if len(agentPoolSpec.NodeTaints) > 0 {
+		nodeTaints := append([]string{}, agentPoolSpec.NodeTaints...)
+		profile.NodeTaints = &nodeTaints
+	}
+	if len(agentPoolSpec.AvailabilityZones) > 0 {
+		availabilityZones := append([]string{}, agentPoolSpec.AvailabilityZones...)
+		profile.AvailabilityZones = &availabilityZones
+	}
+	if agentPoolSpec.MaxSurge != nil {
+		profile.UpgradeSettings = &containerservice.AgentPoolUpgradeSettings{
+			MaxSurge: agentPoolSpec.MaxSurge,
+		}
+	}
 
 	existingPool, err := s.Client.Get(ctx, agentPoolSpec.ResourceGroup, agentPoolSpec.Cluster, agentPoolSpec.Name)
 	if err != nil && !azure.ResourceNotFound(err) {
@@ -77,15 +173,55 @@ func (s *Service) Reconcile(ctx context.Context, spec interface{}) error {
 		}
 
 		// Normalize individual agent pools to diff in case we need to update
+		existing := existingPool.ManagedClusterAgentPoolProfileProperties
 		existingProfile := containerservice.AgentPool{
 			ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
-				VMSize:              existingPool.ManagedClusterAgentPoolProfileProperties.VMSize,
-				OsDiskSizeGB:        existingPool.ManagedClusterAgentPoolProfileProperties.OsDiskSizeGB,
-				Count:               existingPool.ManagedClusterAgentPoolProfileProperties.Count,
-				Type:                containerservice.VirtualMachineScaleSets,
-				OrchestratorVersion: existingPool.ManagedClusterAgentPoolProfileProperties.OrchestratorVersion,
+				VMSize:              existing.VMSize,
+				OsDiskSizeGB:        existing.OsDiskSizeGB,
+				Count:               existing.Count,
+				Type:                containerservice.AgentPoolTypeVirtualMachineScaleSets,
+				OrchestratorVersion: existing.OrchestratorVersion,
+				VnetSubnetID:        existing.VnetSubnetID,
+				OsType:              existing.OsType,
+				Mode:                existing.Mode,
+				EnableAutoScaling:   to.BoolPtr(to.Bool(existing.EnableAutoScaling)),
+				MaxPods:             existing.MaxPods,
 			},
 		}
+		if to.Bool(existing.EnableAutoScaling) {
+			existingProfile.MinCount = existing.MinCount
+			existingProfile.MaxCount = existing.MaxCount
+		}
+		if len(existing.NodeLabels) > 0 {
+			existingProfile.NodeLabels = existing.NodeLabels
+		}
+		if existing.NodeTaints != nil && len(*existing.NodeTaints) > 0 {
+			existingProfile.NodeTaints = existing.NodeTaints
+		}
+		if existing.AvailabilityZones != nil && len(*existing.AvailabilityZones) > 0 {
+			existingProfile.AvailabilityZones = existing.AvailabilityZones
+		}
+		if existing.UpgradeSettings != nil && existing.UpgradeSettings.MaxSurge != nil {
+			existingProfile.UpgradeSettings = existing.UpgradeSettings
+		}
+
+		// AKS defaults the OS type, mode, max pods and max surge of a pool, keep them unless they are set explicitly.
+		if profile.OsType == "" {
+			profile.OsType = existing.OsType
+		}
+		if profile.Mode == "" {
+			profile.Mode = existing.Mode
+		}
+		if profile.MaxPods == nil {
+			profile.MaxPods = existing.MaxPods
+		}
+		if profile.UpgradeSettings == nil {
+			profile.UpgradeSettings = existingProfile.UpgradeSettings
+		}
+		// The autoscaler owns the node count of the pool, don't fight it with the replicas of the machine pool.
+		if agentPoolSpec.EnableAutoScaling && to.Bool(existing.EnableAutoScaling) {
+			profile.Count = existing.Count
+		}
 
 		// Diff and check if we require an update
 		diff := cmp.Diff(profile, existingProfile)
@@ -103,6 +239,34 @@ func (s *Service) Reconcile(ctx context.Context, spec interface{}) error {
 	return nil
 }
 
+// validateAutoScaling validates the autoscaler bounds of an agent pool.
+func validateAutoScaling(agentPoolSpec *Spec) error {
+	if !agentPoolSpec.EnableAutoScaling {
+		return nil
+	}
+	if agentPoolSpec.MinCount == nil || agentPoolSpec.MaxCount == nil {
+		return errors.Errorf("agent pool %s must set minCount and maxCount to enable autoscaling", agentPoolSpec.Name)
+	}
+	if *agentPoolSpec.MinCount > *agentPoolSpec.MaxCount {
+		return errors.Errorf("minCount %d of agent pool %s must not be greater than maxCount %d", *agentPoolSpec.MinCount, agentPoolSpec.Name, *agentPoolSpec.MaxCount)
+	}
+	return nil
+}
+
+// validateOSType validates the AKS restrictions of Windows agent pools.
+func validateOSType(agentPoolSpec *Spec) error {
+	if agentPoolSpec.OSType != string(containerservice.OSTypeWindows) {
+		return nil
+	}
+	if agentPoolSpec.Mode == string(containerservice.AgentPoolModeSystem) {
+		return errors.Errorf("Windows agent pool %s cannot be a system pool", agentPoolSpec.Name)
+	}
+	if len(agentPoolSpec.Name) > 6 {
+		return errors.Errorf("name of Windows agent pool %s must not be longer than 6 characters", agentPoolSpec.Name)
+	}
+	return nil
+}
+
 // Delete deletes the virtual network with the provided name.
 func (s *Service) Delete(ctx context.Context, spec interface{}) error {
 	agentPoolSpec, ok := spec.(*Spec)
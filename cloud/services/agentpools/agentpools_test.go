@@ -21,7 +21,7 @@ import (
 	"net/http"
 	"testing"
 
-	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2020-02-01/containerservice"
+	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2021-07-01/containerservice"
 	network "github.com/Azure/azure-sdk-for-go/services/network/mgmt/2019-06-01/network"
 	"github.com/Azure/go-autorest/autorest"
 	"github.com/Azure/go-autorest/autorest/to"
@@ -210,7 +210,7 @@ func TestReconcile(t *testing.T) {
 					ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
 						Count:               to.Int32Ptr(3),
 						OsDiskSizeGB:        to.Int32Ptr(20),
-						VMSize:              containerservice.VMSizeTypesStandardA1,
+						VMSize:              to.StringPtr(string(containerservice.VMSizeTypesStandardA1)),
 						OrchestratorVersion: to.StringPtr("9.99.9999"),
 						ProvisioningState:   to.StringPtr("Failed"),
 					},
@@ -235,13 +235,129 @@ func TestReconcile(t *testing.T) {
 					ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
 						Count:               to.Int32Ptr(2),
 						OsDiskSizeGB:        to.Int32Ptr(100),
-						VMSize:              containerservice.VMSizeTypesStandardA1,
+						VMSize:              to.StringPtr(string(containerservice.VMSizeTypesStandardA1)),
 						OrchestratorVersion: to.StringPtr("9.99.9999"),
 						ProvisioningState:   to.StringPtr("Succeeded"),
 					},
 				}, nil)
 			},
 		},
+		{
+			name: "autoscaler keeps the node count of the Agent Pool",
+			agentPoolsSpec: Spec{
+				Name:              "my-agent-pool",
+				ResourceGroup:     "my-rg",
+				Cluster:           "my-cluster",
+				SKU:               "Standard_A1",
+				Version:           to.StringPtr("9.99.9999"),
+				Replicas:          2,
+				OSDiskSizeGB:      100,
+				EnableAutoScaling: true,
+				MinCount:          to.Int32Ptr(1),
+				MaxCount:          to.Int32Ptr(5),
+			},
+			expectedError: "",
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.Get(context.TODO(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{
+					ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
+						Count:               to.Int32Ptr(4),
+						OsDiskSizeGB:        to.Int32Ptr(100),
+						VMSize:              to.StringPtr(string(containerservice.VMSizeTypesStandardA1)),
+						OrchestratorVersion: to.StringPtr("9.99.9999"),
+						Mode:                containerservice.AgentPoolModeUser,
+						EnableAutoScaling:   to.BoolPtr(true),
+						MinCount:            to.Int32Ptr(1),
+						MaxCount:            to.Int32Ptr(5),
+						MaxPods:             to.Int32Ptr(110),
+						ProvisioningState:   to.StringPtr("Succeeded"),
+					},
+				}, nil)
+			},
+		},
+		{
+			name: "can update the node labels and taints of an Agent Pool",
+			agentPoolsSpec: Spec{
+				Name:          "my-agent-pool",
+				ResourceGroup: "my-rg",
+				Cluster:       "my-cluster",
+				SKU:           "Standard_A1",
+				Version:       to.StringPtr("9.99.9999"),
+				Replicas:      2,
+				OSDiskSizeGB:  100,
+				NodeLabels:    map[string]string{"role": "ingress"},
+				NodeTaints:    []string{"dedicated=ingress:NoSchedule"},
+			},
+			expectedError: "",
+			expect: func(m *mock_agentpools.MockClientMockRecorder) {
+				m.Get(context.TODO(), "my-rg", "my-cluster", "my-agent-pool").Return(containerservice.AgentPool{
+					ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
+						Count:               to.Int32Ptr(2),
+						OsDiskSizeGB:        to.Int32Ptr(100),
+						VMSize:              to.StringPtr(string(containerservice.VMSizeTypesStandardA1)),
+						OrchestratorVersion: to.StringPtr("9.99.9999"),
+						Mode:                containerservice.AgentPoolModeUser,
+						MaxPods:             to.Int32Ptr(110),
+						NodeLabels:          map[string]*string{"role": to.StringPtr("web")},
+						ProvisioningState:   to.StringPtr("Succeeded"),
+					},
+				}, nil)
+				m.CreateOrUpdate(context.TODO(), "my-rg", "my-cluster", "my-agent-pool", containerservice.AgentPool{
+					ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
+						Count:               to.Int32Ptr(2),
+						OsDiskSizeGB:        to.Int32Ptr(100),
+						VMSize:              to.StringPtr(string(containerservice.VMSizeTypesStandardA1)),
+						Type:                containerservice.AgentPoolTypeVirtualMachineScaleSets,
+						OrchestratorVersion: to.StringPtr("9.99.9999"),
+						Mode:                containerservice.AgentPoolModeUser,
+						EnableAutoScaling:   to.BoolPtr(false),
+						MaxPods:             to.Int32Ptr(110),
+						NodeLabels:          map[string]*string{"role": to.StringPtr("ingress")},
+						NodeTaints:          &[]string{"dedicated=ingress:NoSchedule"},
+					},
+				}).Return(nil)
+			},
+		},
+		{
+			name: "fail to enable autoscaling without bounds",
+			agentPoolsSpec: Spec{
+				Name:              "my-agent-pool",
+				ResourceGroup:     "my-rg",
+				Cluster:           "my-cluster",
+				SKU:               "Standard_A1",
+				Replicas:          2,
+				EnableAutoScaling: true,
+				MaxCount:          to.Int32Ptr(5),
+			},
+			expectedError: "agent pool my-agent-pool must set minCount and maxCount to enable autoscaling",
+			expect:        func(m *mock_agentpools.MockClientMockRecorder) {},
+		},
+		{
+			name: "fail to create a Windows system pool",
+			agentPoolsSpec: Spec{
+				Name:          "win",
+				ResourceGroup: "my-rg",
+				Cluster:       "my-cluster",
+				SKU:           "Standard_D2s_v3",
+				Replicas:      2,
+				OSType:        "Windows",
+				Mode:          "System",
+			},
+			expectedError: "Windows agent pool win cannot be a system pool",
+			expect:        func(m *mock_agentpools.MockClientMockRecorder) {},
+		},
+		{
+			name: "fail to create a Windows pool with a long name",
+			agentPoolsSpec: Spec{
+				Name:          "windows",
+				ResourceGroup: "my-rg",
+				Cluster:       "my-cluster",
+				SKU:           "Standard_D2s_v3",
+				Replicas:      2,
+				OSType:        "Windows",
+			},
+			expectedError: "name of Windows agent pool windows must not be longer than 6 characters",
+			expect:        func(m *mock_agentpools.MockClientMockRecorder) {},
+		},
 	}
 
 	for _, tc := range testcases {
@@ -270,6 +386,74 @@ func TestReconcile(t *testing.T) {
 	}
 }
 
+func TestReconcileMaxSurge(t *testing.T) {
+	testcases := []struct {
+		name             string
+		maxSurge         *string
+		existing         *containerservice.AgentPoolUpgradeSettings
+		expectUpdate     bool
+		expectedSettings *containerservice.AgentPoolUpgradeSettings
+	}{
+		{
+			name:             "max surge is set",
+			maxSurge:         to.StringPtr("33%"),
+			expectUpdate:     true,
+			expectedSettings: &containerservice.AgentPoolUpgradeSettings{MaxSurge: to.StringPtr("33%")},
+		},
+		{
+			name:     "max surge is unchanged",
+			maxSurge: to.StringPtr("33%"),
+			existing: &containerservice.AgentPoolUpgradeSettings{MaxSurge: to.StringPtr("33%")},
+		},
+		{
+			name:     "existing max surge is kept",
+			existing: &containerservice.AgentPoolUpgradeSettings{MaxSurge: to.StringPtr("2")},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			agentpoolsMock := mock_agentpools.NewMockClient(mockCtrl)
+
+			agentpoolsMock.EXPECT().Get(context.TODO(), "my-rg", "my-cluster", "my-agentpool").Return(containerservice.AgentPool{
+				ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
+					ProvisioningState: to.StringPtr("Succeeded"),
+					VMSize:            to.StringPtr(string(containerservice.VMSizeTypesStandardD2sV3)),
+					OsDiskSizeGB:      to.Int32Ptr(0),
+					Count:             to.Int32Ptr(1),
+					EnableAutoScaling: to.BoolPtr(false),
+					UpgradeSettings:   tc.existing,
+				},
+			}, nil)
+			if tc.expectUpdate {
+				agentpoolsMock.EXPECT().CreateOrUpdate(context.TODO(), "my-rg", "my-cluster", "my-agentpool", gomock.Any()).
+					DoAndReturn(func(_ context.Context, _, _, _ string, pool containerservice.AgentPool) error {
+						g.Expect(pool.UpgradeSettings).To(Equal(tc.expectedSettings))
+						return nil
+					})
+			}
+
+			s := &Service{
+				Client: agentpoolsMock,
+			}
+
+			err := s.Reconcile(context.TODO(), &Spec{
+				ResourceGroup: "my-rg",
+				Cluster:       "my-cluster",
+				Name:          "my-agentpool",
+				SKU:           "Standard_D2s_v3",
+				Replicas:      1,
+				MaxSurge:      tc.maxSurge,
+			})
+			g.Expect(err).NotTo(HaveOccurred())
+		})
+	}
+}
+
 func TestDeleteAgentPools(t *testing.T) {
 	testcases := []struct {
 		name           string
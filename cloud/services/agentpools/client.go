@@ -19,7 +19,7 @@ package agentpools
 import (
 	"context"
 
-	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2020-02-01/containerservice"
+	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2021-07-01/containerservice"
 	"github.com/Azure/go-autorest/autorest"
 	"github.com/pkg/errors"
 	azure "sigs.k8s.io/cluster-api-provider-azure/cloud"
@@ -30,6 +30,8 @@ type Client interface {
 	Get(context.Context, string, string, string) (containerservice.AgentPool, error)
 	CreateOrUpdate(context.Context, string, string, string, containerservice.AgentPool) error
 	Delete(context.Context, string, string, string) error
+	GetUpgradeProfile(context.Context, string, string, string) (containerservice.AgentPoolUpgradeProfile, error)
+	UpgradeNodeImageVersion(context.Context, string, string, string) error
 }
 
 // AzureClient contains the Azure go-sdk Client
@@ -83,3 +85,17 @@ func (ac *AzureClient) Delete(ctx context.Context, resourceGroupName, cluster, n
 	_, err = future.Result(ac.agentpools)
 	return err
 }
+
+// GetUpgradeProfile gets the upgrade profile of an agent pool.
+func (ac *AzureClient) GetUpgradeProfile(ctx context.Context, resourceGroupName, cluster, name string) (containerservice.AgentPoolUpgradeProfile, error) {
+	return ac.agentpools.GetUpgradeProfile(ctx, resourceGroupName, cluster, name)
+}
+
+// UpgradeNodeImageVersion starts upgrading the nodes of an agent pool to the latest node image. It doesn't wait for
+// the upgrade to finish, as it replaces every node of the pool.
+func (ac *AzureClient) UpgradeNodeImageVersion(ctx context.Context, resourceGroupName, cluster, name string) error {
+	if _, err := ac.agentpools.UpgradeNodeImageVersion(ctx, resourceGroupName, cluster, name); err != nil {
+		return errors.Wrap(err, "failed to begin operation")
+	}
+	return nil
+}
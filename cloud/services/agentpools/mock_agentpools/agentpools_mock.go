@@ -22,7 +22,7 @@ package mock_agentpools
 
 import (
 	context "context"
-	containerservice "github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2020-02-01/containerservice"
+	containerservice "github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2021-07-01/containerservice"
 	gomock "github.com/golang/mock/gomock"
 	reflect "reflect"
 )
@@ -92,3 +92,32 @@ func (mr *MockClientMockRecorder) Delete(arg0, arg1, arg2, arg3 interface{}) *go
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockClient)(nil).Delete), arg0, arg1, arg2, arg3)
 }
+
+// GetUpgradeProfile mocks base method.
+func (m *MockClient) GetUpgradeProfile(arg0 context.Context, arg1, arg2, arg3 string) (containerservice.AgentPoolUpgradeProfile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUpgradeProfile", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(containerservice.AgentPoolUpgradeProfile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUpgradeProfile indicates an expected call of GetUpgradeProfile.
+func (mr *MockClientMockRecorder) GetUpgradeProfile(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUpgradeProfile", reflect.TypeOf((*MockClient)(nil).GetUpgradeProfile), arg0, arg1, arg2, arg3)
+}
+
+// UpgradeNodeImageVersion mocks base method.
+func (m *MockClient) UpgradeNodeImageVersion(arg0 context.Context, arg1, arg2, arg3 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpgradeNodeImageVersion", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpgradeNodeImageVersion indicates an expected call of UpgradeNodeImageVersion.
+func (mr *MockClientMockRecorder) UpgradeNodeImageVersion(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpgradeNodeImageVersion", reflect.TypeOf((*MockClient)(nil).UpgradeNodeImageVersion), arg0, arg1, arg2, arg3)
+}
@@ -22,7 +22,7 @@ import (
 	"sort"
 	"strings"
 
-	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2021-07-01/compute"
 	"github.com/pkg/errors"
 )
 
@@ -69,7 +69,7 @@ func (s *Service) filterForVMSizeInLocation(ctx context.Context, vmSize *string,
 				if strings.EqualFold(*locationInfo.Location, s.Scope.Location()) { //NOTE: this should always be true due to the filter
 					for _, restriction := range *resSku.Restrictions {
 						// Can't deploy anything in this subscription in this location. Bail out.
-						if restriction.Type == compute.Location {
+						if restriction.Type == compute.ResourceSkuRestrictionsTypeLocation {
 							return []string{}, errors.Errorf("rejecting sku: %s in location: %s due to susbcription restriction", *vmSize, s.Scope.Location())
 						}
 						// May be able to deploy one or more zones to this location.
@@ -19,7 +19,7 @@ package availabilityzones
 import (
 	"context"
 
-	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2021-07-01/compute"
 	"github.com/Azure/go-autorest/autorest"
 	azure "sigs.k8s.io/cluster-api-provider-azure/cloud"
 )
@@ -52,5 +52,5 @@ func newResourceSkusClient(subscriptionID string, baseURI string, authorizer aut
 
 // ListComplete enumerates all values, automatically crossing page boundaries as required.
 func (ac *AzureClient) ListComplete(ctx context.Context, filter string) (compute.ResourceSkusResultIterator, error) {
-	return ac.resourceSkus.ListComplete(ctx, filter)
+	return ac.resourceSkus.ListComplete(ctx, filter, "")
 }
@@ -22,7 +22,7 @@ package mock_availabilityzones
 
 import (
 	context "context"
-	compute "github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	compute "github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2021-07-01/compute"
 	gomock "github.com/golang/mock/gomock"
 	reflect "reflect"
 )
@@ -53,6 +53,10 @@ func (s *Service) Reconcile(ctx context.Context, spec interface{}) error {
 // Delete deletes the resource group with the provided name.
 func (s *Service) Delete(ctx context.Context, spec interface{}) error {
 	managed, err := s.isGroupManaged(ctx)
+	if err != nil && azure.ResourceNotFound(err) {
+		// already deleted
+		return nil
+	}
 	if err != nil {
 		return errors.Wrap(err, "could not get resource group management state")
 	}
@@ -241,6 +241,25 @@ func TestDeleteGroups(t *testing.T) {
 				}, nil)
 			},
 		},
+		{
+			name: "resource group not found",
+			clusterScopeParams: scope.ClusterScopeParams{
+				AzureClients: scope.AzureClients{
+					Authorizer: autorest.NullAuthorizer{},
+				},
+				AzureCluster: &infrav1.AzureCluster{
+					Spec: infrav1.AzureClusterSpec{
+						Location: "test-location",
+						SubscriptionID: subscriptionID,
+						ResourceGroup:  "my-rg",
+					},
+				},
+			},
+			expectedError: "",
+			expect: func(m *mock_groups.MockClientMockRecorder) {
+				m.Get(context.TODO(), "my-rg").Return(resources.Group{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not Found"))
+			},
+		},
 		{
 			name: "resource group deletion fails",
 			clusterScopeParams: scope.ClusterScopeParams{
@@ -0,0 +1,57 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package identities
+
+import (
+	"context"
+
+	"github.com/Azure/azure-sdk-for-go/services/msi/mgmt/2018-11-30/msi"
+	"github.com/Azure/go-autorest/autorest"
+	azure "sigs.k8s.io/cluster-api-provider-azure/cloud"
+)
+
+// Client wraps go-sdk
+type Client interface {
+	Get(context.Context, string, string, string) (msi.Identity, error)
+}
+
+// AzureClient contains the Azure go-sdk Client
+type AzureClient struct {
+	authorizer autorest.Authorizer
+	baseURI    string
+}
+
+var _ Client = &AzureClient{}
+
+// NewClient creates a new user-assigned identities client. Identities are fetched from the subscription they are in,
+// which may not be the subscription of the cluster.
+func NewClient(auth azure.Authorizer) *AzureClient {
+	return &AzureClient{authorizer: auth.Authorizer(), baseURI: auth.BaseURI()}
+}
+
+// newUserAssignedIdentitiesClient creates a new user-assigned identities client from subscription ID.
+func newUserAssignedIdentitiesClient(subscriptionID string, baseURI string, authorizer autorest.Authorizer) msi.UserAssignedIdentitiesClient {
+	identitiesClient := msi.NewUserAssignedIdentitiesClientWithBaseURI(baseURI, subscriptionID)
+	identitiesClient.Authorizer = authorizer
+	identitiesClient.AddToUserAgent(azure.UserAgent())
+	return identitiesClient
+}
+
+// Get gets a user-assigned identity.
+func (ac *AzureClient) Get(ctx context.Context, subscriptionID, resourceGroupName, name string) (msi.Identity, error) {
+	return newUserAssignedIdentitiesClient(subscriptionID, ac.baseURI, ac.authorizer).Get(ctx, resourceGroupName, name)
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package identities
+
+import (
+	"context"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2021-07-01/containerservice"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/pkg/errors"
+)
+
+// GetByID fetches a user-assigned identity by its resource ID, which may be in another subscription than the cluster.
+// The identity is returned the way AKS references it, with its principal ID as object ID.
+func (s *Service) GetByID(ctx context.Context, id string) (containerservice.UserAssignedIdentity, error) {
+	// /subscriptions/<id>/resourceGroups/<group>/providers/Microsoft.ManagedIdentity/userAssignedIdentities/<identity>
+	parts := strings.Split(strings.TrimPrefix(id, "/"), "/")
+	if len(parts) != 8 || !strings.EqualFold(parts[0], "subscriptions") || !strings.EqualFold(parts[2], "resourceGroups") ||
+		!strings.EqualFold(parts[5], "Microsoft.ManagedIdentity") || !strings.EqualFold(parts[6], "userAssignedIdentities") {
+		return containerservice.UserAssignedIdentity{}, errors.Errorf("invalid user-assigned identity ID %s", id)
+	}
+
+	identity, err := s.Client.Get(ctx, parts[1], parts[3], parts[7])
+	if err != nil {
+		return containerservice.UserAssignedIdentity{}, errors.Wrapf(err, "failed to get user-assigned identity %s", id)
+	}
+	if identity.UserAssignedIdentityProperties == nil || identity.PrincipalID == nil || identity.ClientID == nil {
+		return containerservice.UserAssignedIdentity{}, errors.Errorf("user-assigned identity %s has no principal", id)
+	}
+	return containerservice.UserAssignedIdentity{
+		ResourceID: identity.ID,
+		ClientID:   to.StringPtr(identity.ClientID.String()),
+		ObjectID:   to.StringPtr(identity.PrincipalID.String()),
+	}, nil
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package identities
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/msi/mgmt/2018-11-30/msi"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/gofrs/uuid"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/identities/mock_identities"
+)
+
+func TestGetByID(t *testing.T) {
+	const identityID = "/subscriptions/456/resourceGroups/identity-rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/kubelet"
+	clientID := uuid.Must(uuid.FromString("6a1c38a8-4b1d-4c0f-9f4b-0e3a7d0f3b11"))
+	principalID := uuid.Must(uuid.FromString("0f1e2d3c-4b5a-6978-8796-a5b4c3d2e1f0"))
+
+	testcases := []struct {
+		name          string
+		id            string
+		expectedError string
+		expect        func(m *mock_identities.MockClientMockRecorder)
+	}{
+		{
+			name: "identity in another subscription",
+			id:   identityID,
+			expect: func(m *mock_identities.MockClientMockRecorder) {
+				m.Get(context.TODO(), "456", "identity-rg", "kubelet").Return(msi.Identity{
+					ID: to.StringPtr(identityID),
+					UserAssignedIdentityProperties: &msi.UserAssignedIdentityProperties{
+						ClientID:    &clientID,
+						PrincipalID: &principalID,
+					},
+				}, nil)
+			},
+		},
+		{
+			name:          "identity without a principal",
+			id:            identityID,
+			expectedError: "user-assigned identity " + identityID + " has no principal",
+			expect: func(m *mock_identities.MockClientMockRecorder) {
+				m.Get(context.TODO(), "456", "identity-rg", "kubelet").Return(msi.Identity{ID: to.StringPtr(identityID)}, nil)
+			},
+		},
+		{
+			name:          "identity not found",
+			id:            identityID,
+			expectedError: "failed to get user-assigned identity " + identityID + ": #: Not Found: StatusCode=404",
+			expect: func(m *mock_identities.MockClientMockRecorder) {
+				m.Get(context.TODO(), "456", "identity-rg", "kubelet").Return(msi.Identity{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not Found"))
+			},
+		},
+		{
+			name:          "not a user-assigned identity",
+			id:            "/subscriptions/456/resourceGroups/identity-rg/providers/Microsoft.Compute/virtualMachines/kubelet",
+			expectedError: "invalid user-assigned identity ID /subscriptions/456/resourceGroups/identity-rg/providers/Microsoft.Compute/virtualMachines/kubelet",
+			expect:        func(m *mock_identities.MockClientMockRecorder) {},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			identitiesMock := mock_identities.NewMockClient(mockCtrl)
+
+			tc.expect(identitiesMock.EXPECT())
+
+			s := &Service{
+				Client: identitiesMock,
+			}
+
+			identity, err := s.GetByID(context.TODO(), tc.id)
+			if tc.expectedError != "" {
+				g.Expect(err).To(MatchError(tc.expectedError))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(to.String(identity.ResourceID)).To(Equal(identityID))
+				g.Expect(to.String(identity.ClientID)).To(Equal(clientID.String()))
+				g.Expect(to.String(identity.ObjectID)).To(Equal(principalID.String()))
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package maintenanceconfigurations
+
+import (
+	"context"
+
+	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2021-07-01/containerservice"
+	"github.com/Azure/go-autorest/autorest"
+	azure "sigs.k8s.io/cluster-api-provider-azure/cloud"
+)
+
+// Client wraps go-sdk
+type Client interface {
+	Get(context.Context, string, string, string) (containerservice.MaintenanceConfiguration, error)
+	CreateOrUpdate(context.Context, string, string, string, containerservice.MaintenanceConfiguration) error
+	Delete(context.Context, string, string, string) error
+}
+
+// AzureClient contains the Azure go-sdk Client
+type AzureClient struct {
+	maintenanceconfigurations containerservice.MaintenanceConfigurationsClient
+}
+
+var _ Client = &AzureClient{}
+
+// NewClient creates a new maintenance configurations client from subscription ID.
+func NewClient(auth azure.Authorizer) *AzureClient {
+	c := newMaintenanceConfigurationsClient(auth.SubscriptionID(), auth.BaseURI(), auth.Authorizer())
+	return &AzureClient{c}
+}
+
+// newMaintenanceConfigurationsClient creates a new maintenance configurations client from subscription ID.
+func newMaintenanceConfigurationsClient(subscriptionID string, baseURI string, authorizer autorest.Authorizer) containerservice.MaintenanceConfigurationsClient {
+	maintenanceConfigurationsClient := containerservice.NewMaintenanceConfigurationsClientWithBaseURI(baseURI, subscriptionID)
+	maintenanceConfigurationsClient.Authorizer = authorizer
+	maintenanceConfigurationsClient.AddToUserAgent(azure.UserAgent())
+	return maintenanceConfigurationsClient
+}
+
+// Get gets a maintenance configuration of a managed cluster.
+func (ac *AzureClient) Get(ctx context.Context, resourceGroupName, cluster, name string) (containerservice.MaintenanceConfiguration, error) {
+	return ac.maintenanceconfigurations.Get(ctx, resourceGroupName, cluster, name)
+}
+
+// CreateOrUpdate creates or updates a maintenance configuration of a managed cluster.
+func (ac *AzureClient) CreateOrUpdate(ctx context.Context, resourceGroupName, cluster, name string, config containerservice.MaintenanceConfiguration) error {
+	_, err := ac.maintenanceconfigurations.CreateOrUpdate(ctx, resourceGroupName, cluster, name, config)
+	return err
+}
+
+// Delete deletes a maintenance configuration of a managed cluster.
+func (ac *AzureClient) Delete(ctx context.Context, resourceGroupName, cluster, name string) error {
+	_, err := ac.maintenanceconfigurations.Delete(ctx, resourceGroupName, cluster, name)
+	return err
+}
@@ -0,0 +1,190 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package maintenanceconfigurations
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2021-07-01/containerservice"
+	"github.com/Azure/go-autorest/autorest/date"
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+	azure "sigs.k8s.io/cluster-api-provider-azure/cloud"
+	infrav1exp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1alpha3"
+)
+
+// DefaultName is the name of the maintenance configuration AKS applies to planned maintenance.
+const DefaultName = "default"
+
+// Spec contains properties to create a maintenance configuration of a managed cluster.
+type Spec struct {
+	Name              string
+	ResourceGroup     string
+	Cluster           string
+	MaintenanceWindow infrav1exp.MaintenanceWindow
+}
+
+// Reconcile idempotently creates or updates a maintenance configuration of a managed cluster, restoring it if it
+// was changed outside of Cluster API.
+func (s *Service) Reconcile(ctx context.Context, spec interface{}) error {
+	configSpec, ok := spec.(*Spec)
+	if !ok {
+		return errors.New("invalid maintenance configuration specification")
+	}
+	if err := validateMaintenanceWindow(configSpec.MaintenanceWindow); err != nil {
+		return err
+	}
+
+	desired := toMaintenanceConfigurationProperties(configSpec.MaintenanceWindow)
+	existing, err := s.Client.Get(ctx, configSpec.ResourceGroup, configSpec.Cluster, configSpec.Name)
+	switch {
+	case err == nil && isUpToDate(existing.MaintenanceConfigurationProperties, desired):
+		return nil
+	case err != nil && !azure.ResourceNotFound(err):
+		return errors.Wrapf(err, "failed to get maintenance configuration %s of managed cluster %s", configSpec.Name, configSpec.Cluster)
+	}
+
+	klog.V(2).Infof("updating maintenance configuration %s of managed cluster %s", configSpec.Name, configSpec.Cluster)
+	if err := s.Client.CreateOrUpdate(ctx, configSpec.ResourceGroup, configSpec.Cluster, configSpec.Name, containerservice.MaintenanceConfiguration{MaintenanceConfigurationProperties: desired}); err != nil {
+		return errors.Wrapf(err, "failed to update maintenance configuration %s of managed cluster %s", configSpec.Name, configSpec.Cluster)
+	}
+	klog.V(2).Infof("successfully updated maintenance configuration %s of managed cluster %s", configSpec.Name, configSpec.Cluster)
+	return nil
+}
+
+// Delete deletes a maintenance configuration of a managed cluster.
+func (s *Service) Delete(ctx context.Context, spec interface{}) error {
+	configSpec, ok := spec.(*Spec)
+	if !ok {
+		return errors.New("invalid maintenance configuration specification")
+	}
+
+	klog.V(2).Infof("deleting maintenance configuration %s of managed cluster %s", configSpec.Name, configSpec.Cluster)
+	if err := s.Client.Delete(ctx, configSpec.ResourceGroup, configSpec.Cluster, configSpec.Name); err != nil && !azure.ResourceNotFound(err) {
+		return errors.Wrapf(err, "failed to delete maintenance configuration %s of managed cluster %s", configSpec.Name, configSpec.Cluster)
+	}
+	klog.V(2).Infof("successfully deleted maintenance configuration %s of managed cluster %s", configSpec.Name, configSpec.Cluster)
+	return nil
+}
+
+// validateMaintenanceWindow checks the hours and time spans of a maintenance window.
+func validateMaintenanceWindow(window infrav1exp.MaintenanceWindow) error {
+	for _, timeInWeek := range window.TimeInWeek {
+		for _, hour := range timeInWeek.HourSlots {
+			if hour < 0 || hour > 23 {
+				return errors.Errorf("invalid hour slot %d on %s, hours must be between 0 and 23", hour, timeInWeek.Day)
+			}
+		}
+	}
+	for _, span := range window.NotAllowedTime {
+		if !span.End.After(span.Start.Time) {
+			return errors.Errorf("invalid time span from %s to %s, the end must be after the start", span.Start.UTC().Format(time.RFC3339), span.End.UTC().Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// toMaintenanceConfigurationProperties converts a maintenance window to its AKS representation.
+func toMaintenanceConfigurationProperties(window infrav1exp.MaintenanceWindow) *containerservice.MaintenanceConfigurationProperties {
+	timeInWeek := make([]containerservice.TimeInWeek, len(window.TimeInWeek))
+	for i, slot := range window.TimeInWeek {
+		hourSlots := append([]int32{}, slot.HourSlots...)
+		timeInWeek[i] = containerservice.TimeInWeek{
+			Day:       containerservice.WeekDay(slot.Day),
+			HourSlots: &hourSlots,
+		}
+	}
+	notAllowedTime := make([]containerservice.TimeSpan, len(window.NotAllowedTime))
+	for i, span := range window.NotAllowedTime {
+		notAllowedTime[i] = containerservice.TimeSpan{
+			Start: &date.Time{Time: span.Start.UTC()},
+			End:   &date.Time{Time: span.End.UTC()},
+		}
+	}
+	return &containerservice.MaintenanceConfigurationProperties{
+		TimeInWeek:     &timeInWeek,
+		NotAllowedTime: &notAllowedTime,
+	}
+}
+
+// isUpToDate tells whether an existing maintenance configuration has the desired hours and time spans. Days are
+// compared case-insensitively and times by the instant they stand for, as AKS returns them in its own format.
+func isUpToDate(existing, desired *containerservice.MaintenanceConfigurationProperties) bool {
+	if existing == nil {
+		existing = &containerservice.MaintenanceConfigurationProperties{}
+	}
+
+	existingTimeInWeek, desiredTimeInWeek := timeInWeekOf(existing), timeInWeekOf(desired)
+	if len(existingTimeInWeek) != len(desiredTimeInWeek) {
+		return false
+	}
+	for i := range desiredTimeInWeek {
+		if !strings.EqualFold(string(existingTimeInWeek[i].Day), string(desiredTimeInWeek[i].Day)) {
+			return false
+		}
+		existingHours, desiredHours := hourSlotsOf(existingTimeInWeek[i]), hourSlotsOf(desiredTimeInWeek[i])
+		if len(existingHours) != len(desiredHours) {
+			return false
+		}
+		for j := range desiredHours {
+			if existingHours[j] != desiredHours[j] {
+				return false
+			}
+		}
+	}
+
+	existingNotAllowedTime, desiredNotAllowedTime := notAllowedTimeOf(existing), notAllowedTimeOf(desired)
+	if len(existingNotAllowedTime) != len(desiredNotAllowedTime) {
+		return false
+	}
+	for i := range desiredNotAllowedTime {
+		if !sameTime(existingNotAllowedTime[i].Start, desiredNotAllowedTime[i].Start) || !sameTime(existingNotAllowedTime[i].End, desiredNotAllowedTime[i].End) {
+			return false
+		}
+	}
+	return true
+}
+
+func timeInWeekOf(properties *containerservice.MaintenanceConfigurationProperties) []containerservice.TimeInWeek {
+	if properties.TimeInWeek == nil {
+		return nil
+	}
+	return *properties.TimeInWeek
+}
+
+func hourSlotsOf(timeInWeek containerservice.TimeInWeek) []int32 {
+	if timeInWeek.HourSlots == nil {
+		return nil
+	}
+	return *timeInWeek.HourSlots
+}
+
+func notAllowedTimeOf(properties *containerservice.MaintenanceConfigurationProperties) []containerservice.TimeSpan {
+	if properties.NotAllowedTime == nil {
+		return nil
+	}
+	return *properties.NotAllowedTime
+}
+
+func sameTime(a, b *date.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(b.Time)
+}
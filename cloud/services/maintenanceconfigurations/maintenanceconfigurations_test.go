@@ -0,0 +1,196 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package maintenanceconfigurations
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2021-07-01/containerservice"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/date"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/maintenanceconfigurations/mock_maintenanceconfigurations"
+	infrav1exp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1alpha3"
+)
+
+func newMaintenanceWindow() infrav1exp.MaintenanceWindow {
+	return infrav1exp.MaintenanceWindow{
+		TimeInWeek: []infrav1exp.TimeInWeek{
+			{Day: "Saturday", HourSlots: []int32{1, 2, 3}},
+		},
+		NotAllowedTime: []infrav1exp.TimeSpan{
+			{
+				Start: metav1.NewTime(time.Date(2020, 12, 24, 0, 0, 0, 0, time.UTC)),
+				End:   metav1.NewTime(time.Date(2020, 12, 27, 0, 0, 0, 0, time.UTC)),
+			},
+		},
+	}
+}
+
+func newMaintenanceConfiguration() containerservice.MaintenanceConfiguration {
+	return containerservice.MaintenanceConfiguration{
+		MaintenanceConfigurationProperties: &containerservice.MaintenanceConfigurationProperties{
+			TimeInWeek: &[]containerservice.TimeInWeek{
+				{Day: containerservice.WeekDaySaturday, HourSlots: &[]int32{1, 2, 3}},
+			},
+			NotAllowedTime: &[]containerservice.TimeSpan{
+				{
+					Start: &date.Time{Time: time.Date(2020, 12, 24, 0, 0, 0, 0, time.UTC)},
+					End:   &date.Time{Time: time.Date(2020, 12, 27, 0, 0, 0, 0, time.UTC)},
+				},
+			},
+		},
+	}
+}
+
+func TestReconcileMaintenanceConfiguration(t *testing.T) {
+	changed := newMaintenanceConfiguration()
+	(*changed.TimeInWeek)[0].HourSlots = &[]int32{4}
+	inLocalTime := newMaintenanceConfiguration()
+	(*inLocalTime.TimeInWeek)[0].Day = "saturday"
+	(*inLocalTime.NotAllowedTime)[0].Start = &date.Time{Time: time.Date(2020, 12, 24, 1, 0, 0, 0, time.FixedZone("UTC+1", 3600))}
+	removed := newMaintenanceConfiguration()
+	removed.NotAllowedTime = nil
+
+	testcases := []struct {
+		name          string
+		window        infrav1exp.MaintenanceWindow
+		expect        func(m *mock_maintenanceconfigurations.MockClientMockRecorder)
+		expectedError string
+	}{
+		{
+			name:   "create",
+			window: newMaintenanceWindow(),
+			expect: func(m *mock_maintenanceconfigurations.MockClientMockRecorder) {
+				m.Get(context.TODO(), "my-rg", "my-cluster", "default").Return(containerservice.MaintenanceConfiguration{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not Found"))
+				m.CreateOrUpdate(context.TODO(), "my-rg", "my-cluster", "default", newMaintenanceConfiguration()).Return(nil)
+			},
+		},
+		{
+			name:   "up to date",
+			window: newMaintenanceWindow(),
+			expect: func(m *mock_maintenanceconfigurations.MockClientMockRecorder) {
+				m.Get(context.TODO(), "my-rg", "my-cluster", "default").Return(inLocalTime, nil)
+			},
+		},
+		{
+			name:   "changed outside of Cluster API",
+			window: newMaintenanceWindow(),
+			expect: func(m *mock_maintenanceconfigurations.MockClientMockRecorder) {
+				m.Get(context.TODO(), "my-rg", "my-cluster", "default").Return(changed, nil)
+				m.CreateOrUpdate(context.TODO(), "my-rg", "my-cluster", "default", newMaintenanceConfiguration()).Return(nil)
+			},
+		},
+		{
+			name:   "time span removed outside of Cluster API",
+			window: newMaintenanceWindow(),
+			expect: func(m *mock_maintenanceconfigurations.MockClientMockRecorder) {
+				m.Get(context.TODO(), "my-rg", "my-cluster", "default").Return(removed, nil)
+				m.CreateOrUpdate(context.TODO(), "my-rg", "my-cluster", "default", newMaintenanceConfiguration()).Return(nil)
+			},
+		},
+		{
+			name:   "get fails",
+			window: newMaintenanceWindow(),
+			expect: func(m *mock_maintenanceconfigurations.MockClientMockRecorder) {
+				m.Get(context.TODO(), "my-rg", "my-cluster", "default").Return(containerservice.MaintenanceConfiguration{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"))
+			},
+			expectedError: "failed to get maintenance configuration default of managed cluster my-cluster: #: Internal Server Error: StatusCode=500",
+		},
+		{
+			name: "invalid hour slot",
+			window: infrav1exp.MaintenanceWindow{
+				TimeInWeek: []infrav1exp.TimeInWeek{{Day: "Monday", HourSlots: []int32{24}}},
+			},
+			expect:        func(m *mock_maintenanceconfigurations.MockClientMockRecorder) {},
+			expectedError: "invalid hour slot 24 on Monday, hours must be between 0 and 23",
+		},
+		{
+			name: "invalid time span",
+			window: infrav1exp.MaintenanceWindow{
+				NotAllowedTime: []infrav1exp.TimeSpan{
+					{
+						Start: metav1.NewTime(time.Date(2020, 12, 27, 0, 0, 0, 0, time.UTC)),
+						End:   metav1.NewTime(time.Date(2020, 12, 24, 0, 0, 0, 0, time.UTC)),
+					},
+				},
+			},
+			expect:        func(m *mock_maintenanceconfigurations.MockClientMockRecorder) {},
+			expectedError: "invalid time span from 2020-12-27T00:00:00Z to 2020-12-24T00:00:00Z, the end must be after the start",
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			maintenanceConfigurationsMock := mock_maintenanceconfigurations.NewMockClient(mockCtrl)
+
+			tc.expect(maintenanceConfigurationsMock.EXPECT())
+
+			s := &Service{
+				Client: maintenanceConfigurationsMock,
+			}
+
+			err := s.Reconcile(context.TODO(), &Spec{
+				Name:              DefaultName,
+				ResourceGroup:     "my-rg",
+				Cluster:           "my-cluster",
+				MaintenanceWindow: tc.window,
+			})
+			if tc.expectedError != "" {
+				g.Expect(err).To(MatchError(tc.expectedError))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestDeleteMaintenanceConfiguration(t *testing.T) {
+	g := NewWithT(t)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	maintenanceConfigurationsMock := mock_maintenanceconfigurations.NewMockClient(mockCtrl)
+
+	s := &Service{
+		Client: maintenanceConfigurationsMock,
+	}
+	spec := &Spec{
+		Name:          DefaultName,
+		ResourceGroup: "my-rg",
+		Cluster:       "my-cluster",
+	}
+
+	maintenanceConfigurationsMock.EXPECT().Delete(context.TODO(), "my-rg", "my-cluster", "default").Return(nil)
+	g.Expect(s.Delete(context.TODO(), spec)).To(Succeed())
+
+	maintenanceConfigurationsMock.EXPECT().Delete(context.TODO(), "my-rg", "my-cluster", "default").
+		Return(autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not Found"))
+	g.Expect(s.Delete(context.TODO(), spec)).To(Succeed())
+
+	maintenanceConfigurationsMock.EXPECT().Delete(context.TODO(), "my-rg", "my-cluster", "default").
+		Return(autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"))
+	g.Expect(s.Delete(context.TODO(), spec)).To(MatchError("failed to delete maintenance configuration default of managed cluster my-cluster: #: Internal Server Error: StatusCode=500"))
+}
@@ -19,7 +19,7 @@ package managedclusters
 import (
 	"context"
 
-	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2020-02-01/containerservice"
+	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2021-07-01/containerservice"
 	"github.com/Azure/go-autorest/autorest"
 	"github.com/pkg/errors"
 	azure "sigs.k8s.io/cluster-api-provider-azure/cloud"
@@ -29,6 +29,8 @@ import (
 type Client interface {
 	Get(context.Context, string, string) (containerservice.ManagedCluster, error)
 	GetCredentials(context.Context, string, string) ([]byte, error)
+	GetUserCredentials(context.Context, string, string) ([]byte, error)
+	GetUpgradeProfile(context.Context, string, string) (containerservice.ManagedClusterUpgradeProfile, error)
 	CreateOrUpdate(context.Context, string, string, containerservice.ManagedCluster) error
 	Delete(context.Context, string, string) error
 }
@@ -62,11 +64,26 @@ func (ac *AzureClient) Get(ctx context.Context, resourceGroupName, name string)
 
 // GetCredentials fetches the admin kubeconfig for a managed cluster.
 func (ac *AzureClient) GetCredentials(ctx context.Context, resourceGroupName, name string) ([]byte, error) {
-	credentialList, err := ac.managedclusters.ListClusterAdminCredentials(ctx, resourceGroupName, name)
+	credentialList, err := ac.managedclusters.ListClusterAdminCredentials(ctx, resourceGroupName, name, "")
 	if err != nil {
 		return nil, err
 	}
 
+	return firstKubeconfig(credentialList)
+}
+
+// GetUserCredentials fetches the user kubeconfig for a managed cluster, which authenticates with Azure AD if the
+// cluster is integrated with it.
+func (ac *AzureClient) GetUserCredentials(ctx context.Context, resourceGroupName, name string) ([]byte, error) {
+	credentialList, err := ac.managedclusters.ListClusterUserCredentials(ctx, resourceGroupName, name, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return firstKubeconfig(credentialList)
+}
+
+func firstKubeconfig(credentialList containerservice.CredentialResults) ([]byte, error) {
 	if credentialList.Kubeconfigs == nil || len(*credentialList.Kubeconfigs) < 1 {
 		return nil, errors.New("no kubeconfigs available for the managed cluster cluster")
 	}
@@ -74,6 +91,11 @@ func (ac *AzureClient) GetCredentials(ctx context.Context, resourceGroupName, na
 	return *(*credentialList.Kubeconfigs)[0].Value, nil
 }
 
+// GetUpgradeProfile gets the Kubernetes versions a managed cluster can be upgraded to.
+func (ac *AzureClient) GetUpgradeProfile(ctx context.Context, resourceGroupName, name string) (containerservice.ManagedClusterUpgradeProfile, error) {
+	return ac.managedclusters.GetUpgradeProfile(ctx, resourceGroupName, name)
+}
+
 // CreateOrUpdate creates or updates a managed cluster.
 func (ac *AzureClient) CreateOrUpdate(ctx context.Context, resourceGroupName, name string, cluster containerservice.ManagedCluster) error {
 	future, err := ac.managedclusters.CreateOrUpdate(ctx, resourceGroupName, name, cluster)
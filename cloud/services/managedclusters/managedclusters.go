@@ -20,9 +20,11 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"regexp"
 	"strings"
 
-	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2020-02-01/containerservice"
+	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2021-07-01/containerservice"
+	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/pkg/errors"
 	"k8s.io/klog"
 	azure "sigs.k8s.io/cluster-api-provider-azure/cloud"
@@ -31,6 +33,16 @@ import (
 var (
 	defaultUser     string = "azureuser"
 	managedIdentity string = "msi"
+
+	privateDNSZoneIDRegex = regexp.MustCompile(`(?i)^/subscriptions/[^/]+/resourceGroups/[^/]+/providers/Microsoft\.Network/privateDnsZones/[^/]+$`)
+)
+
+const (
+	privateDNSZoneSystem = "System"
+	privateDNSZoneNone   = "None"
+
+	// kubeletIdentityName is the key of the identity of the kubelets in the identity profile of a managed cluster.
+	kubeletIdentityName = "kubeletidentity"
 )
 
 // Spec contains properties to create a managed cluster.
@@ -59,9 +71,26 @@ type Spec struct {
 	// NetworkPolicy used for building Kubernetes network. Possible values include: 'Calico', 'Azure'. Defaults to Azure.
 	NetworkPolicy *string
 
+	// WindowsProfile is the administrator account of the Windows nodes of the cluster.
+	WindowsProfile *WindowsProfile
+
+	// OutboundType is the method used for egress from the cluster. Possible values include: 'loadBalancer',
+	// 'userDefinedRouting', 'managedNATGateway'. Defaults to loadBalancer.
+	OutboundType *string
+
+	// LoadBalancerProfile configures the outbound connectivity of the cluster load balancer.
+	LoadBalancerProfile *LoadBalancerProfile
+
+	// NATGatewayProfile configures the outbound connectivity of the NAT gateway AKS manages for the cluster.
+	NATGatewayProfile *NATGatewayProfile
+
 	// SSHPublicKey is a string literal containing an ssh public key. Will autogenerate and discard if not provided.
 	SSHPublicKey string
 
+	// NodeResourceGroup is the name of the resource group for the nodes of the cluster. Defaults to the AKS naming
+	// scheme if empty.
+	NodeResourceGroup string
+
 	// AgentPools is the list of agent pool specifications in this cluster.
 	AgentPools []PoolSpec
 
@@ -70,6 +99,100 @@ type Spec struct {
 
 	// ServiceCIDR is the CIDR block for IP addresses distributed to services
 	ServiceCIDR string
+
+	// SKUTier is the pricing tier of the control plane. Possible values include: 'Free', 'Paid'. Defaults to Free.
+	SKUTier string
+
+	// AutoUpgradeChannel is the channel AKS automatically upgrades the cluster with. Possible values include: 'none',
+	// 'patch', 'stable', 'rapid', 'node-image'. Left as it is if not set.
+	AutoUpgradeChannel *string
+
+	// APIServerAccessProfile is the access profile for the AKS API server.
+	APIServerAccessProfile *APIServerAccessProfile
+
+	// AADProfile is the Azure Active Directory integration of the cluster.
+	AADProfile *AADProfile
+
+	// EnableRBAC enables Kubernetes role-based access control. Defaults to true.
+	EnableRBAC *bool
+
+	// DisableLocalAccounts disables the admin credentials of the cluster. Left as it is if nil.
+	DisableLocalAccounts *bool
+
+	// AddonProfiles are the add-ons to enable or disable. Add-ons that aren't listed are left as they are.
+	AddonProfiles []AddonProfile
+
+	// ControlPlaneIdentityID is the resource ID of the user-assigned identity of the control plane. AKS creates a
+	// system-assigned identity if empty.
+	ControlPlaneIdentityID string
+
+	// KubeletIdentity is the user-assigned identity of the kubelets, which requires a user-assigned control plane
+	// identity. AKS creates one in the node resource group if not set.
+	KubeletIdentity *containerservice.UserAssignedIdentity
+}
+
+// AddonProfile describes an AKS add-on.
+type AddonProfile struct {
+	Name    string
+	Enabled bool
+	Config  map[string]string
+}
+
+// WindowsProfile is the administrator account of the Windows nodes of an AKS cluster.
+type WindowsProfile struct {
+	AdminUsername string
+	AdminPassword string
+}
+
+// LoadBalancerProfile configures the outbound connectivity of the Standard load balancer of an AKS cluster.
+type LoadBalancerProfile struct {
+	// ManagedOutboundIPs is the number of outbound public IPs AKS creates for the load balancer.
+	ManagedOutboundIPs *int32
+
+	// OutboundIPs are the resource IDs of existing public IPs to use for outbound connections.
+	OutboundIPs []string
+
+	// OutboundIPPrefixes are the resource IDs of existing public IP prefixes to use for outbound connections.
+	OutboundIPPrefixes []string
+
+	// AllocatedOutboundPorts is the number of SNAT ports allocated per node.
+	AllocatedOutboundPorts *int32
+
+	// IdleTimeoutInMinutes is the idle timeout of outbound flows.
+	IdleTimeoutInMinutes *int32
+}
+
+// NATGatewayProfile configures the outbound connectivity of the NAT gateway AKS manages for a cluster.
+type NATGatewayProfile struct {
+	// ManagedOutboundIPs is the number of outbound public IPs AKS creates for the NAT gateway.
+	ManagedOutboundIPs *int32
+
+	// IdleTimeoutInMinutes is the idle timeout of outbound flows.
+	IdleTimeoutInMinutes *int32
+}
+
+// AADProfile is the managed Azure Active Directory integration of an AKS cluster.
+type AADProfile struct {
+	// AdminGroupObjectIDs are the object IDs of the AAD groups that are cluster admins.
+	AdminGroupObjectIDs []string
+
+	// TenantID is the AAD tenant to authenticate against. Defaults to the tenant of the subscription.
+	TenantID string
+
+	// EnableAzureRBAC authorizes AAD identities with Azure role assignments. Left as it is if nil.
+	EnableAzureRBAC *bool
+}
+
+// APIServerAccessProfile controls access to the AKS API server.
+type APIServerAccessProfile struct {
+	// AuthorizedIPRanges are the IP ranges in CIDR notation that are allowed to access the API server.
+	AuthorizedIPRanges []string
+
+	// EnablePrivateCluster creates the cluster with an API server that is only reachable from within its virtual network.
+	EnablePrivateCluster *bool
+
+	// PrivateDNSZone is the private DNS zone of a private cluster: System, None, or the resource ID of a private DNS zone.
+	PrivateDNSZone *string
 }
 
 type PoolSpec struct {
@@ -77,6 +200,17 @@ type PoolSpec struct {
 	SKU          string
 	Replicas     int32
 	OSDiskSizeGB int32
+	VnetSubnetID string
+
+	OSType            string
+	Mode              string
+	EnableAutoScaling bool
+	MinCount          *int32
+	MaxCount          *int32
+	NodeLabels        map[string]string
+	NodeTaints        []string
+	MaxPods           *int32
+	AvailabilityZones []string
 }
 
 // Get fetches a managed cluster from Azure.
@@ -93,6 +227,30 @@ func (s *Service) GetCredentials(ctx context.Context, group, name string) ([]byt
 	return s.Client.GetCredentials(ctx, group, name)
 }
 
+// GetUserCredentials fetches a managed cluster user kubeconfig from Azure.
+func (s *Service) GetUserCredentials(ctx context.Context, group, name string) ([]byte, error) {
+	return s.Client.GetUserCredentials(ctx, group, name)
+}
+
+// GetAvailableUpgrades returns the Kubernetes versions the control plane of a managed cluster can be upgraded to.
+func (s *Service) GetAvailableUpgrades(ctx context.Context, group, name string) ([]string, error) {
+	profile, err := s.Client.GetUpgradeProfile(ctx, group, name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get upgrade profile for managed cluster %s", name)
+	}
+
+	var versions []string
+	if profile.ManagedClusterUpgradeProfileProperties == nil || profile.ControlPlaneProfile == nil || profile.ControlPlaneProfile.Upgrades == nil {
+		return versions, nil
+	}
+	for _, upgrade := range *profile.ControlPlaneProfile.Upgrades {
+		if upgrade.KubernetesVersion != nil {
+			versions = append(versions, *upgrade.KubernetesVersion)
+		}
+	}
+	return versions, nil
+}
+
 // Reconcile idempotently creates or updates a managed cluster, if possible.
 func (s *Service) Reconcile(ctx context.Context, spec interface{}) error {
 	managedClusterSpec, ok := spec.(*Spec)
@@ -102,9 +260,10 @@ func (s *Service) Reconcile(ctx context.Context, spec interface{}) error {
 
 	properties := containerservice.ManagedCluster{
 		Identity: &containerservice.ManagedClusterIdentity{
-			Type: containerservice.SystemAssigned,
+			Type: containerservice.ResourceIdentityTypeSystemAssigned,
 		},
 		Location: &managedClusterSpec.Location,
+		Tags:     getTags(nil, managedClusterSpec.Tags),
 		ManagedClusterProperties: &containerservice.ManagedClusterProperties{
 			DNSPrefix:         &managedClusterSpec.Name,
 			KubernetesVersion: &managedClusterSpec.Version,
@@ -122,9 +281,9 @@ func (s *Service) Reconcile(ctx context.Context, spec interface{}) error {
 				ClientID: &managedIdentity,
 			},
 			AgentPoolProfiles: &[]containerservice.ManagedClusterAgentPoolProfile{},
-			NetworkProfile: &containerservice.NetworkProfileType{
-				NetworkPlugin:   containerservice.Azure,
-				LoadBalancerSku: containerservice.Standard,
+			NetworkProfile: &containerservice.NetworkProfile{
+				NetworkPlugin:   containerservice.NetworkPluginAzure,
+				LoadBalancerSku: containerservice.LoadBalancerSkuStandard,
 			},
 		},
 	}
@@ -167,13 +326,167 @@ func (s *Service) Reconcile(ctx context.Context, spec interface{}) error {
 		properties.NetworkProfile.LoadBalancerSku = containerservice.LoadBalancerSku(*managedClusterSpec.LoadBalancerSKU)
 	}
 
+	if managedClusterSpec.WindowsProfile != nil {
+		properties.WindowsProfile = &containerservice.ManagedClusterWindowsProfile{
+			AdminUsername: &managedClusterSpec.WindowsProfile.AdminUsername,
+			AdminPassword: &managedClusterSpec.WindowsProfile.AdminPassword,
+		}
+	}
+
+	if managedClusterSpec.OutboundType != nil {
+		properties.NetworkProfile.OutboundType = containerservice.OutboundType(*managedClusterSpec.OutboundType)
+	}
+
+	if managedClusterSpec.LoadBalancerProfile != nil {
+		if properties.NetworkProfile.OutboundType == containerservice.OutboundTypeUserDefinedRouting || properties.NetworkProfile.OutboundType == containerservice.OutboundTypeManagedNATGateway {
+			return errors.Errorf("a load balancer profile is not supported with the %s outbound type", properties.NetworkProfile.OutboundType)
+		}
+		loadBalancerProfile, err := getLoadBalancerProfile(managedClusterSpec.LoadBalancerProfile)
+		if err != nil {
+			return err
+		}
+		properties.NetworkProfile.LoadBalancerProfile = loadBalancerProfile
+	}
+
+	if managedClusterSpec.NATGatewayProfile != nil {
+		if properties.NetworkProfile.OutboundType != containerservice.OutboundTypeManagedNATGateway {
+			return errors.New("a NAT gateway profile is only supported with the managedNATGateway outbound type")
+		}
+		properties.NetworkProfile.NatGatewayProfile = &containerservice.ManagedClusterNATGatewayProfile{
+			IdleTimeoutInMinutes: managedClusterSpec.NATGatewayProfile.IdleTimeoutInMinutes,
+		}
+		if managedClusterSpec.NATGatewayProfile.ManagedOutboundIPs != nil {
+			properties.NetworkProfile.NatGatewayProfile.ManagedOutboundIPProfile = &containerservice.ManagedClusterManagedOutboundIPProfile{
+				Count: managedClusterSpec.NATGatewayProfile.ManagedOutboundIPs,
+			}
+		}
+	}
+
+	if managedClusterSpec.SKUTier != "" {
+		properties.Sku = &containerservice.ManagedClusterSKU{
+			Name: containerservice.ManagedClusterSKUNameBasic,
+			Tier: containerservice.ManagedClusterSKUTier(managedClusterSpec.SKUTier),
+		}
+	}
+
+	if managedClusterSpec.APIServerAccessProfile != nil {
+		if err := validateAPIServerAccessProfile(managedClusterSpec.APIServerAccessProfile); err != nil {
+			return err
+		}
+		// Always send the authorized IP ranges, as an empty list removes the ranges of an existing cluster.
+		authorizedIPRanges := append([]string{}, managedClusterSpec.APIServerAccessProfile.AuthorizedIPRanges...)
+		properties.APIServerAccessProfile = &containerservice.ManagedClusterAPIServerAccessProfile{
+			AuthorizedIPRanges:   &authorizedIPRanges,
+			EnablePrivateCluster: managedClusterSpec.APIServerAccessProfile.EnablePrivateCluster,
+			PrivateDNSZone:       managedClusterSpec.APIServerAccessProfile.PrivateDNSZone,
+		}
+	}
+
+	// Always send the auto-upgrade channel when it is declared, so that changes made outside of Cluster API are undone.
+	if managedClusterSpec.AutoUpgradeChannel != nil {
+		properties.AutoUpgradeProfile = &containerservice.ManagedClusterAutoUpgradeProfile{
+			UpgradeChannel: containerservice.UpgradeChannel(*managedClusterSpec.AutoUpgradeChannel),
+		}
+	}
+
+	// Always send the control plane identity, so that AKS switches the cluster to it.
+	if managedClusterSpec.ControlPlaneIdentityID != "" {
+		properties.Identity = &containerservice.ManagedClusterIdentity{
+			Type: containerservice.ResourceIdentityTypeUserAssigned,
+			UserAssignedIdentities: map[string]*containerservice.ManagedClusterIdentityUserAssignedIdentitiesValue{
+				managedClusterSpec.ControlPlaneIdentityID: {},
+			},
+		}
+	}
+
+	if managedClusterSpec.KubeletIdentity != nil {
+		if managedClusterSpec.ControlPlaneIdentityID == "" {
+			return errors.New("a user-assigned kubelet identity requires a user-assigned control plane identity")
+		}
+		properties.IdentityProfile = map[string]*containerservice.UserAssignedIdentity{
+			kubeletIdentityName: {
+				ResourceID: managedClusterSpec.KubeletIdentity.ResourceID,
+				ClientID:   managedClusterSpec.KubeletIdentity.ClientID,
+				ObjectID:   managedClusterSpec.KubeletIdentity.ObjectID,
+			},
+		}
+	}
+
+	if managedClusterSpec.EnableRBAC != nil {
+		properties.EnableRBAC = managedClusterSpec.EnableRBAC
+	}
+
+	if managedClusterSpec.AADProfile != nil {
+		if managedClusterSpec.EnableRBAC != nil && !*managedClusterSpec.EnableRBAC {
+			return errors.New("Azure AD integration requires Kubernetes RBAC to be enabled")
+		}
+		adminGroupObjectIDs := append([]string{}, managedClusterSpec.AADProfile.AdminGroupObjectIDs...)
+		properties.AadProfile = &containerservice.ManagedClusterAADProfile{
+			Managed:             to.BoolPtr(true),
+			AdminGroupObjectIDs: &adminGroupObjectIDs,
+		}
+		if managedClusterSpec.AADProfile.TenantID != "" {
+			properties.AadProfile.TenantID = &managedClusterSpec.AADProfile.TenantID
+		}
+		properties.AadProfile.EnableAzureRBAC = managedClusterSpec.AADProfile.EnableAzureRBAC
+	}
+
+	if to.Bool(managedClusterSpec.DisableLocalAccounts) && managedClusterSpec.AADProfile == nil {
+		return errors.New("disabling local accounts requires Azure AD integration")
+	}
+	properties.DisableLocalAccounts = managedClusterSpec.DisableLocalAccounts
+
+	if managedClusterSpec.NodeResourceGroup != "" {
+		properties.NodeResourceGroup = &managedClusterSpec.NodeResourceGroup
+	}
+
+	properties.AddonProfiles = getAddonProfiles(nil, managedClusterSpec.AddonProfiles)
+
 	for _, pool := range managedClusterSpec.AgentPools {
 		profile := containerservice.ManagedClusterAgentPoolProfile{
 			Name:         &pool.Name,
-			VMSize:       containerservice.VMSizeTypes(pool.SKU),
+			VMSize:       to.StringPtr(pool.SKU),
 			OsDiskSizeGB: &pool.OSDiskSizeGB,
 			Count:        &pool.Replicas,
-			Type:         containerservice.VirtualMachineScaleSets,
+			Type:         containerservice.AgentPoolTypeVirtualMachineScaleSets,
+			OsType:       containerservice.OSType(pool.OSType),
+			Mode:         containerservice.AgentPoolMode(pool.Mode),
+			MaxPods:      pool.MaxPods,
+		}
+		if pool.VnetSubnetID != "" {
+			profile.VnetSubnetID = &pool.VnetSubnetID
+		}
+		// AKS creates a cluster with its agent pools as system pools, which can't run Windows.
+		if pool.OSType == string(containerservice.OSTypeWindows) {
+			return errors.Errorf("Windows agent pool %s cannot be the default pool of the cluster", pool.Name)
+		}
+		if pool.EnableAutoScaling {
+			if pool.MinCount == nil || pool.MaxCount == nil {
+				return errors.Errorf("agent pool %s must set minCount and maxCount to enable autoscaling", pool.Name)
+			}
+			profile.EnableAutoScaling = to.BoolPtr(true)
+			profile.MinCount = pool.MinCount
+			profile.MaxCount = pool.MaxCount
+			// Start the autoscaler within its bounds, it owns the node count from then on.
+			count := pool.Replicas
+			if count < *pool.MinCount {
+				count = *pool.MinCount
+			}
+			if count > *pool.MaxCount {
+				count = *pool.MaxCount
+			}
+			profile.Count = &count
+		}
+		if len(pool.NodeLabels) > 0 {
+			profile.NodeLabels = *to.StringMapPtr(pool.NodeLabels)
+		}
+		if len(pool.NodeTaints) > 0 {
+			nodeTaints := append([]string{}, pool.NodeTaints...)
+			profile.NodeTaints = &nodeTaints
+		}
+		if len(pool.AvailabilityZones) > 0 {
+			availabilityZones := append([]string{}, pool.AvailabilityZones...)
+			profile.AvailabilityZones = &availabilityZones
 		}
 		*properties.AgentPoolProfiles = append(*properties.AgentPoolProfiles, profile)
 	}
@@ -187,6 +500,86 @@ func (s *Service) Reconcile(ctx context.Context, spec interface{}) error {
 			klog.V(2).Infof("Unable to update existing managed cluster in non terminal state.  Managed cluster must be in one of the following provisioning states: canceled, failed, or succeeded")
 			return nil
 		}
+
+		// Send the existing agent pools back with their current orchestrator
+		// versions, so that a version change only upgrades the control plane.
+		// Node pools are upgraded afterwards by the machine pool controller.
+		properties.AgentPoolProfiles = existingMC.AgentPoolProfiles
+
+		// The node resource group can't be changed once the cluster is created.
+		properties.NodeResourceGroup = existingMC.NodeResourceGroup
+
+		// Neither can the private DNS zone, which AKS defaults for private clusters.
+		if existingMC.APIServerAccessProfile != nil && existingMC.APIServerAccessProfile.PrivateDNSZone != nil {
+			if properties.APIServerAccessProfile == nil {
+				properties.APIServerAccessProfile = &containerservice.ManagedClusterAPIServerAccessProfile{}
+			}
+			declared := properties.APIServerAccessProfile.PrivateDNSZone
+			if declared != nil && !strings.EqualFold(*declared, *existingMC.APIServerAccessProfile.PrivateDNSZone) {
+				return errors.Errorf("the private DNS zone of managed cluster %s cannot be changed from %s to %s", managedClusterSpec.Name, *existingMC.APIServerAccessProfile.PrivateDNSZone, *declared)
+			}
+			properties.APIServerAccessProfile.PrivateDNSZone = existingMC.APIServerAccessProfile.PrivateDNSZone
+		}
+
+		// Nor the kubelet identity.
+		properties.IdentityProfile = existingMC.IdentityProfile
+
+		// The outbound type can't be changed once the cluster is created, and AKS defaults
+		// the load balancer profile, which is kept unless it is set explicitly.
+		if existingMC.NetworkProfile != nil {
+			properties.NetworkProfile.OutboundType = existingMC.NetworkProfile.OutboundType
+			// Neither can the network CIDRs, which AKS defaults too.
+			if properties.NetworkProfile.PodCidr == nil {
+				properties.NetworkProfile.PodCidr = existingMC.NetworkProfile.PodCidr
+			}
+			if properties.NetworkProfile.ServiceCidr == nil {
+				properties.NetworkProfile.ServiceCidr = existingMC.NetworkProfile.ServiceCidr
+				properties.NetworkProfile.DNSServiceIP = existingMC.NetworkProfile.DNSServiceIP
+			}
+			properties.NetworkProfile.DockerBridgeCidr = existingMC.NetworkProfile.DockerBridgeCidr
+			if properties.NetworkProfile.LoadBalancerProfile == nil && existingMC.NetworkProfile.LoadBalancerProfile != nil {
+				loadBalancerProfile := *existingMC.NetworkProfile.LoadBalancerProfile
+				loadBalancerProfile.EffectiveOutboundIPs = nil
+				properties.NetworkProfile.LoadBalancerProfile = &loadBalancerProfile
+			}
+			if properties.NetworkProfile.NatGatewayProfile == nil && existingMC.NetworkProfile.NatGatewayProfile != nil {
+				natGatewayProfile := *existingMC.NetworkProfile.NatGatewayProfile
+				natGatewayProfile.EffectiveOutboundIPs = nil
+				properties.NetworkProfile.NatGatewayProfile = &natGatewayProfile
+			}
+		}
+
+		// Keep Azure RBAC and the local accounts as they are unless they are declared.
+		if properties.AadProfile != nil && properties.AadProfile.EnableAzureRBAC == nil && existingMC.AadProfile != nil {
+			properties.AadProfile.EnableAzureRBAC = existingMC.AadProfile.EnableAzureRBAC
+		}
+		if properties.DisableLocalAccounts == nil {
+			properties.DisableLocalAccounts = existingMC.DisableLocalAccounts
+		}
+
+		// Keep the auto-upgrade channel that was set outside of Cluster API unless one is declared.
+		if properties.AutoUpgradeProfile == nil {
+			properties.AutoUpgradeProfile = existingMC.AutoUpgradeProfile
+		}
+
+		// Keep the tier the cluster is on unless one is declared.
+		if properties.Sku == nil {
+			properties.Sku = existingMC.Sku
+		}
+
+		// The Windows admin username can't be changed once the cluster is created, only the password.
+		if existingMC.WindowsProfile != nil {
+			if properties.WindowsProfile == nil {
+				properties.WindowsProfile = &containerservice.ManagedClusterWindowsProfile{}
+			}
+			properties.WindowsProfile.AdminUsername = existingMC.WindowsProfile.AdminUsername
+		}
+
+		// Keep the tags that were added outside of Cluster API.
+		properties.Tags = getTags(existingMC.Tags, managedClusterSpec.Tags)
+
+		// Keep the add-ons that were enabled outside of Cluster API.
+		properties.AddonProfiles = getAddonProfiles(existingMC.AddonProfiles, managedClusterSpec.AddonProfiles)
 	}
 
 	err = s.Client.CreateOrUpdate(ctx, managedClusterSpec.ResourceGroup, managedClusterSpec.Name, properties)
@@ -197,6 +590,116 @@ func (s *Service) Reconcile(ctx context.Context, spec interface{}) error {
 	return nil
 }
 
+// getTags applies the given tags to the tags of an existing managed cluster.
+func getTags(existing map[string]*string, tags map[string]string) map[string]*string {
+	merged := make(map[string]*string, len(existing)+len(tags))
+	for key, value := range existing {
+		merged[key] = value
+	}
+	for key, value := range tags {
+		merged[key] = to.StringPtr(value)
+	}
+	return merged
+}
+
+// getAddonProfiles applies the given add-ons to the add-on profiles of an existing managed cluster. Add-on names are
+// matched case-insensitively, as AKS doesn't always return them in the case they were sent in.
+func getAddonProfiles(existing map[string]*containerservice.ManagedClusterAddonProfile, addons []AddonProfile) map[string]*containerservice.ManagedClusterAddonProfile {
+	profiles := make(map[string]*containerservice.ManagedClusterAddonProfile, len(existing)+len(addons))
+	for name, profile := range existing {
+		if profile == nil {
+			continue
+		}
+		profiles[name] = &containerservice.ManagedClusterAddonProfile{
+			Enabled: profile.Enabled,
+			Config:  profile.Config,
+		}
+	}
+
+	for _, addon := range addons {
+		name := addon.Name
+		for existingName := range profiles {
+			if strings.EqualFold(existingName, addon.Name) {
+				name = existingName
+				break
+			}
+		}
+		profiles[name] = &containerservice.ManagedClusterAddonProfile{
+			Enabled: to.BoolPtr(addon.Enabled),
+			Config:  *to.StringMapPtr(addon.Config),
+		}
+	}
+
+	if len(profiles) == 0 {
+		return nil
+	}
+	return profiles
+}
+
+// getLoadBalancerProfile converts a load balancer profile to its AKS representation. AKS only allows one source of
+// outbound IPs.
+func getLoadBalancerProfile(profile *LoadBalancerProfile) (*containerservice.ManagedClusterLoadBalancerProfile, error) {
+	sources := 0
+	loadBalancerProfile := &containerservice.ManagedClusterLoadBalancerProfile{
+		AllocatedOutboundPorts: profile.AllocatedOutboundPorts,
+		IdleTimeoutInMinutes:   profile.IdleTimeoutInMinutes,
+	}
+	if profile.ManagedOutboundIPs != nil {
+		sources++
+		loadBalancerProfile.ManagedOutboundIPs = &containerservice.ManagedClusterLoadBalancerProfileManagedOutboundIPs{
+			Count: profile.ManagedOutboundIPs,
+		}
+	}
+	if len(profile.OutboundIPs) > 0 {
+		sources++
+		loadBalancerProfile.OutboundIPs = &containerservice.ManagedClusterLoadBalancerProfileOutboundIPs{
+			PublicIPs: toResourceReferences(profile.OutboundIPs),
+		}
+	}
+	if len(profile.OutboundIPPrefixes) > 0 {
+		sources++
+		loadBalancerProfile.OutboundIPPrefixes = &containerservice.ManagedClusterLoadBalancerProfileOutboundIPPrefixes{
+			PublicIPPrefixes: toResourceReferences(profile.OutboundIPPrefixes),
+		}
+	}
+	if sources > 1 {
+		return nil, errors.New("load balancer profile can only have one of managed outbound IPs, outbound IPs and outbound IP prefixes")
+	}
+	return loadBalancerProfile, nil
+}
+
+func toResourceReferences(ids []string) *[]containerservice.ResourceReference {
+	references := make([]containerservice.ResourceReference, len(ids))
+	for i := range ids {
+		references[i] = containerservice.ResourceReference{ID: to.StringPtr(ids[i])}
+	}
+	return &references
+}
+
+// validateAPIServerAccessProfile checks the authorized IP ranges of the API server, which AKS doesn't support
+// for private clusters, and the private DNS zone, which only private clusters have.
+func validateAPIServerAccessProfile(profile *APIServerAccessProfile) error {
+	privateCluster := profile.EnablePrivateCluster != nil && *profile.EnablePrivateCluster
+	if privateCluster && len(profile.AuthorizedIPRanges) > 0 {
+		return errors.New("authorized IP ranges are not supported for private clusters")
+	}
+	if profile.PrivateDNSZone != nil {
+		if !privateCluster {
+			return errors.New("a private DNS zone is only supported for private clusters")
+		}
+		zone := *profile.PrivateDNSZone
+		if !strings.EqualFold(zone, privateDNSZoneSystem) && !strings.EqualFold(zone, privateDNSZoneNone) && !privateDNSZoneIDRegex.MatchString(zone) {
+			return fmt.Errorf("invalid private DNS zone: '%s'. Allowed options are 'System', 'None' and the resource ID of a private DNS zone", zone)
+		}
+	}
+	for _, ipRange := range profile.AuthorizedIPRanges {
+		if _, _, err := net.ParseCIDR(ipRange); err != nil {
+			return fmt.Errorf("invalid authorized IP range: '%s'. IP ranges must be in CIDR notation", ipRange)
+		}
+	}
+	return nil
+}
+
 // Delete deletes the virtual network with the provided name.
 func (s *Service) Delete(ctx context.Context, spec interface{}) error {
 	managedClusterSpec, ok := spec.(*Spec)
@@ -21,8 +21,9 @@ import (
 	"net/http"
 	"testing"
 
-	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2020-02-01/containerservice"
+	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2021-07-01/containerservice"
 	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/gomega"
 	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/managedclusters/mock_managedclusters"
@@ -138,3 +139,1159 @@ func TestReconcile(t *testing.T) {
 	}
 
 }
+
+func TestReconcileKeepsAgentPoolVersions(t *testing.T) {
+	g := NewWithT(t)
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	managedclusterMock := mock_managedclusters.NewMockClient(mockCtrl)
+
+	existingPools := []containerservice.ManagedClusterAgentPoolProfile{
+		{
+			Name:                to.StringPtr("pool0"),
+			OrchestratorVersion: to.StringPtr("1.16.9"),
+		},
+	}
+	managedclusterMock.EXPECT().Get(context.TODO(), "my-rg", "my-managedcluster").Return(containerservice.ManagedCluster{ManagedClusterProperties: &containerservice.ManagedClusterProperties{
+		ProvisioningState: to.StringPtr("Succeeded"),
+		KubernetesVersion: to.StringPtr("1.16.9"),
+		AgentPoolProfiles: &existingPools,
+	}}, nil)
+	managedclusterMock.EXPECT().CreateOrUpdate(context.TODO(), "my-rg", "my-managedcluster", gomock.Any()).
+		DoAndReturn(func(_ context.Context, _, _ string, cluster containerservice.ManagedCluster) error {
+			g.Expect(*cluster.KubernetesVersion).To(Equal("1.17.7"))
+			g.Expect(*cluster.AgentPoolProfiles).To(Equal(existingPools))
+			return nil
+		})
+
+	s := &Service{
+		Client: managedclusterMock,
+	}
+
+	err := s.Reconcile(context.TODO(), &Spec{
+		Name:          "my-managedcluster",
+		ResourceGroup: "my-rg",
+		Version:       "1.17.7",
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+}
+
+func TestGetAvailableUpgrades(t *testing.T) {
+	testcases := []struct {
+		name          string
+		expectedError string
+		expected      []string
+		expect        func(m *mock_managedclusters.MockClientMockRecorder)
+	}{
+		{
+			name:     "returns control plane upgrades",
+			expected: []string{"1.17.7", "1.17.9"},
+			expect: func(m *mock_managedclusters.MockClientMockRecorder) {
+				m.GetUpgradeProfile(context.TODO(), "my-rg", "my-managedcluster").Return(containerservice.ManagedClusterUpgradeProfile{
+					ManagedClusterUpgradeProfileProperties: &containerservice.ManagedClusterUpgradeProfileProperties{
+						ControlPlaneProfile: &containerservice.ManagedClusterPoolUpgradeProfile{
+							KubernetesVersion: to.StringPtr("1.16.9"),
+							Upgrades: &[]containerservice.ManagedClusterPoolUpgradeProfileUpgradesItem{
+								{KubernetesVersion: to.StringPtr("1.17.7")},
+								{KubernetesVersion: to.StringPtr("1.17.9"), IsPreview: to.BoolPtr(true)},
+							},
+						},
+					},
+				}, nil)
+			},
+		},
+		{
+			name:     "no upgrades available",
+			expected: nil,
+			expect: func(m *mock_managedclusters.MockClientMockRecorder) {
+				m.GetUpgradeProfile(context.TODO(), "my-rg", "my-managedcluster").Return(containerservice.ManagedClusterUpgradeProfile{
+					ManagedClusterUpgradeProfileProperties: &containerservice.ManagedClusterUpgradeProfileProperties{
+						ControlPlaneProfile: &containerservice.ManagedClusterPoolUpgradeProfile{
+							KubernetesVersion: to.StringPtr("1.18.4"),
+						},
+					},
+				}, nil)
+			},
+		},
+		{
+			name:          "fails to get upgrade profile",
+			expectedError: "failed to get upgrade profile for managed cluster my-managedcluster: #: Internal Server Error: StatusCode=500",
+			expect: func(m *mock_managedclusters.MockClientMockRecorder) {
+				m.GetUpgradeProfile(context.TODO(), "my-rg", "my-managedcluster").Return(containerservice.ManagedClusterUpgradeProfile{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"))
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			managedclusterMock := mock_managedclusters.NewMockClient(mockCtrl)
+
+			tc.expect(managedclusterMock.EXPECT())
+
+			s := &Service{
+				Client: managedclusterMock,
+			}
+
+			versions, err := s.GetAvailableUpgrades(context.TODO(), "my-rg", "my-managedcluster")
+			if tc.expectedError != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err).To(MatchError(tc.expectedError))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(versions).To(Equal(tc.expected))
+			}
+		})
+	}
+}
+
+func TestReconcileAPIServerAccessProfile(t *testing.T) {
+	testcases := []struct {
+		name          string
+		profile       *APIServerAccessProfile
+		expectedError string
+		expected      *containerservice.ManagedClusterAPIServerAccessProfile
+	}{
+		{
+			name: "private cluster",
+			profile: &APIServerAccessProfile{
+				EnablePrivateCluster: to.BoolPtr(true),
+			},
+			expected: &containerservice.ManagedClusterAPIServerAccessProfile{
+				AuthorizedIPRanges:   &[]string{},
+				EnablePrivateCluster: to.BoolPtr(true),
+			},
+		},
+		{
+			name: "authorized IP ranges",
+			profile: &APIServerAccessProfile{
+				AuthorizedIPRanges: []string{"73.140.245.0/24", "20.43.21.7/32"},
+			},
+			expected: &containerservice.ManagedClusterAPIServerAccessProfile{
+				AuthorizedIPRanges: &[]string{"73.140.245.0/24", "20.43.21.7/32"},
+			},
+		},
+		{
+			name: "authorized IP ranges for private cluster",
+			profile: &APIServerAccessProfile{
+				AuthorizedIPRanges:   []string{"73.140.245.0/24"},
+				EnablePrivateCluster: to.BoolPtr(true),
+			},
+			expectedError: "authorized IP ranges are not supported for private clusters",
+		},
+		{
+			name: "invalid authorized IP range",
+			profile: &APIServerAccessProfile{
+				AuthorizedIPRanges: []string{"73.140.245.0"},
+			},
+			expectedError: "invalid authorized IP range: '73.140.245.0'. IP ranges must be in CIDR notation",
+		},
+		{
+			name: "private DNS zone for public cluster",
+			profile: &APIServerAccessProfile{
+				PrivateDNSZone: to.StringPtr("System"),
+			},
+			expectedError: "a private DNS zone is only supported for private clusters",
+		},
+		{
+			name: "invalid private DNS zone",
+			profile: &APIServerAccessProfile{
+				EnablePrivateCluster: to.BoolPtr(true),
+				PrivateDNSZone:       to.StringPtr("privatelink.westus2.azmk8s.io"),
+			},
+			expectedError: "invalid private DNS zone: 'privatelink.westus2.azmk8s.io'. Allowed options are 'System', 'None' and the resource ID of a private DNS zone",
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			managedclusterMock := mock_managedclusters.NewMockClient(mockCtrl)
+
+			if tc.expectedError == "" {
+				managedclusterMock.EXPECT().Get(context.TODO(), "my-rg", "my-managedcluster").Return(containerservice.ManagedCluster{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not Found"))
+				managedclusterMock.EXPECT().CreateOrUpdate(context.TODO(), "my-rg", "my-managedcluster", gomock.Any()).
+					DoAndReturn(func(_ context.Context, _, _ string, cluster containerservice.ManagedCluster) error {
+						g.Expect(cluster.APIServerAccessProfile).To(Equal(tc.expected))
+						return nil
+					})
+			}
+
+			s := &Service{
+				Client: managedclusterMock,
+			}
+
+			err := s.Reconcile(context.TODO(), &Spec{
+				Name:                   "my-managedcluster",
+				ResourceGroup:          "my-rg",
+				APIServerAccessProfile: tc.profile,
+			})
+			if tc.expectedError != "" {
+				g.Expect(err).To(MatchError(tc.expectedError))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestReconcilePrivateDNSZone(t *testing.T) {
+	zoneID := "/subscriptions/123/resourceGroups/dns-rg/providers/Microsoft.Network/privateDnsZones/privatelink.westus2.azmk8s.io"
+	testcases := []struct {
+		name          string
+		zone          string
+		expect        func(g *WithT, m *mock_managedclusters.MockClientMockRecorder)
+		expectedError string
+	}{
+		{
+			name: "sent when the cluster is created",
+			zone: zoneID,
+			expect: func(g *WithT, m *mock_managedclusters.MockClientMockRecorder) {
+				m.Get(context.TODO(), "my-rg", "my-managedcluster").Return(containerservice.ManagedCluster{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not Found"))
+				m.CreateOrUpdate(context.TODO(), "my-rg", "my-managedcluster", gomock.Any()).DoAndReturn(func(_ context.Context, _, _ string, cluster containerservice.ManagedCluster) error {
+					g.Expect(*cluster.APIServerAccessProfile.EnablePrivateCluster).To(BeTrue())
+					g.Expect(to.String(cluster.APIServerAccessProfile.PrivateDNSZone)).To(Equal(zoneID))
+					return nil
+				})
+			},
+		},
+		{
+			name: "existing zone kept when the cluster exists",
+			zone: "system",
+			expect: func(g *WithT, m *mock_managedclusters.MockClientMockRecorder) {
+				m.Get(context.TODO(), "my-rg", "my-managedcluster").Return(containerservice.ManagedCluster{ManagedClusterProperties: &containerservice.ManagedClusterProperties{
+					ProvisioningState: to.StringPtr("Succeeded"),
+					APIServerAccessProfile: &containerservice.ManagedClusterAPIServerAccessProfile{
+						EnablePrivateCluster: to.BoolPtr(true),
+						PrivateDNSZone:       to.StringPtr("System"),
+					},
+				}}, nil)
+				m.CreateOrUpdate(context.TODO(), "my-rg", "my-managedcluster", gomock.Any()).DoAndReturn(func(_ context.Context, _, _ string, cluster containerservice.ManagedCluster) error {
+					g.Expect(to.String(cluster.APIServerAccessProfile.PrivateDNSZone)).To(Equal("System"))
+					return nil
+				})
+			},
+		},
+		{
+			name: "changed zone refused when the cluster exists",
+			zone: zoneID,
+			expect: func(g *WithT, m *mock_managedclusters.MockClientMockRecorder) {
+				m.Get(context.TODO(), "my-rg", "my-managedcluster").Return(containerservice.ManagedCluster{ManagedClusterProperties: &containerservice.ManagedClusterProperties{
+					ProvisioningState: to.StringPtr("Succeeded"),
+					APIServerAccessProfile: &containerservice.ManagedClusterAPIServerAccessProfile{
+						EnablePrivateCluster: to.BoolPtr(true),
+						PrivateDNSZone:       to.StringPtr("System"),
+					},
+				}}, nil)
+			},
+			expectedError: "the private DNS zone of managed cluster my-managedcluster cannot be changed from System to " + zoneID,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			managedclusterMock := mock_managedclusters.NewMockClient(mockCtrl)
+
+			tc.expect(g, managedclusterMock.EXPECT())
+
+			s := &Service{
+				Client: managedclusterMock,
+			}
+
+			err := s.Reconcile(context.TODO(), &Spec{
+				Name:          "my-managedcluster",
+				ResourceGroup: "my-rg",
+				APIServerAccessProfile: &APIServerAccessProfile{
+					EnablePrivateCluster: to.BoolPtr(true),
+					PrivateDNSZone:       to.StringPtr(tc.zone),
+				},
+			})
+			if tc.expectedError != "" {
+				g.Expect(err).To(MatchError(tc.expectedError))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestReconcileIdentity(t *testing.T) {
+	controlPlaneIdentityID := "/subscriptions/123/resourceGroups/identity-rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/control-plane"
+	kubeletIdentityID := "/subscriptions/123/resourceGroups/identity-rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/kubelet"
+	expectControlPlaneIdentity := func(g *WithT, cluster containerservice.ManagedCluster) {
+		g.Expect(cluster.Identity.Type).To(Equal(containerservice.ResourceIdentityTypeUserAssigned))
+		g.Expect(cluster.Identity.UserAssignedIdentities).To(HaveKey(controlPlaneIdentityID))
+	}
+	testcases := []struct {
+		name    string
+		kubelet bool
+		expect  func(g *WithT, m *mock_managedclusters.MockClientMockRecorder)
+	}{
+		{
+			name:    "kubelet identity sent when the cluster is created",
+			kubelet: true,
+			expect: func(g *WithT, m *mock_managedclusters.MockClientMockRecorder) {
+				m.Get(context.TODO(), "my-rg", "my-managedcluster").Return(containerservice.ManagedCluster{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not Found"))
+				m.CreateOrUpdate(context.TODO(), "my-rg", "my-managedcluster", gomock.Any()).DoAndReturn(func(_ context.Context, _, _ string, cluster containerservice.ManagedCluster) error {
+					expectControlPlaneIdentity(g, cluster)
+					g.Expect(cluster.IdentityProfile).To(HaveKey("kubeletidentity"))
+					g.Expect(to.String(cluster.IdentityProfile["kubeletidentity"].ResourceID)).To(Equal(kubeletIdentityID))
+					g.Expect(to.String(cluster.IdentityProfile["kubeletidentity"].ObjectID)).To(Equal("kubelet-principal-id"))
+					return nil
+				})
+			},
+		},
+		{
+			name:    "existing kubelet identity kept when the cluster exists",
+			kubelet: true,
+			expect: func(g *WithT, m *mock_managedclusters.MockClientMockRecorder) {
+				existing := map[string]*containerservice.UserAssignedIdentity{
+					"kubeletidentity": {ResourceID: to.StringPtr("aks-created-identity")},
+				}
+				m.Get(context.TODO(), "my-rg", "my-managedcluster").Return(containerservice.ManagedCluster{ManagedClusterProperties: &containerservice.ManagedClusterProperties{
+					ProvisioningState: to.StringPtr("Succeeded"),
+					IdentityProfile:   existing,
+				}}, nil)
+				m.CreateOrUpdate(context.TODO(), "my-rg", "my-managedcluster", gomock.Any()).DoAndReturn(func(_ context.Context, _, _ string, cluster containerservice.ManagedCluster) error {
+					expectControlPlaneIdentity(g, cluster)
+					g.Expect(cluster.IdentityProfile).To(Equal(existing))
+					return nil
+				})
+			},
+		},
+		{
+			name: "control plane identity sent without a kubelet identity",
+			expect: func(g *WithT, m *mock_managedclusters.MockClientMockRecorder) {
+				m.Get(context.TODO(), "my-rg", "my-managedcluster").Return(containerservice.ManagedCluster{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not Found"))
+				m.CreateOrUpdate(context.TODO(), "my-rg", "my-managedcluster", gomock.Any()).DoAndReturn(func(_ context.Context, _, _ string, cluster containerservice.ManagedCluster) error {
+					expectControlPlaneIdentity(g, cluster)
+					g.Expect(cluster.IdentityProfile).To(BeNil())
+					return nil
+				})
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			managedclusterMock := mock_managedclusters.NewMockClient(mockCtrl)
+
+			tc.expect(g, managedclusterMock.EXPECT())
+
+			s := &Service{
+				Client: managedclusterMock,
+			}
+
+			spec := &Spec{
+				Name:                   "my-managedcluster",
+				ResourceGroup:          "my-rg",
+				ControlPlaneIdentityID: controlPlaneIdentityID,
+			}
+			if tc.kubelet {
+				spec.KubeletIdentity = &containerservice.UserAssignedIdentity{
+					ResourceID: to.StringPtr(kubeletIdentityID),
+					ClientID:   to.StringPtr("kubelet-client-id"),
+					ObjectID:   to.StringPtr("kubelet-principal-id"),
+				}
+			}
+			g.Expect(s.Reconcile(context.TODO(), spec)).To(Succeed())
+		})
+	}
+
+	t.Run("kubelet identity requires a control plane identity", func(t *testing.T) {
+		g := NewWithT(t)
+		s := &Service{}
+		err := s.Reconcile(context.TODO(), &Spec{
+			Name:            "my-managedcluster",
+			ResourceGroup:   "my-rg",
+			KubeletIdentity: &containerservice.UserAssignedIdentity{ResourceID: to.StringPtr(kubeletIdentityID)},
+		})
+		g.Expect(err).To(MatchError("a user-assigned kubelet identity requires a user-assigned control plane identity"))
+	})
+}
+
+func TestReconcileAutoUpgradeChannel(t *testing.T) {
+	testcases := []struct {
+		name            string
+		channel         *string
+		existing        *containerservice.ManagedCluster
+		expectedChannel containerservice.UpgradeChannel
+	}{
+		{
+			name:            "sent when the cluster is created",
+			channel:         to.StringPtr("stable"),
+			expectedChannel: containerservice.UpgradeChannelStable,
+		},
+		{
+			name:    "sent when the cluster exists",
+			channel: to.StringPtr("stable"),
+			existing: &containerservice.ManagedCluster{ManagedClusterProperties: &containerservice.ManagedClusterProperties{
+				ProvisioningState: to.StringPtr("Succeeded"),
+				AutoUpgradeProfile: &containerservice.ManagedClusterAutoUpgradeProfile{
+					UpgradeChannel: containerservice.UpgradeChannelRapid,
+				},
+			}},
+			expectedChannel: containerservice.UpgradeChannelStable,
+		},
+		{
+			name: "existing channel kept when none is declared",
+			existing: &containerservice.ManagedCluster{ManagedClusterProperties: &containerservice.ManagedClusterProperties{
+				ProvisioningState: to.StringPtr("Succeeded"),
+				AutoUpgradeProfile: &containerservice.ManagedClusterAutoUpgradeProfile{
+					UpgradeChannel: containerservice.UpgradeChannelRapid,
+				},
+			}},
+			expectedChannel: containerservice.UpgradeChannelRapid,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			managedclusterMock := mock_managedclusters.NewMockClient(mockCtrl)
+
+			if tc.existing != nil {
+				managedclusterMock.EXPECT().Get(context.TODO(), "my-rg", "my-managedcluster").Return(*tc.existing, nil)
+			} else {
+				managedclusterMock.EXPECT().Get(context.TODO(), "my-rg", "my-managedcluster").Return(containerservice.ManagedCluster{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not Found"))
+			}
+			managedclusterMock.EXPECT().CreateOrUpdate(context.TODO(), "my-rg", "my-managedcluster", gomock.Any()).DoAndReturn(func(_ context.Context, _, _ string, cluster containerservice.ManagedCluster) error {
+				g.Expect(cluster.AutoUpgradeProfile.UpgradeChannel).To(Equal(tc.expectedChannel))
+				return nil
+			})
+
+			s := &Service{
+				Client: managedclusterMock,
+			}
+
+			err := s.Reconcile(context.TODO(), &Spec{
+				Name:               "my-managedcluster",
+				ResourceGroup:      "my-rg",
+				AutoUpgradeChannel: tc.channel,
+			})
+			g.Expect(err).NotTo(HaveOccurred())
+		})
+	}
+}
+
+func TestReconcileAADProfile(t *testing.T) {
+	testcases := []struct {
+		name          string
+		aadProfile    *AADProfile
+		enableRBAC    *bool
+		expectedError string
+		expected      *containerservice.ManagedClusterAADProfile
+	}{
+		{
+			name:     "no AAD integration",
+			expected: nil,
+		},
+		{
+			name: "managed AAD integration",
+			aadProfile: &AADProfile{
+				AdminGroupObjectIDs: []string{"917056a9-8eb5-439c-g679-b34901ade75h"},
+				TenantID:            "a1b2c3d4-0000-0000-0000-000000000000",
+			},
+			expected: &containerservice.ManagedClusterAADProfile{
+				Managed:             to.BoolPtr(true),
+				AdminGroupObjectIDs: &[]string{"917056a9-8eb5-439c-g679-b34901ade75h"},
+				TenantID:            to.StringPtr("a1b2c3d4-0000-0000-0000-000000000000"),
+			},
+		},
+		{
+			name: "managed AAD integration with Azure RBAC",
+			aadProfile: &AADProfile{
+				EnableAzureRBAC: to.BoolPtr(true),
+			},
+			expected: &containerservice.ManagedClusterAADProfile{
+				Managed:             to.BoolPtr(true),
+				AdminGroupObjectIDs: &[]string{},
+				EnableAzureRBAC:     to.BoolPtr(true),
+			},
+		},
+		{
+			name:          "managed AAD integration without RBAC",
+			aadProfile:    &AADProfile{},
+			enableRBAC:    to.BoolPtr(false),
+			expectedError: "Azure AD integration requires Kubernetes RBAC to be enabled",
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			managedclusterMock := mock_managedclusters.NewMockClient(mockCtrl)
+
+			if tc.expectedError == "" {
+				managedclusterMock.EXPECT().Get(context.TODO(), "my-rg", "my-managedcluster").Return(containerservice.ManagedCluster{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not Found"))
+				managedclusterMock.EXPECT().CreateOrUpdate(context.TODO(), "my-rg", "my-managedcluster", gomock.Any()).
+					DoAndReturn(func(_ context.Context, _, _ string, cluster containerservice.ManagedCluster) error {
+						g.Expect(cluster.AadProfile).To(Equal(tc.expected))
+						return nil
+					})
+			}
+
+			s := &Service{
+				Client: managedclusterMock,
+			}
+
+			err := s.Reconcile(context.TODO(), &Spec{
+				Name:          "my-managedcluster",
+				ResourceGroup: "my-rg",
+				AADProfile:    tc.aadProfile,
+				EnableRBAC:    tc.enableRBAC,
+			})
+			if tc.expectedError != "" {
+				g.Expect(err).To(MatchError(tc.expectedError))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestReconcileLocalAccounts(t *testing.T) {
+	testcases := []struct {
+		name                 string
+		aadProfile           *AADProfile
+		disableLocalAccounts *bool
+		existing             *containerservice.ManagedCluster
+		expectedError        string
+		expected             *bool
+		expectedAzureRBAC    *bool
+	}{
+		{
+			name:                 "disabled on a new cluster",
+			aadProfile:           &AADProfile{},
+			disableLocalAccounts: to.BoolPtr(true),
+			expected:             to.BoolPtr(true),
+		},
+		{
+			name:       "kept as they are when not declared",
+			aadProfile: &AADProfile{},
+			existing: &containerservice.ManagedCluster{ManagedClusterProperties: &containerservice.ManagedClusterProperties{
+				ProvisioningState:    to.StringPtr("Succeeded"),
+				DisableLocalAccounts: to.BoolPtr(true),
+				AadProfile: &containerservice.ManagedClusterAADProfile{
+					Managed:         to.BoolPtr(true),
+					EnableAzureRBAC: to.BoolPtr(true),
+				},
+			}},
+			expected:          to.BoolPtr(true),
+			expectedAzureRBAC: to.BoolPtr(true),
+		},
+		{
+			name:                 "disabled without AAD integration",
+			disableLocalAccounts: to.BoolPtr(true),
+			expectedError:        "disabling local accounts requires Azure AD integration",
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			managedclusterMock := mock_managedclusters.NewMockClient(mockCtrl)
+
+			if tc.expectedError == "" {
+				if tc.existing != nil {
+					managedclusterMock.EXPECT().Get(context.TODO(), "my-rg", "my-managedcluster").Return(*tc.existing, nil)
+				} else {
+					managedclusterMock.EXPECT().Get(context.TODO(), "my-rg", "my-managedcluster").Return(containerservice.ManagedCluster{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not Found"))
+				}
+				managedclusterMock.EXPECT().CreateOrUpdate(context.TODO(), "my-rg", "my-managedcluster", gomock.Any()).
+					DoAndReturn(func(_ context.Context, _, _ string, cluster containerservice.ManagedCluster) error {
+						g.Expect(cluster.DisableLocalAccounts).To(Equal(tc.expected))
+						g.Expect(cluster.AadProfile.EnableAzureRBAC).To(Equal(tc.expectedAzureRBAC))
+						return nil
+					})
+			}
+
+			s := &Service{
+				Client: managedclusterMock,
+			}
+
+			err := s.Reconcile(context.TODO(), &Spec{
+				Name:                 "my-managedcluster",
+				ResourceGroup:        "my-rg",
+				AADProfile:           tc.aadProfile,
+				DisableLocalAccounts: tc.disableLocalAccounts,
+			})
+			if tc.expectedError != "" {
+				g.Expect(err).To(MatchError(tc.expectedError))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestReconcileAddonProfiles(t *testing.T) {
+	g := NewWithT(t)
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	managedclusterMock := mock_managedclusters.NewMockClient(mockCtrl)
+
+	managedclusterMock.EXPECT().Get(context.TODO(), "my-rg", "my-managedcluster").Return(containerservice.ManagedCluster{ManagedClusterProperties: &containerservice.ManagedClusterProperties{
+		ProvisioningState: to.StringPtr("Succeeded"),
+		AddonProfiles: map[string]*containerservice.ManagedClusterAddonProfile{
+			"azurepolicy": {
+				Enabled: to.BoolPtr(true),
+				Config:  map[string]*string{},
+				Identity: &containerservice.ManagedClusterAddonProfileIdentity{
+					ClientID: to.StringPtr("00000000-0000-0000-0000-000000000000"),
+				},
+			},
+			"omsAgent": {
+				Enabled: to.BoolPtr(true),
+				Config:  map[string]*string{"logAnalyticsWorkspaceResourceID": to.StringPtr("old-workspace")},
+			},
+		},
+	}}, nil)
+	managedclusterMock.EXPECT().CreateOrUpdate(context.TODO(), "my-rg", "my-managedcluster", gomock.Any()).
+		DoAndReturn(func(_ context.Context, _, _ string, cluster containerservice.ManagedCluster) error {
+			g.Expect(cluster.AddonProfiles).To(Equal(map[string]*containerservice.ManagedClusterAddonProfile{
+				"azurepolicy": {
+					Enabled: to.BoolPtr(true),
+					Config:  map[string]*string{},
+				},
+				"omsAgent": {
+					Enabled: to.BoolPtr(true),
+					Config:  map[string]*string{"logAnalyticsWorkspaceResourceID": to.StringPtr("new-workspace")},
+				},
+				"ingressApplicationGateway": {
+					Enabled: to.BoolPtr(false),
+					Config:  map[string]*string{},
+				},
+			}))
+			return nil
+		})
+
+	s := &Service{
+		Client: managedclusterMock,
+	}
+
+	err := s.Reconcile(context.TODO(), &Spec{
+		Name:          "my-managedcluster",
+		ResourceGroup: "my-rg",
+		AddonProfiles: []AddonProfile{
+			{
+				Name:    "omsagent",
+				Enabled: true,
+				Config:  map[string]string{"logAnalyticsWorkspaceResourceID": "new-workspace"},
+			},
+			{
+				Name:    "ingressApplicationGateway",
+				Enabled: false,
+			},
+		},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+}
+
+func TestReconcileTags(t *testing.T) {
+	testcases := []struct {
+		name     string
+		existing *containerservice.ManagedCluster
+		expected map[string]*string
+	}{
+		{
+			name: "tags on create",
+			expected: map[string]*string{
+				"env": to.StringPtr("prod"),
+			},
+		},
+		{
+			name: "existing tags are kept",
+			existing: &containerservice.ManagedCluster{
+				Tags: map[string]*string{
+					"env":   to.StringPtr("dev"),
+					"owner": to.StringPtr("team-a"),
+				},
+				ManagedClusterProperties: &containerservice.ManagedClusterProperties{
+					ProvisioningState: to.StringPtr("Succeeded"),
+				},
+			},
+			expected: map[string]*string{
+				"env":   to.StringPtr("prod"),
+				"owner": to.StringPtr("team-a"),
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			managedclusterMock := mock_managedclusters.NewMockClient(mockCtrl)
+
+			if tc.existing != nil {
+				managedclusterMock.EXPECT().Get(context.TODO(), "my-rg", "my-managedcluster").Return(*tc.existing, nil)
+			} else {
+				managedclusterMock.EXPECT().Get(context.TODO(), "my-rg", "my-managedcluster").Return(containerservice.ManagedCluster{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not Found"))
+			}
+			managedclusterMock.EXPECT().CreateOrUpdate(context.TODO(), "my-rg", "my-managedcluster", gomock.Any()).
+				DoAndReturn(func(_ context.Context, _, _ string, cluster containerservice.ManagedCluster) error {
+					g.Expect(cluster.Tags).To(Equal(tc.expected))
+					return nil
+				})
+
+			s := &Service{
+				Client: managedclusterMock,
+			}
+
+			err := s.Reconcile(context.TODO(), &Spec{
+				Name:          "my-managedcluster",
+				ResourceGroup: "my-rg",
+				Tags:          map[string]string{"env": "prod"},
+			})
+			g.Expect(err).NotTo(HaveOccurred())
+		})
+	}
+}
+
+func TestReconcileNodeResourceGroup(t *testing.T) {
+	testcases := []struct {
+		name     string
+		existing *containerservice.ManagedCluster
+		expected *string
+	}{
+		{
+			name:     "custom node resource group on create",
+			expected: to.StringPtr("my-node-rg"),
+		},
+		{
+			name: "existing node resource group is kept",
+			existing: &containerservice.ManagedCluster{ManagedClusterProperties: &containerservice.ManagedClusterProperties{
+				ProvisioningState: to.StringPtr("Succeeded"),
+				NodeResourceGroup: to.StringPtr("MC_my-rg_my-managedcluster_westus2"),
+			}},
+			expected: to.StringPtr("MC_my-rg_my-managedcluster_westus2"),
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			managedclusterMock := mock_managedclusters.NewMockClient(mockCtrl)
+
+			if tc.existing != nil {
+				managedclusterMock.EXPECT().Get(context.TODO(), "my-rg", "my-managedcluster").Return(*tc.existing, nil)
+			} else {
+				managedclusterMock.EXPECT().Get(context.TODO(), "my-rg", "my-managedcluster").Return(containerservice.ManagedCluster{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not Found"))
+			}
+			managedclusterMock.EXPECT().CreateOrUpdate(context.TODO(), "my-rg", "my-managedcluster", gomock.Any()).
+				DoAndReturn(func(_ context.Context, _, _ string, cluster containerservice.ManagedCluster) error {
+					g.Expect(cluster.NodeResourceGroup).To(Equal(tc.expected))
+					return nil
+				})
+
+			s := &Service{
+				Client: managedclusterMock,
+			}
+
+			err := s.Reconcile(context.TODO(), &Spec{
+				Name:              "my-managedcluster",
+				ResourceGroup:     "my-rg",
+				NodeResourceGroup: "my-node-rg",
+			})
+			g.Expect(err).NotTo(HaveOccurred())
+		})
+	}
+}
+
+func TestReconcileSKU(t *testing.T) {
+	testcases := []struct {
+		name     string
+		tier     string
+		existing *containerservice.ManagedCluster
+		expected *containerservice.ManagedClusterSKU
+	}{
+		{
+			name:     "paid tier on create",
+			tier:     "Paid",
+			expected: &containerservice.ManagedClusterSKU{Name: containerservice.ManagedClusterSKUNameBasic, Tier: containerservice.ManagedClusterSKUTierPaid},
+		},
+		{
+			name: "no tier on create",
+		},
+		{
+			name: "declared tier is restored",
+			tier: "Paid",
+			existing: &containerservice.ManagedCluster{
+				Sku: &containerservice.ManagedClusterSKU{Name: containerservice.ManagedClusterSKUNameBasic, Tier: containerservice.ManagedClusterSKUTierFree},
+				ManagedClusterProperties: &containerservice.ManagedClusterProperties{
+					ProvisioningState: to.StringPtr("Succeeded"),
+				},
+			},
+			expected: &containerservice.ManagedClusterSKU{Name: containerservice.ManagedClusterSKUNameBasic, Tier: containerservice.ManagedClusterSKUTierPaid},
+		},
+		{
+			name: "existing tier is kept",
+			existing: &containerservice.ManagedCluster{
+				Sku: &containerservice.ManagedClusterSKU{Name: containerservice.ManagedClusterSKUNameBasic, Tier: containerservice.ManagedClusterSKUTierPaid},
+				ManagedClusterProperties: &containerservice.ManagedClusterProperties{
+					ProvisioningState: to.StringPtr("Succeeded"),
+				},
+			},
+			expected: &containerservice.ManagedClusterSKU{Name: containerservice.ManagedClusterSKUNameBasic, Tier: containerservice.ManagedClusterSKUTierPaid},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			managedclusterMock := mock_managedclusters.NewMockClient(mockCtrl)
+
+			if tc.existing != nil {
+				managedclusterMock.EXPECT().Get(context.TODO(), "my-rg", "my-managedcluster").Return(*tc.existing, nil)
+			} else {
+				managedclusterMock.EXPECT().Get(context.TODO(), "my-rg", "my-managedcluster").Return(containerservice.ManagedCluster{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not Found"))
+			}
+			managedclusterMock.EXPECT().CreateOrUpdate(context.TODO(), "my-rg", "my-managedcluster", gomock.Any()).
+				DoAndReturn(func(_ context.Context, _, _ string, cluster containerservice.ManagedCluster) error {
+					g.Expect(cluster.Sku).To(Equal(tc.expected))
+					return nil
+				})
+
+			s := &Service{
+				Client: managedclusterMock,
+			}
+
+			err := s.Reconcile(context.TODO(), &Spec{
+				Name:          "my-managedcluster",
+				ResourceGroup: "my-rg",
+				SKUTier:       tc.tier,
+			})
+			g.Expect(err).NotTo(HaveOccurred())
+		})
+	}
+}
+
+func TestReconcileKeepsNetworkCIDRs(t *testing.T) {
+	g := NewWithT(t)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	managedclusterMock := mock_managedclusters.NewMockClient(mockCtrl)
+
+	managedclusterMock.EXPECT().Get(context.TODO(), "my-rg", "my-managedcluster").Return(containerservice.ManagedCluster{
+		ManagedClusterProperties: &containerservice.ManagedClusterProperties{
+			ProvisioningState: to.StringPtr("Succeeded"),
+			NetworkProfile: &containerservice.NetworkProfile{
+				PodCidr:          to.StringPtr("10.244.0.0/16"),
+				ServiceCidr:      to.StringPtr("10.0.0.0/16"),
+				DNSServiceIP:     to.StringPtr("10.0.0.10"),
+				DockerBridgeCidr: to.StringPtr("172.17.0.1/16"),
+			},
+		},
+	}, nil)
+	managedclusterMock.EXPECT().CreateOrUpdate(context.TODO(), "my-rg", "my-managedcluster", gomock.Any()).
+		DoAndReturn(func(_ context.Context, _, _ string, cluster containerservice.ManagedCluster) error {
+			g.Expect(cluster.NetworkProfile.PodCidr).To(Equal(to.StringPtr("10.244.0.0/16")))
+			g.Expect(cluster.NetworkProfile.ServiceCidr).To(Equal(to.StringPtr("10.0.0.0/16")))
+			g.Expect(cluster.NetworkProfile.DNSServiceIP).To(Equal(to.StringPtr("10.0.0.10")))
+			g.Expect(cluster.NetworkProfile.DockerBridgeCidr).To(Equal(to.StringPtr("172.17.0.1/16")))
+			return nil
+		})
+
+	s := &Service{
+		Client: managedclusterMock,
+	}
+
+	err := s.Reconcile(context.TODO(), &Spec{
+		Name:          "my-managedcluster",
+		ResourceGroup: "my-rg",
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+}
+
+func TestReconcileOutboundConnectivity(t *testing.T) {
+	testcases := []struct {
+		name                        string
+		outboundType                *string
+		loadBalancerProfile         *LoadBalancerProfile
+		natGatewayProfile           *NATGatewayProfile
+		existing                    *containerservice.ManagedCluster
+		expectedError               string
+		expectedOutboundType        containerservice.OutboundType
+		expectedLoadBalancerProfile *containerservice.ManagedClusterLoadBalancerProfile
+		expectedNATGatewayProfile   *containerservice.ManagedClusterNATGatewayProfile
+	}{
+		{
+			name:                 "user defined routing",
+			outboundType:         to.StringPtr("userDefinedRouting"),
+			expectedOutboundType: containerservice.OutboundTypeUserDefinedRouting,
+		},
+		{
+			name:         "managed NAT gateway",
+			outboundType: to.StringPtr("managedNATGateway"),
+			natGatewayProfile: &NATGatewayProfile{
+				ManagedOutboundIPs:   to.Int32Ptr(2),
+				IdleTimeoutInMinutes: to.Int32Ptr(10),
+			},
+			expectedOutboundType: containerservice.OutboundTypeManagedNATGateway,
+			expectedNATGatewayProfile: &containerservice.ManagedClusterNATGatewayProfile{
+				ManagedOutboundIPProfile: &containerservice.ManagedClusterManagedOutboundIPProfile{
+					Count: to.Int32Ptr(2),
+				},
+				IdleTimeoutInMinutes: to.Int32Ptr(10),
+			},
+		},
+		{
+			name: "existing NAT gateway profile is kept",
+			existing: &containerservice.ManagedCluster{ManagedClusterProperties: &containerservice.ManagedClusterProperties{
+				ProvisioningState: to.StringPtr("Succeeded"),
+				NetworkProfile: &containerservice.NetworkProfile{
+					OutboundType: containerservice.OutboundTypeManagedNATGateway,
+					NatGatewayProfile: &containerservice.ManagedClusterNATGatewayProfile{
+						ManagedOutboundIPProfile: &containerservice.ManagedClusterManagedOutboundIPProfile{
+							Count: to.Int32Ptr(1),
+						},
+						EffectiveOutboundIPs: &[]containerservice.ResourceReference{
+							{ID: to.StringPtr("/subscriptions/123/resourceGroups/MC_my-rg_my-managedcluster_westus2/providers/Microsoft.Network/publicIPAddresses/my-ip")},
+						},
+						IdleTimeoutInMinutes: to.Int32Ptr(4),
+					},
+				},
+			}},
+			expectedOutboundType: containerservice.OutboundTypeManagedNATGateway,
+			expectedNATGatewayProfile: &containerservice.ManagedClusterNATGatewayProfile{
+				ManagedOutboundIPProfile: &containerservice.ManagedClusterManagedOutboundIPProfile{
+					Count: to.Int32Ptr(1),
+				},
+				IdleTimeoutInMinutes: to.Int32Ptr(4),
+			},
+		},
+		{
+			name: "outbound IP prefixes",
+			loadBalancerProfile: &LoadBalancerProfile{
+				OutboundIPPrefixes:     []string{"/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/publicIPPrefixes/my-prefix"},
+				AllocatedOutboundPorts: to.Int32Ptr(1024),
+				IdleTimeoutInMinutes:   to.Int32Ptr(10),
+			},
+			expectedLoadBalancerProfile: &containerservice.ManagedClusterLoadBalancerProfile{
+				OutboundIPPrefixes: &containerservice.ManagedClusterLoadBalancerProfileOutboundIPPrefixes{
+					PublicIPPrefixes: &[]containerservice.ResourceReference{
+						{ID: to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/publicIPPrefixes/my-prefix")},
+					},
+				},
+				AllocatedOutboundPorts: to.Int32Ptr(1024),
+				IdleTimeoutInMinutes:   to.Int32Ptr(10),
+			},
+		},
+		{
+			name: "existing outbound type and load balancer profile are kept",
+			existing: &containerservice.ManagedCluster{ManagedClusterProperties: &containerservice.ManagedClusterProperties{
+				ProvisioningState: to.StringPtr("Succeeded"),
+				NetworkProfile: &containerservice.NetworkProfile{
+					OutboundType: containerservice.OutboundTypeLoadBalancer,
+					LoadBalancerProfile: &containerservice.ManagedClusterLoadBalancerProfile{
+						ManagedOutboundIPs: &containerservice.ManagedClusterLoadBalancerProfileManagedOutboundIPs{
+							Count: to.Int32Ptr(1),
+						},
+						EffectiveOutboundIPs: &[]containerservice.ResourceReference{
+							{ID: to.StringPtr("/subscriptions/123/resourceGroups/MC_my-rg_my-managedcluster_westus2/providers/Microsoft.Network/publicIPAddresses/my-ip")},
+						},
+					},
+				},
+			}},
+			expectedOutboundType: containerservice.OutboundTypeLoadBalancer,
+			expectedLoadBalancerProfile: &containerservice.ManagedClusterLoadBalancerProfile{
+				ManagedOutboundIPs: &containerservice.ManagedClusterLoadBalancerProfileManagedOutboundIPs{
+					Count: to.Int32Ptr(1),
+				},
+			},
+		},
+		{
+			name: "more than one source of outbound IPs",
+			loadBalancerProfile: &LoadBalancerProfile{
+				ManagedOutboundIPs: to.Int32Ptr(2),
+				OutboundIPs:        []string{"/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/publicIPAddresses/my-ip"},
+			},
+			expectedError: "load balancer profile can only have one of managed outbound IPs, outbound IPs and outbound IP prefixes",
+		},
+		{
+			name:         "load balancer profile with user defined routing",
+			outboundType: to.StringPtr("userDefinedRouting"),
+			loadBalancerProfile: &LoadBalancerProfile{
+				IdleTimeoutInMinutes: to.Int32Ptr(10),
+			},
+			expectedError: "a load balancer profile is not supported with the userDefinedRouting outbound type",
+		},
+		{
+			name:         "load balancer profile with a managed NAT gateway",
+			outboundType: to.StringPtr("managedNATGateway"),
+			loadBalancerProfile: &LoadBalancerProfile{
+				IdleTimeoutInMinutes: to.Int32Ptr(10),
+			},
+			expectedError: "a load balancer profile is not supported with the managedNATGateway outbound type",
+		},
+		{
+			name: "NAT gateway profile with a load balancer",
+			natGatewayProfile: &NATGatewayProfile{
+				ManagedOutboundIPs: to.Int32Ptr(2),
+			},
+			expectedError: "a NAT gateway profile is only supported with the managedNATGateway outbound type",
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			managedclusterMock := mock_managedclusters.NewMockClient(mockCtrl)
+
+			if tc.expectedError == "" {
+				if tc.existing != nil {
+					managedclusterMock.EXPECT().Get(context.TODO(), "my-rg", "my-managedcluster").Return(*tc.existing, nil)
+				} else {
+					managedclusterMock.EXPECT().Get(context.TODO(), "my-rg", "my-managedcluster").Return(containerservice.ManagedCluster{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not Found"))
+				}
+				managedclusterMock.EXPECT().CreateOrUpdate(context.TODO(), "my-rg", "my-managedcluster", gomock.Any()).
+					DoAndReturn(func(_ context.Context, _, _ string, cluster containerservice.ManagedCluster) error {
+						g.Expect(cluster.NetworkProfile.OutboundType).To(Equal(tc.expectedOutboundType))
+						g.Expect(cluster.NetworkProfile.LoadBalancerProfile).To(Equal(tc.expectedLoadBalancerProfile))
+						g.Expect(cluster.NetworkProfile.NatGatewayProfile).To(Equal(tc.expectedNATGatewayProfile))
+						return nil
+					})
+			}
+
+			s := &Service{
+				Client: managedclusterMock,
+			}
+
+			err := s.Reconcile(context.TODO(), &Spec{
+				Name:                "my-managedcluster",
+				ResourceGroup:       "my-rg",
+				OutboundType:        tc.outboundType,
+				LoadBalancerProfile: tc.loadBalancerProfile,
+				NATGatewayProfile:   tc.natGatewayProfile,
+			})
+			if tc.expectedError != "" {
+				g.Expect(err).To(MatchError(tc.expectedError))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestReconcileWindowsProfile(t *testing.T) {
+	testcases := []struct {
+		name           string
+		windowsProfile *WindowsProfile
+		agentPools     []PoolSpec
+		existing       *containerservice.ManagedCluster
+		expectedError  string
+		expected       *containerservice.ManagedClusterWindowsProfile
+	}{
+		{
+			name: "Windows profile on create",
+			windowsProfile: &WindowsProfile{
+				AdminUsername: "azureuser",
+				AdminPassword: "P@ssw0rd1234",
+			},
+			expected: &containerservice.ManagedClusterWindowsProfile{
+				AdminUsername: to.StringPtr("azureuser"),
+				AdminPassword: to.StringPtr("P@ssw0rd1234"),
+			},
+		},
+		{
+			name: "existing Windows admin username is kept",
+			windowsProfile: &WindowsProfile{
+				AdminUsername: "otheruser",
+				AdminPassword: "N3wP@ssw0rd",
+			},
+			existing: &containerservice.ManagedCluster{ManagedClusterProperties: &containerservice.ManagedClusterProperties{
+				ProvisioningState: to.StringPtr("Succeeded"),
+				WindowsProfile: &containerservice.ManagedClusterWindowsProfile{
+					AdminUsername: to.StringPtr("azureuser"),
+				},
+			}},
+			expected: &containerservice.ManagedClusterWindowsProfile{
+				AdminUsername: to.StringPtr("azureuser"),
+				AdminPassword: to.StringPtr("N3wP@ssw0rd"),
+			},
+		},
+		{
+			name: "Windows default pool",
+			agentPools: []PoolSpec{
+				{Name: "win", SKU: "Standard_D2s_v3", Replicas: 1, OSType: "Windows"},
+			},
+			expectedError: "Windows agent pool win cannot be the default pool of the cluster",
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			managedclusterMock := mock_managedclusters.NewMockClient(mockCtrl)
+
+			if tc.expectedError == "" {
+				if tc.existing != nil {
+					managedclusterMock.EXPECT().Get(context.TODO(), "my-rg", "my-managedcluster").Return(*tc.existing, nil)
+				} else {
+					managedclusterMock.EXPECT().Get(context.TODO(), "my-rg", "my-managedcluster").Return(containerservice.ManagedCluster{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not Found"))
+				}
+				managedclusterMock.EXPECT().CreateOrUpdate(context.TODO(), "my-rg", "my-managedcluster", gomock.Any()).
+					DoAndReturn(func(_ context.Context, _, _ string, cluster containerservice.ManagedCluster) error {
+						g.Expect(cluster.WindowsProfile).To(Equal(tc.expected))
+						return nil
+					})
+			}
+
+			s := &Service{
+				Client: managedclusterMock,
+			}
+
+			err := s.Reconcile(context.TODO(), &Spec{
+				Name:           "my-managedcluster",
+				ResourceGroup:  "my-rg",
+				WindowsProfile: tc.windowsProfile,
+				AgentPools:     tc.agentPools,
+			})
+			if tc.expectedError != "" {
+				g.Expect(err).To(MatchError(tc.expectedError))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
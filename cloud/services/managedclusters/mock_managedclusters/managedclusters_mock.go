@@ -22,7 +22,7 @@ package mock_managedclusters
 
 import (
 	context "context"
-	containerservice "github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2020-02-01/containerservice"
+	containerservice "github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2021-07-01/containerservice"
 	gomock "github.com/golang/mock/gomock"
 	reflect "reflect"
 )
@@ -80,6 +80,36 @@ func (mr *MockClientMockRecorder) GetCredentials(arg0, arg1, arg2 interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCredentials", reflect.TypeOf((*MockClient)(nil).GetCredentials), arg0, arg1, arg2)
 }
 
+// GetUserCredentials mocks base method.
+func (m *MockClient) GetUserCredentials(arg0 context.Context, arg1, arg2 string) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserCredentials", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserCredentials indicates an expected call of GetUserCredentials.
+func (mr *MockClientMockRecorder) GetUserCredentials(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserCredentials", reflect.TypeOf((*MockClient)(nil).GetUserCredentials), arg0, arg1, arg2)
+}
+
+// GetUpgradeProfile mocks base method.
+func (m *MockClient) GetUpgradeProfile(arg0 context.Context, arg1, arg2 string) (containerservice.ManagedClusterUpgradeProfile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUpgradeProfile", arg0, arg1, arg2)
+	ret0, _ := ret[0].(containerservice.ManagedClusterUpgradeProfile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUpgradeProfile indicates an expected call of GetUpgradeProfile.
+func (mr *MockClientMockRecorder) GetUpgradeProfile(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUpgradeProfile", reflect.TypeOf((*MockClient)(nil).GetUpgradeProfile), arg0, arg1, arg2)
+}
+
 // CreateOrUpdate mocks base method.
 func (m *MockClient) CreateOrUpdate(arg0 context.Context, arg1, arg2 string, arg3 containerservice.ManagedCluster) error {
 	m.ctrl.T.Helper()
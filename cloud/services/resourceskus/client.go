@@ -20,7 +20,7 @@ import (
 	"context"
 	"strings"
 
-	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2021-07-01/compute"
 	"github.com/Azure/go-autorest/autorest"
 	"github.com/pkg/errors"
 
@@ -57,7 +57,7 @@ func newResourceSkusClient(subscriptionID string, baseURI string, authorizer aut
 
 // List returns all Resource SKUs available to the subscription.
 func (ac *AzureClient) List(ctx context.Context, filter string) ([]compute.ResourceSku, error) {
-	iter, err := ac.skus.ListComplete(ctx, filter)
+	iter, err := ac.skus.ListComplete(ctx, filter, "")
 	if err != nil {
 		return nil, errors.Wrap(err, "could not list resource skus")
 	}
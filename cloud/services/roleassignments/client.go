@@ -26,7 +26,10 @@ import (
 
 // Client wraps go-sdk
 type Client interface {
+	Get(context.Context, string, string) (authorization.RoleAssignment, error)
 	Create(context.Context, string, string, authorization.RoleAssignmentCreateParameters) (authorization.RoleAssignment, error)
+	Delete(context.Context, string, string) (authorization.RoleAssignment, error)
+	ListForScope(context.Context, string, string) ([]authorization.RoleAssignment, error)
 }
 
 // AzureClient contains the Azure go-sdk Client
@@ -50,6 +53,11 @@ func newRoleAssignmentClient(subscriptionID string, baseURI string, authorizer a
 	return roleClient
 }
 
+// Get gets the specified role assignment.
+func (ac *AzureClient) Get(ctx context.Context, scope string, roleAssignmentName string) (authorization.RoleAssignment, error) {
+	return ac.roleassignments.Get(ctx, scope, roleAssignmentName)
+}
+
 // Create creates a role assignment.
 // Parameters:
 // scope - the scope of the role assignment to create. The scope can be any REST resource instance. For
@@ -62,3 +70,28 @@ func newRoleAssignmentClient(subscriptionID string, baseURI string, authorizer a
 func (ac *AzureClient) Create(ctx context.Context, scope string, roleAssignmentName string, parameters authorization.RoleAssignmentCreateParameters) (authorization.RoleAssignment, error) {
 	return ac.roleassignments.Create(ctx, scope, roleAssignmentName, parameters)
 }
+
+// Delete deletes a role assignment.
+func (ac *AzureClient) Delete(ctx context.Context, scope string, roleAssignmentName string) (authorization.RoleAssignment, error) {
+	return ac.roleassignments.Delete(ctx, scope, roleAssignmentName)
+}
+
+// ListForScope lists the role assignments that apply to a scope, including the ones inherited from its parents.
+// Parameters:
+// scope - the scope of the role assignments.
+// filter - the filter to apply on the operation, e.g. "principalId eq '{id}'" for the role assignments of a principal.
+func (ac *AzureClient) ListForScope(ctx context.Context, scope string, filter string) ([]authorization.RoleAssignment, error) {
+	iter, err := ac.roleassignments.ListForScopeComplete(ctx, scope, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var roleAssignments []authorization.RoleAssignment
+	for ; iter.NotDone(); err = iter.NextWithContext(ctx) {
+		if err != nil {
+			return nil, err
+		}
+		roleAssignments = append(roleAssignments, iter.Value())
+	}
+	return roleAssignments, nil
+}
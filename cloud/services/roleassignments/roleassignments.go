@@ -0,0 +1,246 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package roleassignments
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/profiles/2019-03-01/authorization/mgmt/authorization"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
+	azure "sigs.k8s.io/cluster-api-provider-azure/cloud"
+)
+
+// ContributorRoleID is the ID of the Azure built-in Contributor role.
+// See https://docs.microsoft.com/en-us/azure/role-based-access-control/built-in-roles
+const ContributorRoleID = "b24988ac-6180-42a0-ab88-20f7382dd24c"
+
+// AcrPullRoleID is the ID of the Azure built-in AcrPull role, which allows pulling images from a container registry.
+const AcrPullRoleID = "7f951dda-4ed3-4680-a7ca-43fe172d538d"
+
+// ManagedIdentityOperatorRoleID is the ID of the Azure built-in Managed Identity Operator role, which allows
+// assigning a user-assigned identity to resources.
+const ManagedIdentityOperatorRoleID = "f1a07417-d97a-45cb-824c-7a7467783830"
+
+// Spec specification for role assignment
+type Spec struct {
+	Name             string
+	Scope            string
+	RoleDefinitionID string
+	PrincipalID      string
+}
+
+// ID returns the resource ID of the role assignment.
+func (s Spec) ID() string {
+	return fmt.Sprintf("%s/providers/Microsoft.Authorization/roleAssignments/%s", s.Scope, s.Name)
+}
+
+// SpecFromID returns the scope and name of the role assignment with the given resource ID.
+func SpecFromID(id string) (Spec, error) {
+	parts := strings.Split(id, "/providers/Microsoft.Authorization/roleAssignments/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" || strings.Contains(parts[1], "/") {
+		return Spec{}, errors.Errorf("invalid role assignment ID %s", id)
+	}
+	return Spec{Name: parts[1], Scope: parts[0]}, nil
+}
+
+// Reconcile gets/creates a role assignment. A role assignment with the same name that assigns a different role or
+// belongs to a different principal, e.g. the identity of a deleted VM with the same name, is recreated.
+func (s *Service) Reconcile(ctx context.Context, spec interface{}) error {
+	roleSpec, ok := spec.(*Spec)
+	if !ok {
+		return errors.New("invalid role assignment specification")
+	}
+
+	existing, err := s.Client.Get(ctx, roleSpec.Scope, roleSpec.Name)
+	switch {
+	case err == nil && isUpToDate(existing, roleSpec):
+		// role assignment already exists, skip creation
+		return nil
+	case err == nil:
+		klog.V(2).Infof("role assignment %s in scope %s does not match its specification, recreating it", roleSpec.Name, roleSpec.Scope)
+		if err := s.Delete(ctx, roleSpec); err != nil {
+			return err
+		}
+	case !azure.ResourceNotFound(err):
+		return errors.Wrapf(err, "failed to get role assignment %s in scope %s", roleSpec.Name, roleSpec.Scope)
+	}
+
+	klog.V(2).Infof("creating role assignment %s in scope %s", roleSpec.Name, roleSpec.Scope)
+	params := authorization.RoleAssignmentCreateParameters{
+		Properties: &authorization.RoleAssignmentProperties{
+			RoleDefinitionID: to.StringPtr(roleSpec.RoleDefinitionID),
+			PrincipalID:      to.StringPtr(roleSpec.PrincipalID),
+		},
+	}
+	if _, err := s.Client.Create(ctx, roleSpec.Scope, roleSpec.Name, params); err != nil {
+		return errors.Wrapf(err, "failed to create role assignment %s in scope %s", roleSpec.Name, roleSpec.Scope)
+	}
+
+	klog.V(2).Infof("successfully created role assignment %s in scope %s", roleSpec.Name, roleSpec.Scope)
+	return nil
+}
+
+// ReconcileGrant makes sure the principal of the spec has its role in its scope. The role assignment of the spec is
+// only created if the role isn't assigned to the principal yet, e.g. by a role assignment created outside of Cluster
+// API or inherited from a parent scope.
+func (s *Service) ReconcileGrant(ctx context.Context, spec interface{}) error {
+	roleSpec, ok := spec.(*Spec)
+	if !ok {
+		return errors.New("invalid role assignment specification")
+	}
+
+	existing, err := s.Client.ListForScope(ctx, roleSpec.Scope, fmt.Sprintf("principalId eq '%s'", roleSpec.PrincipalID))
+	if err != nil {
+		return errors.Wrapf(err, "failed to list role assignments of principal %s in scope %s", roleSpec.PrincipalID, roleSpec.Scope)
+	}
+	for _, roleAssignment := range existing {
+		if grantsRole(roleAssignment, roleSpec) {
+			klog.V(2).Infof("role of principal %s in scope %s is already granted by role assignment %s", roleSpec.PrincipalID, roleSpec.Scope, to.String(roleAssignment.Name))
+			return nil
+		}
+	}
+	return s.Reconcile(ctx, roleSpec)
+}
+
+// grantsRole returns true if the role assignment assigns the role of the spec to its principal. Role definition IDs
+// are compared by their name, as inherited role assignments may reference the role in another subscription.
+func grantsRole(roleAssignment authorization.RoleAssignment, roleSpec *Spec) bool {
+	if roleAssignment.Properties == nil {
+		return false
+	}
+	roleDefinitionID := to.String(roleAssignment.Properties.RoleDefinitionID)
+	return strings.EqualFold(to.String(roleAssignment.Properties.PrincipalID), roleSpec.PrincipalID) &&
+		strings.EqualFold(roleDefinitionID[strings.LastIndex(roleDefinitionID, "/")+1:], roleSpec.RoleDefinitionID[strings.LastIndex(roleSpec.RoleDefinitionID, "/")+1:])
+}
+
+// isUpToDate returns true if the existing role assignment assigns the role of the spec to its principal.
+func isUpToDate(existing authorization.RoleAssignment, roleSpec *Spec) bool {
+	if existing.Properties == nil {
+		return false
+	}
+	return strings.EqualFold(to.String(existing.Properties.PrincipalID), roleSpec.PrincipalID) &&
+		strings.EqualFold(to.String(existing.Properties.RoleDefinitionID), roleSpec.RoleDefinitionID)
+}
+
+// Delete deletes the role assignment with the provided name.
+func (s *Service) Delete(ctx context.Context, spec interface{}) error {
+	roleSpec, ok := spec.(*Spec)
+	if !ok {
+		return errors.New("invalid role assignment specification")
+	}
+
+	klog.V(2).Infof("deleting role assignment %s in scope %s", roleSpec.Name, roleSpec.Scope)
+	_, err := s.Client.Delete(ctx, roleSpec.Scope, roleSpec.Name)
+	if err != nil && !azure.ResourceNotFound(err) {
+		return errors.Wrapf(err, "failed to delete role assignment %s in scope %s", roleSpec.Name, roleSpec.Scope)
+	}
+
+	klog.V(2).Infof("successfully deleted role assignment %s in scope %s", roleSpec.Name, roleSpec.Scope)
+	return nil
+}
+
+// DeleteStale deletes the role assignments with the previous IDs that are not among the wanted IDs.
+func (s *Service) DeleteStale(ctx context.Context, previousIDs, wantedIDs []string) error {
+	wanted := make(map[string]struct{}, len(wantedIDs))
+	for _, id := range wantedIDs {
+		wanted[strings.ToLower(id)] = struct{}{}
+	}
+	for _, id := range previousIDs {
+		if _, ok := wanted[strings.ToLower(id)]; ok {
+			continue
+		}
+		roleSpec, err := SpecFromID(id)
+		if err != nil {
+			return err
+		}
+		if err := s.Delete(ctx, &roleSpec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SpecsForIdentity returns the role assignments of the system-assigned identity of a resource. Roles without a
+// scope are scoped to the given resource group of the cluster, and the identity is assigned the Contributor role on
+// that resource group if no roles are given.
+func SpecsForIdentity(subscriptionID, resourceGroup, resourceID, principalID string, roles []infrav1.SystemAssignedIdentityRole) []Spec {
+	if len(roles) == 0 {
+		roles = []infrav1.SystemAssignedIdentityRole{
+			{
+				DefinitionID: ContributorRoleID,
+			},
+		}
+	}
+
+	specs := make([]Spec, 0, len(roles))
+	for _, role := range roles {
+		scope := role.Scope
+		if scope == "" {
+			scope = fmt.Sprintf("/subscriptions/%s/resourceGroups/%s", subscriptionID, resourceGroup)
+		}
+		roleDefinitionID := role.DefinitionID
+		if !strings.HasPrefix(roleDefinitionID, "/") {
+			roleDefinitionID = fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Authorization/roleDefinitions/%s", subscriptionID, roleDefinitionID)
+		}
+		specs = append(specs, Spec{
+			Name:             azure.GenerateRoleAssignmentName(resourceID, scope, roleDefinitionID),
+			Scope:            scope,
+			RoleDefinitionID: roleDefinitionID,
+			PrincipalID:      principalID,
+		})
+	}
+	return specs
+}
+
+// SpecForManagedIdentity returns the Managed Identity Operator role assignment of an identity on a user-assigned
+// identity. The name of the role assignment is derived from the resource the identity belongs to.
+func SpecForManagedIdentity(resourceID, identityID, principalID string) (Spec, error) {
+	// /subscriptions/<id>/resourceGroups/<group>/providers/Microsoft.ManagedIdentity/userAssignedIdentities/<identity>
+	parts := strings.Split(strings.TrimPrefix(identityID, "/"), "/")
+	if len(parts) != 8 || !strings.EqualFold(parts[0], "subscriptions") || !strings.EqualFold(parts[6], "userAssignedIdentities") {
+		return Spec{}, errors.Errorf("invalid user-assigned identity ID %s", identityID)
+	}
+	roleDefinitionID := fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Authorization/roleDefinitions/%s", parts[1], ManagedIdentityOperatorRoleID)
+	return Spec{
+		Name:             azure.GenerateRoleAssignmentName(resourceID, identityID, roleDefinitionID),
+		Scope:            identityID,
+		RoleDefinitionID: roleDefinitionID,
+		PrincipalID:      principalID,
+	}, nil
+}
+
+// SpecForContainerRegistry returns the AcrPull role assignment of an identity on a container registry. The name of
+// the role assignment is derived from the resource the identity belongs to.
+func SpecForContainerRegistry(resourceID, registryID, principalID string) (Spec, error) {
+	// /subscriptions/<id>/resourceGroups/<group>/providers/Microsoft.ContainerRegistry/registries/<registry>
+	parts := strings.Split(strings.TrimPrefix(registryID, "/"), "/")
+	if len(parts) != 8 || !strings.EqualFold(parts[0], "subscriptions") || !strings.EqualFold(parts[6], "registries") {
+		return Spec{}, errors.Errorf("invalid container registry ID %s", registryID)
+	}
+	roleDefinitionID := fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Authorization/roleDefinitions/%s", parts[1], AcrPullRoleID)
+	return Spec{
+		Name:             azure.GenerateRoleAssignmentName(resourceID, registryID, roleDefinitionID),
+		Scope:            registryID,
+		RoleDefinitionID: roleDefinitionID,
+		PrincipalID:      principalID,
+	}, nil
+}
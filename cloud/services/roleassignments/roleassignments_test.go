@@ -0,0 +1,373 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package roleassignments
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/roleassignments/mock_roleassignments"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/golang/mock/gomock"
+
+	"github.com/Azure/azure-sdk-for-go/profiles/2019-03-01/authorization/mgmt/authorization"
+	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
+)
+
+const (
+	roleAssignmentName = "7e2d8b8c-9b5b-5c3e-8e1d-2f0b5b1f8a3c"
+	roleScope          = "/subscriptions/123/resourceGroups/my-rg"
+	roleDefinitionID   = "/subscriptions/123/providers/Microsoft.Authorization/roleDefinitions/acdd72a7-3385-48ef-bd42-f606fba81ae7"
+)
+
+func TestReconcileRoleAssignment(t *testing.T) {
+	g := NewWithT(t)
+
+	testcases := []struct {
+		name          string
+		expectedError string
+		expect        func(m *mock_roleassignments.MockClientMockRecorder)
+	}{
+		{
+			name:          "role assignment already exists",
+			expectedError: "",
+			expect: func(m *mock_roleassignments.MockClientMockRecorder) {
+				m.Get(context.TODO(), roleScope, roleAssignmentName).Return(authorization.RoleAssignment{
+					Name: to.StringPtr(roleAssignmentName),
+					Properties: &authorization.RoleAssignmentPropertiesWithScope{
+						RoleDefinitionID: to.StringPtr(roleDefinitionID),
+						PrincipalID:      to.StringPtr("PRINCIPAL-ID"),
+					},
+				}, nil)
+			},
+		},
+		{
+			name:          "recreate role assignment of another principal",
+			expectedError: "",
+			expect: func(m *mock_roleassignments.MockClientMockRecorder) {
+				m.Get(context.TODO(), roleScope, roleAssignmentName).Return(authorization.RoleAssignment{
+					Name: to.StringPtr(roleAssignmentName),
+					Properties: &authorization.RoleAssignmentPropertiesWithScope{
+						RoleDefinitionID: to.StringPtr(roleDefinitionID),
+						PrincipalID:      to.StringPtr("deleted-principal-id"),
+					},
+				}, nil)
+				gomock.InOrder(
+					m.Delete(context.TODO(), roleScope, roleAssignmentName),
+					m.Create(context.TODO(), roleScope, roleAssignmentName, gomock.AssignableToTypeOf(authorization.RoleAssignmentCreateParameters{})).Do(
+						func(_ context.Context, _, _ string, params authorization.RoleAssignmentCreateParameters) {
+							g.Expect(to.String(params.Properties.PrincipalID)).To(Equal("principal-id"))
+						}),
+				)
+			},
+		},
+		{
+			name:          "create role assignment",
+			expectedError: "",
+			expect: func(m *mock_roleassignments.MockClientMockRecorder) {
+				m.Get(context.TODO(), roleScope, roleAssignmentName).Return(authorization.RoleAssignment{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not Found"))
+				m.Create(context.TODO(), roleScope, roleAssignmentName, gomock.AssignableToTypeOf(authorization.RoleAssignmentCreateParameters{})).Do(
+					func(_ context.Context, _, _ string, params authorization.RoleAssignmentCreateParameters) {
+						g.Expect(to.String(params.Properties.RoleDefinitionID)).To(Equal(roleDefinitionID))
+						g.Expect(to.String(params.Properties.PrincipalID)).To(Equal("principal-id"))
+					})
+			},
+		},
+		{
+			name:          "fail to get role assignment",
+			expectedError: "failed to get role assignment " + roleAssignmentName + " in scope " + roleScope + ": #: Internal Server Error: StatusCode=500",
+			expect: func(m *mock_roleassignments.MockClientMockRecorder) {
+				m.Get(context.TODO(), roleScope, roleAssignmentName).Return(authorization.RoleAssignment{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"))
+			},
+		},
+		{
+			name:          "fail to create role assignment",
+			expectedError: "failed to create role assignment " + roleAssignmentName + " in scope " + roleScope + ": #: Internal Server Error: StatusCode=500",
+			expect: func(m *mock_roleassignments.MockClientMockRecorder) {
+				m.Get(context.TODO(), roleScope, roleAssignmentName).Return(authorization.RoleAssignment{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not Found"))
+				m.Create(context.TODO(), roleScope, roleAssignmentName, gomock.AssignableToTypeOf(authorization.RoleAssignmentCreateParameters{})).Return(authorization.RoleAssignment{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"))
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			roleAssignmentsMock := mock_roleassignments.NewMockClient(mockCtrl)
+
+			tc.expect(roleAssignmentsMock.EXPECT())
+
+			s := &Service{
+				Client: roleAssignmentsMock,
+			}
+
+			err := s.Reconcile(context.TODO(), &Spec{
+				Name:             roleAssignmentName,
+				Scope:            roleScope,
+				RoleDefinitionID: roleDefinitionID,
+				PrincipalID:      "principal-id",
+			})
+			if tc.expectedError != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err).To(MatchError(tc.expectedError))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestReconcileGrant(t *testing.T) {
+	g := NewWithT(t)
+
+	testcases := []struct {
+		name          string
+		expectedError string
+		expect        func(m *mock_roleassignments.MockClientMockRecorder)
+	}{
+		{
+			name:          "role already granted by an inherited role assignment",
+			expectedError: "",
+			expect: func(m *mock_roleassignments.MockClientMockRecorder) {
+				m.ListForScope(context.TODO(), roleScope, "principalId eq 'principal-id'").Return([]authorization.RoleAssignment{
+					{
+						Name: to.StringPtr("other-role-assignment"),
+						Properties: &authorization.RoleAssignmentPropertiesWithScope{
+							RoleDefinitionID: to.StringPtr("/subscriptions/456/providers/Microsoft.Authorization/roleDefinitions/ACDD72A7-3385-48EF-BD42-F606FBA81AE7"),
+							PrincipalID:      to.StringPtr("principal-id"),
+						},
+					},
+				}, nil)
+			},
+		},
+		{
+			name:          "create role assignment if the principal has another role",
+			expectedError: "",
+			expect: func(m *mock_roleassignments.MockClientMockRecorder) {
+				m.ListForScope(context.TODO(), roleScope, "principalId eq 'principal-id'").Return([]authorization.RoleAssignment{
+					{
+						Name: to.StringPtr("other-role-assignment"),
+						Properties: &authorization.RoleAssignmentPropertiesWithScope{
+							RoleDefinitionID: to.StringPtr("/subscriptions/123/providers/Microsoft.Authorization/roleDefinitions/" + AcrPullRoleID),
+							PrincipalID:      to.StringPtr("principal-id"),
+						},
+					},
+				}, nil)
+				m.Get(context.TODO(), roleScope, roleAssignmentName).Return(authorization.RoleAssignment{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not Found"))
+				m.Create(context.TODO(), roleScope, roleAssignmentName, gomock.AssignableToTypeOf(authorization.RoleAssignmentCreateParameters{}))
+			},
+		},
+		{
+			name:          "fail to list role assignments",
+			expectedError: "failed to list role assignments of principal principal-id in scope " + roleScope + ": #: Forbidden: StatusCode=403",
+			expect: func(m *mock_roleassignments.MockClientMockRecorder) {
+				m.ListForScope(context.TODO(), roleScope, "principalId eq 'principal-id'").Return(nil, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 403}, "Forbidden"))
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			roleAssignmentsMock := mock_roleassignments.NewMockClient(mockCtrl)
+
+			tc.expect(roleAssignmentsMock.EXPECT())
+
+			s := &Service{
+				Client: roleAssignmentsMock,
+			}
+
+			err := s.ReconcileGrant(context.TODO(), &Spec{
+				Name:             roleAssignmentName,
+				Scope:            roleScope,
+				RoleDefinitionID: roleDefinitionID,
+				PrincipalID:      "principal-id",
+			})
+			if tc.expectedError != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err).To(MatchError(tc.expectedError))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestDeleteRoleAssignment(t *testing.T) {
+	g := NewWithT(t)
+
+	testcases := []struct {
+		name          string
+		expectedError string
+		expect        func(m *mock_roleassignments.MockClientMockRecorder)
+	}{
+		{
+			name:          "delete role assignment",
+			expectedError: "",
+			expect: func(m *mock_roleassignments.MockClientMockRecorder) {
+				m.Delete(context.TODO(), roleScope, roleAssignmentName)
+			},
+		},
+		{
+			name:          "role assignment already deleted",
+			expectedError: "",
+			expect: func(m *mock_roleassignments.MockClientMockRecorder) {
+				m.Delete(context.TODO(), roleScope, roleAssignmentName).Return(authorization.RoleAssignment{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not Found"))
+			},
+		},
+		{
+			name:          "fail to delete role assignment",
+			expectedError: "failed to delete role assignment " + roleAssignmentName + " in scope " + roleScope + ": #: Internal Server Error: StatusCode=500",
+			expect: func(m *mock_roleassignments.MockClientMockRecorder) {
+				m.Delete(context.TODO(), roleScope, roleAssignmentName).Return(authorization.RoleAssignment{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"))
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			roleAssignmentsMock := mock_roleassignments.NewMockClient(mockCtrl)
+
+			tc.expect(roleAssignmentsMock.EXPECT())
+
+			s := &Service{
+				Client: roleAssignmentsMock,
+			}
+
+			err := s.Delete(context.TODO(), &Spec{Name: roleAssignmentName, Scope: roleScope})
+			if tc.expectedError != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err).To(MatchError(tc.expectedError))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestSpecsForIdentity(t *testing.T) {
+	g := NewWithT(t)
+
+	vmID := "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/my-vm"
+
+	specs := SpecsForIdentity("123", "my-rg", vmID, "principal-id", nil)
+	g.Expect(specs).To(HaveLen(1))
+	g.Expect(specs[0].Scope).To(Equal("/subscriptions/123/resourceGroups/my-rg"))
+	g.Expect(specs[0].RoleDefinitionID).To(Equal("/subscriptions/123/providers/Microsoft.Authorization/roleDefinitions/" + ContributorRoleID))
+	g.Expect(specs[0].PrincipalID).To(Equal("principal-id"))
+
+	specs = SpecsForIdentity("123", "my-rg", vmID, "principal-id", []infrav1.SystemAssignedIdentityRole{
+		{
+			DefinitionID: "acdd72a7-3385-48ef-bd42-f606fba81ae7",
+		},
+		{
+			DefinitionID: "/subscriptions/123/providers/Microsoft.Authorization/roleDefinitions/custom-role",
+			Scope:        "/subscriptions/123/resourceGroups/other-rg",
+		},
+	})
+	g.Expect(specs).To(HaveLen(2))
+	g.Expect(specs[0].Scope).To(Equal(roleScope))
+	g.Expect(specs[0].RoleDefinitionID).To(Equal(roleDefinitionID))
+	g.Expect(specs[1].Scope).To(Equal("/subscriptions/123/resourceGroups/other-rg"))
+	g.Expect(specs[1].RoleDefinitionID).To(Equal("/subscriptions/123/providers/Microsoft.Authorization/roleDefinitions/custom-role"))
+	g.Expect(specs[0].Name).NotTo(Equal(specs[1].Name))
+
+	// role assignment names are deterministic
+	g.Expect(SpecsForIdentity("123", "my-rg", vmID, "other-principal-id", []infrav1.SystemAssignedIdentityRole{
+		{
+			DefinitionID: "acdd72a7-3385-48ef-bd42-f606fba81ae7",
+		},
+	})[0].Name).To(Equal(specs[0].Name))
+}
+
+func TestSpecForContainerRegistry(t *testing.T) {
+	g := NewWithT(t)
+
+	clusterID := "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.ContainerService/managedClusters/my-cluster"
+	registryID := "/subscriptions/456/resourceGroups/acr-rg/providers/Microsoft.ContainerRegistry/registries/myregistry"
+
+	spec, err := SpecForContainerRegistry(clusterID, registryID, "principal-id")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(spec.Scope).To(Equal(registryID))
+	g.Expect(spec.RoleDefinitionID).To(Equal("/subscriptions/456/providers/Microsoft.Authorization/roleDefinitions/" + AcrPullRoleID))
+	g.Expect(spec.PrincipalID).To(Equal("principal-id"))
+
+	// role assignment names are deterministic
+	other, err := SpecForContainerRegistry(clusterID, registryID, "other-principal-id")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(other.Name).To(Equal(spec.Name))
+
+	_, err = SpecForContainerRegistry(clusterID, "/subscriptions/456/resourceGroups/acr-rg", "principal-id")
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestSpecForManagedIdentity(t *testing.T) {
+	g := NewWithT(t)
+
+	clusterID := "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.ContainerService/managedClusters/my-cluster"
+	identityID := "/subscriptions/456/resourceGroups/identity-rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/kubelet"
+
+	spec, err := SpecForManagedIdentity(clusterID, identityID, "principal-id")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(spec.Scope).To(Equal(identityID))
+	g.Expect(spec.RoleDefinitionID).To(Equal("/subscriptions/456/providers/Microsoft.Authorization/roleDefinitions/" + ManagedIdentityOperatorRoleID))
+	g.Expect(spec.PrincipalID).To(Equal("principal-id"))
+
+	_, err = SpecForManagedIdentity(clusterID, "/subscriptions/456/resourceGroups/identity-rg", "principal-id")
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestDeleteStaleRoleAssignments(t *testing.T) {
+	g := NewWithT(t)
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	roleAssignmentsMock := mock_roleassignments.NewMockClient(mockCtrl)
+
+	wanted := Spec{Name: roleAssignmentName, Scope: roleScope}
+	stale := Spec{Name: "0b7d5e1c-3c1d-5f7b-9a4e-6d2c8b9e1f0a", Scope: "/subscriptions/123"}
+	roleAssignmentsMock.EXPECT().Delete(context.TODO(), "/subscriptions/123", stale.Name)
+
+	s := &Service{
+		Client: roleAssignmentsMock,
+	}
+	g.Expect(s.DeleteStale(context.TODO(), []string{wanted.ID(), stale.ID()}, []string{wanted.ID()})).To(Succeed())
+	g.Expect(s.DeleteStale(context.TODO(), []string{"not-a-role-assignment"}, nil)).NotTo(Succeed())
+}
+
+func TestSpecFromID(t *testing.T) {
+	g := NewWithT(t)
+
+	spec, err := SpecFromID(roleScope + "/providers/Microsoft.Authorization/roleAssignments/" + roleAssignmentName)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(spec).To(Equal(Spec{Name: roleAssignmentName, Scope: roleScope}))
+	g.Expect(spec.ID()).To(Equal(roleScope + "/providers/Microsoft.Authorization/roleAssignments/" + roleAssignmentName))
+
+	_, err = SpecFromID(roleScope)
+	g.Expect(err).To(HaveOccurred())
+}
@@ -20,7 +20,7 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2021-07-01/compute"
 	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2019-11-01/network"
 	"github.com/Azure/go-autorest/autorest"
 
@@ -35,13 +35,22 @@ type Client interface {
 	CreateOrUpdate(context.Context, string, string, compute.VirtualMachineScaleSet) error
 	Update(context.Context, string, string, compute.VirtualMachineScaleSetUpdate) error
 	Delete(context.Context, string, string) error
+	DeleteInstances(context.Context, string, string, []string) error
+	RunCommand(context.Context, string, string, string, compute.RunCommandInput) (compute.RunCommandResult, error)
+	GetInstanceView(context.Context, string, string, string) (compute.VirtualMachineScaleSetVMInstanceView, error)
 	GetPublicIPAddress(context.Context, string, string) (network.PublicIPAddress, error)
+	ListVirtualMachines(context.Context, string) ([]compute.VirtualMachine, error)
+	CreateVirtualMachine(context.Context, string, string, compute.VirtualMachine) error
+	DeleteVirtualMachine(context.Context, string, string) error
+	RunVirtualMachineCommand(context.Context, string, string, compute.RunCommandInput) (compute.RunCommandResult, error)
+	GetVirtualMachineInstanceView(context.Context, string, string) (compute.VirtualMachineInstanceView, error)
 }
 
 // AzureClient contains the Azure go-sdk Client
 type AzureClient struct {
 	scalesetvms compute.VirtualMachineScaleSetVMsClient
 	scalesets   compute.VirtualMachineScaleSetsClient
+	vms         compute.VirtualMachinesClient
 	publicIPs   network.PublicIPAddressesClient
 }
 
@@ -52,6 +61,7 @@ func NewClient(auth azure.Authorizer) *AzureClient {
 	return &AzureClient{
 		scalesetvms: newVirtualMachineScaleSetVMsClient(auth.SubscriptionID(), auth.BaseURI(), auth.Authorizer()),
 		scalesets:   newVirtualMachineScaleSetsClient(auth.SubscriptionID(), auth.BaseURI(), auth.Authorizer()),
+		vms:         newVirtualMachinesClient(auth.SubscriptionID(), auth.BaseURI(), auth.Authorizer()),
 		publicIPs:   newPublicIPsClient(auth.SubscriptionID(), auth.BaseURI(), auth.Authorizer()),
 	}
 }
@@ -72,6 +82,14 @@ func newVirtualMachineScaleSetsClient(subscriptionID string, baseURI string, aut
 	return c
 }
 
+// newVirtualMachinesClient creates a new VM client from subscription ID.
+func newVirtualMachinesClient(subscriptionID string, baseURI string, authorizer autorest.Authorizer) compute.VirtualMachinesClient {
+	c := compute.NewVirtualMachinesClientWithBaseURI(baseURI, subscriptionID)
+	c.Authorizer = authorizer
+	_ = c.AddToUserAgent(azure.UserAgent()) // intentionally ignore error as it doesn't matter
+	return c
+}
+
 // newPublicIPsClient creates a new publicIPs client from subscription ID.
 func newPublicIPsClient(subscriptionID string, baseURI string, authorizer autorest.Authorizer) network.PublicIPAddressesClient {
 	c := network.NewPublicIPAddressesClientWithBaseURI(baseURI, subscriptionID)
@@ -82,7 +100,7 @@ func newPublicIPsClient(subscriptionID string, baseURI string, authorizer autore
 
 // Get retrieves information about the model view of a virtual machine scale set.
 func (ac *AzureClient) ListInstances(ctx context.Context, resourceGroupName, vmssName string) ([]compute.VirtualMachineScaleSetVM, error) {
-	itr, err := ac.scalesetvms.ListComplete(ctx, resourceGroupName, vmssName, "", "", "")
+	itr, err := ac.scalesetvms.ListComplete(ctx, resourceGroupName, vmssName, "", "", string(compute.InstanceViewTypesInstanceView))
 	if err != nil {
 		return nil, err
 	}
@@ -114,7 +132,7 @@ func (ac *AzureClient) List(ctx context.Context, resourceGroupName string) ([]co
 
 // Get retrieves information about the model view of a virtual machine scale set.
 func (ac *AzureClient) Get(ctx context.Context, resourceGroupName, vmssName string) (compute.VirtualMachineScaleSet, error) {
-	return ac.scalesets.Get(ctx, resourceGroupName, vmssName)
+	return ac.scalesets.Get(ctx, resourceGroupName, vmssName, "")
 }
 
 // CreateOrUpdate the operation to create or update a virtual machine scale set.
@@ -148,7 +166,7 @@ func (ac *AzureClient) Update(ctx context.Context, resourceGroupName, vmssName s
 
 // Delete the operation to delete a virtual machine scale set.
 func (ac *AzureClient) Delete(ctx context.Context, resourceGroupName, vmssName string) error {
-	future, err := ac.scalesets.Delete(ctx, resourceGroupName, vmssName)
+	future, err := ac.scalesets.Delete(ctx, resourceGroupName, vmssName, nil)
 	if err != nil {
 		return err
 	}
@@ -160,6 +178,84 @@ func (ac *AzureClient) Delete(ctx context.Context, resourceGroupName, vmssName s
 	return err
 }
 
+// DeleteInstances starts deleting virtual machines in a VM scale set, reducing its capacity accordingly. It does not
+// wait for the deletion to complete, which can take as long as the terminate notification timeout; the instances are
+// in the Deleting provisioning state until then.
+func (ac *AzureClient) DeleteInstances(ctx context.Context, resourceGroupName, vmssName string, instanceIDs []string) error {
+	_, err := ac.scalesets.DeleteInstances(ctx, resourceGroupName, vmssName, compute.VirtualMachineScaleSetVMInstanceRequiredIDs{
+		InstanceIds: &instanceIDs,
+	}, nil)
+	return err
+}
+
+// RunCommand runs a command on a virtual machine in a VM scale set and returns its output.
+func (ac *AzureClient) RunCommand(ctx context.Context, resourceGroupName, vmssName, instanceID string, parameters compute.RunCommandInput) (compute.RunCommandResult, error) {
+	future, err := ac.scalesetvms.RunCommand(ctx, resourceGroupName, vmssName, instanceID, parameters)
+	if err != nil {
+		return compute.RunCommandResult{}, err
+	}
+	err = future.WaitForCompletionRef(ctx, ac.scalesetvms.Client)
+	if err != nil {
+		return compute.RunCommandResult{}, err
+	}
+	return future.Result(ac.scalesetvms)
+}
+
+// GetInstanceView retrieves information about the run-time state of a virtual machine in a VM scale set.
+func (ac *AzureClient) GetInstanceView(ctx context.Context, resourceGroupName, vmssName, instanceID string) (compute.VirtualMachineScaleSetVMInstanceView, error) {
+	return ac.scalesetvms.GetInstanceView(ctx, resourceGroupName, vmssName, instanceID)
+}
+
 func (ac *AzureClient) GetPublicIPAddress(ctx context.Context, resourceGroupName, publicIPName string) (network.PublicIPAddress, error) {
 	return ac.publicIPs.Get(ctx, resourceGroupName, publicIPName, "true")
 }
+
+// ListVirtualMachines lists all virtual machines in a resource group, including the instances of the VM scale sets
+// in the Flexible orchestration mode, which are not listed as VM scale set VMs.
+func (ac *AzureClient) ListVirtualMachines(ctx context.Context, resourceGroupName string) ([]compute.VirtualMachine, error) {
+	itr, err := ac.vms.ListComplete(ctx, resourceGroupName)
+	if err != nil {
+		return nil, err
+	}
+
+	var vms []compute.VirtualMachine
+	for ; itr.NotDone(); err = itr.NextWithContext(ctx) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate vms [%w]", err)
+		}
+		vms = append(vms, itr.Value())
+	}
+	return vms, nil
+}
+
+// CreateVirtualMachine starts creating a virtual machine without waiting for it to be provisioned. The virtual
+// machine is in the Creating provisioning state until then.
+func (ac *AzureClient) CreateVirtualMachine(ctx context.Context, resourceGroupName, vmName string, vm compute.VirtualMachine) error {
+	_, err := ac.vms.CreateOrUpdate(ctx, resourceGroupName, vmName, vm)
+	return err
+}
+
+// DeleteVirtualMachine starts deleting a virtual machine without waiting for the deletion to complete, which can take
+// as long as the terminate notification timeout; the virtual machine is in the Deleting provisioning state until then.
+func (ac *AzureClient) DeleteVirtualMachine(ctx context.Context, resourceGroupName, vmName string) error {
+	_, err := ac.vms.Delete(ctx, resourceGroupName, vmName, nil)
+	return err
+}
+
+// RunVirtualMachineCommand runs a command on a virtual machine and returns its output.
+func (ac *AzureClient) RunVirtualMachineCommand(ctx context.Context, resourceGroupName, vmName string, parameters compute.RunCommandInput) (compute.RunCommandResult, error) {
+	future, err := ac.vms.RunCommand(ctx, resourceGroupName, vmName, parameters)
+	if err != nil {
+		return compute.RunCommandResult{}, err
+	}
+	err = future.WaitForCompletionRef(ctx, ac.vms.Client)
+	if err != nil {
+		return compute.RunCommandResult{}, err
+	}
+	return future.Result(ac.vms)
+}
+
+// GetVirtualMachineInstanceView retrieves information about the run-time state of a virtual machine.
+func (ac *AzureClient) GetVirtualMachineInstanceView(ctx context.Context, resourceGroupName, vmName string) (compute.VirtualMachineInstanceView, error) {
+	return ac.vms.InstanceView(ctx, resourceGroupName, vmName)
+}
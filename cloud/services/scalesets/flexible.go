@@ -0,0 +1,350 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scalesets
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2021-07-01/compute"
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2019-06-01/network"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
+	azure "sigs.k8s.io/cluster-api-provider-azure/cloud"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/converters"
+	infrav1exp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1alpha3"
+)
+
+// deletingState is the provisioning state of a virtual machine being deleted.
+const deletingState = "Deleting"
+
+// reconcileFlexible creates or updates a scale set in the Flexible orchestration mode and its instances. Azure does
+// not create the instances of such a scale set, so every instance is a virtual machine created from the model of the
+// spec, with its own network interface, and added to the scale set.
+func (s *Service) reconcileFlexible(ctx context.Context, vmssSpec *Spec, backendAddressPoolID *string) error {
+	model, err := generateVirtualMachine(*vmssSpec)
+	if err != nil {
+		return errors.Wrapf(err, "failed to generate the virtual machine model of VMSS %s", vmssSpec.Name)
+	}
+	hash, err := modelHash(model)
+	if err != nil {
+		return errors.Wrapf(err, "failed to hash the virtual machine model of VMSS %s", vmssSpec.Name)
+	}
+	tags := generateTags(*vmssSpec)
+	tags[infrav1exp.ModelTagKey] = to.StringPtr(hash)
+
+	existing, err := s.Client.Get(ctx, vmssSpec.ResourceGroup, vmssSpec.Name)
+	switch {
+	case azure.ResourceNotFound(err):
+		vmss := compute.VirtualMachineScaleSet{
+			Location: to.StringPtr(vmssSpec.Location),
+			Tags:     tags,
+			VirtualMachineScaleSetProperties: &compute.VirtualMachineScaleSetProperties{
+				OrchestrationMode: compute.OrchestrationModeFlexible,
+				// instances are spread across fault domains on a best effort basis
+				PlatformFaultDomainCount: to.Int32Ptr(1),
+			},
+		}
+		if len(vmssSpec.Zones) > 0 {
+			zones := vmssSpec.Zones
+			vmss.Zones = &zones
+		}
+		if err := s.Client.CreateOrUpdate(ctx, vmssSpec.ResourceGroup, vmssSpec.Name, vmss); err != nil {
+			return errors.Wrapf(err, "cannot create VMSS")
+		}
+		klog.V(2).Infof("successfully created VMSS %s ", vmssSpec.Name)
+	case err != nil:
+		return errors.Wrapf(err, "failed to get scale set %s in %s", vmssSpec.Name, vmssSpec.ResourceGroup)
+	case !isFlexible(existing):
+		return errors.Errorf("VMSS %s does not use the Flexible orchestration mode", vmssSpec.Name)
+	case to.String(existing.Tags[infrav1exp.ModelTagKey]) != hash:
+		// the model of a scale set in the Flexible orchestration mode only lives in its tags
+		if err := s.Client.Update(ctx, vmssSpec.ResourceGroup, vmssSpec.Name, compute.VirtualMachineScaleSetUpdate{Tags: tags}); err != nil {
+			return errors.Wrapf(err, "failed to update the model of VMSS %s", vmssSpec.Name)
+		}
+	}
+
+	vmssID := azure.ScaleSetID(vmssSpec.SubscriptionID, vmssSpec.ResourceGroup, vmssSpec.Name)
+	instances, err := s.listFlexibleInstances(ctx, vmssSpec.ResourceGroup, vmssID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to list the instances of VMSS %s", vmssSpec.Name)
+	}
+
+	// new instances get an index greater than the ones of the existing instances, including the ones being deleted,
+	// so that the index increases as instances are created and names are not reused
+	next := 0
+	var active []int
+	for _, vm := range instances {
+		index, ok := instanceIndex(vmssSpec.Name, to.String(vm.Name))
+		if !ok {
+			continue
+		}
+		if index >= next {
+			next = index + 1
+		}
+		if vm.VirtualMachineProperties == nil || to.String(vm.ProvisioningState) != deletingState {
+			active = append(active, index)
+		}
+	}
+
+	// surplus instances are normally chosen and drained by the controller before the scale set is reconciled, the
+	// newest ones are removed otherwise
+	sort.Sort(sort.Reverse(sort.IntSlice(active)))
+	for i := 0; i < len(active)-int(vmssSpec.Capacity); i++ {
+		name := instanceName(vmssSpec.Name, active[i])
+		klog.V(2).Infof("deleting surplus instance %s of VMSS %s", name, vmssSpec.Name)
+		if err := s.Client.DeleteVirtualMachine(ctx, vmssSpec.ResourceGroup, name); err != nil && !azure.ResourceNotFound(err) {
+			return errors.Wrapf(err, "failed to delete instance %s of VMSS %s", name, vmssSpec.Name)
+		}
+	}
+
+	for count := len(active); count < int(vmssSpec.Capacity); count++ {
+		if err := s.createFlexibleInstance(ctx, vmssSpec, vmssID, backendAddressPoolID, hash, next); err != nil {
+			return err
+		}
+		next++
+	}
+	return nil
+}
+
+// createFlexibleInstance creates the network interface of an instance of a scale set in the Flexible orchestration
+// mode, then starts creating the virtual machine of the instance. The network interface and the OS disk of the
+// instance are deleted with its virtual machine.
+func (s *Service) createFlexibleInstance(ctx context.Context, vmssSpec *Spec, vmssID string, backendAddressPoolID *string, hash string, index int) error {
+	name := instanceName(vmssSpec.Name, index)
+
+	nicName := azure.GenerateNICName(name)
+	nic := network.Interface{
+		Location: to.StringPtr(vmssSpec.Location),
+		Tags:     generateTags(*vmssSpec),
+		InterfacePropertiesFormat: &network.InterfacePropertiesFormat{
+			EnableAcceleratedNetworking: vmssSpec.AcceleratedNetworking,
+			EnableIPForwarding:          to.BoolPtr(true),
+			IPConfigurations: &[]network.InterfaceIPConfiguration{
+				{
+					Name: to.StringPtr(name + "-ipconfig"),
+					InterfaceIPConfigurationPropertiesFormat: &network.InterfaceIPConfigurationPropertiesFormat{
+						Subnet:                          &network.Subnet{ID: to.StringPtr(vmssSpec.SubnetID)},
+						Primary:                         to.BoolPtr(true),
+						PrivateIPAllocationMethod:       network.Dynamic,
+						PrivateIPAddressVersion:         network.IPv4,
+						LoadBalancerBackendAddressPools: &[]network.BackendAddressPool{{ID: backendAddressPoolID}},
+					},
+				},
+			},
+		},
+	}
+	if err := s.NetworkInterfacesClient.CreateOrUpdate(ctx, vmssSpec.ResourceGroup, nicName, nic); err != nil {
+		return errors.Wrapf(err, "failed to create network interface %s of instance %s of VMSS %s", nicName, name, vmssSpec.Name)
+	}
+
+	vm, err := generateVirtualMachine(*vmssSpec)
+	if err != nil {
+		return errors.Wrapf(err, "failed to generate the virtual machine of instance %s of VMSS %s", name, vmssSpec.Name)
+	}
+	vm.Tags = generateTags(*vmssSpec)
+	vm.Tags[infrav1exp.ModelTagKey] = to.StringPtr(hash)
+	vm.OsProfile.ComputerName = to.StringPtr(name)
+	vm.StorageProfile.OsDisk.Name = to.StringPtr(azure.GenerateOSDiskName(name))
+	vm.NetworkProfile = &compute.NetworkProfile{
+		NetworkInterfaces: &[]compute.NetworkInterfaceReference{
+			{
+				ID: to.StringPtr(azure.NetworkInterfaceID(vmssSpec.SubscriptionID, vmssSpec.ResourceGroup, nicName)),
+				NetworkInterfaceReferenceProperties: &compute.NetworkInterfaceReferenceProperties{
+					Primary:      to.BoolPtr(true),
+					DeleteOption: compute.DeleteOptionsDelete,
+				},
+			},
+		},
+	}
+	vm.VirtualMachineScaleSet = &compute.SubResource{ID: to.StringPtr(vmssID)}
+	if len(vmssSpec.Zones) > 0 {
+		// spread the instances across the zones of the scale set
+		vm.Zones = &[]string{vmssSpec.Zones[index%len(vmssSpec.Zones)]}
+	}
+
+	klog.V(2).Infof("creating instance %s of VMSS %s", name, vmssSpec.Name)
+	if err := s.Client.CreateVirtualMachine(ctx, vmssSpec.ResourceGroup, name, vm); err != nil {
+		return errors.Wrapf(err, "failed to create instance %s of VMSS %s", name, vmssSpec.Name)
+	}
+	return nil
+}
+
+// deleteFlexibleInstances starts deleting the instances of a scale set in the Flexible orchestration mode, and
+// returns an error until they are all deleted.
+func (s *Service) deleteFlexibleInstances(ctx context.Context, vmssSpec *Spec) error {
+	vmssID := azure.ScaleSetID(vmssSpec.SubscriptionID, vmssSpec.ResourceGroup, vmssSpec.Name)
+	instances, err := s.listFlexibleInstances(ctx, vmssSpec.ResourceGroup, vmssID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to list the instances of VMSS %s", vmssSpec.Name)
+	}
+	if len(instances) == 0 {
+		return nil
+	}
+
+	for _, vm := range instances {
+		if vm.VirtualMachineProperties != nil && to.String(vm.ProvisioningState) == deletingState {
+			continue
+		}
+		klog.V(2).Infof("deleting instance %s of VMSS %s", to.String(vm.Name), vmssSpec.Name)
+		if err := s.Client.DeleteVirtualMachine(ctx, vmssSpec.ResourceGroup, to.String(vm.Name)); err != nil && !azure.ResourceNotFound(err) {
+			return errors.Wrapf(err, "failed to delete instance %s of VMSS %s", to.String(vm.Name), vmssSpec.Name)
+		}
+	}
+	return errors.Errorf("waiting for the %d instances of VMSS %s to be deleted", len(instances), vmssSpec.Name)
+}
+
+// listFlexibleInstances returns the virtual machines of a resource group which are instances of the scale set with
+// the provided ID.
+func (s *Service) listFlexibleInstances(ctx context.Context, resourceGroup, vmssID string) ([]compute.VirtualMachine, error) {
+	vms, err := s.Client.ListVirtualMachines(ctx, resourceGroup)
+	if err != nil {
+		return nil, err
+	}
+	var instances []compute.VirtualMachine
+	for _, vm := range vms {
+		if vm.VirtualMachineProperties != nil && vm.VirtualMachineScaleSet != nil && strings.EqualFold(to.String(vm.VirtualMachineScaleSet.ID), vmssID) {
+			instances = append(instances, vm)
+		}
+	}
+	return instances, nil
+}
+
+// generateVirtualMachine returns the model of the instances of a scale set in the Flexible orchestration mode,
+// without the properties specific to an instance.
+func generateVirtualMachine(vmssSpec Spec) (compute.VirtualMachine, error) {
+	imageRef, err := converters.ImageToSDK(vmssSpec.Image)
+	if err != nil {
+		return compute.VirtualMachine{}, err
+	}
+
+	priority, evictionPolicy, billingProfile, err := getSpotVMOptions(vmssSpec.SpotVMOptions)
+	if err != nil {
+		return compute.VirtualMachine{}, errors.Wrapf(err, "failed to get Spot VM options")
+	}
+
+	vm := compute.VirtualMachine{
+		Location: to.StringPtr(vmssSpec.Location),
+		VirtualMachineProperties: &compute.VirtualMachineProperties{
+			HardwareProfile: &compute.HardwareProfile{
+				VMSize: compute.VirtualMachineSizeTypes(vmssSpec.Sku),
+			},
+			StorageProfile: &compute.StorageProfile{
+				ImageReference: imageRef,
+				OsDisk: &compute.OSDisk{
+					OsType:       compute.OperatingSystemTypes(vmssSpec.OSDisk.OSType),
+					CreateOption: compute.DiskCreateOptionTypesFromImage,
+					DiskSizeGB:   to.Int32Ptr(vmssSpec.OSDisk.DiskSizeGB),
+					ManagedDisk: &compute.ManagedDiskParameters{
+						StorageAccountType: compute.StorageAccountTypes(vmssSpec.OSDisk.ManagedDisk.StorageAccountType),
+					},
+					DeleteOption: compute.DiskDeleteOptionTypesDelete,
+				},
+			},
+			OsProfile: &compute.OSProfile{
+				AdminUsername: to.StringPtr(azure.DefaultUserName),
+				CustomData:    to.StringPtr(vmssSpec.CustomData),
+				LinuxConfiguration: &compute.LinuxConfiguration{
+					SSH: &compute.SSHConfiguration{
+						PublicKeys: &[]compute.SSHPublicKey{
+							{
+								Path:    to.StringPtr(fmt.Sprintf("/home/%s/.ssh/authorized_keys", azure.DefaultUserName)),
+								KeyData: to.StringPtr(vmssSpec.SSHKeyData),
+							},
+						},
+					},
+					DisablePasswordAuthentication: to.BoolPtr(true),
+				},
+			},
+			Priority:               priority,
+			EvictionPolicy:         evictionPolicy,
+			BillingProfile:         billingProfile,
+			ScheduledEventsProfile: generateScheduledEventsProfile(vmssSpec),
+		},
+	}
+
+	if vmssSpec.BootDiagnosticsStorageURI != "" {
+		vm.DiagnosticsProfile = &compute.DiagnosticsProfile{
+			BootDiagnostics: &compute.BootDiagnostics{
+				Enabled:    to.BoolPtr(true),
+				StorageURI: to.StringPtr(vmssSpec.BootDiagnosticsStorageURI),
+			},
+		}
+	}
+
+	switch vmssSpec.Identity {
+	case infrav1.VMIdentitySystemAssigned:
+		// every instance would have its own identity, which would need its own role assignments
+		return compute.VirtualMachine{}, errors.New("the 'SystemAssigned' identity type is not supported with the Flexible orchestration mode")
+	case infrav1.VMIdentityUserAssigned:
+		if len(vmssSpec.UserAssignedIdentities) == 0 {
+			return compute.VirtualMachine{}, errors.New("the user-assigned identity provider ids must not be null or empty for 'UserAssigned' identity type")
+		}
+		userIdentitiesMap := make(map[string]*compute.VirtualMachineIdentityUserAssignedIdentitiesValue, len(vmssSpec.UserAssignedIdentities))
+		for _, id := range vmssSpec.UserAssignedIdentities {
+			userIdentitiesMap[strings.TrimPrefix(id.ProviderID, "azure:///")] = &compute.VirtualMachineIdentityUserAssignedIdentitiesValue{}
+		}
+		vm.Identity = &compute.VirtualMachineIdentity{
+			Type:                   compute.ResourceIdentityTypeUserAssigned,
+			UserAssignedIdentities: userIdentitiesMap,
+		}
+	}
+
+	return vm, nil
+}
+
+// modelHash returns a hash of the model of the instances of a scale set in the Flexible orchestration mode, which
+// tells whether an instance was created from the latest model.
+func modelHash(model compute.VirtualMachine) (string, error) {
+	// json.Marshal sorts map keys, so the hash is stable
+	data, err := json.Marshal(model)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data))[:16], nil
+}
+
+// instanceName returns the name of the virtual machine of the instance of a scale set with the provided index.
+func instanceName(vmssName string, index int) string {
+	return fmt.Sprintf("%s-%d", vmssName, index)
+}
+
+// instanceIndex returns the index of an instance of a scale set from the name of its virtual machine.
+func instanceIndex(vmssName, vmName string) (int, bool) {
+	suffix := strings.TrimPrefix(vmName, vmssName+"-")
+	if suffix == vmName {
+		return 0, false
+	}
+	index, err := strconv.Atoi(suffix)
+	if err != nil || index < 0 {
+		return 0, false
+	}
+	return index, true
+}
+
+// isFlexible returns true if the scale set uses the Flexible orchestration mode.
+func isFlexible(vmss compute.VirtualMachineScaleSet) bool {
+	return vmss.VirtualMachineScaleSetProperties != nil && vmss.OrchestrationMode == compute.OrchestrationModeFlexible
+}
@@ -0,0 +1,354 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scalesets
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2021-07-01/compute"
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2019-06-01/network"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/golang/mock/gomock"
+	"github.com/onsi/gomega"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/networkinterfaces/mock_networkinterfaces"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/publicloadbalancers/mock_publicloadbalancers"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/scalesets/mock_scalesets"
+	infrav1exp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1alpha3"
+)
+
+const flexibleVMSSID = "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachineScaleSets/capz-mp-0"
+
+func newFlexibleSpec() *Spec {
+	return &Spec{
+		Name:                   "capz-mp-0",
+		ResourceGroup:          "my-rg",
+		Location:               "test-location",
+		ClusterName:            "test-cluster",
+		MachinePoolName:        "capz-mp-0",
+		SubscriptionID:         "123",
+		Sku:                    "Standard_D2s_v3",
+		Capacity:               2,
+		SSHKeyData:             "sshKeyData",
+		CustomData:             "customData",
+		SubnetID:               "subnet-id",
+		PublicLoadBalancerName: "test-cluster",
+		AcceleratedNetworking:  to.BoolPtr(false),
+		OSDisk: infrav1.OSDisk{
+			OSType:     "Linux",
+			DiskSizeGB: 120,
+			ManagedDisk: infrav1.ManagedDisk{
+				StorageAccountType: "Premium_LRS",
+			},
+		},
+		Image: &infrav1.Image{
+			ID: to.StringPtr("image"),
+		},
+		Zones:             []string{"1", "2"},
+		OrchestrationMode: infrav1exp.FlexibleOrchestrationMode,
+	}
+}
+
+func newFlexibleInstance(name, state, model string) compute.VirtualMachine {
+	return compute.VirtualMachine{
+		ID:   to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/" + name),
+		Name: to.StringPtr(name),
+		Tags: map[string]*string{
+			infrav1exp.ModelTagKey: to.StringPtr(model),
+		},
+		VirtualMachineProperties: &compute.VirtualMachineProperties{
+			ProvisioningState:      to.StringPtr(state),
+			VirtualMachineScaleSet: &compute.SubResource{ID: to.StringPtr(flexibleVMSSID)},
+		},
+	}
+}
+
+func TestService_ReconcileFlexible(t *testing.T) {
+	model, err := generateVirtualMachine(*newFlexibleSpec())
+	gomega.NewGomegaWithT(t).Expect(err).ToNot(gomega.HaveOccurred())
+	hash, err := modelHash(model)
+	gomega.NewGomegaWithT(t).Expect(err).ToNot(gomega.HaveOccurred())
+
+	existingVMSS := compute.VirtualMachineScaleSet{
+		ID:   to.StringPtr(flexibleVMSSID),
+		Name: to.StringPtr("capz-mp-0"),
+		Tags: map[string]*string{
+			infrav1exp.ModelTagKey: to.StringPtr(hash),
+		},
+		VirtualMachineScaleSetProperties: &compute.VirtualMachineScaleSetProperties{
+			OrchestrationMode: compute.OrchestrationModeFlexible,
+		},
+	}
+	otherVM := compute.VirtualMachine{
+		Name: to.StringPtr("capz-control-plane-0"),
+		VirtualMachineProperties: &compute.VirtualMachineProperties{
+			ProvisioningState: to.StringPtr("Succeeded"),
+		},
+	}
+
+	cases := []struct {
+		name   string
+		spec   func(*Spec)
+		expect func(vmssMock *mock_scalesets.MockClientMockRecorder, nicMock *mock_networkinterfaces.MockClientMockRecorder)
+		err    string
+	}{
+		{
+			name: "scale set is created with its instances",
+			expect: func(vmssMock *mock_scalesets.MockClientMockRecorder, nicMock *mock_networkinterfaces.MockClientMockRecorder) {
+				vmssMock.Get(gomock.Any(), "my-rg", "capz-mp-0").Return(compute.VirtualMachineScaleSet{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				vmssMock.CreateOrUpdate(gomock.Any(), "my-rg", "capz-mp-0", gomock.Any()).DoAndReturn(
+					func(_ context.Context, _, _ string, vmss compute.VirtualMachineScaleSet) error {
+						g := gomega.NewGomegaWithT(t)
+						g.Expect(vmss.OrchestrationMode).To(gomega.Equal(compute.OrchestrationModeFlexible))
+						g.Expect(vmss.PlatformFaultDomainCount).To(gomega.Equal(to.Int32Ptr(1)))
+						g.Expect(vmss.VirtualMachineProfile).To(gomega.BeNil())
+						g.Expect(vmss.Sku).To(gomega.BeNil())
+						g.Expect(vmss.Zones).To(gomega.Equal(&[]string{"1", "2"}))
+						g.Expect(vmss.Tags).To(gomega.HaveKeyWithValue(infrav1exp.ModelTagKey, to.StringPtr(hash)))
+						return nil
+					})
+				vmssMock.ListVirtualMachines(gomock.Any(), "my-rg").Return([]compute.VirtualMachine{otherVM}, nil)
+				for i, name := range []string{"capz-mp-0-0", "capz-mp-0-1"} {
+					name, zone := name, []string{"1", "2"}[i]
+					nicMock.CreateOrUpdate(gomock.Any(), "my-rg", name+"-nic", gomock.Any()).DoAndReturn(
+						func(_ context.Context, _, _ string, nic network.Interface) error {
+							g := gomega.NewGomegaWithT(t)
+							ipConfig := (*nic.IPConfigurations)[0]
+							g.Expect(ipConfig.Subnet.ID).To(gomega.Equal(to.StringPtr("subnet-id")))
+							g.Expect(*ipConfig.LoadBalancerBackendAddressPools).To(gomega.Equal([]network.BackendAddressPool{{ID: to.StringPtr("cluster-name-outboundBackendPool")}}))
+							return nil
+						})
+					vmssMock.CreateVirtualMachine(gomock.Any(), "my-rg", name, gomock.Any()).DoAndReturn(
+						func(_ context.Context, _, _ string, vm compute.VirtualMachine) error {
+							g := gomega.NewGomegaWithT(t)
+							g.Expect(vm.OsProfile.ComputerName).To(gomega.Equal(to.StringPtr(name)))
+							g.Expect(vm.VirtualMachineScaleSet.ID).To(gomega.Equal(to.StringPtr(flexibleVMSSID)))
+							g.Expect(vm.Zones).To(gomega.Equal(&[]string{zone}))
+							g.Expect(vm.Tags).To(gomega.HaveKeyWithValue(infrav1exp.ModelTagKey, to.StringPtr(hash)))
+							nics := *vm.NetworkProfile.NetworkInterfaces
+							g.Expect(nics).To(gomega.HaveLen(1))
+							g.Expect(nics[0].ID).To(gomega.Equal(to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/networkInterfaces/" + name + "-nic")))
+							g.Expect(nics[0].DeleteOption).To(gomega.Equal(compute.DeleteOptionsDelete))
+							g.Expect(vm.StorageProfile.OsDisk.DeleteOption).To(gomega.Equal(compute.DiskDeleteOptionTypesDelete))
+							return nil
+						})
+				}
+			},
+		},
+		{
+			name: "missing instances are created after the newest instance",
+			spec: func(spec *Spec) {
+				spec.Capacity = 3
+			},
+			expect: func(vmssMock *mock_scalesets.MockClientMockRecorder, nicMock *mock_networkinterfaces.MockClientMockRecorder) {
+				vmssMock.Get(gomock.Any(), "my-rg", "capz-mp-0").Return(existingVMSS, nil)
+				vmssMock.ListVirtualMachines(gomock.Any(), "my-rg").Return([]compute.VirtualMachine{
+					newFlexibleInstance("capz-mp-0-0", "Succeeded", hash),
+					newFlexibleInstance("capz-mp-0-1", "Deleting", hash),
+					otherVM,
+				}, nil)
+				for _, name := range []string{"capz-mp-0-2", "capz-mp-0-3"} {
+					nicMock.CreateOrUpdate(gomock.Any(), "my-rg", name+"-nic", gomock.Any()).Return(nil)
+					vmssMock.CreateVirtualMachine(gomock.Any(), "my-rg", name, gomock.Any()).Return(nil)
+				}
+			},
+		},
+		{
+			name: "surplus instances are deleted newest first",
+			expect: func(vmssMock *mock_scalesets.MockClientMockRecorder, nicMock *mock_networkinterfaces.MockClientMockRecorder) {
+				vmssMock.Get(gomock.Any(), "my-rg", "capz-mp-0").Return(existingVMSS, nil)
+				vmssMock.ListVirtualMachines(gomock.Any(), "my-rg").Return([]compute.VirtualMachine{
+					newFlexibleInstance("capz-mp-0-0", "Succeeded", hash),
+					newFlexibleInstance("capz-mp-0-10", "Succeeded", hash),
+					newFlexibleInstance("capz-mp-0-2", "Succeeded", hash),
+				}, nil)
+				vmssMock.DeleteVirtualMachine(gomock.Any(), "my-rg", "capz-mp-0-10").Return(nil)
+			},
+		},
+		{
+			name: "model of the scale set is updated",
+			spec: func(spec *Spec) {
+				spec.Sku = "Standard_D4s_v3"
+			},
+			expect: func(vmssMock *mock_scalesets.MockClientMockRecorder, nicMock *mock_networkinterfaces.MockClientMockRecorder) {
+				vmssMock.Get(gomock.Any(), "my-rg", "capz-mp-0").Return(existingVMSS, nil)
+				vmssMock.Update(gomock.Any(), "my-rg", "capz-mp-0", gomock.Any()).DoAndReturn(
+					func(_ context.Context, _, _ string, update compute.VirtualMachineScaleSetUpdate) error {
+						g := gomega.NewGomegaWithT(t)
+						g.Expect(update.Tags).To(gomega.HaveKey(infrav1exp.ModelTagKey))
+						g.Expect(update.Tags[infrav1exp.ModelTagKey]).ToNot(gomega.Equal(to.StringPtr(hash)))
+						return nil
+					})
+				vmssMock.ListVirtualMachines(gomock.Any(), "my-rg").Return([]compute.VirtualMachine{
+					newFlexibleInstance("capz-mp-0-0", "Succeeded", hash),
+					newFlexibleInstance("capz-mp-0-1", "Succeeded", hash),
+				}, nil)
+			},
+		},
+		{
+			name: "scale set in the Uniform orchestration mode is refused",
+			expect: func(vmssMock *mock_scalesets.MockClientMockRecorder, nicMock *mock_networkinterfaces.MockClientMockRecorder) {
+				vmssMock.Get(gomock.Any(), "my-rg", "capz-mp-0").Return(compute.VirtualMachineScaleSet{
+					VirtualMachineScaleSetProperties: &compute.VirtualMachineScaleSetProperties{
+						OrchestrationMode: compute.OrchestrationModeUniform,
+					},
+				}, nil)
+			},
+			err: "VMSS capz-mp-0 does not use the Flexible orchestration mode",
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			g := gomega.NewGomegaWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			vmssMock := mock_scalesets.NewMockClient(mockCtrl)
+			nicMock := mock_networkinterfaces.NewMockClient(mockCtrl)
+			lbMock := mock_publicloadbalancers.NewMockClient(mockCtrl)
+			svc := &Service{
+				Client:                    vmssMock,
+				PublicLoadBalancersClient: lbMock,
+				NetworkInterfacesClient:   nicMock,
+			}
+
+			spec := newFlexibleSpec()
+			if c.spec != nil {
+				c.spec(spec)
+			}
+			lbMock.EXPECT().Get(gomock.Any(), "my-rg", "test-cluster").Return(getFakeNodeOutboundLoadBalancer(), nil)
+			c.expect(vmssMock.EXPECT(), nicMock.EXPECT())
+
+			err := svc.Reconcile(context.TODO(), spec)
+			if c.err != "" {
+				g.Expect(err).To(gomega.MatchError(c.err))
+				return
+			}
+			g.Expect(err).ToNot(gomega.HaveOccurred())
+		})
+	}
+}
+
+func TestService_GetFlexible(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	vmssMock := mock_scalesets.NewMockClient(mockCtrl)
+	svc := &Service{Client: vmssMock}
+
+	vmssMock.EXPECT().Get(gomock.Any(), "my-rg", "capz-mp-0").Return(compute.VirtualMachineScaleSet{
+		ID:   to.StringPtr(flexibleVMSSID),
+		Name: to.StringPtr("capz-mp-0"),
+		VirtualMachineScaleSetProperties: &compute.VirtualMachineScaleSetProperties{
+			ProvisioningState: to.StringPtr("Succeeded"),
+			OrchestrationMode: compute.OrchestrationModeFlexible,
+		},
+	}, nil)
+	vmssMock.EXPECT().ListVirtualMachines(gomock.Any(), "my-rg").Return([]compute.VirtualMachine{
+		newFlexibleInstance("capz-mp-0-0", "Succeeded", "model"),
+		{
+			Name:                     to.StringPtr("capz-control-plane-0"),
+			VirtualMachineProperties: &compute.VirtualMachineProperties{},
+		},
+	}, nil)
+
+	vmss, err := svc.Get(context.TODO(), newFlexibleSpec())
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(vmss.Instances).To(gomega.Equal([]infrav1exp.VMSSVM{
+		{
+			ID:         "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachines/capz-mp-0-0",
+			InstanceID: "capz-mp-0-0",
+			Name:       "capz-mp-0-0",
+			State:      "Succeeded",
+		},
+	}))
+}
+
+func TestService_DeleteFlexible(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	vmssMock := mock_scalesets.NewMockClient(mockCtrl)
+	svc := &Service{Client: vmssMock}
+	spec := newFlexibleSpec()
+
+	vmssMock.EXPECT().ListVirtualMachines(gomock.Any(), "my-rg").Return([]compute.VirtualMachine{
+		newFlexibleInstance("capz-mp-0-0", "Succeeded", "model"),
+		newFlexibleInstance("capz-mp-0-1", "Deleting", "model"),
+	}, nil)
+	vmssMock.EXPECT().DeleteVirtualMachine(gomock.Any(), "my-rg", "capz-mp-0-0").Return(nil)
+	g.Expect(svc.Delete(context.TODO(), spec)).To(gomega.MatchError("waiting for the 2 instances of VMSS capz-mp-0 to be deleted"))
+
+	vmssMock.EXPECT().ListVirtualMachines(gomock.Any(), "my-rg").Return(nil, nil)
+	vmssMock.EXPECT().Delete(gomock.Any(), "my-rg", "capz-mp-0").Return(nil)
+	g.Expect(svc.Delete(context.TODO(), spec)).To(gomega.Succeed())
+}
+
+func TestService_DeleteInstancesFlexible(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	vmssMock := mock_scalesets.NewMockClient(mockCtrl)
+	svc := &Service{Client: vmssMock}
+	spec := &Spec{Name: "capz-mp-0", ResourceGroup: "my-rg", OrchestrationMode: infrav1exp.FlexibleOrchestrationMode}
+
+	vmssMock.EXPECT().DeleteVirtualMachine(gomock.Any(), "my-rg", "capz-mp-0-0").Return(nil)
+	vmssMock.EXPECT().DeleteVirtualMachine(gomock.Any(), "my-rg", "capz-mp-0-2").Return(autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+	g.Expect(svc.DeleteInstances(context.TODO(), spec, []string{"capz-mp-0-0", "capz-mp-0-2"})).To(gomega.Succeed())
+
+	vmssMock.EXPECT().RunVirtualMachineCommand(gomock.Any(), "my-rg", "capz-mp-0-1", gomock.Any()).Return(runCommandResult("termination approved"), nil)
+	g.Expect(svc.ApproveTermination(context.TODO(), spec, "capz-mp-0-1")).To(gomega.Succeed())
+}
+
+func TestGenerateVirtualMachine(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	spec := newFlexibleSpec()
+	spec.SpotVMOptions = &infrav1.SpotVMOptions{}
+	spec.BootDiagnosticsStorageURI = "https://account.blob.core.windows.net/"
+	spec.Identity = infrav1.VMIdentityUserAssigned
+	spec.UserAssignedIdentities = []infrav1.UserAssignedIdentity{{ProviderID: "azure:///subscriptions/123/identity"}}
+	vm, err := generateVirtualMachine(*spec)
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(vm.HardwareProfile.VMSize).To(gomega.Equal(compute.VirtualMachineSizeTypes("Standard_D2s_v3")))
+	g.Expect(vm.Priority).To(gomega.Equal(compute.VirtualMachinePriorityTypesSpot))
+	g.Expect(vm.DiagnosticsProfile.BootDiagnostics.StorageURI).To(gomega.Equal(to.StringPtr(spec.BootDiagnosticsStorageURI)))
+	g.Expect(vm.Identity.UserAssignedIdentities).To(gomega.HaveKey("subscriptions/123/identity"))
+
+	spec.Identity = infrav1.VMIdentitySystemAssigned
+	_, err = generateVirtualMachine(*spec)
+	g.Expect(err).To(gomega.MatchError("the 'SystemAssigned' identity type is not supported with the Flexible orchestration mode"))
+}
+
+func TestInstanceIndex(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	index, ok := instanceIndex("capz-mp-0", "capz-mp-0-12")
+	g.Expect(ok).To(gomega.BeTrue())
+	g.Expect(index).To(gomega.Equal(12))
+	g.Expect(instanceName("capz-mp-0", index)).To(gomega.Equal("capz-mp-0-12"))
+
+	for _, name := range []string{"capz-mp-0", "capz-mp-1-0", "capz-mp-0-abc", "capz-mp-0--1"} {
+		_, ok := instanceIndex("capz-mp-0", name)
+		g.Expect(ok).To(gomega.BeFalse(), name)
+	}
+}
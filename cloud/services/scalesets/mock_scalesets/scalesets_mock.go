@@ -22,7 +22,7 @@ package mock_scalesets
 
 import (
 	context "context"
-	compute "github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	compute "github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2021-07-01/compute"
 	network "github.com/Azure/azure-sdk-for-go/services/network/mgmt/2019-11-01/network"
 	gomock "github.com/golang/mock/gomock"
 	reflect "reflect"
@@ -138,6 +138,50 @@ func (mr *MockClientMockRecorder) Delete(arg0, arg1, arg2 interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockClient)(nil).Delete), arg0, arg1, arg2)
 }
 
+// DeleteInstances mocks base method.
+func (m *MockClient) DeleteInstances(arg0 context.Context, arg1, arg2 string, arg3 []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteInstances", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteInstances indicates an expected call of DeleteInstances.
+func (mr *MockClientMockRecorder) DeleteInstances(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteInstances", reflect.TypeOf((*MockClient)(nil).DeleteInstances), arg0, arg1, arg2, arg3)
+}
+
+// RunCommand mocks base method.
+func (m *MockClient) RunCommand(arg0 context.Context, arg1, arg2, arg3 string, arg4 compute.RunCommandInput) (compute.RunCommandResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RunCommand", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(compute.RunCommandResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RunCommand indicates an expected call of RunCommand.
+func (mr *MockClientMockRecorder) RunCommand(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RunCommand", reflect.TypeOf((*MockClient)(nil).RunCommand), arg0, arg1, arg2, arg3, arg4)
+}
+
+// GetInstanceView mocks base method.
+func (m *MockClient) GetInstanceView(arg0 context.Context, arg1, arg2, arg3 string) (compute.VirtualMachineScaleSetVMInstanceView, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetInstanceView", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(compute.VirtualMachineScaleSetVMInstanceView)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetInstanceView indicates an expected call of GetInstanceView.
+func (mr *MockClientMockRecorder) GetInstanceView(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInstanceView", reflect.TypeOf((*MockClient)(nil).GetInstanceView), arg0, arg1, arg2, arg3)
+}
+
 // GetPublicIPAddress mocks base method.
 func (m *MockClient) GetPublicIPAddress(arg0 context.Context, arg1, arg2 string) (network.PublicIPAddress, error) {
 	m.ctrl.T.Helper()
@@ -152,3 +196,76 @@ func (mr *MockClientMockRecorder) GetPublicIPAddress(arg0, arg1, arg2 interface{
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPublicIPAddress", reflect.TypeOf((*MockClient)(nil).GetPublicIPAddress), arg0, arg1, arg2)
 }
+
+// ListVirtualMachines mocks base method.
+func (m *MockClient) ListVirtualMachines(arg0 context.Context, arg1 string) ([]compute.VirtualMachine, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListVirtualMachines", arg0, arg1)
+	ret0, _ := ret[0].([]compute.VirtualMachine)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListVirtualMachines indicates an expected call of ListVirtualMachines.
+func (mr *MockClientMockRecorder) ListVirtualMachines(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListVirtualMachines", reflect.TypeOf((*MockClient)(nil).ListVirtualMachines), arg0, arg1)
+}
+
+// CreateVirtualMachine mocks base method.
+func (m *MockClient) CreateVirtualMachine(arg0 context.Context, arg1, arg2 string, arg3 compute.VirtualMachine) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateVirtualMachine", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateVirtualMachine indicates an expected call of CreateVirtualMachine.
+func (mr *MockClientMockRecorder) CreateVirtualMachine(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateVirtualMachine", reflect.TypeOf((*MockClient)(nil).CreateVirtualMachine), arg0, arg1, arg2, arg3)
+}
+
+// DeleteVirtualMachine mocks base method.
+func (m *MockClient) DeleteVirtualMachine(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteVirtualMachine", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteVirtualMachine indicates an expected call of DeleteVirtualMachine.
+func (mr *MockClientMockRecorder) DeleteVirtualMachine(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteVirtualMachine", reflect.TypeOf((*MockClient)(nil).DeleteVirtualMachine), arg0, arg1, arg2)
+}
+
+// RunVirtualMachineCommand mocks base method.
+func (m *MockClient) RunVirtualMachineCommand(arg0 context.Context, arg1, arg2 string, arg3 compute.RunCommandInput) (compute.RunCommandResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RunVirtualMachineCommand", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(compute.RunCommandResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RunVirtualMachineCommand indicates an expected call of RunVirtualMachineCommand.
+func (mr *MockClientMockRecorder) RunVirtualMachineCommand(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RunVirtualMachineCommand", reflect.TypeOf((*MockClient)(nil).RunVirtualMachineCommand), arg0, arg1, arg2, arg3)
+}
+
+// GetVirtualMachineInstanceView mocks base method.
+func (m *MockClient) GetVirtualMachineInstanceView(arg0 context.Context, arg1, arg2 string) (compute.VirtualMachineInstanceView, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetVirtualMachineInstanceView", arg0, arg1, arg2)
+	ret0, _ := ret[0].(compute.VirtualMachineInstanceView)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetVirtualMachineInstanceView indicates an expected call of GetVirtualMachineInstanceView.
+func (mr *MockClientMockRecorder) GetVirtualMachineInstanceView(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVirtualMachineInstanceView", reflect.TypeOf((*MockClient)(nil).GetVirtualMachineInstanceView), arg0, arg1, arg2)
+}
@@ -17,8 +17,10 @@ package scalesets
 
 import (
 	azure "sigs.k8s.io/cluster-api-provider-azure/cloud"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/networkinterfaces"
 	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/publicloadbalancers"
 	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/resourceskus"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/roleassignments"
 )
 
 // Service provides operations on azure resources
@@ -26,6 +28,8 @@ type Service struct {
 	Client
 	ResourceSkusClient        resourceskus.Client
 	PublicLoadBalancersClient publicloadbalancers.Client
+	RoleAssignmentsClient     roleassignments.Client
+	NetworkInterfacesClient   networkinterfaces.Client
 }
 
 // NewService creates a new service.
@@ -34,5 +38,7 @@ func NewService(auth azure.Authorizer) *Service {
 		Client:                    NewClient(auth),
 		ResourceSkusClient:        resourceskus.NewClient(auth),
 		PublicLoadBalancersClient: publicloadbalancers.NewClient(auth),
+		RoleAssignmentsClient:     roleassignments.NewClient(auth),
+		NetworkInterfacesClient:   networkinterfaces.NewClient(auth),
 	}
 }
@@ -19,9 +19,13 @@ package scalesets
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
 	infrav1exp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1alpha3"
 
-	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2021-07-01/compute"
 	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/pkg/errors"
 	"k8s.io/klog"
@@ -29,6 +33,18 @@ import (
 	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
 	azure "sigs.k8s.io/cluster-api-provider-azure/cloud"
 	"sigs.k8s.io/cluster-api-provider-azure/cloud/converters"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/roleassignments"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/virtualmachineextensions"
+)
+
+const (
+	// HealthExtensionName is the name of the Application Health extension of a scale set.
+	HealthExtensionName = "HealthExtension"
+
+	healthExtensionPublisher   = "Microsoft.ManagedServices"
+	healthExtensionLinuxType   = "ApplicationHealthLinux"
+	healthExtensionWindowsType = "ApplicationHealthWindows"
+	healthExtensionVersion     = "1.0"
 )
 
 // Spec contains properties to create a managed cluster.
@@ -50,6 +66,53 @@ type (
 		PublicLoadBalancerName string
 		AdditionalTags         infrav1.Tags
 		AcceleratedNetworking  *bool
+		// BootDiagnosticsStorageURI is the blob endpoint of the storage account used for boot diagnostics.
+		// Boot diagnostics are disabled when empty.
+		BootDiagnosticsStorageURI string
+		// VMExtensions are the extensions installed on every instance of the scale set. A nil list leaves the
+		// extensions of an existing scale set untouched, an empty list removes them.
+		VMExtensions           []ExtensionSpec
+		SubscriptionID         string
+		Identity               infrav1.VMIdentity
+		UserAssignedIdentities []infrav1.UserAssignedIdentity
+		// SystemAssignedIdentityRoles are the roles assigned to the system-assigned identity of the scale set.
+		// The identity is assigned the Contributor role on the resource group of the cluster when empty.
+		SystemAssignedIdentityRoles []infrav1.SystemAssignedIdentityRole
+		// RoleAssignmentIDs are the IDs of the role assignments previously made for the system-assigned identity
+		// of the scale set. The ones that are no longer wanted are deleted.
+		RoleAssignmentIDs []string
+		// Zones are the availability zones the instances are spread across. The scale set is not zonal when empty.
+		Zones         []string
+		ZoneBalance   *bool
+		SpotVMOptions *infrav1.SpotVMOptions
+		ScaleInPolicy infrav1exp.ScaleInPolicy
+		Overprovision *bool
+		// HealthProbe installs the Application Health extension on every instance when set.
+		HealthProbe *HealthProbeSpec
+		// AutomaticRepairsGracePeriod enables automatic repairs of unhealthy instances when set.
+		// Requires HealthProbe.
+		AutomaticRepairsGracePeriod *time.Duration
+		// TerminateNotificationTimeout enables terminate notifications when set.
+		TerminateNotificationTimeout *time.Duration
+		// OrchestrationMode is the orchestration mode of the scale set. In the Flexible mode, the instances are
+		// virtual machines created from the spec, each with its own network interface.
+		OrchestrationMode infrav1exp.OrchestrationMode
+	}
+
+	// HealthProbeSpec specification for the health endpoint probed by the Application Health extension.
+	HealthProbeSpec struct {
+		Port        int32
+		RequestPath string
+	}
+
+	// ExtensionSpec specification for a virtual machine extension of a scale set.
+	ExtensionSpec struct {
+		Name              string
+		Publisher         string
+		Type              string
+		Version           string
+		Settings          map[string]interface{}
+		ProtectedSettings map[string]string
 	}
 )
 
@@ -59,12 +122,20 @@ func (s *Service) Get(ctx context.Context, vmssSpec *Spec) (*infrav1exp.VMSS, er
 		return nil, err
 	}
 
+	if isFlexible(vmss) {
+		vms, err := s.listFlexibleInstances(ctx, vmssSpec.ResourceGroup, to.String(vmss.ID))
+		if err != nil {
+			return nil, err
+		}
+		return converters.SDKToVMSS(vmss, nil, vms), nil
+	}
+
 	vmssInstances, err := s.Client.ListInstances(ctx, vmssSpec.ResourceGroup, vmssSpec.Name)
 	if err != nil {
 		return nil, err
 	}
 
-	return converters.SDKToVMSS(vmss, vmssInstances), nil
+	return converters.SDKToVMSS(vmss, vmssInstances, nil), nil
 }
 
 func (s *Service) Reconcile(ctx context.Context, spec interface{}) error {
@@ -100,6 +171,10 @@ func (s *Service) Reconcile(ctx context.Context, spec interface{}) error {
 		return errors.Wrap(lberr, "failed to get cloud provider LB")
 	}
 
+	if vmssSpec.OrchestrationMode == infrav1exp.FlexibleOrchestrationMode {
+		return s.reconcileFlexible(ctx, vmssSpec, (*lb.BackendAddressPools)[0].ID)
+	}
+
 	backendAddressPools := []compute.SubResource{
 		{
 			ID: (*lb.BackendAddressPools)[0].ID,
@@ -108,13 +183,7 @@ func (s *Service) Reconcile(ctx context.Context, spec interface{}) error {
 
 	vmss := compute.VirtualMachineScaleSet{
 		Location: to.StringPtr(vmssSpec.Location),
-		Tags: converters.TagsToMap(infrav1.Build(infrav1.BuildParams{
-			ClusterName: vmssSpec.ClusterName,
-			Lifecycle:   infrav1.ResourceLifecycleOwned,
-			Name:        to.StringPtr(vmssSpec.MachinePoolName),
-			Role:        to.StringPtr(infrav1.Node),
-			Additional:  vmssSpec.AdditionalTags,
-		})),
+		Tags:     generateTags(*vmssSpec),
 		Sku: &compute.Sku{
 			Name:     to.StringPtr(vmssSpec.Sku),
 			Tier:     to.StringPtr("Standard"),
@@ -122,8 +191,9 @@ func (s *Service) Reconcile(ctx context.Context, spec interface{}) error {
 		},
 		VirtualMachineScaleSetProperties: &compute.VirtualMachineScaleSetProperties{
 			UpgradePolicy: &compute.UpgradePolicy{
-				Mode: compute.Manual,
+				Mode: compute.UpgradeModeManual,
 			},
+			Overprovision: to.BoolPtr(to.Bool(vmssSpec.Overprovision)),
 			VirtualMachineProfile: &compute.VirtualMachineScaleSetVMProfile{
 				OsProfile: &compute.VirtualMachineScaleSetOSProfile{
 					ComputerNamePrefix: to.StringPtr(vmssSpec.Name),
@@ -157,7 +227,7 @@ func (s *Service) Reconcile(ctx context.Context, spec interface{}) error {
 												ID: to.StringPtr(vmssSpec.SubnetID),
 											},
 											Primary:                         to.BoolPtr(true),
-											PrivateIPAddressVersion:         compute.IPv4,
+											PrivateIPAddressVersion:         compute.IPVersionIPv4,
 											LoadBalancerBackendAddressPools: &backendAddressPools,
 										},
 									},
@@ -171,6 +241,52 @@ func (s *Service) Reconcile(ctx context.Context, spec interface{}) error {
 		},
 	}
 
+	if len(vmssSpec.Zones) > 0 {
+		zones := vmssSpec.Zones
+		vmss.Zones = &zones
+		vmss.ZoneBalance = vmssSpec.ZoneBalance
+	}
+
+	if vmssSpec.ScaleInPolicy != "" {
+		vmss.ScaleInPolicy = &compute.ScaleInPolicy{
+			Rules: &[]compute.VirtualMachineScaleSetScaleInRules{compute.VirtualMachineScaleSetScaleInRules(vmssSpec.ScaleInPolicy)},
+		}
+	}
+
+	priority, evictionPolicy, billingProfile, err := getSpotVMOptions(vmssSpec.SpotVMOptions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get Spot VM options")
+	}
+	vmss.VirtualMachineProfile.Priority = priority
+	vmss.VirtualMachineProfile.EvictionPolicy = evictionPolicy
+	vmss.VirtualMachineProfile.BillingProfile = billingProfile
+
+	if vmssSpec.BootDiagnosticsStorageURI != "" {
+		vmss.VirtualMachineProfile.DiagnosticsProfile = &compute.DiagnosticsProfile{
+			BootDiagnostics: &compute.BootDiagnostics{
+				Enabled:    to.BoolPtr(true),
+				StorageURI: to.StringPtr(vmssSpec.BootDiagnosticsStorageURI),
+			},
+		}
+	}
+
+	vmss.AutomaticRepairsPolicy = generateAutomaticRepairsPolicy(*vmssSpec)
+	vmss.VirtualMachineProfile.ScheduledEventsProfile = generateScheduledEventsProfile(*vmssSpec)
+
+	if vmssSpec.VMExtensions != nil || vmssSpec.HealthProbe != nil {
+		extensionProfile, err := generateExtensionProfile(*vmssSpec)
+		if err != nil {
+			return errors.Wrapf(err, "failed to generate extension profile for scale set %s", vmssSpec.Name)
+		}
+		vmss.VirtualMachineProfile.ExtensionProfile = extensionProfile
+	}
+
+	identity, err := generateIdentity(*vmssSpec)
+	if err != nil {
+		return errors.Wrapf(err, "failed to generate identity for scale set %s", vmssSpec.Name)
+	}
+	vmss.Identity = identity
+
 	_, err = s.Client.Get(ctx, vmssSpec.ResourceGroup, vmssSpec.Name)
 	if !azure.ResourceNotFound(err) {
 		if err != nil {
@@ -183,27 +299,102 @@ func (s *Service) Reconcile(ctx context.Context, spec interface{}) error {
 			return errors.Wrapf(err, "failed to generate scale set update parameters for %s", vmssSpec.Name)
 		}
 		update.VirtualMachineProfile.NetworkProfile = nil
-		return s.Client.Update(ctx, vmssSpec.ResourceGroup, vmssSpec.Name, update)
+		if err := s.Client.Update(ctx, vmssSpec.ResourceGroup, vmssSpec.Name, update); err != nil {
+			return err
+		}
+	} else {
+		err = s.Client.CreateOrUpdate(
+			ctx,
+			vmssSpec.ResourceGroup,
+			vmssSpec.Name,
+			vmss)
+		if err != nil {
+			return errors.Wrapf(err, "cannot create VMSS")
+		}
+		klog.V(2).Infof("successfully created VMSS %s ", vmssSpec.Name)
 	}
 
-	err = s.Client.CreateOrUpdate(
-		ctx,
-		vmssSpec.ResourceGroup,
-		vmssSpec.Name,
-		vmss)
+	if vmssSpec.Identity == infrav1.VMIdentitySystemAssigned {
+		if err := s.reconcileRoleAssignments(ctx, vmssSpec); err != nil {
+			return errors.Wrapf(err, "failed to assign roles to the system assigned identity of VMSS %s", vmssSpec.Name)
+		}
+	}
+
+	roleAssignmentsSvc := &roleassignments.Service{Client: s.RoleAssignmentsClient}
+	if err := roleAssignmentsSvc.DeleteStale(ctx, vmssSpec.RoleAssignmentIDs, RoleAssignmentIDs(vmssSpec)); err != nil {
+		return errors.Wrapf(err, "failed to delete stale role assignments of VMSS %s", vmssSpec.Name)
+	}
+
+	return nil
+}
+
+// reconcileRoleAssignments assigns the roles of the scale set spec to the system-assigned identity of the scale set.
+func (s *Service) reconcileRoleAssignments(ctx context.Context, vmssSpec *Spec) error {
+	vmss, err := s.Client.Get(ctx, vmssSpec.ResourceGroup, vmssSpec.Name)
 	if err != nil {
-		return errors.Wrapf(err, "cannot create VMSS")
+		return errors.Wrapf(err, "failed to get VMSS %s", vmssSpec.Name)
+	}
+	if vmss.Identity == nil || vmss.Identity.PrincipalID == nil {
+		return errors.Errorf("VMSS %s does not have a system assigned identity", vmssSpec.Name)
 	}
 
-	klog.V(2).Infof("successfully created VMSS %s ", vmssSpec.Name)
+	roleAssignmentsSvc := &roleassignments.Service{Client: s.RoleAssignmentsClient}
+	for _, roleSpec := range roleAssignmentSpecs(vmssSpec, to.String(vmss.Identity.PrincipalID)) {
+		roleSpec := roleSpec
+		if err := roleAssignmentsSvc.Reconcile(ctx, &roleSpec); err != nil {
+			return err
+		}
+	}
+
+	klog.V(2).Infof("successfully reconciled role assignments for the system assigned identity of VMSS %s", vmssSpec.Name)
 	return nil
 }
 
+// RoleAssignmentIDs returns the IDs of the role assignments of the system-assigned identity of a scale set, or nil
+// if the scale set does not have a system-assigned identity.
+func RoleAssignmentIDs(vmssSpec *Spec) []string {
+	if vmssSpec.Identity != infrav1.VMIdentitySystemAssigned {
+		return nil
+	}
+	var ids []string
+	for _, roleSpec := range roleAssignmentSpecs(vmssSpec, "") {
+		ids = append(ids, roleSpec.ID())
+	}
+	return ids
+}
+
+// roleAssignmentSpecs returns the role assignments of the system-assigned identity of a scale set.
+func roleAssignmentSpecs(vmssSpec *Spec, principalID string) []roleassignments.Spec {
+	vmssID := azure.ScaleSetID(vmssSpec.SubscriptionID, vmssSpec.ResourceGroup, vmssSpec.Name)
+	return roleassignments.SpecsForIdentity(vmssSpec.SubscriptionID, vmssSpec.ResourceGroup, vmssID, principalID, vmssSpec.SystemAssignedIdentityRoles)
+}
+
 func (s *Service) Delete(ctx context.Context, spec interface{}) error {
 	vmssSpec, ok := spec.(*Spec)
 	if !ok {
 		return errors.New("invalid VMSS specification")
 	}
+	roleAssignmentsSvc := &roleassignments.Service{Client: s.RoleAssignmentsClient}
+	if vmssSpec.Identity == infrav1.VMIdentitySystemAssigned {
+		for _, roleSpec := range roleAssignmentSpecs(vmssSpec, "") {
+			roleSpec := roleSpec
+			if err := roleAssignmentsSvc.Delete(ctx, &roleSpec); err != nil {
+				return errors.Wrapf(err, "failed to delete role assignments of VMSS %s", vmssSpec.Name)
+			}
+		}
+	}
+	// The recorded role assignments also include the ones of roles removed from the spec since they were assigned.
+	if err := roleAssignmentsSvc.DeleteStale(ctx, vmssSpec.RoleAssignmentIDs, nil); err != nil {
+		return errors.Wrapf(err, "failed to delete role assignments of VMSS %s", vmssSpec.Name)
+	}
+
+	if vmssSpec.OrchestrationMode == infrav1exp.FlexibleOrchestrationMode {
+		// a scale set in the Flexible orchestration mode cannot be deleted while it has instances
+		if err := s.deleteFlexibleInstances(ctx, vmssSpec); err != nil {
+			return err
+		}
+	}
+
 	klog.V(2).Infof("deleting VMSS %s ", vmssSpec.Name)
 	err := s.Client.Delete(ctx, vmssSpec.ResourceGroup, vmssSpec.Name)
 	if err != nil {
@@ -218,6 +409,174 @@ func (s *Service) Delete(ctx context.Context, spec interface{}) error {
 	return nil
 }
 
+// DeleteInstances deletes the instances of the scale set with the provided instance IDs.
+func (s *Service) DeleteInstances(ctx context.Context, vmssSpec *Spec, instanceIDs []string) error {
+	if len(instanceIDs) == 0 {
+		return nil
+	}
+	klog.V(2).Infof("deleting instances %v of VMSS %s", instanceIDs, vmssSpec.Name)
+	if vmssSpec.OrchestrationMode == infrav1exp.FlexibleOrchestrationMode {
+		// the instances of a scale set in the Flexible orchestration mode are identified by the name of their VM
+		for _, name := range instanceIDs {
+			if err := s.Client.DeleteVirtualMachine(ctx, vmssSpec.ResourceGroup, name); err != nil && !azure.ResourceNotFound(err) {
+				return errors.Wrapf(err, "failed to delete instance %s of VMSS %s in resource group %s", name, vmssSpec.Name, vmssSpec.ResourceGroup)
+			}
+		}
+		klog.V(2).Infof("started deleting instances %v of VMSS %s", instanceIDs, vmssSpec.Name)
+		return nil
+	}
+	if err := s.Client.DeleteInstances(ctx, vmssSpec.ResourceGroup, vmssSpec.Name, instanceIDs); err != nil {
+		return errors.Wrapf(err, "failed to delete instances %v of VMSS %s in resource group %s", instanceIDs, vmssSpec.Name, vmssSpec.ResourceGroup)
+	}
+	klog.V(2).Infof("started deleting instances %v of VMSS %s", instanceIDs, vmssSpec.Name)
+	return nil
+}
+
+// scheduledEventsURI is the Scheduled Events endpoint of the Instance Metadata Service, which is only reachable
+// from the instance itself.
+const scheduledEventsURI = "http://169.254.169.254/metadata/scheduledevents?api-version=2019-08-01"
+
+// instanceNameURI is the Instance Metadata Service endpoint returning the name of the instance, as listed in the
+// resources of a Scheduled Event.
+const instanceNameURI = "http://169.254.169.254/metadata/instance/compute/name?api-version=2019-08-01&format=text"
+
+// terminationApprovedOutput is printed by the approval scripts once the Scheduled Events are approved.
+const terminationApprovedOutput = "termination approved"
+
+// approveTerminationShellScript approves the Terminate Scheduled Events of the Linux instance it runs on. The events
+// are parsed with standard shell tools, as the image may not provide a JSON processor: every event is put on its own
+// line, which works as the events do not contain nested objects.
+var approveTerminationShellScript = []string{
+	"set -e",
+	`name=$(curl -sSf -H Metadata:true "` + instanceNameURI + `")`,
+	`events=$(curl -sSf -H Metadata:true "` + scheduledEventsURI + `")`,
+	`ids=$(echo "${events}" | tr -d ' \n' | sed 's/},{/}\n{/g' | grep '"EventType":"Terminate"' | grep -F "\"${name}\"" | sed 's/.*"EventId":"\([^"]*\)".*/\1/')`,
+	`test -n "${ids}" || { echo "no Terminate Scheduled Event for ${name}" >&2; exit 1; }`,
+	`requests=$(for id in ${ids}; do printf '{"EventId":"%s"},' "${id}"; done)`,
+	`curl -sSf -X POST -H Metadata:true -d "{\"StartRequests\":[${requests%,}]}" "` + scheduledEventsURI + `"`,
+	`echo "` + terminationApprovedOutput + `"`,
+}
+
+// approveTerminationPowerShellScript approves the Terminate Scheduled Events of the Windows instance it runs on.
+var approveTerminationPowerShellScript = []string{
+	`$ErrorActionPreference = "Stop"`,
+	`$headers = @{Metadata = "true"}`,
+	`$name = Invoke-RestMethod -Headers $headers -Uri "` + instanceNameURI + `"`,
+	`$events = Invoke-RestMethod -Headers $headers -Uri "` + scheduledEventsURI + `"`,
+	`$requests = @($events.Events | Where-Object { $_.EventType -eq "Terminate" -and $_.Resources -contains $name } | ForEach-Object { @{EventId = $_.EventId} })`,
+	`if ($requests.Count -eq 0) { throw "no Terminate Scheduled Event for $name" }`,
+	`Invoke-RestMethod -Method Post -Headers $headers -ContentType "application/json" -Uri "` + scheduledEventsURI + `" -Body (ConvertTo-Json @{StartRequests = $requests})`,
+	`Write-Output "` + terminationApprovedOutput + `"`,
+}
+
+// ApproveTermination approves the Terminate Scheduled Event of an instance of the scale set, so that Azure deletes
+// the instance without waiting for the terminate notification timeout. Scheduled Events can only be approved from
+// the instance itself, so the approval is sent by a command run on the instance, whose output is checked.
+func (s *Service) ApproveTermination(ctx context.Context, vmssSpec *Spec, instanceID string) error {
+	input := compute.RunCommandInput{
+		CommandID: to.StringPtr("RunShellScript"),
+		Script:    &approveTerminationShellScript,
+	}
+	if strings.EqualFold(vmssSpec.OSDisk.OSType, string(compute.OperatingSystemTypesWindows)) {
+		input = compute.RunCommandInput{
+			CommandID: to.StringPtr("RunPowerShellScript"),
+			Script:    &approveTerminationPowerShellScript,
+		}
+	}
+
+	klog.V(2).Infof("approving termination of instance %s of VMSS %s", instanceID, vmssSpec.Name)
+	var (
+		result compute.RunCommandResult
+		err    error
+	)
+	if vmssSpec.OrchestrationMode == infrav1exp.FlexibleOrchestrationMode {
+		// the instances of a scale set in the Flexible orchestration mode are identified by the name of their VM
+		result, err = s.Client.RunVirtualMachineCommand(ctx, vmssSpec.ResourceGroup, instanceID, input)
+	} else {
+		result, err = s.Client.RunCommand(ctx, vmssSpec.ResourceGroup, vmssSpec.Name, instanceID, input)
+	}
+	if err == nil {
+		err = checkApprovalOutput(result)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to approve termination of instance %s of VMSS %s in resource group %s", instanceID, vmssSpec.Name, vmssSpec.ResourceGroup)
+	}
+	return nil
+}
+
+// InstanceBootDiagnostics is the boot diagnostics data of an instance of a scale set.
+type InstanceBootDiagnostics struct {
+	// ProvisionedTime is the time the provisioning of the instance last succeeded, zero if it has not.
+	ProvisionedTime time.Time
+	// SerialConsoleLogURI is the URI of the blob holding the serial console log of the instance, empty when boot
+	// diagnostics are disabled.
+	SerialConsoleLogURI string
+}
+
+// GetInstanceBootDiagnostics returns the boot diagnostics data of an instance of the scale set, read from its
+// instance view.
+func (s *Service) GetInstanceBootDiagnostics(ctx context.Context, vmssSpec *Spec, instanceID string) (*InstanceBootDiagnostics, error) {
+	var (
+		statuses        *[]compute.InstanceViewStatus
+		bootDiagnostics *compute.BootDiagnosticsInstanceView
+	)
+	if vmssSpec.OrchestrationMode == infrav1exp.FlexibleOrchestrationMode {
+		instanceView, err := s.Client.GetVirtualMachineInstanceView(ctx, vmssSpec.ResourceGroup, instanceID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get instance view of instance %s of VMSS %s", instanceID, vmssSpec.Name)
+		}
+		statuses, bootDiagnostics = instanceView.Statuses, instanceView.BootDiagnostics
+	} else {
+		instanceView, err := s.Client.GetInstanceView(ctx, vmssSpec.ResourceGroup, vmssSpec.Name, instanceID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get instance view of instance %s of VMSS %s", instanceID, vmssSpec.Name)
+		}
+		statuses, bootDiagnostics = instanceView.Statuses, instanceView.BootDiagnostics
+	}
+
+	result := &InstanceBootDiagnostics{}
+	if statuses != nil {
+		for _, status := range *statuses {
+			if strings.EqualFold(to.String(status.Code), "ProvisioningState/succeeded") && status.Time != nil {
+				result.ProvisionedTime = status.Time.Time
+			}
+		}
+	}
+	if bootDiagnostics != nil {
+		result.SerialConsoleLogURI = to.String(bootDiagnostics.SerialConsoleLogBlobURI)
+	}
+	return result, nil
+}
+
+// checkApprovalOutput returns an error with the output of the approval script unless it reports the approval. The
+// output of a failed script is still returned with a succeeded provisioning state by Run Command.
+func checkApprovalOutput(result compute.RunCommandResult) error {
+	var messages []string
+	if result.Value != nil {
+		for _, status := range *result.Value {
+			message := strings.TrimSpace(to.String(status.Message))
+			if strings.Contains(message, terminationApprovedOutput) {
+				return nil
+			}
+			if message != "" {
+				messages = append(messages, message)
+			}
+		}
+	}
+	return errors.Errorf("the approval script did not succeed: %s", strings.Join(messages, "; "))
+}
+
+// generateTags returns the tags of the scale set.
+func generateTags(vmssSpec Spec) map[string]*string {
+	return converters.TagsToMap(infrav1.Build(infrav1.BuildParams{
+		ClusterName: vmssSpec.ClusterName,
+		Lifecycle:   infrav1.ResourceLifecycleOwned,
+		Name:        to.StringPtr(vmssSpec.MachinePoolName),
+		Role:        to.StringPtr(infrav1.Node),
+		Additional:  vmssSpec.AdditionalTags,
+	}))
+}
+
 // generateStorageProfile generates a pointer to a compute.VirtualMachineScaleSetStorageProfile which can utilized for VM creation.
 func generateStorageProfile(vmssSpec Spec) (*compute.VirtualMachineScaleSetStorageProfile, error) {
 	storageProfile := &compute.VirtualMachineScaleSetStorageProfile{
@@ -241,6 +600,142 @@ func generateStorageProfile(vmssSpec Spec) (*compute.VirtualMachineScaleSetStora
 	return storageProfile, nil
 }
 
+// generateExtensionProfile generates a pointer to a compute.VirtualMachineScaleSetExtensionProfile which contains the
+// VM extensions of the scale set.
+func generateExtensionProfile(vmssSpec Spec) (*compute.VirtualMachineScaleSetExtensionProfile, error) {
+	extensions := make([]compute.VirtualMachineScaleSetExtension, 0, len(vmssSpec.VMExtensions))
+	for _, extSpec := range vmssSpec.VMExtensions {
+		forceUpdateTag, err := virtualmachineextensions.ProtectedSettingsHash(extSpec.ProtectedSettings)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to hash protected settings of VM extension %s", extSpec.Name)
+		}
+		extension := compute.VirtualMachineScaleSetExtension{
+			Name: to.StringPtr(extSpec.Name),
+			VirtualMachineScaleSetExtensionProperties: &compute.VirtualMachineScaleSetExtensionProperties{
+				Publisher:               to.StringPtr(extSpec.Publisher),
+				Type:                    to.StringPtr(extSpec.Type),
+				TypeHandlerVersion:      to.StringPtr(extSpec.Version),
+				AutoUpgradeMinorVersion: to.BoolPtr(true),
+				ForceUpdateTag:          to.StringPtr(forceUpdateTag),
+			},
+		}
+		if len(extSpec.Settings) > 0 {
+			extension.Settings = extSpec.Settings
+		}
+		if len(extSpec.ProtectedSettings) > 0 {
+			extension.ProtectedSettings = extSpec.ProtectedSettings
+		}
+		extensions = append(extensions, extension)
+	}
+
+	if vmssSpec.HealthProbe != nil {
+		extensions = append(extensions, generateHealthExtension(vmssSpec))
+	}
+
+	return &compute.VirtualMachineScaleSetExtensionProfile{
+		Extensions: &extensions,
+	}, nil
+}
+
+// generateHealthExtension returns the Application Health extension probing the health endpoint of the instances.
+func generateHealthExtension(vmssSpec Spec) compute.VirtualMachineScaleSetExtension {
+	extensionType := healthExtensionLinuxType
+	if compute.OperatingSystemTypes(vmssSpec.OSDisk.OSType) == compute.OperatingSystemTypesWindows {
+		extensionType = healthExtensionWindowsType
+	}
+	return compute.VirtualMachineScaleSetExtension{
+		Name: to.StringPtr(HealthExtensionName),
+		VirtualMachineScaleSetExtensionProperties: &compute.VirtualMachineScaleSetExtensionProperties{
+			Publisher:               to.StringPtr(healthExtensionPublisher),
+			Type:                    to.StringPtr(extensionType),
+			TypeHandlerVersion:      to.StringPtr(healthExtensionVersion),
+			AutoUpgradeMinorVersion: to.BoolPtr(true),
+			Settings: map[string]interface{}{
+				"protocol":    "http",
+				"port":        vmssSpec.HealthProbe.Port,
+				"requestPath": vmssSpec.HealthProbe.RequestPath,
+			},
+		},
+	}
+}
+
+// generateAutomaticRepairsPolicy returns the automatic repairs policy of the scale set. Automatic repairs are
+// explicitly disabled when not requested, so that they are turned off on existing scale sets.
+func generateAutomaticRepairsPolicy(vmssSpec Spec) *compute.AutomaticRepairsPolicy {
+	if vmssSpec.AutomaticRepairsGracePeriod == nil || vmssSpec.HealthProbe == nil {
+		return &compute.AutomaticRepairsPolicy{
+			Enabled: to.BoolPtr(false),
+		}
+	}
+	return &compute.AutomaticRepairsPolicy{
+		Enabled:     to.BoolPtr(true),
+		GracePeriod: to.StringPtr(fmt.Sprintf("PT%dM", int(vmssSpec.AutomaticRepairsGracePeriod.Minutes()))),
+	}
+}
+
+// generateIdentity generates a pointer to a compute.VirtualMachineScaleSetIdentity from the identity of the scale set spec.
+func generateIdentity(vmssSpec Spec) (*compute.VirtualMachineScaleSetIdentity, error) {
+	switch vmssSpec.Identity {
+	case infrav1.VMIdentitySystemAssigned:
+		return &compute.VirtualMachineScaleSetIdentity{
+			Type: compute.ResourceIdentityTypeSystemAssigned,
+		}, nil
+	case infrav1.VMIdentityUserAssigned:
+		if len(vmssSpec.UserAssignedIdentities) == 0 {
+			return nil, errors.New("the user-assigned identity provider ids must not be null or empty for 'UserAssigned' identity type")
+		}
+		// The user identity dictionary key references will be ARM resource ids in the form:
+		// '/subscriptions/{subscriptionId}/resourceGroups/{resourceGroupName}/providers/Microsoft.ManagedIdentity/userAssignedIdentities/{identityName}'.
+		userIdentitiesMap := make(map[string]*compute.VirtualMachineScaleSetIdentityUserAssignedIdentitiesValue, len(vmssSpec.UserAssignedIdentities))
+		for _, id := range vmssSpec.UserAssignedIdentities {
+			userIdentitiesMap[strings.TrimPrefix(id.ProviderID, "azure:///")] = &compute.VirtualMachineScaleSetIdentityUserAssignedIdentitiesValue{}
+		}
+		return &compute.VirtualMachineScaleSetIdentity{
+			Type:                   compute.ResourceIdentityTypeUserAssigned,
+			UserAssignedIdentities: userIdentitiesMap,
+		}, nil
+	}
+	return nil, nil
+}
+
+// generateScheduledEventsProfile returns the scheduled events profile of the scale set. Terminate notifications are
+// explicitly disabled when not requested, so that they are turned off on existing scale sets.
+func generateScheduledEventsProfile(vmssSpec Spec) *compute.ScheduledEventsProfile {
+	if vmssSpec.TerminateNotificationTimeout == nil {
+		return &compute.ScheduledEventsProfile{
+			TerminateNotificationProfile: &compute.TerminateNotificationProfile{
+				Enable: to.BoolPtr(false),
+			},
+		}
+	}
+	return &compute.ScheduledEventsProfile{
+		TerminateNotificationProfile: &compute.TerminateNotificationProfile{
+			Enable:           to.BoolPtr(true),
+			NotBeforeTimeout: to.StringPtr(fmt.Sprintf("PT%dM", int(vmssSpec.TerminateNotificationTimeout.Minutes()))),
+		},
+	}
+}
+
+// getSpotVMOptions returns the priority, eviction policy and billing profile of the instances of a scale set.
+// Evicted Spot instances are deleted rather than deallocated, so that they are recreated when capacity is available.
+func getSpotVMOptions(spotVMOptions *infrav1.SpotVMOptions) (compute.VirtualMachinePriorityTypes, compute.VirtualMachineEvictionPolicyTypes, *compute.BillingProfile, error) {
+	// Spot VM not requested, return zero values to apply defaults
+	if spotVMOptions == nil {
+		return compute.VirtualMachinePriorityTypes(""), compute.VirtualMachineEvictionPolicyTypes(""), nil, nil
+	}
+	var billingProfile *compute.BillingProfile
+	if spotVMOptions.MaxPrice != nil {
+		maxPrice, err := strconv.ParseFloat(*spotVMOptions.MaxPrice, 64)
+		if err != nil {
+			return compute.VirtualMachinePriorityTypes(""), compute.VirtualMachineEvictionPolicyTypes(""), nil, err
+		}
+		billingProfile = &compute.BillingProfile{
+			MaxPrice: &maxPrice,
+		}
+	}
+	return compute.VirtualMachinePriorityTypesSpot, compute.VirtualMachineEvictionPolicyTypesDelete, billingProfile, nil
+}
+
 func getVMSSUpdateFromVMSS(vmss compute.VirtualMachineScaleSet) (compute.VirtualMachineScaleSetUpdate, error) {
 	json, err := vmss.MarshalJSON()
 	if err != nil {
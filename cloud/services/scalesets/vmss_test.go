@@ -21,10 +21,13 @@ import (
 	"fmt"
 	"net/http"
 	"testing"
+	"time"
 
-	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/Azure/azure-sdk-for-go/profiles/2019-03-01/authorization/mgmt/authorization"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2021-07-01/compute"
 	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2019-06-01/network"
 	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/date"
 	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/golang/mock/gomock"
 	"github.com/onsi/gomega"
@@ -40,6 +43,7 @@ import (
 	"sigs.k8s.io/cluster-api-provider-azure/cloud/scope"
 	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/publicloadbalancers/mock_publicloadbalancers"
 	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/resourceskus/mock_resourceskus"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/roleassignments/mock_roleassignments"
 	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/scalesets/mock_scalesets"
 	infrav1exp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1alpha3"
 	"sigs.k8s.io/cluster-api-provider-azure/internal/test/matchers"
@@ -294,7 +298,11 @@ func TestService_Reconcile(t *testing.T) {
 					},
 					VirtualMachineScaleSetProperties: &compute.VirtualMachineScaleSetProperties{
 						UpgradePolicy: &compute.UpgradePolicy{
-							Mode: compute.Manual,
+							Mode: compute.UpgradeModeManual,
+						},
+						Overprovision: to.BoolPtr(false),
+						AutomaticRepairsPolicy: &compute.AutomaticRepairsPolicy{
+							Enabled: to.BoolPtr(false),
 						},
 						VirtualMachineProfile: &compute.VirtualMachineScaleSetVMProfile{
 							OsProfile: &compute.VirtualMachineScaleSetOSProfile{
@@ -314,6 +322,11 @@ func TestService_Reconcile(t *testing.T) {
 								},
 							},
 							StorageProfile: storageProfile,
+							ScheduledEventsProfile: &compute.ScheduledEventsProfile{
+								TerminateNotificationProfile: &compute.TerminateNotificationProfile{
+									Enable: to.BoolPtr(false),
+								},
+							},
 							NetworkProfile: &compute.VirtualMachineScaleSetNetworkProfile{
 								NetworkInterfaceConfigurations: &[]compute.VirtualMachineScaleSetNetworkConfiguration{
 									{
@@ -330,7 +343,7 @@ func TestService_Reconcile(t *testing.T) {
 															ID: to.StringPtr(scope.AzureCluster.Spec.NetworkSpec.Subnets[0].ID),
 														},
 														Primary:                         to.BoolPtr(true),
-														PrivateIPAddressVersion:         compute.IPv4,
+														PrivateIPAddressVersion:         compute.IPVersionIPv4,
 														LoadBalancerBackendAddressPools: &[]compute.SubResource{{ID: to.StringPtr("cluster-name-outboundBackendPool")}},
 													},
 												},
@@ -406,7 +419,11 @@ func TestService_Reconcile(t *testing.T) {
 					},
 					VirtualMachineScaleSetProperties: &compute.VirtualMachineScaleSetProperties{
 						UpgradePolicy: &compute.UpgradePolicy{
-							Mode: compute.Manual,
+							Mode: compute.UpgradeModeManual,
+						},
+						Overprovision: to.BoolPtr(false),
+						AutomaticRepairsPolicy: &compute.AutomaticRepairsPolicy{
+							Enabled: to.BoolPtr(false),
 						},
 						VirtualMachineProfile: &compute.VirtualMachineScaleSetVMProfile{
 							OsProfile: &compute.VirtualMachineScaleSetOSProfile{
@@ -426,6 +443,11 @@ func TestService_Reconcile(t *testing.T) {
 								},
 							},
 							StorageProfile: storageProfile,
+							ScheduledEventsProfile: &compute.ScheduledEventsProfile{
+								TerminateNotificationProfile: &compute.TerminateNotificationProfile{
+									Enable: to.BoolPtr(false),
+								},
+							},
 							NetworkProfile: &compute.VirtualMachineScaleSetNetworkProfile{
 								NetworkInterfaceConfigurations: &[]compute.VirtualMachineScaleSetNetworkConfiguration{
 									{
@@ -442,7 +464,7 @@ func TestService_Reconcile(t *testing.T) {
 															ID: to.StringPtr(scope.AzureCluster.Spec.NetworkSpec.Subnets[0].ID),
 														},
 														Primary:                         to.BoolPtr(true),
-														PrivateIPAddressVersion:         compute.IPv4,
+														PrivateIPAddressVersion:         compute.IPVersionIPv4,
 														LoadBalancerBackendAddressPools: &[]compute.SubResource{{ID: to.StringPtr("cluster-name-outboundBackendPool")}},
 													},
 												},
@@ -518,7 +540,11 @@ func TestService_Reconcile(t *testing.T) {
 					},
 					VirtualMachineScaleSetProperties: &compute.VirtualMachineScaleSetProperties{
 						UpgradePolicy: &compute.UpgradePolicy{
-							Mode: compute.Manual,
+							Mode: compute.UpgradeModeManual,
+						},
+						Overprovision: to.BoolPtr(false),
+						AutomaticRepairsPolicy: &compute.AutomaticRepairsPolicy{
+							Enabled: to.BoolPtr(false),
 						},
 						VirtualMachineProfile: &compute.VirtualMachineScaleSetVMProfile{
 							OsProfile: &compute.VirtualMachineScaleSetOSProfile{
@@ -538,6 +564,11 @@ func TestService_Reconcile(t *testing.T) {
 								},
 							},
 							StorageProfile: storageProfile,
+							ScheduledEventsProfile: &compute.ScheduledEventsProfile{
+								TerminateNotificationProfile: &compute.TerminateNotificationProfile{
+									Enable: to.BoolPtr(false),
+								},
+							},
 							NetworkProfile: &compute.VirtualMachineScaleSetNetworkProfile{
 								NetworkInterfaceConfigurations: &[]compute.VirtualMachineScaleSetNetworkConfiguration{
 									{
@@ -554,7 +585,7 @@ func TestService_Reconcile(t *testing.T) {
 															ID: to.StringPtr(scope.AzureCluster.Spec.NetworkSpec.Subnets[0].ID),
 														},
 														Primary:                         to.BoolPtr(true),
-														PrivateIPAddressVersion:         compute.IPv4,
+														PrivateIPAddressVersion:         compute.IPVersionIPv4,
 														LoadBalancerBackendAddressPools: &[]compute.SubResource{{ID: to.StringPtr("cluster-name-outboundBackendPool")}},
 													},
 												},
@@ -581,7 +612,11 @@ func TestService_Reconcile(t *testing.T) {
 					},
 					VirtualMachineScaleSetUpdateProperties: &compute.VirtualMachineScaleSetUpdateProperties{
 						UpgradePolicy: &compute.UpgradePolicy{
-							Mode: compute.Manual,
+							Mode: compute.UpgradeModeManual,
+						},
+						Overprovision: to.BoolPtr(false),
+						AutomaticRepairsPolicy: &compute.AutomaticRepairsPolicy{
+							Enabled: to.BoolPtr(false),
 						},
 						VirtualMachineProfile: &compute.VirtualMachineScaleSetUpdateVMProfile{
 							OsProfile: &compute.VirtualMachineScaleSetUpdateOSProfile{
@@ -605,6 +640,11 @@ func TestService_Reconcile(t *testing.T) {
 									ManagedDisk: &compute.VirtualMachineScaleSetManagedDiskParameters{StorageAccountType: "accountType"},
 								},
 							},
+							ScheduledEventsProfile: &compute.ScheduledEventsProfile{
+								TerminateNotificationProfile: &compute.TerminateNotificationProfile{
+									Enable: to.BoolPtr(false),
+								},
+							},
 						},
 					},
 				}
@@ -618,6 +658,140 @@ func TestService_Reconcile(t *testing.T) {
 				g.Expect(err).ToNot(gomega.HaveOccurred())
 			},
 		},
+		{
+			Name: "WithSystemAssignedIdentity",
+			SpecFactory: func(g *gomega.GomegaWithT, scope *scope.ClusterScope, mpScope *scope.MachinePoolScope) interface{} {
+				return &Spec{
+					Name:                   mpScope.Name(),
+					ResourceGroup:          scope.AzureCluster.Spec.ResourceGroup,
+					SubscriptionID:         scope.AzureCluster.Spec.SubscriptionID,
+					Location:               scope.AzureCluster.Spec.Location,
+					ClusterName:            scope.Cluster.Name,
+					SubnetID:               scope.AzureCluster.Spec.NetworkSpec.Subnets[0].ID,
+					PublicLoadBalancerName: scope.Cluster.Name,
+					MachinePoolName:        mpScope.Name(),
+					Sku:                    "skuName",
+					Capacity:               2,
+					SSHKeyData:             "sshKeyData",
+					OSDisk: infrav1.OSDisk{
+						OSType:     "Linux",
+						DiskSizeGB: 120,
+						ManagedDisk: infrav1.ManagedDisk{
+							StorageAccountType: "accountType",
+						},
+					},
+					Image: &infrav1.Image{
+						ID: to.StringPtr("image"),
+					},
+					CustomData: "customData",
+					Identity:   infrav1.VMIdentitySystemAssigned,
+					SystemAssignedIdentityRoles: []infrav1.SystemAssignedIdentityRole{
+						{
+							DefinitionID: "acdd72a7-3385-48ef-bd42-f606fba81ae7",
+						},
+					},
+					RoleAssignmentIDs: []string{"/subscriptions/123/providers/Microsoft.Authorization/roleAssignments/contributor-assignment"},
+				}
+			},
+			Setup: func(ctx context.Context, g *gomega.GomegaWithT, svc *Service, scope *scope.ClusterScope, mpScope *scope.MachinePoolScope, spec *Spec) {
+				mockCtrl := gomock.NewController(t)
+				vmssMock := mock_scalesets.NewMockClient(mockCtrl)
+				svc.Client = vmssMock
+				skusMock := mock_resourceskus.NewMockClient(mockCtrl)
+				svc.ResourceSkusClient = skusMock
+				lbMock := mock_publicloadbalancers.NewMockClient(mockCtrl)
+				svc.PublicLoadBalancersClient = lbMock
+				roleAssignmentsMock := mock_roleassignments.NewMockClient(mockCtrl)
+				svc.RoleAssignmentsClient = roleAssignmentsMock
+
+				skusMock.EXPECT().HasAcceleratedNetworking(gomock.Any(), gomock.Any()).Return(false, nil)
+				lbMock.EXPECT().Get(gomock.Any(), scope.AzureCluster.Spec.ResourceGroup, spec.ClusterName).Return(getFakeNodeOutboundLoadBalancer(), nil)
+				gomock.InOrder(
+					vmssMock.EXPECT().Get(gomock.Any(), scope.AzureCluster.Spec.ResourceGroup, spec.Name).Return(compute.VirtualMachineScaleSet{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found")),
+					vmssMock.EXPECT().CreateOrUpdate(gomock.Any(), scope.AzureCluster.Spec.ResourceGroup, spec.Name, gomock.Any()).
+						Do(func(_ context.Context, _, _ string, vmss compute.VirtualMachineScaleSet) {
+							g.Expect(vmss.Identity).To(gomega.Equal(&compute.VirtualMachineScaleSetIdentity{Type: compute.ResourceIdentityTypeSystemAssigned}))
+						}).Return(nil),
+					vmssMock.EXPECT().Get(gomock.Any(), scope.AzureCluster.Spec.ResourceGroup, spec.Name).Return(compute.VirtualMachineScaleSet{
+						Identity: &compute.VirtualMachineScaleSetIdentity{
+							PrincipalID: to.StringPtr("principal-id"),
+						},
+					}, nil),
+				)
+				roleAssignmentsMock.EXPECT().Get(gomock.Any(), "/subscriptions/123/resourceGroups/my-rg", gomock.Any()).
+					Return(authorization.RoleAssignment{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				roleAssignmentsMock.EXPECT().Create(gomock.Any(), "/subscriptions/123/resourceGroups/my-rg", gomock.Any(), gomock.Any()).
+					Do(func(_ context.Context, _, _ string, params authorization.RoleAssignmentCreateParameters) {
+						g.Expect(to.String(params.Properties.PrincipalID)).To(gomega.Equal("principal-id"))
+						g.Expect(to.String(params.Properties.RoleDefinitionID)).To(gomega.Equal("/subscriptions/123/providers/Microsoft.Authorization/roleDefinitions/acdd72a7-3385-48ef-bd42-f606fba81ae7"))
+					}).Return(authorization.RoleAssignment{}, nil)
+				roleAssignmentsMock.EXPECT().Delete(gomock.Any(), "/subscriptions/123", "contributor-assignment").Return(authorization.RoleAssignment{}, nil)
+			},
+			Expect: func(ctx context.Context, g *gomega.GomegaWithT, err error) {
+				g.Expect(err).ToNot(gomega.HaveOccurred())
+			},
+		},
+		{
+			Name: "WithZonesSpotAndScaleInPolicy",
+			SpecFactory: func(g *gomega.GomegaWithT, scope *scope.ClusterScope, mpScope *scope.MachinePoolScope) interface{} {
+				return &Spec{
+					Name:                   mpScope.Name(),
+					ResourceGroup:          scope.AzureCluster.Spec.ResourceGroup,
+					Location:               scope.AzureCluster.Spec.Location,
+					ClusterName:            scope.Cluster.Name,
+					SubnetID:               scope.AzureCluster.Spec.NetworkSpec.Subnets[0].ID,
+					PublicLoadBalancerName: scope.Cluster.Name,
+					MachinePoolName:        mpScope.Name(),
+					Sku:                    "skuName",
+					Capacity:               2,
+					SSHKeyData:             "sshKeyData",
+					OSDisk: infrav1.OSDisk{
+						OSType:     "Linux",
+						DiskSizeGB: 120,
+						ManagedDisk: infrav1.ManagedDisk{
+							StorageAccountType: "accountType",
+						},
+					},
+					Image: &infrav1.Image{
+						ID: to.StringPtr("image"),
+					},
+					CustomData:    "customData",
+					Zones:         []string{"1", "2", "3"},
+					ZoneBalance:   to.BoolPtr(true),
+					SpotVMOptions: &infrav1.SpotVMOptions{MaxPrice: to.StringPtr("0.05")},
+					ScaleInPolicy: infrav1exp.OldestVMScaleInPolicy,
+					Overprovision: to.BoolPtr(true),
+				}
+			},
+			Setup: func(ctx context.Context, g *gomega.GomegaWithT, svc *Service, scope *scope.ClusterScope, mpScope *scope.MachinePoolScope, spec *Spec) {
+				mockCtrl := gomock.NewController(t)
+				vmssMock := mock_scalesets.NewMockClient(mockCtrl)
+				svc.Client = vmssMock
+				skusMock := mock_resourceskus.NewMockClient(mockCtrl)
+				svc.ResourceSkusClient = skusMock
+				lbMock := mock_publicloadbalancers.NewMockClient(mockCtrl)
+				svc.PublicLoadBalancersClient = lbMock
+
+				skusMock.EXPECT().HasAcceleratedNetworking(gomock.Any(), gomock.Any()).Return(false, nil)
+				lbMock.EXPECT().Get(gomock.Any(), scope.AzureCluster.Spec.ResourceGroup, spec.ClusterName).Return(getFakeNodeOutboundLoadBalancer(), nil)
+				vmssMock.EXPECT().Get(gomock.Any(), scope.AzureCluster.Spec.ResourceGroup, spec.Name).Return(compute.VirtualMachineScaleSet{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				vmssMock.EXPECT().CreateOrUpdate(gomock.Any(), scope.AzureCluster.Spec.ResourceGroup, spec.Name, gomock.Any()).
+					Do(func(_ context.Context, _, _ string, vmss compute.VirtualMachineScaleSet) {
+						g.Expect(vmss.Zones).To(gomega.Equal(&[]string{"1", "2", "3"}))
+						g.Expect(vmss.ZoneBalance).To(gomega.Equal(to.BoolPtr(true)))
+						g.Expect(vmss.Overprovision).To(gomega.Equal(to.BoolPtr(true)))
+						g.Expect(vmss.ScaleInPolicy).To(gomega.Equal(&compute.ScaleInPolicy{
+							Rules: &[]compute.VirtualMachineScaleSetScaleInRules{compute.VirtualMachineScaleSetScaleInRulesOldestVM},
+						}))
+						g.Expect(vmss.VirtualMachineProfile.Priority).To(gomega.Equal(compute.VirtualMachinePriorityTypesSpot))
+						g.Expect(vmss.VirtualMachineProfile.EvictionPolicy).To(gomega.Equal(compute.VirtualMachineEvictionPolicyTypesDelete))
+						g.Expect(vmss.VirtualMachineProfile.BillingProfile).To(gomega.Equal(&compute.BillingProfile{MaxPrice: to.Float64Ptr(0.05)}))
+					}).Return(nil)
+			},
+			Expect: func(ctx context.Context, g *gomega.GomegaWithT, err error) {
+				g.Expect(err).ToNot(gomega.HaveOccurred())
+			},
+		},
 	}
 
 	for _, c := range cases {
@@ -704,6 +878,64 @@ func TestService_Delete(t *testing.T) {
 				g.Expect(err).ToNot(gomega.HaveOccurred())
 			},
 		},
+		{
+			Name: "WithSystemAssignedIdentity",
+			SpecFactory: func(g *gomega.GomegaWithT, scope *scope.ClusterScope, mpScope *scope.MachinePoolScope) interface{} {
+				return &Spec{
+					Name:           mpScope.Name(),
+					ResourceGroup:  scope.AzureCluster.Spec.ResourceGroup,
+					SubscriptionID: scope.AzureCluster.Spec.SubscriptionID,
+					Identity:       infrav1.VMIdentitySystemAssigned,
+				}
+			},
+			Setup: func(ctx context.Context, g *gomega.GomegaWithT, svc *Service, scope *scope.ClusterScope, mpScope *scope.MachinePoolScope) {
+				mockCtrl := gomock.NewController(t)
+				vmssMock := mock_scalesets.NewMockClient(mockCtrl)
+				svc.Client = vmssMock
+				roleAssignmentsMock := mock_roleassignments.NewMockClient(mockCtrl)
+				svc.RoleAssignmentsClient = roleAssignmentsMock
+				gomock.InOrder(
+					roleAssignmentsMock.EXPECT().Delete(gomock.Any(), "/subscriptions/123/resourceGroups/my-rg", gomock.Any()).Return(authorization.RoleAssignment{}, nil),
+					vmssMock.EXPECT().Delete(gomock.Any(), scope.AzureCluster.Spec.ResourceGroup, mpScope.Name()).Return(nil),
+				)
+			},
+			Expect: func(ctx context.Context, g *gomega.GomegaWithT, err error) {
+				g.Expect(err).ToNot(gomega.HaveOccurred())
+			},
+		},
+		{
+			Name: "WithRoleAssignmentsOfRemovedRoles",
+			SpecFactory: func(g *gomega.GomegaWithT, scope *scope.ClusterScope, mpScope *scope.MachinePoolScope) interface{} {
+				return &Spec{
+					Name:           mpScope.Name(),
+					ResourceGroup:  scope.AzureCluster.Spec.ResourceGroup,
+					SubscriptionID: scope.AzureCluster.Spec.SubscriptionID,
+					Identity:       infrav1.VMIdentitySystemAssigned,
+					SystemAssignedIdentityRoles: []infrav1.SystemAssignedIdentityRole{
+						{
+							DefinitionID: "acdd72a7-3385-48ef-bd42-f606fba81ae7",
+							Scope:        "/subscriptions/123/resourceGroups/other-rg",
+						},
+					},
+					RoleAssignmentIDs: []string{"/subscriptions/123/providers/Microsoft.Authorization/roleAssignments/contributor-assignment"},
+				}
+			},
+			Setup: func(ctx context.Context, g *gomega.GomegaWithT, svc *Service, scope *scope.ClusterScope, mpScope *scope.MachinePoolScope) {
+				mockCtrl := gomock.NewController(t)
+				vmssMock := mock_scalesets.NewMockClient(mockCtrl)
+				svc.Client = vmssMock
+				roleAssignmentsMock := mock_roleassignments.NewMockClient(mockCtrl)
+				svc.RoleAssignmentsClient = roleAssignmentsMock
+				gomock.InOrder(
+					roleAssignmentsMock.EXPECT().Delete(gomock.Any(), "/subscriptions/123/resourceGroups/other-rg", gomock.Any()).Return(authorization.RoleAssignment{}, nil),
+					roleAssignmentsMock.EXPECT().Delete(gomock.Any(), "/subscriptions/123", "contributor-assignment").Return(authorization.RoleAssignment{}, nil),
+					vmssMock.EXPECT().Delete(gomock.Any(), scope.AzureCluster.Spec.ResourceGroup, mpScope.Name()).Return(nil),
+				)
+			},
+			Expect: func(ctx context.Context, g *gomega.GomegaWithT, err error) {
+				g.Expect(err).ToNot(gomega.HaveOccurred())
+			},
+		},
 	}
 
 	for _, c := range cases {
@@ -725,6 +957,23 @@ func TestService_Delete(t *testing.T) {
 	}
 }
 
+func TestRoleAssignmentIDs(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	spec := &Spec{
+		Name:           "my-vmss",
+		ResourceGroup:  "my-rg",
+		SubscriptionID: "123",
+		Identity:       infrav1.VMIdentitySystemAssigned,
+	}
+	ids := RoleAssignmentIDs(spec)
+	g.Expect(ids).To(gomega.HaveLen(1))
+	g.Expect(ids[0]).To(gomega.HavePrefix("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Authorization/roleAssignments/"))
+
+	spec.Identity = infrav1.VMIdentityNone
+	g.Expect(RoleAssignmentIDs(spec)).To(gomega.BeEmpty())
+}
+
 func TestGetVMSSUpdateFromVMSS(t *testing.T) {
 	g := gomega.NewGomegaWithT(t)
 
@@ -738,7 +987,7 @@ func TestGetVMSSUpdateFromVMSS(t *testing.T) {
 		},
 		VirtualMachineScaleSetProperties: &compute.VirtualMachineScaleSetProperties{
 			UpgradePolicy: &compute.UpgradePolicy{
-				Mode: compute.Manual,
+				Mode: compute.UpgradeModeManual,
 			},
 			VirtualMachineProfile: &compute.VirtualMachineScaleSetVMProfile{
 				OsProfile: &compute.VirtualMachineScaleSetOSProfile{
@@ -770,7 +1019,7 @@ func TestGetVMSSUpdateFromVMSS(t *testing.T) {
 		},
 		VirtualMachineScaleSetUpdateProperties: &compute.VirtualMachineScaleSetUpdateProperties{
 			UpgradePolicy: &compute.UpgradePolicy{
-				Mode: compute.Manual,
+				Mode: compute.UpgradeModeManual,
 			},
 			VirtualMachineProfile: &compute.VirtualMachineScaleSetUpdateVMProfile{
 				OsProfile: &compute.VirtualMachineScaleSetUpdateOSProfile{
@@ -856,3 +1105,239 @@ func getFakeNodeOutboundLoadBalancer() network.LoadBalancer {
 			},
 		}}
 }
+
+func TestGenerateExtensionProfile(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	spec := Spec{
+		VMExtensions: []ExtensionSpec{
+			{
+				Name:      "hardening",
+				Publisher: "Microsoft.Azure.Extensions",
+				Type:      "CustomScript",
+				Version:   "2.1",
+				Settings: map[string]interface{}{
+					"commandToExecute": "./harden.sh",
+				},
+				ProtectedSettings: map[string]string{
+					"storageAccountKey": "secret",
+				},
+			},
+		},
+	}
+
+	profile, err := generateExtensionProfile(spec)
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(*profile.Extensions).To(gomega.HaveLen(1))
+
+	extension := (*profile.Extensions)[0]
+	g.Expect(to.String(extension.Name)).To(gomega.Equal("hardening"))
+	g.Expect(to.String(extension.Publisher)).To(gomega.Equal("Microsoft.Azure.Extensions"))
+	g.Expect(to.String(extension.VirtualMachineScaleSetExtensionProperties.Type)).To(gomega.Equal("CustomScript"))
+	g.Expect(to.String(extension.TypeHandlerVersion)).To(gomega.Equal("2.1"))
+	g.Expect(extension.Settings).To(gomega.Equal(map[string]interface{}{"commandToExecute": "./harden.sh"}))
+	g.Expect(extension.ProtectedSettings).To(gomega.Equal(map[string]string{"storageAccountKey": "secret"}))
+	g.Expect(to.String(extension.ForceUpdateTag)).ToNot(gomega.BeEmpty())
+
+	profile, err = generateExtensionProfile(Spec{VMExtensions: []ExtensionSpec{}})
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(*profile.Extensions).To(gomega.BeEmpty())
+}
+
+func TestGenerateHealthExtension(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	spec := Spec{
+		OSDisk:      infrav1.OSDisk{OSType: "Linux"},
+		HealthProbe: &HealthProbeSpec{Port: 10248, RequestPath: "/healthz"},
+	}
+	profile, err := generateExtensionProfile(spec)
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(*profile.Extensions).To(gomega.HaveLen(1))
+
+	extension := (*profile.Extensions)[0]
+	g.Expect(to.String(extension.Name)).To(gomega.Equal(HealthExtensionName))
+	g.Expect(to.String(extension.Publisher)).To(gomega.Equal("Microsoft.ManagedServices"))
+	g.Expect(to.String(extension.VirtualMachineScaleSetExtensionProperties.Type)).To(gomega.Equal("ApplicationHealthLinux"))
+	g.Expect(extension.Settings).To(gomega.Equal(map[string]interface{}{
+		"protocol":    "http",
+		"port":        int32(10248),
+		"requestPath": "/healthz",
+	}))
+
+	spec.OSDisk.OSType = "Windows"
+	profile, err = generateExtensionProfile(spec)
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(to.String((*profile.Extensions)[0].VirtualMachineScaleSetExtensionProperties.Type)).To(gomega.Equal("ApplicationHealthWindows"))
+}
+
+func TestGenerateScheduledEventsProfile(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	g.Expect(generateScheduledEventsProfile(Spec{})).To(gomega.Equal(&compute.ScheduledEventsProfile{
+		TerminateNotificationProfile: &compute.TerminateNotificationProfile{
+			Enable: to.BoolPtr(false),
+		},
+	}))
+
+	timeout := 10 * time.Minute
+	g.Expect(generateScheduledEventsProfile(Spec{TerminateNotificationTimeout: &timeout})).To(gomega.Equal(&compute.ScheduledEventsProfile{
+		TerminateNotificationProfile: &compute.TerminateNotificationProfile{
+			Enable:           to.BoolPtr(true),
+			NotBeforeTimeout: to.StringPtr("PT10M"),
+		},
+	}))
+}
+
+func TestGenerateAutomaticRepairsPolicy(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	gracePeriod := 45 * time.Minute
+	g.Expect(generateAutomaticRepairsPolicy(Spec{})).To(gomega.Equal(&compute.AutomaticRepairsPolicy{
+		Enabled: to.BoolPtr(false),
+	}))
+	g.Expect(generateAutomaticRepairsPolicy(Spec{AutomaticRepairsGracePeriod: &gracePeriod})).To(gomega.Equal(&compute.AutomaticRepairsPolicy{
+		Enabled: to.BoolPtr(false),
+	}))
+	g.Expect(generateAutomaticRepairsPolicy(Spec{
+		HealthProbe:                 &HealthProbeSpec{Port: 10248, RequestPath: "/healthz"},
+		AutomaticRepairsGracePeriod: &gracePeriod,
+	})).To(gomega.Equal(&compute.AutomaticRepairsPolicy{
+		Enabled:     to.BoolPtr(true),
+		GracePeriod: to.StringPtr("PT45M"),
+	}))
+}
+
+func TestGenerateIdentity(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	identity, err := generateIdentity(Spec{})
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(identity).To(gomega.BeNil())
+
+	identity, err = generateIdentity(Spec{Identity: infrav1.VMIdentitySystemAssigned})
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(identity.Type).To(gomega.Equal(compute.ResourceIdentityTypeSystemAssigned))
+
+	identity, err = generateIdentity(Spec{
+		Identity: infrav1.VMIdentityUserAssigned,
+		UserAssignedIdentities: []infrav1.UserAssignedIdentity{
+			{
+				ProviderID: "azure:///subscriptions/123/resourcegroups/my-rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/my-identity",
+			},
+		},
+	})
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(identity.Type).To(gomega.Equal(compute.ResourceIdentityTypeUserAssigned))
+	g.Expect(identity.UserAssignedIdentities).To(gomega.HaveKey("subscriptions/123/resourcegroups/my-rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/my-identity"))
+
+	_, err = generateIdentity(Spec{Identity: infrav1.VMIdentityUserAssigned})
+	g.Expect(err).To(gomega.MatchError("the user-assigned identity provider ids must not be null or empty for 'UserAssigned' identity type"))
+}
+
+func TestService_DeleteInstances(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	vmssMock := mock_scalesets.NewMockClient(mockCtrl)
+	svc := &Service{Client: vmssMock}
+	spec := &Spec{Name: "capz-mp-0", ResourceGroup: "my-rg"}
+
+	g.Expect(svc.DeleteInstances(context.TODO(), spec, nil)).To(gomega.Succeed())
+
+	vmssMock.EXPECT().DeleteInstances(gomock.Any(), "my-rg", "capz-mp-0", []string{"0", "2"}).Return(nil)
+	g.Expect(svc.DeleteInstances(context.TODO(), spec, []string{"0", "2"})).To(gomega.Succeed())
+
+	vmssMock.EXPECT().DeleteInstances(gomock.Any(), "my-rg", "capz-mp-0", []string{"1"}).
+		Return(autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"))
+	g.Expect(svc.DeleteInstances(context.TODO(), spec, []string{"1"})).To(gomega.MatchError("failed to delete instances [1] of VMSS capz-mp-0 in resource group my-rg: #: Internal Server Error: StatusCode=500"))
+}
+
+func TestService_ApproveTermination(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	vmssMock := mock_scalesets.NewMockClient(mockCtrl)
+	svc := &Service{Client: vmssMock}
+	spec := &Spec{Name: "capz-mp-0", ResourceGroup: "my-rg", OSDisk: infrav1.OSDisk{OSType: "Linux"}}
+
+	vmssMock.EXPECT().RunCommand(gomock.Any(), "my-rg", "capz-mp-0", "0", compute.RunCommandInput{
+		CommandID: to.StringPtr("RunShellScript"),
+		Script:    &approveTerminationShellScript,
+	}).Return(runCommandResult("Enable succeeded: \n[stdout]\ntermination approved\n\n[stderr]\n"), nil)
+	g.Expect(svc.ApproveTermination(context.TODO(), spec, "0")).To(gomega.Succeed())
+
+	vmssMock.EXPECT().RunCommand(gomock.Any(), "my-rg", "capz-mp-0", "3", gomock.Any()).
+		Return(runCommandResult("Enable succeeded: \n[stdout]\n\n[stderr]\nno Terminate Scheduled Event for capz-mp-0_3\n"), nil)
+	g.Expect(svc.ApproveTermination(context.TODO(), spec, "3")).To(gomega.MatchError(gomega.ContainSubstring("the approval script did not succeed: Enable succeeded: \n[stdout]\n\n[stderr]\nno Terminate Scheduled Event for capz-mp-0_3")))
+
+	spec.OSDisk.OSType = "Windows"
+	vmssMock.EXPECT().RunCommand(gomock.Any(), "my-rg", "capz-mp-0", "1", compute.RunCommandInput{
+		CommandID: to.StringPtr("RunPowerShellScript"),
+		Script:    &approveTerminationPowerShellScript,
+	}).Return(runCommandResult("termination approved", ""), nil)
+	g.Expect(svc.ApproveTermination(context.TODO(), spec, "1")).To(gomega.Succeed())
+
+	vmssMock.EXPECT().RunCommand(gomock.Any(), "my-rg", "capz-mp-0", "2", gomock.Any()).
+		Return(compute.RunCommandResult{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 409}, "Conflict"))
+	g.Expect(svc.ApproveTermination(context.TODO(), spec, "2")).To(gomega.MatchError("failed to approve termination of instance 2 of VMSS capz-mp-0 in resource group my-rg: #: Conflict: StatusCode=409"))
+}
+
+func TestService_GetInstanceBootDiagnostics(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	vmssMock := mock_scalesets.NewMockClient(mockCtrl)
+	svc := &Service{Client: vmssMock}
+	spec := &Spec{Name: "capz-mp-0", ResourceGroup: "my-rg"}
+	provisioned := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	blobURI := "https://account.blob.core.windows.net/bootdiagnostics-capzmp0/capz-mp-0_3.serialconsole.log"
+
+	vmssMock.EXPECT().GetInstanceView(gomock.Any(), "my-rg", "capz-mp-0", "3").Return(compute.VirtualMachineScaleSetVMInstanceView{
+		Statuses: &[]compute.InstanceViewStatus{
+			{Code: to.StringPtr("ProvisioningState/succeeded"), Time: &date.Time{Time: provisioned}},
+			{Code: to.StringPtr("PowerState/running")},
+		},
+		BootDiagnostics: &compute.BootDiagnosticsInstanceView{SerialConsoleLogBlobURI: to.StringPtr(blobURI)},
+	}, nil)
+	bootDiagnostics, err := svc.GetInstanceBootDiagnostics(context.TODO(), spec, "3")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(bootDiagnostics.ProvisionedTime).To(gomega.Equal(provisioned))
+	g.Expect(bootDiagnostics.SerialConsoleLogURI).To(gomega.Equal(blobURI))
+
+	vmssMock.EXPECT().GetInstanceView(gomock.Any(), "my-rg", "capz-mp-0", "4").Return(compute.VirtualMachineScaleSetVMInstanceView{
+		Statuses: &[]compute.InstanceViewStatus{{Code: to.StringPtr("ProvisioningState/creating")}},
+	}, nil)
+	bootDiagnostics, err = svc.GetInstanceBootDiagnostics(context.TODO(), spec, "4")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(bootDiagnostics.ProvisionedTime.IsZero()).To(gomega.BeTrue())
+	g.Expect(bootDiagnostics.SerialConsoleLogURI).To(gomega.BeEmpty())
+
+	spec.OrchestrationMode = infrav1exp.FlexibleOrchestrationMode
+	vmssMock.EXPECT().GetVirtualMachineInstanceView(gomock.Any(), "my-rg", "capz-mp-0-3").Return(compute.VirtualMachineInstanceView{
+		Statuses: &[]compute.InstanceViewStatus{
+			{Code: to.StringPtr("ProvisioningState/succeeded"), Time: &date.Time{Time: provisioned}},
+		},
+		BootDiagnostics: &compute.BootDiagnosticsInstanceView{SerialConsoleLogBlobURI: to.StringPtr(blobURI)},
+	}, nil)
+	bootDiagnostics, err = svc.GetInstanceBootDiagnostics(context.TODO(), spec, "capz-mp-0-3")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(bootDiagnostics.ProvisionedTime).To(gomega.Equal(provisioned))
+
+	vmssMock.EXPECT().GetVirtualMachineInstanceView(gomock.Any(), "my-rg", "capz-mp-0-4").
+		Return(compute.VirtualMachineInstanceView{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+	_, err = svc.GetInstanceBootDiagnostics(context.TODO(), spec, "capz-mp-0-4")
+	g.Expect(err).To(gomega.MatchError("failed to get instance view of instance capz-mp-0-4 of VMSS capz-mp-0: #: Not found: StatusCode=404"))
+}
+
+// runCommandResult returns the result of a Run Command with the given messages.
+func runCommandResult(messages ...string) compute.RunCommandResult {
+	statuses := make([]compute.InstanceViewStatus, len(messages))
+	for i, message := range messages {
+		statuses[i] = compute.InstanceViewStatus{
+			Code:    to.StringPtr("ProvisioningState/succeeded"),
+			Message: to.StringPtr(message),
+		}
+	}
+	return compute.RunCommandResult{Value: &statuses}
+}
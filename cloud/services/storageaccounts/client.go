@@ -0,0 +1,132 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storageaccounts
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2019-06-01/storage"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/pkg/errors"
+	azure "sigs.k8s.io/cluster-api-provider-azure/cloud"
+)
+
+// Client wraps go-sdk
+type Client interface {
+	Get(context.Context, string, string) (storage.Account, error)
+	List(context.Context) ([]storage.Account, error)
+	Create(context.Context, string, string, storage.AccountCreateParameters) error
+	Delete(context.Context, string, string) error
+	ListServiceSAS(context.Context, string, string, storage.ServiceSasParameters) (string, error)
+	GetBlob(context.Context, string) ([]byte, error)
+}
+
+// AzureClient contains the Azure go-sdk Client
+type AzureClient struct {
+	accounts storage.AccountsClient
+}
+
+var _ Client = &AzureClient{}
+
+// NewClient creates a new storage accounts client from subscription ID.
+func NewClient(auth azure.Authorizer) *AzureClient {
+	c := newAccountsClient(auth.SubscriptionID(), auth.BaseURI(), auth.Authorizer())
+	return &AzureClient{c}
+}
+
+// newAccountsClient creates a new storage accounts client from subscription ID.
+func newAccountsClient(subscriptionID string, baseURI string, authorizer autorest.Authorizer) storage.AccountsClient {
+	accountsClient := storage.NewAccountsClientWithBaseURI(baseURI, subscriptionID)
+	accountsClient.Authorizer = authorizer
+	accountsClient.AddToUserAgent(azure.UserAgent())
+	return accountsClient
+}
+
+// Get returns the properties of the specified storage account.
+func (ac *AzureClient) Get(ctx context.Context, resourceGroupName, name string) (storage.Account, error) {
+	return ac.accounts.GetProperties(ctx, resourceGroupName, name, "")
+}
+
+// List lists all the storage accounts in the subscription.
+func (ac *AzureClient) List(ctx context.Context) ([]storage.Account, error) {
+	itr, err := ac.accounts.ListComplete(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var accounts []storage.Account
+	for ; itr.NotDone(); err = itr.NextWithContext(ctx) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate storage accounts [%w]", err)
+		}
+		accounts = append(accounts, itr.Value())
+	}
+	return accounts, nil
+}
+
+// Create creates a new storage account with the specified parameters.
+func (ac *AzureClient) Create(ctx context.Context, resourceGroupName, name string, account storage.AccountCreateParameters) error {
+	future, err := ac.accounts.Create(ctx, resourceGroupName, name, account)
+	if err != nil {
+		return err
+	}
+	err = future.WaitForCompletionRef(ctx, ac.accounts.Client)
+	if err != nil {
+		return err
+	}
+	_, err = future.Result(ac.accounts)
+	return err
+}
+
+// Delete deletes a storage account.
+func (ac *AzureClient) Delete(ctx context.Context, resourceGroupName, name string) error {
+	_, err := ac.accounts.Delete(ctx, resourceGroupName, name)
+	return err
+}
+
+// ListServiceSAS returns a service SAS token for a resource of the specified storage account.
+func (ac *AzureClient) ListServiceSAS(ctx context.Context, resourceGroupName, name string, parameters storage.ServiceSasParameters) (string, error) {
+	result, err := ac.accounts.ListServiceSAS(ctx, resourceGroupName, name, parameters)
+	if err != nil {
+		return "", err
+	}
+	if result.ServiceSasToken == nil {
+		return "", errors.Errorf("no service SAS token returned for storage account %s", name)
+	}
+	return *result.ServiceSasToken, nil
+}
+
+// GetBlob downloads the content of a blob from a URL granting read access to it, e.g. a URL carrying a SAS token.
+func (ac *AzureClient) GetBlob(ctx context.Context, blobURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, blobURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, autorest.NewErrorWithResponse("storageaccounts.AzureClient", "GetBlob", resp, "failed to download blob")
+	}
+	return ioutil.ReadAll(resp.Body)
+}
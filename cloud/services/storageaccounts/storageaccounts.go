@@ -0,0 +1,201 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storageaccounts
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2019-06-01/storage"
+	autorestazure "github.com/Azure/go-autorest/autorest/azure"
+	"github.com/Azure/go-autorest/autorest/date"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
+	azure "sigs.k8s.io/cluster-api-provider-azure/cloud"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/converters"
+)
+
+// sasTokenExpiry is the validity period of the SAS tokens used to read blobs.
+const sasTokenExpiry = 30 * time.Minute
+
+// Spec specification for storage account
+type Spec struct {
+	Name string
+}
+
+// Reconcile gets/creates a storage account.
+func (s *Service) Reconcile(ctx context.Context, spec interface{}) error {
+	accountSpec, ok := spec.(*Spec)
+	if !ok {
+		return errors.New("invalid storage account specification")
+	}
+
+	_, err := s.Client.Get(ctx, s.Scope.ResourceGroup(), accountSpec.Name)
+	if err == nil {
+		// storage account already exists, skip creation
+		return nil
+	}
+	if !azure.ResourceNotFound(err) {
+		return errors.Wrapf(err, "failed to get storage account %s in resource group %s", accountSpec.Name, s.Scope.ResourceGroup())
+	}
+
+	klog.V(2).Infof("creating storage account %s", accountSpec.Name)
+	account := storage.AccountCreateParameters{
+		Sku: &storage.Sku{
+			Name: storage.StandardLRS,
+		},
+		Kind:     storage.StorageV2,
+		Location: to.StringPtr(s.Scope.Location()),
+		Tags: converters.TagsToMap(infrav1.Build(infrav1.BuildParams{
+			ClusterName: s.Scope.ClusterName(),
+			Lifecycle:   infrav1.ResourceLifecycleOwned,
+			Name:        to.StringPtr(accountSpec.Name),
+			Role:        to.StringPtr(infrav1.CommonRole),
+			Additional:  s.Scope.AdditionalTags(),
+		})),
+		AccountPropertiesCreateParameters: &storage.AccountPropertiesCreateParameters{
+			EnableHTTPSTrafficOnly: to.BoolPtr(true),
+		},
+	}
+	if err := s.Client.Create(ctx, s.Scope.ResourceGroup(), accountSpec.Name, account); err != nil {
+		return errors.Wrapf(err, "failed to create storage account %s in resource group %s", accountSpec.Name, s.Scope.ResourceGroup())
+	}
+
+	klog.V(2).Infof("successfully created storage account %s", accountSpec.Name)
+	return nil
+}
+
+// Delete deletes the storage account with the provided name if it is owned by the cluster.
+func (s *Service) Delete(ctx context.Context, spec interface{}) error {
+	accountSpec, ok := spec.(*Spec)
+	if !ok {
+		return errors.New("invalid storage account specification")
+	}
+
+	account, err := s.Client.Get(ctx, s.Scope.ResourceGroup(), accountSpec.Name)
+	if err != nil && azure.ResourceNotFound(err) {
+		// already deleted
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to get storage account %s in resource group %s", accountSpec.Name, s.Scope.ResourceGroup())
+	}
+
+	if !converters.MapToTags(account.Tags).HasOwned(s.Scope.ClusterName()) {
+		klog.V(4).Infof("skipping deletion of unmanaged storage account %s", accountSpec.Name)
+		return nil
+	}
+
+	klog.V(2).Infof("deleting storage account %s", accountSpec.Name)
+	err = s.Client.Delete(ctx, s.Scope.ResourceGroup(), accountSpec.Name)
+	if err != nil && azure.ResourceNotFound(err) {
+		// already deleted
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to delete storage account %s in resource group %s", accountSpec.Name, s.Scope.ResourceGroup())
+	}
+
+	klog.V(2).Infof("successfully deleted storage account %s", accountSpec.Name)
+	return nil
+}
+
+// GetBlobEndpoint returns the primary blob endpoint of a storage account.
+func (s *Service) GetBlobEndpoint(ctx context.Context, accountSpec *Spec) (string, error) {
+	account, err := s.Client.Get(ctx, s.Scope.ResourceGroup(), accountSpec.Name)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get storage account %s in resource group %s", accountSpec.Name, s.Scope.ResourceGroup())
+	}
+
+	if account.AccountProperties == nil || account.PrimaryEndpoints == nil || account.PrimaryEndpoints.Blob == nil {
+		return "", errors.Errorf("storage account %s does not have a blob endpoint yet", accountSpec.Name)
+	}
+	return to.String(account.PrimaryEndpoints.Blob), nil
+}
+
+// GetBlob downloads a blob of a storage account in the subscription, e.g.
+// https://mystorageaccount.blob.core.windows.net/mycontainer/myblob.
+func (s *Service) GetBlob(ctx context.Context, blobURI string) ([]byte, error) {
+	accountName, container, blob, err := parseBlobURI(blobURI)
+	if err != nil {
+		return nil, err
+	}
+
+	resourceGroup, err := s.getResourceGroup(ctx, accountName)
+	if err != nil {
+		return nil, err
+	}
+
+	params := storage.ServiceSasParameters{
+		CanonicalizedResource:  to.StringPtr(fmt.Sprintf("/blob/%s/%s/%s", accountName, container, blob)),
+		Resource:               storage.SignedResourceB,
+		Permissions:            storage.R,
+		Protocols:              storage.HTTPS,
+		SharedAccessExpiryTime: &date.Time{Time: time.Now().Add(sasTokenExpiry)},
+	}
+	sasToken, err := s.Client.ListServiceSAS(ctx, resourceGroup, accountName, params)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get SAS token for blob %s", blobURI)
+	}
+
+	data, err := s.Client.GetBlob(ctx, fmt.Sprintf("%s?%s", blobURI, sasToken))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to download blob %s", blobURI)
+	}
+	return data, nil
+}
+
+// getResourceGroup returns the resource group of a storage account in the subscription.
+func (s *Service) getResourceGroup(ctx context.Context, accountName string) (string, error) {
+	accounts, err := s.Client.List(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to list storage accounts")
+	}
+
+	for _, account := range accounts {
+		if !strings.EqualFold(to.String(account.Name), accountName) {
+			continue
+		}
+		resource, err := autorestazure.ParseResourceID(to.String(account.ID))
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to parse ID of storage account %s", accountName)
+		}
+		return resource.ResourceGroup, nil
+	}
+
+	return "", errors.Errorf("storage account %s not found in subscription %s", accountName, s.Scope.SubscriptionID())
+}
+
+// parseBlobURI splits a blob URI into its storage account, container and blob names.
+func parseBlobURI(blobURI string) (string, string, string, error) {
+	u, err := url.Parse(blobURI)
+	if err != nil {
+		return "", "", "", errors.Wrapf(err, "failed to parse blob URI %s", blobURI)
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(u.Path, "/"), "/", 2)
+	if u.Host == "" || len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", "", errors.Errorf("invalid blob URI %s", blobURI)
+	}
+
+	return strings.Split(u.Host, ".")[0], parts[0], parts[1], nil
+}
@@ -0,0 +1,294 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storageaccounts
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/storageaccounts/mock_storageaccounts"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/golang/mock/gomock"
+
+	"github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2019-06-01/storage"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/scope"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func init() {
+	clusterv1.AddToScheme(scheme.Scheme)
+}
+
+const (
+	subscriptionID = "123"
+)
+
+func newClusterScope(g *WithT) *scope.ClusterScope {
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-cluster"},
+	}
+
+	client := fake.NewFakeClientWithScheme(scheme.Scheme, cluster)
+
+	clusterScope, err := scope.NewClusterScope(scope.ClusterScopeParams{
+		AzureClients: scope.AzureClients{
+			Authorizer: autorest.NullAuthorizer{},
+		},
+		Client:  client,
+		Cluster: cluster,
+		AzureCluster: &infrav1.AzureCluster{
+			Spec: infrav1.AzureClusterSpec{
+				Location:       "test-location",
+				ResourceGroup:  "my-rg",
+				SubscriptionID: subscriptionID,
+			},
+		},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	return clusterScope
+}
+
+func TestReconcileStorageAccount(t *testing.T) {
+	g := NewWithT(t)
+
+	testcases := []struct {
+		name          string
+		expectedError string
+		expect        func(m *mock_storageaccounts.MockClientMockRecorder)
+	}{
+		{
+			name:          "storage account already exists",
+			expectedError: "",
+			expect: func(m *mock_storageaccounts.MockClientMockRecorder) {
+				m.Get(context.TODO(), "my-rg", "mystorageaccount").Return(storage.Account{Name: to.StringPtr("mystorageaccount")}, nil)
+			},
+		},
+		{
+			name:          "create storage account",
+			expectedError: "",
+			expect: func(m *mock_storageaccounts.MockClientMockRecorder) {
+				m.Get(context.TODO(), "my-rg", "mystorageaccount").Return(storage.Account{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not Found"))
+				m.Create(context.TODO(), "my-rg", "mystorageaccount", gomock.AssignableToTypeOf(storage.AccountCreateParameters{})).Do(
+					func(_ context.Context, _, _ string, account storage.AccountCreateParameters) {
+						g.Expect(account.Sku.Name).To(Equal(storage.StandardLRS))
+						g.Expect(account.Kind).To(Equal(storage.StorageV2))
+						g.Expect(to.String(account.Location)).To(Equal("test-location"))
+						g.Expect(to.String(account.Tags["sigs.k8s.io_cluster-api-provider-azure_cluster_test-cluster"])).To(Equal("owned"))
+					})
+			},
+		},
+		{
+			name:          "fail to get storage account",
+			expectedError: "failed to get storage account mystorageaccount in resource group my-rg: #: Internal Server Error: StatusCode=500",
+			expect: func(m *mock_storageaccounts.MockClientMockRecorder) {
+				m.Get(context.TODO(), "my-rg", "mystorageaccount").Return(storage.Account{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"))
+			},
+		},
+		{
+			name:          "fail to create storage account",
+			expectedError: "failed to create storage account mystorageaccount in resource group my-rg: #: Internal Server Error: StatusCode=500",
+			expect: func(m *mock_storageaccounts.MockClientMockRecorder) {
+				m.Get(context.TODO(), "my-rg", "mystorageaccount").Return(storage.Account{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not Found"))
+				m.Create(context.TODO(), "my-rg", "mystorageaccount", gomock.AssignableToTypeOf(storage.AccountCreateParameters{})).Return(autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"))
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			accountsMock := mock_storageaccounts.NewMockClient(mockCtrl)
+
+			tc.expect(accountsMock.EXPECT())
+
+			s := &Service{
+				Scope:  newClusterScope(g),
+				Client: accountsMock,
+			}
+
+			err := s.Reconcile(context.TODO(), &Spec{Name: "mystorageaccount"})
+			if tc.expectedError != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err).To(MatchError(tc.expectedError))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestDeleteStorageAccount(t *testing.T) {
+	g := NewWithT(t)
+
+	testcases := []struct {
+		name          string
+		expectedError string
+		expect        func(m *mock_storageaccounts.MockClientMockRecorder)
+	}{
+		{
+			name:          "delete managed storage account",
+			expectedError: "",
+			expect: func(m *mock_storageaccounts.MockClientMockRecorder) {
+				m.Get(context.TODO(), "my-rg", "mystorageaccount").Return(storage.Account{
+					Name: to.StringPtr("mystorageaccount"),
+					Tags: map[string]*string{
+						"sigs.k8s.io_cluster-api-provider-azure_cluster_test-cluster": to.StringPtr("owned"),
+					},
+				}, nil)
+				m.Delete(context.TODO(), "my-rg", "mystorageaccount")
+			},
+		},
+		{
+			name:          "skip unmanaged storage account",
+			expectedError: "",
+			expect: func(m *mock_storageaccounts.MockClientMockRecorder) {
+				m.Get(context.TODO(), "my-rg", "mystorageaccount").Return(storage.Account{Name: to.StringPtr("mystorageaccount")}, nil)
+			},
+		},
+		{
+			name:          "storage account already deleted",
+			expectedError: "",
+			expect: func(m *mock_storageaccounts.MockClientMockRecorder) {
+				m.Get(context.TODO(), "my-rg", "mystorageaccount").Return(storage.Account{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not Found"))
+			},
+		},
+		{
+			name:          "error while trying to delete the storage account",
+			expectedError: "failed to delete storage account mystorageaccount in resource group my-rg: #: Internal Server Error: StatusCode=500",
+			expect: func(m *mock_storageaccounts.MockClientMockRecorder) {
+				m.Get(context.TODO(), "my-rg", "mystorageaccount").Return(storage.Account{
+					Name: to.StringPtr("mystorageaccount"),
+					Tags: map[string]*string{
+						"sigs.k8s.io_cluster-api-provider-azure_cluster_test-cluster": to.StringPtr("owned"),
+					},
+				}, nil)
+				m.Delete(context.TODO(), "my-rg", "mystorageaccount").Return(autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"))
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			accountsMock := mock_storageaccounts.NewMockClient(mockCtrl)
+
+			tc.expect(accountsMock.EXPECT())
+
+			s := &Service{
+				Scope:  newClusterScope(g),
+				Client: accountsMock,
+			}
+
+			err := s.Delete(context.TODO(), &Spec{Name: "mystorageaccount"})
+			if tc.expectedError != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err).To(MatchError(tc.expectedError))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestGetBlob(t *testing.T) {
+	g := NewWithT(t)
+
+	const blobURI = "https://mystorageaccount.blob.core.windows.net/bootdiagnostics-myvm/myvm.serialconsole.log"
+
+	testcases := []struct {
+		name          string
+		blobURI       string
+		expectedError string
+		expect        func(m *mock_storageaccounts.MockClientMockRecorder)
+	}{
+		{
+			name:          "download blob",
+			blobURI:       blobURI,
+			expectedError: "",
+			expect: func(m *mock_storageaccounts.MockClientMockRecorder) {
+				m.List(context.TODO()).Return([]storage.Account{
+					{
+						ID:   to.StringPtr("/subscriptions/123/resourceGroups/other-rg/providers/Microsoft.Storage/storageAccounts/otheraccount"),
+						Name: to.StringPtr("otheraccount"),
+					},
+					{
+						ID:   to.StringPtr("/subscriptions/123/resourceGroups/diag-rg/providers/Microsoft.Storage/storageAccounts/mystorageaccount"),
+						Name: to.StringPtr("mystorageaccount"),
+					},
+				}, nil)
+				m.ListServiceSAS(context.TODO(), "diag-rg", "mystorageaccount", gomock.AssignableToTypeOf(storage.ServiceSasParameters{})).DoAndReturn(
+					func(_ context.Context, _, _ string, params storage.ServiceSasParameters) (string, error) {
+						g.Expect(to.String(params.CanonicalizedResource)).To(Equal("/blob/mystorageaccount/bootdiagnostics-myvm/myvm.serialconsole.log"))
+						g.Expect(params.Permissions).To(Equal(storage.R))
+						return "sv=2019-02-02&sig=abc", nil
+					})
+				m.GetBlob(context.TODO(), blobURI+"?sv=2019-02-02&sig=abc").Return([]byte("serial console log"), nil)
+			},
+		},
+		{
+			name:          "storage account not found",
+			blobURI:       blobURI,
+			expectedError: "storage account mystorageaccount not found in subscription 123",
+			expect: func(m *mock_storageaccounts.MockClientMockRecorder) {
+				m.List(context.TODO()).Return([]storage.Account{}, nil)
+			},
+		},
+		{
+			name:          "invalid blob URI",
+			blobURI:       "https://mystorageaccount.blob.core.windows.net/",
+			expectedError: "invalid blob URI https://mystorageaccount.blob.core.windows.net/",
+			expect:        func(m *mock_storageaccounts.MockClientMockRecorder) {},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			accountsMock := mock_storageaccounts.NewMockClient(mockCtrl)
+
+			tc.expect(accountsMock.EXPECT())
+
+			s := &Service{
+				Scope:  newClusterScope(g),
+				Client: accountsMock,
+			}
+
+			data, err := s.GetBlob(context.TODO(), tc.blobURI)
+			if tc.expectedError != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err).To(MatchError(tc.expectedError))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(string(data)).To(Equal("serial console log"))
+			}
+		})
+	}
+}
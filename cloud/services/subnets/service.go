@@ -17,21 +17,32 @@ limitations under the License.
 package subnets
 
 import (
-	"sigs.k8s.io/cluster-api-provider-azure/cloud/scope"
+	"github.com/go-logr/logr"
+	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
+	azure "sigs.k8s.io/cluster-api-provider-azure/cloud"
 	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/routetables"
 	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/securitygroups"
 )
 
+// SubnetScope defines the scope interface for a subnet service.
+type SubnetScope interface {
+	logr.Logger
+	azure.ClusterDescriber
+	Vnet() *infrav1.VnetSpec
+	ControlPlaneSubnet() *infrav1.SubnetSpec
+	NodeSubnet() *infrav1.SubnetSpec
+}
+
 // Service provides operations on azure resources
 type Service struct {
-	Scope *scope.ClusterScope
+	Scope SubnetScope
 	Client
 	SecurityGroupsClient securitygroups.Client
 	RouteTablesClient    routetables.Client
 }
 
 // NewService creates a new service.
-func NewService(scope *scope.ClusterScope) *Service {
+func NewService(scope SubnetScope) *Service {
 	return &Service{
 		Scope:                scope,
 		Client:               NewClient(scope),
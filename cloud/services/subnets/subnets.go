@@ -106,13 +106,15 @@ func (s *Service) Reconcile(ctx context.Context, spec interface{}) error {
 		subnetProperties.RouteTable = &rt
 	}
 
-	klog.V(2).Infof("getting nsg %s", subnetSpec.SecurityGroupName)
-	nsg, err := s.SecurityGroupsClient.Get(ctx, s.Scope.ResourceGroup(), subnetSpec.SecurityGroupName)
-	if err != nil {
-		return err
+	if subnetSpec.SecurityGroupName != "" {
+		klog.V(2).Infof("getting nsg %s", subnetSpec.SecurityGroupName)
+		nsg, err := s.SecurityGroupsClient.Get(ctx, s.Scope.ResourceGroup(), subnetSpec.SecurityGroupName)
+		if err != nil {
+			return err
+		}
+		klog.V(2).Infof("got nsg %s", subnetSpec.SecurityGroupName)
+		subnetProperties.NetworkSecurityGroup = &nsg
 	}
-	klog.V(2).Infof("got nsg %s", subnetSpec.SecurityGroupName)
-	subnetProperties.NetworkSecurityGroup = &nsg
 
 	klog.V(2).Infof("creating subnet %s in vnet %s", subnetSpec.Name, subnetSpec.VnetName)
 	err = s.Client.CreateOrUpdate(
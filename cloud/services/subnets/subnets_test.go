@@ -85,6 +85,29 @@ func TestReconcileSubnets(t *testing.T) {
 				m.CreateOrUpdate(context.TODO(), "", "my-vnet", "my-subnet", gomock.AssignableToTypeOf(network.Subnet{}))
 			},
 		},
+		{
+			name: "subnet without security group does not exist",
+			subnetSpec: Spec{
+				Name:     "my-subnet",
+				CIDR:     "10.0.0.0/16",
+				VnetName: "my-vnet",
+				Role:     infrav1.SubnetNode,
+			},
+			vnetSpec:      &infrav1.VnetSpec{Name: "my-vnet"},
+			subnets:       []*infrav1.SubnetSpec{},
+			expectedError: "",
+			expect: func(m *mock_subnets.MockClientMockRecorder, m1 *mock_routetables.MockClientMockRecorder, m2 *mock_securitygroups.MockClientMockRecorder) {
+				m.Get(context.TODO(), "", "my-vnet", "my-subnet").
+					Return(network.Subnet{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+
+				m.CreateOrUpdate(context.TODO(), "", "my-vnet", "my-subnet", network.Subnet{
+					Name: to.StringPtr("my-subnet"),
+					SubnetPropertiesFormat: &network.SubnetPropertiesFormat{
+						AddressPrefix: to.StringPtr("10.0.0.0/16"),
+					},
+				})
+			},
+		},
 		{
 			name: "vnet was provided but subnet is missing",
 			subnetSpec: Spec{
@@ -0,0 +1,163 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package virtualmachineextensions
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2021-07-01/compute"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/pkg/errors"
+	"k8s.io/klog"
+	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
+	azure "sigs.k8s.io/cluster-api-provider-azure/cloud"
+)
+
+// Spec specification for a virtual machine extension
+type Spec struct {
+	Name              string
+	VMName            string
+	Publisher         string
+	Type              string
+	Version           string
+	Settings          map[string]interface{}
+	ProtectedSettings map[string]string
+}
+
+// Get returns the observed state of a virtual machine extension.
+func (s *Service) Get(ctx context.Context, extSpec *Spec) (*infrav1.VMExtensionStatus, error) {
+	extension, err := s.Client.Get(ctx, s.Scope.ResourceGroup(), extSpec.VMName, extSpec.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &infrav1.VMExtensionStatus{
+		Name: extSpec.Name,
+	}
+	if extension.VirtualMachineExtensionProperties != nil {
+		status.ProvisioningState = to.String(extension.ProvisioningState)
+	}
+	return status, nil
+}
+
+// Reconcile gets/creates/updates a virtual machine extension.
+func (s *Service) Reconcile(ctx context.Context, spec interface{}) error {
+	extSpec, ok := spec.(*Spec)
+	if !ok {
+		return errors.New("invalid VM extension specification")
+	}
+
+	forceUpdateTag, err := ProtectedSettingsHash(extSpec.ProtectedSettings)
+	if err != nil {
+		return errors.Wrapf(err, "failed to hash protected settings of VM extension %s", extSpec.Name)
+	}
+
+	existing, err := s.Client.Get(ctx, s.Scope.ResourceGroup(), extSpec.VMName, extSpec.Name)
+	if err != nil && !azure.ResourceNotFound(err) {
+		return errors.Wrapf(err, "failed to get VM extension %s on VM %s", extSpec.Name, extSpec.VMName)
+	}
+	if err == nil && isUpToDate(existing, extSpec, forceUpdateTag) {
+		// extension already exists with the desired configuration
+		return nil
+	}
+
+	klog.V(2).Infof("creating or updating VM extension %s on VM %s", extSpec.Name, extSpec.VMName)
+	extension := compute.VirtualMachineExtension{
+		Location: to.StringPtr(s.Scope.Location()),
+		VirtualMachineExtensionProperties: &compute.VirtualMachineExtensionProperties{
+			Publisher:               to.StringPtr(extSpec.Publisher),
+			Type:                    to.StringPtr(extSpec.Type),
+			TypeHandlerVersion:      to.StringPtr(extSpec.Version),
+			AutoUpgradeMinorVersion: to.BoolPtr(true),
+			ForceUpdateTag:          to.StringPtr(forceUpdateTag),
+		},
+	}
+	if len(extSpec.Settings) > 0 {
+		extension.Settings = extSpec.Settings
+	}
+	if len(extSpec.ProtectedSettings) > 0 {
+		extension.ProtectedSettings = extSpec.ProtectedSettings
+	}
+
+	err = s.Client.CreateOrUpdate(ctx, s.Scope.ResourceGroup(), extSpec.VMName, extSpec.Name, extension)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create VM extension %s on VM %s in resource group %s", extSpec.Name, extSpec.VMName, s.Scope.ResourceGroup())
+	}
+
+	klog.V(2).Infof("successfully created VM extension %s on VM %s", extSpec.Name, extSpec.VMName)
+	return nil
+}
+
+// Delete deletes the virtual machine extension with the provided name.
+func (s *Service) Delete(ctx context.Context, spec interface{}) error {
+	extSpec, ok := spec.(*Spec)
+	if !ok {
+		return errors.New("invalid VM extension specification")
+	}
+
+	klog.V(2).Infof("deleting VM extension %s on VM %s", extSpec.Name, extSpec.VMName)
+	err := s.Client.Delete(ctx, s.Scope.ResourceGroup(), extSpec.VMName, extSpec.Name)
+	if err != nil && !azure.ResourceNotFound(err) {
+		return errors.Wrapf(err, "failed to delete VM extension %s on VM %s in resource group %s", extSpec.Name, extSpec.VMName, s.Scope.ResourceGroup())
+	}
+
+	klog.V(2).Infof("successfully deleted VM extension %s on VM %s", extSpec.Name, extSpec.VMName)
+	return nil
+}
+
+// ProtectedSettingsHash returns a hash of the protected settings of an extension. Azure never returns the
+// protected settings of an extension, so the hash is used as the extension force update tag to detect changes.
+func ProtectedSettingsHash(protectedSettings map[string]string) (string, error) {
+	if len(protectedSettings) == 0 {
+		return "", nil
+	}
+	// json.Marshal sorts map keys, so the hash is stable
+	data, err := json.Marshal(protectedSettings)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data))[:16], nil
+}
+
+// isUpToDate returns true if the existing extension matches the desired extension.
+func isUpToDate(existing compute.VirtualMachineExtension, extSpec *Spec, forceUpdateTag string) bool {
+	props := existing.VirtualMachineExtensionProperties
+	if props == nil {
+		return false
+	}
+	if to.String(props.Publisher) != extSpec.Publisher ||
+		to.String(props.Type) != extSpec.Type ||
+		to.String(props.TypeHandlerVersion) != extSpec.Version ||
+		to.String(props.ForceUpdateTag) != forceUpdateTag {
+		return false
+	}
+
+	// settings are returned as decoded JSON
+	existingSettings := map[string]interface{}{}
+	if settings, ok := props.Settings.(map[string]interface{}); ok {
+		existingSettings = settings
+	}
+	desiredSettings := extSpec.Settings
+	if desiredSettings == nil {
+		desiredSettings = map[string]interface{}{}
+	}
+	return reflect.DeepEqual(existingSettings, desiredSettings)
+}
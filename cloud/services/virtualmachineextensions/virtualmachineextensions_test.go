@@ -0,0 +1,242 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package virtualmachineextensions
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/virtualmachineextensions/mock_virtualmachineextensions"
+
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/golang/mock/gomock"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2021-07-01/compute"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/scope"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func init() {
+	clusterv1.AddToScheme(scheme.Scheme)
+}
+
+const (
+	subscriptionID = "123"
+)
+
+func newClusterScope(g *WithT) *scope.ClusterScope {
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-cluster"},
+	}
+
+	client := fake.NewFakeClientWithScheme(scheme.Scheme, cluster)
+
+	clusterScope, err := scope.NewClusterScope(scope.ClusterScopeParams{
+		AzureClients: scope.AzureClients{
+			Authorizer: autorest.NullAuthorizer{},
+		},
+		Client:  client,
+		Cluster: cluster,
+		AzureCluster: &infrav1.AzureCluster{
+			Spec: infrav1.AzureClusterSpec{
+				Location:       "test-location",
+				ResourceGroup:  "my-rg",
+				SubscriptionID: subscriptionID,
+			},
+		},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	return clusterScope
+}
+
+func newExtensionSpec() *Spec {
+	return &Spec{
+		Name:      "hardening",
+		VMName:    "my-vm",
+		Publisher: "Microsoft.Azure.Extensions",
+		Type:      "CustomScript",
+		Version:   "2.1",
+		Settings: map[string]interface{}{
+			"commandToExecute": "./harden.sh",
+		},
+		ProtectedSettings: map[string]string{
+			"storageAccountKey": "secret",
+		},
+	}
+}
+
+func newExistingExtension(version string) compute.VirtualMachineExtension {
+	forceUpdateTag, _ := ProtectedSettingsHash(newExtensionSpec().ProtectedSettings)
+	return compute.VirtualMachineExtension{
+		Name: to.StringPtr("hardening"),
+		VirtualMachineExtensionProperties: &compute.VirtualMachineExtensionProperties{
+			Publisher:          to.StringPtr("Microsoft.Azure.Extensions"),
+			Type:               to.StringPtr("CustomScript"),
+			TypeHandlerVersion: to.StringPtr(version),
+			ForceUpdateTag:     to.StringPtr(forceUpdateTag),
+			Settings: map[string]interface{}{
+				"commandToExecute": "./harden.sh",
+			},
+			ProvisioningState: to.StringPtr("Succeeded"),
+		},
+	}
+}
+
+func TestReconcileVMExtension(t *testing.T) {
+	g := NewWithT(t)
+
+	testcases := []struct {
+		name          string
+		expectedError string
+		expect        func(m *mock_virtualmachineextensions.MockClientMockRecorder)
+	}{
+		{
+			name:          "extension is up to date",
+			expectedError: "",
+			expect: func(m *mock_virtualmachineextensions.MockClientMockRecorder) {
+				m.Get(context.TODO(), "my-rg", "my-vm", "hardening").Return(newExistingExtension("2.1"), nil)
+			},
+		},
+		{
+			name:          "create extension",
+			expectedError: "",
+			expect: func(m *mock_virtualmachineextensions.MockClientMockRecorder) {
+				m.Get(context.TODO(), "my-rg", "my-vm", "hardening").Return(compute.VirtualMachineExtension{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not Found"))
+				m.CreateOrUpdate(context.TODO(), "my-rg", "my-vm", "hardening", gomock.AssignableToTypeOf(compute.VirtualMachineExtension{})).Do(
+					func(_ context.Context, _, _, _ string, extension compute.VirtualMachineExtension) {
+						g.Expect(to.String(extension.Location)).To(Equal("test-location"))
+						g.Expect(to.String(extension.Publisher)).To(Equal("Microsoft.Azure.Extensions"))
+						g.Expect(to.String(extension.VirtualMachineExtensionProperties.Type)).To(Equal("CustomScript"))
+						g.Expect(to.String(extension.TypeHandlerVersion)).To(Equal("2.1"))
+						g.Expect(extension.Settings).To(Equal(map[string]interface{}{"commandToExecute": "./harden.sh"}))
+						g.Expect(extension.ProtectedSettings).To(Equal(map[string]string{"storageAccountKey": "secret"}))
+						g.Expect(to.String(extension.ForceUpdateTag)).NotTo(BeEmpty())
+					})
+			},
+		},
+		{
+			name:          "update extension with a different version",
+			expectedError: "",
+			expect: func(m *mock_virtualmachineextensions.MockClientMockRecorder) {
+				m.Get(context.TODO(), "my-rg", "my-vm", "hardening").Return(newExistingExtension("2.0"), nil)
+				m.CreateOrUpdate(context.TODO(), "my-rg", "my-vm", "hardening", gomock.AssignableToTypeOf(compute.VirtualMachineExtension{}))
+			},
+		},
+		{
+			name:          "fail to get extension",
+			expectedError: "failed to get VM extension hardening on VM my-vm: #: Internal Server Error: StatusCode=500",
+			expect: func(m *mock_virtualmachineextensions.MockClientMockRecorder) {
+				m.Get(context.TODO(), "my-rg", "my-vm", "hardening").Return(compute.VirtualMachineExtension{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"))
+			},
+		},
+		{
+			name:          "fail to create extension",
+			expectedError: "failed to create VM extension hardening on VM my-vm in resource group my-rg: #: Internal Server Error: StatusCode=500",
+			expect: func(m *mock_virtualmachineextensions.MockClientMockRecorder) {
+				m.Get(context.TODO(), "my-rg", "my-vm", "hardening").Return(compute.VirtualMachineExtension{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not Found"))
+				m.CreateOrUpdate(context.TODO(), "my-rg", "my-vm", "hardening", gomock.AssignableToTypeOf(compute.VirtualMachineExtension{})).Return(autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"))
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			extensionsMock := mock_virtualmachineextensions.NewMockClient(mockCtrl)
+
+			tc.expect(extensionsMock.EXPECT())
+
+			s := &Service{
+				Scope:  newClusterScope(g),
+				Client: extensionsMock,
+			}
+
+			err := s.Reconcile(context.TODO(), newExtensionSpec())
+			if tc.expectedError != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err).To(MatchError(tc.expectedError))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestDeleteVMExtension(t *testing.T) {
+	g := NewWithT(t)
+
+	testcases := []struct {
+		name          string
+		expectedError string
+		expect        func(m *mock_virtualmachineextensions.MockClientMockRecorder)
+	}{
+		{
+			name:          "delete extension",
+			expectedError: "",
+			expect: func(m *mock_virtualmachineextensions.MockClientMockRecorder) {
+				m.Delete(context.TODO(), "my-rg", "my-vm", "hardening")
+			},
+		},
+		{
+			name:          "extension already deleted",
+			expectedError: "",
+			expect: func(m *mock_virtualmachineextensions.MockClientMockRecorder) {
+				m.Delete(context.TODO(), "my-rg", "my-vm", "hardening").Return(autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not Found"))
+			},
+		},
+		{
+			name:          "fail to delete extension",
+			expectedError: "failed to delete VM extension hardening on VM my-vm in resource group my-rg: #: Internal Server Error: StatusCode=500",
+			expect: func(m *mock_virtualmachineextensions.MockClientMockRecorder) {
+				m.Delete(context.TODO(), "my-rg", "my-vm", "hardening").Return(autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"))
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			extensionsMock := mock_virtualmachineextensions.NewMockClient(mockCtrl)
+
+			tc.expect(extensionsMock.EXPECT())
+
+			s := &Service{
+				Scope:  newClusterScope(g),
+				Client: extensionsMock,
+			}
+
+			err := s.Delete(context.TODO(), &Spec{Name: "hardening", VMName: "my-vm"})
+			if tc.expectedError != "" {
+				g.Expect(err).To(HaveOccurred())
+				g.Expect(err).To(MatchError(tc.expectedError))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
@@ -19,7 +19,7 @@ package virtualmachines
 import (
 	"context"
 
-	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2021-07-01/compute"
 	"github.com/Azure/go-autorest/autorest"
 	azure "sigs.k8s.io/cluster-api-provider-azure/cloud"
 )
@@ -27,6 +27,7 @@ import (
 // Client wraps go-sdk
 type Client interface {
 	Get(context.Context, string, string) (compute.VirtualMachine, error)
+	GetInstanceView(context.Context, string, string) (compute.VirtualMachineInstanceView, error)
 	CreateOrUpdate(context.Context, string, string, compute.VirtualMachine) error
 	Delete(context.Context, string, string) error
 }
@@ -57,6 +58,11 @@ func (ac *AzureClient) Get(ctx context.Context, resourceGroupName, vmName string
 	return ac.virtualmachines.Get(ctx, resourceGroupName, vmName, "")
 }
 
+// GetInstanceView retrieves information about the run-time state of a virtual machine.
+func (ac *AzureClient) GetInstanceView(ctx context.Context, resourceGroupName, vmName string) (compute.VirtualMachineInstanceView, error) {
+	return ac.virtualmachines.InstanceView(ctx, resourceGroupName, vmName)
+}
+
 // CreateOrUpdate the operation to create or update a virtual machine.
 func (ac *AzureClient) CreateOrUpdate(ctx context.Context, resourceGroupName, vmName string, vm compute.VirtualMachine) error {
 	future, err := ac.virtualmachines.CreateOrUpdate(ctx, resourceGroupName, vmName, vm)
@@ -73,7 +79,7 @@ func (ac *AzureClient) CreateOrUpdate(ctx context.Context, resourceGroupName, vm
 
 // Delete the operation to delete a virtual machine.
 func (ac *AzureClient) Delete(ctx context.Context, resourceGroupName, vmName string) error {
-	future, err := ac.virtualmachines.Delete(ctx, resourceGroupName, vmName)
+	future, err := ac.virtualmachines.Delete(ctx, resourceGroupName, vmName, nil)
 	if err != nil {
 		return err
 	}
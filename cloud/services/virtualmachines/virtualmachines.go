@@ -24,20 +24,17 @@ import (
 	"strconv"
 	"strings"
 
-	"github.com/Azure/azure-sdk-for-go/profiles/2019-03-01/authorization/mgmt/authorization"
-	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2021-07-01/compute"
 	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/util/uuid"
 	"k8s.io/klog"
 	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
 	azure "sigs.k8s.io/cluster-api-provider-azure/cloud"
 	"sigs.k8s.io/cluster-api-provider-azure/cloud/converters"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/roleassignments"
 )
 
-const azureBuiltInContributorID = "b24988ac-6180-42a0-ab88-20f7382dd24c"
-
 // Spec input specification for Get/CreateOrUpdate/Delete calls
 type Spec struct {
 	Name                   string
@@ -50,7 +47,15 @@ type Spec struct {
 	OSDisk                 infrav1.OSDisk
 	CustomData             string
 	UserAssignedIdentities []infrav1.UserAssignedIdentity
-	SpotVMOptions          *infrav1.SpotVMOptions
+	// SystemAssignedIdentityRoles are the roles assigned to the system-assigned identity of the VM.
+	SystemAssignedIdentityRoles []infrav1.SystemAssignedIdentityRole
+	// RoleAssignmentIDs are the IDs of the role assignments previously made for the system-assigned identity of
+	// the VM. The ones that are no longer wanted are deleted.
+	RoleAssignmentIDs []string
+	SpotVMOptions     *infrav1.SpotVMOptions
+	// BootDiagnosticsStorageURI is the blob endpoint of the storage account used for boot diagnostics.
+	// Boot diagnostics are disabled when empty.
+	BootDiagnosticsStorageURI string
 }
 
 // Get provides information about a virtual machine.
@@ -154,6 +159,15 @@ func (s *Service) Reconcile(ctx context.Context, spec interface{}) error {
 		},
 	}
 
+	if vmSpec.BootDiagnosticsStorageURI != "" {
+		virtualMachine.DiagnosticsProfile = &compute.DiagnosticsProfile{
+			BootDiagnostics: &compute.BootDiagnostics{
+				Enabled:    to.BoolPtr(true),
+				StorageURI: to.StringPtr(vmSpec.BootDiagnosticsStorageURI),
+			},
+		}
+	}
+
 	klog.V(2).Infof("Setting zone %s ", vmSpec.Zone)
 
 	if vmSpec.Zone != "" {
@@ -196,7 +210,7 @@ func (s *Service) Reconcile(ctx context.Context, spec interface{}) error {
 	}
 
 	if vmSpec.Identity == infrav1.VMIdentitySystemAssigned {
-		err = s.createRoleAssignmentForIdentity(ctx, vmSpec.Name)
+		_, err = s.ReconcileRoleAssignments(ctx, vmSpec)
 		if err != nil {
 			return errors.Wrapf(err, "cannot create VM")
 		}
@@ -206,28 +220,40 @@ func (s *Service) Reconcile(ctx context.Context, spec interface{}) error {
 	return nil
 }
 
-func (s *Service) createRoleAssignmentForIdentity(ctx context.Context, vmName string) error {
-	resultVM, err := s.Client.Get(ctx, s.Scope.ResourceGroup(), vmName)
+// ReconcileRoleAssignments assigns the roles of the VM spec to the system-assigned identity of the VM and deletes
+// the previously made role assignments that are no longer wanted. It returns the IDs of the role assignments of
+// the identity.
+func (s *Service) ReconcileRoleAssignments(ctx context.Context, vmSpec *Spec) ([]string, error) {
+	resultVM, err := s.Client.Get(ctx, s.Scope.ResourceGroup(), vmSpec.Name)
 	if err != nil {
-		return errors.Wrapf(err, "cannot get VM to assign role to system assigned identity")
+		return nil, errors.Wrapf(err, "cannot get VM to assign role to system assigned identity")
+	}
+	if resultVM.Identity == nil || resultVM.Identity.PrincipalID == nil {
+		return nil, errors.Errorf("VM %s does not have a system assigned identity", vmSpec.Name)
 	}
 
-	scope := fmt.Sprintf("/subscriptions/%s/", s.Scope.SubscriptionID())
-	// Azure built-in roles https://docs.microsoft.com/en-us/azure/role-based-access-control/built-in-roles
-	contributorRoleDefinitionID := fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Authorization/roleDefinitions/%s", s.Scope.SubscriptionID(), azureBuiltInContributorID)
-	params := authorization.RoleAssignmentCreateParameters{
-		Properties: &authorization.RoleAssignmentProperties{
-			RoleDefinitionID: to.StringPtr(contributorRoleDefinitionID),
-			PrincipalID:      to.StringPtr(*resultVM.Identity.PrincipalID),
-		},
+	roleAssignmentsSvc := &roleassignments.Service{Client: s.RoleAssignmentsClient}
+	var ids []string
+	for _, roleSpec := range s.roleAssignmentSpecs(vmSpec, to.String(resultVM.Identity.PrincipalID)) {
+		roleSpec := roleSpec
+		if err := roleAssignmentsSvc.Reconcile(ctx, &roleSpec); err != nil {
+			return nil, errors.Wrapf(err, "cannot assign role to VM system assigned identity")
+		}
+		ids = append(ids, roleSpec.ID())
 	}
-	_, err = s.RoleAssignmentsClient.Create(ctx, scope, string(uuid.NewUUID()), params)
-	if err != nil {
-		return errors.Wrapf(err, "cannot assign role to VM system assigned identity")
+
+	if err := roleAssignmentsSvc.DeleteStale(ctx, vmSpec.RoleAssignmentIDs, ids); err != nil {
+		return nil, errors.Wrapf(err, "failed to delete stale role assignments of VM %s", vmSpec.Name)
 	}
 
-	klog.V(2).Infof("successfully created Role assignment for generated Identity for VM %s ", vmName)
-	return nil
+	klog.V(2).Infof("successfully reconciled role assignments for generated Identity for VM %s ", vmSpec.Name)
+	return ids, nil
+}
+
+// roleAssignmentSpecs returns the role assignments of the system-assigned identity of a VM.
+func (s *Service) roleAssignmentSpecs(vmSpec *Spec, principalID string) []roleassignments.Spec {
+	vmID := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Compute/virtualMachines/%s", s.Scope.SubscriptionID(), s.Scope.ResourceGroup(), vmSpec.Name)
+	return roleassignments.SpecsForIdentity(s.Scope.SubscriptionID(), s.Scope.ResourceGroup(), vmID, principalID, vmSpec.SystemAssignedIdentityRoles)
 }
 
 // Delete deletes the virtual machine with the provided name.
@@ -236,6 +262,20 @@ func (s *Service) Delete(ctx context.Context, spec interface{}) error {
 	if !ok {
 		return errors.New("invalid VM specification")
 	}
+	roleAssignmentsSvc := &roleassignments.Service{Client: s.RoleAssignmentsClient}
+	if vmSpec.Identity == infrav1.VMIdentitySystemAssigned {
+		for _, roleSpec := range s.roleAssignmentSpecs(vmSpec, "") {
+			roleSpec := roleSpec
+			if err := roleAssignmentsSvc.Delete(ctx, &roleSpec); err != nil {
+				return errors.Wrapf(err, "failed to delete role assignments of VM %s", vmSpec.Name)
+			}
+		}
+	}
+	// The recorded role assignments also include the ones of roles removed from the spec since they were assigned.
+	if err := roleAssignmentsSvc.DeleteStale(ctx, vmSpec.RoleAssignmentIDs, nil); err != nil {
+		return errors.Wrapf(err, "failed to delete role assignments of VM %s", vmSpec.Name)
+	}
+
 	klog.V(2).Infof("deleting VM %s ", vmSpec.Name)
 	err := s.Client.Delete(ctx, s.Scope.ResourceGroup(), vmSpec.Name)
 	if err != nil && azure.ResourceNotFound(err) {
@@ -250,6 +290,21 @@ func (s *Service) Delete(ctx context.Context, spec interface{}) error {
 	return nil
 }
 
+// GetSerialConsoleLogURI returns the URI of the blob holding the serial console log of a virtual machine
+// with boot diagnostics enabled.
+func (s *Service) GetSerialConsoleLogURI(ctx context.Context, vmSpec *Spec) (string, error) {
+	instanceView, err := s.Client.GetInstanceView(ctx, s.Scope.ResourceGroup(), vmSpec.Name)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get instance view of VM %s", vmSpec.Name)
+	}
+
+	if instanceView.BootDiagnostics == nil || instanceView.BootDiagnostics.SerialConsoleLogBlobURI == nil {
+		return "", errors.Errorf("boot diagnostics serial console log is not available for VM %s", vmSpec.Name)
+	}
+
+	return to.String(instanceView.BootDiagnostics.SerialConsoleLogBlobURI), nil
+}
+
 func (s *Service) getAddresses(ctx context.Context, vm compute.VirtualMachine) ([]corev1.NodeAddress, error) {
 
 	addresses := []corev1.NodeAddress{}
@@ -364,7 +419,7 @@ func getSpotVMOptions(spotVMOptions *infrav1.SpotVMOptions) (compute.VirtualMach
 			MaxPrice: &maxPrice,
 		}
 	}
-	return compute.Spot, compute.Deallocate, billingProfile, nil
+	return compute.VirtualMachinePriorityTypesSpot, compute.VirtualMachineEvictionPolicyTypesDeallocate, billingProfile, nil
 }
 
 // GenerateRandomString returns a URL-safe, base64 encoded
@@ -27,11 +27,12 @@ import (
 	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/roleassignments/mock_roleassignments"
 	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/virtualmachines/mock_virtualmachines"
 
+	"github.com/Azure/azure-sdk-for-go/profiles/2019-03-01/authorization/mgmt/authorization"
 	"github.com/Azure/go-autorest/autorest"
 	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/golang/mock/gomock"
 
-	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2021-07-01/compute"
 	network "github.com/Azure/azure-sdk-for-go/services/network/mgmt/2019-06-01/network"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -435,6 +436,7 @@ func TestReconcileVM(t *testing.T) {
 			azureCluster: &infrav1.AzureCluster{
 				Spec: infrav1.AzureClusterSpec{
 					SubscriptionID: subscriptionID,
+					ResourceGroup:  "my-rg",
 					NetworkSpec: infrav1.NetworkSpec{
 						Subnets: infrav1.Subnets{
 							&infrav1.SubnetSpec{
@@ -455,7 +457,80 @@ func TestReconcileVM(t *testing.T) {
 			expect: func(g *WithT, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder, mra *mock_roleassignments.MockClientMockRecorder) {
 				mnic.Get(gomock.Any(), gomock.Any(), gomock.Any())
 				m.CreateOrUpdate(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any())
-				mra.Create(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any())
+				m.Get(gomock.Any(), gomock.Any(), gomock.Any()).Return(compute.VirtualMachine{
+					Identity: &compute.VirtualMachineIdentity{
+						PrincipalID: to.StringPtr("principal-id"),
+					},
+				}, nil)
+				mra.Get(gomock.Any(), "/subscriptions/123/resourceGroups/my-rg", gomock.Any()).Return(authorization.RoleAssignment{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
+				mra.Create(gomock.Any(), "/subscriptions/123/resourceGroups/my-rg", gomock.Any(), gomock.AssignableToTypeOf(authorization.RoleAssignmentCreateParameters{})).Do(
+					func(_ context.Context, _, _ string, params authorization.RoleAssignmentCreateParameters) {
+						g.Expect(to.String(params.Properties.RoleDefinitionID)).To(Equal("/subscriptions/123/providers/Microsoft.Authorization/roleDefinitions/b24988ac-6180-42a0-ab88-20f7382dd24c"))
+						g.Expect(to.String(params.Properties.PrincipalID)).To(Equal("principal-id"))
+					})
+			},
+			expectedError: "",
+		},
+		{
+			name: "can create a vm with system assigned identity roles",
+			machine: clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"set": "node"},
+				},
+				Spec: clusterv1.MachineSpec{
+					Bootstrap: clusterv1.Bootstrap{
+						Data: to.StringPtr("bootstrap-data"),
+					},
+					Version: to.StringPtr("1.15.7"),
+				},
+			},
+			machineConfig: &infrav1.AzureMachineSpec{
+				VMSize:   "Standard_B2ms",
+				Location: "eastus",
+				Image:    image,
+				Identity: "SystemAssigned",
+				SystemAssignedIdentityRoles: []infrav1.SystemAssignedIdentityRole{
+					{
+						DefinitionID: "acdd72a7-3385-48ef-bd42-f606fba81ae7",
+					},
+				},
+			},
+			azureCluster: &infrav1.AzureCluster{
+				Spec: infrav1.AzureClusterSpec{
+					ResourceGroup:  "my-rg",
+					SubscriptionID: subscriptionID,
+					NetworkSpec: infrav1.NetworkSpec{
+						Subnets: infrav1.Subnets{
+							&infrav1.SubnetSpec{
+								Name: "subnet-1",
+							},
+							&infrav1.SubnetSpec{},
+						},
+					},
+				},
+				Status: infrav1.AzureClusterStatus{
+					Network: infrav1.Network{
+						APIServerIP: infrav1.PublicIP{
+							DNSName: "azure-test-dns",
+						},
+					},
+				},
+			},
+			expect: func(g *WithT, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder, mra *mock_roleassignments.MockClientMockRecorder) {
+				mnic.Get(gomock.Any(), gomock.Any(), gomock.Any())
+				m.CreateOrUpdate(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any())
+				m.Get(gomock.Any(), "my-rg", gomock.Any()).Return(compute.VirtualMachine{
+					Identity: &compute.VirtualMachineIdentity{
+						PrincipalID: to.StringPtr("principal-id"),
+					},
+				}, nil)
+				mra.Get(gomock.Any(), "/subscriptions/123/resourceGroups/my-rg", gomock.Any()).Return(authorization.RoleAssignment{
+					Name: to.StringPtr("existing"),
+					Properties: &authorization.RoleAssignmentPropertiesWithScope{
+						RoleDefinitionID: to.StringPtr("/subscriptions/123/providers/Microsoft.Authorization/roleDefinitions/acdd72a7-3385-48ef-bd42-f606fba81ae7"),
+						PrincipalID:      to.StringPtr("principal-id"),
+					},
+				}, nil)
 			},
 			expectedError: "",
 		},
@@ -502,7 +577,6 @@ func TestReconcileVM(t *testing.T) {
 			expect: func(g *WithT, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder, mra *mock_roleassignments.MockClientMockRecorder) {
 				mnic.Get(gomock.Any(), gomock.Any(), gomock.Any())
 				m.CreateOrUpdate(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any())
-				mra.Create(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any())
 			},
 			expectedError: "",
 		},
@@ -548,13 +622,64 @@ func TestReconcileVM(t *testing.T) {
 			expect: func(g *WithT, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder, mra *mock_roleassignments.MockClientMockRecorder) {
 				mnic.Get(gomock.Any(), gomock.Any(), gomock.Any())
 				m.CreateOrUpdate(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(func(_, _, _ interface{}, vm compute.VirtualMachine) {
-					g.Expect(vm.Priority).To(Equal(compute.Spot))
-					g.Expect(vm.EvictionPolicy).To(Equal(compute.Deallocate))
+					g.Expect(vm.Priority).To(Equal(compute.VirtualMachinePriorityTypesSpot))
+					g.Expect(vm.EvictionPolicy).To(Equal(compute.VirtualMachineEvictionPolicyTypesDeallocate))
 					g.Expect(vm.BillingProfile).To(BeNil())
 				})
 			},
 			expectedError: "",
 		},
+		{
+			name: "can create a vm with boot diagnostics",
+			machine: clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"set": "node"},
+				},
+				Spec: clusterv1.MachineSpec{
+					Bootstrap: clusterv1.Bootstrap{
+						Data: to.StringPtr("bootstrap-data"),
+					},
+					Version: to.StringPtr("1.15.7"),
+				},
+			},
+			machineConfig: &infrav1.AzureMachineSpec{
+				VMSize:   "Standard_B2ms",
+				Location: "eastus",
+				Image:    image,
+				BootDiagnostics: &infrav1.BootDiagnostics{
+					StorageAccountURI: "https://fakestorage.blob.core.windows.net/",
+				},
+			},
+			azureCluster: &infrav1.AzureCluster{
+				Spec: infrav1.AzureClusterSpec{
+					SubscriptionID: subscriptionID,
+					NetworkSpec: infrav1.NetworkSpec{
+						Subnets: infrav1.Subnets{
+							&infrav1.SubnetSpec{
+								Name: "subnet-1",
+							},
+							&infrav1.SubnetSpec{},
+						},
+					},
+				},
+				Status: infrav1.AzureClusterStatus{
+					Network: infrav1.Network{
+						APIServerIP: infrav1.PublicIP{
+							DNSName: "azure-test-dns",
+						},
+					},
+				},
+			},
+			expect: func(g *WithT, m *mock_virtualmachines.MockClientMockRecorder, mnic *mock_networkinterfaces.MockClientMockRecorder, mpip *mock_publicips.MockClientMockRecorder, mra *mock_roleassignments.MockClientMockRecorder) {
+				mnic.Get(gomock.Any(), gomock.Any(), gomock.Any())
+				m.CreateOrUpdate(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(func(_, _, _ interface{}, vm compute.VirtualMachine) {
+					g.Expect(vm.DiagnosticsProfile).NotTo(BeNil())
+					g.Expect(*vm.DiagnosticsProfile.BootDiagnostics.Enabled).To(BeTrue())
+					g.Expect(*vm.DiagnosticsProfile.BootDiagnostics.StorageURI).To(Equal("https://fakestorage.blob.core.windows.net/"))
+				})
+			},
+			expectedError: "",
+		},
 		{
 			name: "vm creation fails",
 			machine: clusterv1.Machine{
@@ -610,6 +735,7 @@ func TestReconcileVM(t *testing.T) {
 			interfaceMock := mock_networkinterfaces.NewMockClient(mockCtrl)
 			publicIPMock := mock_publicips.NewMockClient(mockCtrl)
 			roleAssignmentMock := mock_roleassignments.NewMockClient(mockCtrl)
+			defer mockCtrl.Finish()
 
 			cluster := &clusterv1.Cluster{
 				ObjectMeta: metav1.ObjectMeta{
@@ -685,6 +811,13 @@ func TestReconcileVM(t *testing.T) {
 				Image:         machineScope.AzureMachine.Spec.Image,
 				CustomData:    *machineScope.Machine.Spec.Bootstrap.Data,
 				SpotVMOptions: machineScope.AzureMachine.Spec.SpotVMOptions,
+
+				Identity:                    machineScope.AzureMachine.Spec.Identity,
+				UserAssignedIdentities:      machineScope.AzureMachine.Spec.UserAssignedIdentities,
+				SystemAssignedIdentityRoles: machineScope.AzureMachine.Spec.SystemAssignedIdentityRoles,
+			}
+			if bd := machineScope.AzureMachine.Spec.BootDiagnostics; bd != nil {
+				vmSpec.BootDiagnosticsStorageURI = bd.StorageAccountURI
 			}
 
 			err = s.Reconcile(context.TODO(), vmSpec)
@@ -705,7 +838,7 @@ func TestDeleteVM(t *testing.T) {
 		name          string
 		vmSpec        Spec
 		expectedError string
-		expect        func(m *mock_virtualmachines.MockClientMockRecorder)
+		expect        func(m *mock_virtualmachines.MockClientMockRecorder, mra *mock_roleassignments.MockClientMockRecorder)
 	}{
 		{
 			name: "successfully delete an existing vm",
@@ -713,7 +846,7 @@ func TestDeleteVM(t *testing.T) {
 				Name: "my-vm",
 			},
 			expectedError: "",
-			expect: func(m *mock_virtualmachines.MockClientMockRecorder) {
+			expect: func(m *mock_virtualmachines.MockClientMockRecorder, mra *mock_roleassignments.MockClientMockRecorder) {
 				m.Delete(context.TODO(), "my-rg", "my-vm")
 			},
 		},
@@ -723,7 +856,7 @@ func TestDeleteVM(t *testing.T) {
 				Name: "my-vm",
 			},
 			expectedError: "",
-			expect: func(m *mock_virtualmachines.MockClientMockRecorder) {
+			expect: func(m *mock_virtualmachines.MockClientMockRecorder, mra *mock_roleassignments.MockClientMockRecorder) {
 				m.Delete(context.TODO(), "my-rg", "my-vm").
 					Return(autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not found"))
 			},
@@ -734,17 +867,38 @@ func TestDeleteVM(t *testing.T) {
 				Name: "my-vm",
 			},
 			expectedError: "failed to delete VM my-vm in resource group my-rg: #: Internal Server Error: StatusCode=500",
-			expect: func(m *mock_virtualmachines.MockClientMockRecorder) {
+			expect: func(m *mock_virtualmachines.MockClientMockRecorder, mra *mock_roleassignments.MockClientMockRecorder) {
 				m.Delete(context.TODO(), "my-rg", "my-vm").
 					Return(autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 500}, "Internal Server Error"))
 			},
 		},
+		{
+			name: "delete the role assignments of removed roles",
+			vmSpec: Spec{
+				Name:     "my-vm",
+				Identity: infrav1.VMIdentitySystemAssigned,
+				SystemAssignedIdentityRoles: []infrav1.SystemAssignedIdentityRole{
+					{
+						DefinitionID: "acdd72a7-3385-48ef-bd42-f606fba81ae7",
+					},
+				},
+				RoleAssignmentIDs: []string{"/subscriptions/123/providers/Microsoft.Authorization/roleAssignments/contributor-assignment"},
+			},
+			expectedError: "",
+			expect: func(m *mock_virtualmachines.MockClientMockRecorder, mra *mock_roleassignments.MockClientMockRecorder) {
+				mra.Delete(context.TODO(), "/subscriptions/123/resourceGroups/my-rg", gomock.Any())
+				mra.Delete(context.TODO(), "/subscriptions/123", "contributor-assignment")
+				m.Delete(context.TODO(), "my-rg", "my-vm")
+			},
+		},
 	}
 
 	for _, tc := range testcases {
 		t.Run(tc.name, func(t *testing.T) {
 			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
 			vmMock := mock_virtualmachines.NewMockClient(mockCtrl)
+			roleAssignmentMock := mock_roleassignments.NewMockClient(mockCtrl)
 
 			cluster := &clusterv1.Cluster{
 				ObjectMeta: metav1.ObjectMeta{Name: "test-cluster"},
@@ -752,7 +906,7 @@ func TestDeleteVM(t *testing.T) {
 
 			client := fake.NewFakeClientWithScheme(scheme.Scheme, cluster)
 
-			tc.expect(vmMock.EXPECT())
+			tc.expect(vmMock.EXPECT(), roleAssignmentMock.EXPECT())
 
 			clusterScope, err := scope.NewClusterScope(scope.ClusterScopeParams{
 				AzureClients: scope.AzureClients{
@@ -774,8 +928,9 @@ func TestDeleteVM(t *testing.T) {
 			g.Expect(err).NotTo(HaveOccurred())
 
 			s := &Service{
-				Scope:  clusterScope,
-				Client: vmMock,
+				Scope:                 clusterScope,
+				Client:                vmMock,
+				RoleAssignmentsClient: roleAssignmentMock,
 			}
 
 			err = s.Delete(context.TODO(), &tc.vmSpec)
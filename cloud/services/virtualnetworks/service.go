@@ -17,17 +17,26 @@ limitations under the License.
 package virtualnetworks
 
 import (
-	"sigs.k8s.io/cluster-api-provider-azure/cloud/scope"
+	"github.com/go-logr/logr"
+	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
+	azure "sigs.k8s.io/cluster-api-provider-azure/cloud"
 )
 
+// VNetScope defines the scope interface for a virtual network service.
+type VNetScope interface {
+	logr.Logger
+	azure.ClusterDescriber
+	Vnet() *infrav1.VnetSpec
+}
+
 // Service provides operations on azure resources
 type Service struct {
-	Scope *scope.ClusterScope
+	Scope VNetScope
 	Client
 }
 
 // NewService creates a new service.
-func NewService(scope *scope.ClusterScope) *Service {
+func NewService(scope VNetScope) *Service {
 	return &Service{
 		Scope:  scope,
 		Client: NewClient(scope),
@@ -36,6 +36,7 @@ import (
 	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/publicloadbalancers"
 	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/routetables"
 	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/securitygroups"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/storageaccounts"
 	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/subnets"
 	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/virtualnetworks"
 )
@@ -52,6 +53,7 @@ type azureClusterReconciler struct {
 	publicIPSvc          azure.Service
 	publicLBSvc          azure.OldService
 	availabilityZonesSvc azure.GetterService
+	storageAccountsSvc   azure.OldService
 }
 
 // newAzureClusterReconciler populates all the services based on input scope
@@ -67,6 +69,7 @@ func newAzureClusterReconciler(scope *scope.ClusterScope) *azureClusterReconcile
 		publicIPSvc:          publicips.NewService(scope),
 		publicLBSvc:          publicloadbalancers.NewService(scope),
 		availabilityZonesSvc: availabilityzones.NewService(scope),
+		storageAccountsSvc:   storageaccounts.NewService(scope),
 	}
 }
 
@@ -211,6 +214,13 @@ func (r *azureClusterReconciler) Delete(ctx context.Context) error {
 		}
 	}
 
+	storageAccountSpec := &storageaccounts.Spec{
+		Name: azure.GenerateBootDiagnosticsStorageAccountName(r.scope.SubscriptionID(), r.scope.ResourceGroup(), r.scope.ClusterName()),
+	}
+	if err := r.storageAccountsSvc.Delete(ctx, storageAccountSpec); err != nil {
+		return errors.Wrapf(err, "failed to delete boot diagnostics storage account %s for cluster %s", storageAccountSpec.Name, r.scope.ClusterName())
+	}
+
 	if err := r.groupsSvc.Delete(ctx, nil); err != nil {
 		if !azure.ResourceNotFound(err) {
 			return errors.Wrapf(err, "failed to delete resource group for cluster %s", r.scope.ClusterName())
@@ -0,0 +1,117 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/scope"
+)
+
+const (
+	// DefaultBootLogCaptureTimeout is the default duration after which the serial console log of a VM
+	// that has not become a Node is captured.
+	DefaultBootLogCaptureTimeout = 20 * time.Minute
+
+	// BootLogSecretKey is the key of the serial console log in the boot log Secret.
+	BootLogSecretKey = "serialconsole.log"
+
+	// maxBootLogSize is the maximum number of bytes of the serial console log stored in the boot log Secret,
+	// keeping the Secret well below the 1MiB object size limit. The end of the log is kept.
+	maxBootLogSize = 512 * 1024
+)
+
+// BootLogSecretName returns the name of the Secret holding the serial console log of an AzureMachine.
+func BootLogSecretName(azureMachineName string) string {
+	return fmt.Sprintf("%s-boot-log", azureMachineName)
+}
+
+// reconcileBootLog captures the serial console log of a VM whose Machine has not become a Node within the
+// boot log capture timeout into a Secret owned by the AzureMachine, so that bootstrap failures can be
+// debugged without access to the VM or the Azure portal.
+func (r *AzureMachineReconciler) reconcileBootLog(ctx context.Context, machineScope *scope.MachineScope, ams *azureMachineService) (reconcile.Result, error) {
+	if r.BootLogCaptureTimeout <= 0 || !machineScope.AzureMachine.Status.Ready || machineScope.Machine.Status.NodeRef != nil {
+		return reconcile.Result{}, nil
+	}
+
+	if remaining := time.Until(machineScope.AzureMachine.CreationTimestamp.Add(r.BootLogCaptureTimeout)); remaining > 0 {
+		return reconcile.Result{RequeueAfter: remaining}, nil
+	}
+
+	secretName := BootLogSecretName(machineScope.Name())
+	err := r.Get(ctx, client.ObjectKey{Namespace: machineScope.Namespace(), Name: secretName}, &corev1.Secret{})
+	if err == nil {
+		// the serial console log has already been captured
+		return reconcile.Result{}, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return reconcile.Result{}, errors.Wrapf(err, "failed to get boot log secret %s/%s", machineScope.Namespace(), secretName)
+	}
+
+	machineScope.Info("Machine has not become a Node, capturing serial console log", "timeout", r.BootLogCaptureTimeout)
+	bootLog, err := ams.GetSerialConsoleLog(ctx)
+	if err != nil {
+		r.Recorder.Eventf(machineScope.AzureMachine, corev1.EventTypeWarning, "BootLogCaptureFailed", "Failed to capture serial console log: %v", err)
+		return reconcile.Result{}, errors.Wrapf(err, "failed to capture serial console log of VM %s", machineScope.Name())
+	}
+	owner := metav1.OwnerReference{
+		APIVersion: infrav1.GroupVersion.String(),
+		Kind:       "AzureMachine",
+		Name:       machineScope.AzureMachine.Name,
+		UID:        machineScope.AzureMachine.UID,
+	}
+	secret := NewBootLogSecret(secretName, machineScope.Namespace(), machineScope.ClusterName(), owner, bootLog)
+	if err := r.Create(ctx, secret); err != nil && !apierrors.IsAlreadyExists(err) {
+		return reconcile.Result{}, errors.Wrapf(err, "failed to create boot log secret %s/%s", machineScope.Namespace(), secretName)
+	}
+
+	r.Recorder.Eventf(machineScope.AzureMachine, corev1.EventTypeWarning, "BootLogCaptured",
+		"Machine did not become a Node within %s, serial console log captured to Secret %s", r.BootLogCaptureTimeout, secretName)
+	return reconcile.Result{}, nil
+}
+
+// NewBootLogSecret returns a Secret holding the end of a serial console log, labeled with the name of the cluster
+// and owned by the given owner.
+func NewBootLogSecret(name, namespace, clusterName string, owner metav1.OwnerReference, bootLog []byte) *corev1.Secret {
+	if len(bootLog) > maxBootLogSize {
+		bootLog = bootLog[len(bootLog)-maxBootLogSize:]
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels: map[string]string{
+				clusterv1.ClusterLabelName: clusterName,
+			},
+			OwnerReferences: []metav1.OwnerReference{owner},
+		},
+		Data: map[string][]byte{
+			BootLogSecretKey: bootLog,
+		},
+	}
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2021-07-01/compute"
+	"github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2019-06-01/storage"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/scope"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/storageaccounts"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/storageaccounts/mock_storageaccounts"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/virtualmachines"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/virtualmachines/mock_virtualmachines"
+)
+
+func TestReconcileBootLog(t *testing.T) {
+	const blobURI = "https://mystorageaccount.blob.core.windows.net/bootdiagnostics-myvm/my-vm.serialconsole.log"
+
+	testcases := []struct {
+		name           string
+		created        time.Time
+		nodeRef        *corev1.ObjectReference
+		expectRequeue  bool
+		expectCaptured bool
+		expect         func(vm *mock_virtualmachines.MockClientMockRecorder, sa *mock_storageaccounts.MockClientMockRecorder)
+	}{
+		{
+			name:    "machine became a node",
+			created: time.Now().Add(-time.Hour),
+			nodeRef: &corev1.ObjectReference{Name: "my-node"},
+			expect: func(vm *mock_virtualmachines.MockClientMockRecorder, sa *mock_storageaccounts.MockClientMockRecorder) {
+			},
+		},
+		{
+			name:          "timeout not reached yet",
+			created:       time.Now(),
+			expectRequeue: true,
+			expect: func(vm *mock_virtualmachines.MockClientMockRecorder, sa *mock_storageaccounts.MockClientMockRecorder) {
+			},
+		},
+		{
+			name:           "capture serial console log after timeout",
+			created:        time.Now().Add(-time.Hour),
+			expectCaptured: true,
+			expect: func(vm *mock_virtualmachines.MockClientMockRecorder, sa *mock_storageaccounts.MockClientMockRecorder) {
+				vm.GetInstanceView(gomock.Any(), "my-rg", "my-vm").Return(compute.VirtualMachineInstanceView{
+					BootDiagnostics: &compute.BootDiagnosticsInstanceView{
+						SerialConsoleLogBlobURI: to.StringPtr(blobURI),
+					},
+				}, nil)
+				sa.List(gomock.Any()).Return([]storage.Account{
+					{
+						ID:   to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Storage/storageAccounts/mystorageaccount"),
+						Name: to.StringPtr("mystorageaccount"),
+					},
+				}, nil)
+				sa.ListServiceSAS(gomock.Any(), "my-rg", "mystorageaccount", gomock.Any()).Return("sig=abc", nil)
+				sa.GetBlob(gomock.Any(), blobURI+"?sig=abc").Return([]byte("kubeadm join failed"), nil)
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			vmMock := mock_virtualmachines.NewMockClient(mockCtrl)
+			saMock := mock_storageaccounts.NewMockClient(mockCtrl)
+			tc.expect(vmMock.EXPECT(), saMock.EXPECT())
+
+			scheme := runtime.NewScheme()
+			g.Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+			c := fake.NewFakeClientWithScheme(scheme)
+
+			clusterScope := &scope.ClusterScope{
+				Cluster: &clusterv1.Cluster{
+					ObjectMeta: metav1.ObjectMeta{Name: "my-cluster"},
+				},
+				AzureCluster: &infrav1.AzureCluster{
+					Spec: infrav1.AzureClusterSpec{ResourceGroup: "my-rg"},
+				},
+			}
+			machineScope := &scope.MachineScope{
+				Logger:       log.Log,
+				ClusterScope: clusterScope,
+				Machine: &clusterv1.Machine{
+					Status: clusterv1.MachineStatus{NodeRef: tc.nodeRef},
+				},
+				AzureMachine: &infrav1.AzureMachine{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:              "my-vm",
+						Namespace:         "default",
+						CreationTimestamp: metav1.NewTime(tc.created),
+					},
+					Spec: infrav1.AzureMachineSpec{
+						BootDiagnostics: &infrav1.BootDiagnostics{},
+					},
+					Status: infrav1.AzureMachineStatus{Ready: true},
+				},
+			}
+			ams := &azureMachineService{
+				machineScope:       machineScope,
+				clusterScope:       clusterScope,
+				virtualMachinesSvc: &virtualmachines.Service{Scope: clusterScope, MachineScope: machineScope, Client: vmMock},
+				storageAccountsSvc: &storageaccounts.Service{Scope: clusterScope, Client: saMock},
+			}
+			r := &AzureMachineReconciler{
+				Client:                c,
+				Log:                   log.Log,
+				Recorder:              record.NewFakeRecorder(10),
+				BootLogCaptureTimeout: DefaultBootLogCaptureTimeout,
+			}
+
+			result, err := r.reconcileBootLog(context.TODO(), machineScope, ams)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(result.RequeueAfter > 0).To(Equal(tc.expectRequeue))
+
+			secret := &corev1.Secret{}
+			err = c.Get(context.TODO(), client.ObjectKey{Namespace: "default", Name: BootLogSecretName("my-vm")}, secret)
+			if tc.expectCaptured {
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(string(secret.Data[BootLogSecretKey])).To(Equal("kubeadm join failed"))
+				g.Expect(secret.OwnerReferences).To(HaveLen(1))
+			} else {
+				g.Expect(err).To(HaveOccurred())
+			}
+		})
+	}
+}
@@ -50,6 +50,9 @@ type AzureMachineReconciler struct {
 	Log              logr.Logger
 	Recorder         record.EventRecorder
 	ReconcileTimeout time.Duration
+	// BootLogCaptureTimeout is the duration after which the serial console log of an AzureMachine with boot
+	// diagnostics enabled is captured if its Machine has not become a Node. Zero disables the capture.
+	BootLogCaptureTimeout time.Duration
 }
 
 func (r *AzureMachineReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
@@ -105,7 +108,7 @@ func (r *AzureMachineReconciler) SetupWithManager(mgr ctrl.Manager, options cont
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=azuremachines/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machines;machines/status,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=events,verbs=get;list;watch;create;update;patch
-// +kubebuilder:rbac:groups="",resources=secrets;,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets;,verbs=get;list;watch;create
 
 func (r *AzureMachineReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result, reterr error) {
 	ctx, cancel := context.WithTimeout(context.Background(), reconciler.DefaultedLoopTimeout(r.ReconcileTimeout))
@@ -164,6 +167,7 @@ func (r *AzureMachineReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result, ret
 	// Create the cluster scope
 	clusterScope, err := scope.NewClusterScope(scope.ClusterScopeParams{
 		Client:       r.Client,
+		Context:      ctx,
 		Logger:       logger,
 		Cluster:      cluster,
 		AzureCluster: azureCluster,
@@ -303,6 +307,33 @@ func (r *AzureMachineReconciler) reconcileNormal(ctx context.Context, machineSco
 		return reconcile.Result{}, errors.Errorf("failed to ensure tags: %+v", err)
 	}
 
+	// Ensure that the system-assigned identity of the VM has its roles, in case assigning them failed after the VM was created.
+	if vm.State == infrav1.VMStateSucceeded && machineScope.AzureMachine.Spec.Identity == infrav1.VMIdentitySystemAssigned {
+		if err := ams.reconcileRoleAssignments(ctx); err != nil {
+			r.Recorder.Eventf(machineScope.AzureMachine, corev1.EventTypeWarning, "FailedRoleAssignment", "Failed to assign roles to the VM system-assigned identity: %v", err)
+			return reconcile.Result{}, errors.Wrap(err, "failed to reconcile role assignments")
+		}
+	}
+
+	// Install the VM extensions once the VM is running.
+	if vm.State == infrav1.VMStateSucceeded && (len(machineScope.AzureMachine.Spec.VMExtensions) > 0 || len(machineScope.AzureMachine.Status.VMExtensions) > 0) {
+		extensions, err := ams.reconcileVMExtensions(ctx)
+		if err != nil {
+			r.Recorder.Eventf(machineScope.AzureMachine, corev1.EventTypeWarning, "FailedReconcileVMExtensions", "Failed to reconcile VM extensions: %v", err)
+			return reconcile.Result{}, errors.Wrap(err, "failed to reconcile VM extensions")
+		}
+		machineScope.SetVMExtensions(extensions)
+		for _, extension := range extensions {
+			if extension.ProvisioningState == string(infrav1.VMStateFailed) {
+				r.Recorder.Eventf(machineScope.AzureMachine, corev1.EventTypeWarning, "FailedVMExtension", "VM extension %s is in failed state", extension.Name)
+			}
+		}
+	}
+
+	if machineScope.AzureMachine.Spec.BootDiagnostics != nil {
+		return r.reconcileBootLog(ctx, machineScope, ams)
+	}
+
 	return reconcile.Result{}, nil
 }
 
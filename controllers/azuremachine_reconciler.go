@@ -27,10 +27,13 @@ import (
 	"k8s.io/klog"
 	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
 	azure "sigs.k8s.io/cluster-api-provider-azure/cloud"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/converters"
 	"sigs.k8s.io/cluster-api-provider-azure/cloud/scope"
 	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/availabilityzones"
 	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/disks"
 	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/networkinterfaces"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/storageaccounts"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/virtualmachineextensions"
 	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/virtualmachines"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/util"
@@ -50,6 +53,8 @@ type azureMachineService struct {
 	virtualMachinesSvc   *virtualmachines.Service
 	disksSvc             azure.OldService
 	publicIPsSvc         azure.Service
+	storageAccountsSvc   *storageaccounts.Service
+	vmExtensionsSvc      *virtualmachineextensions.Service
 }
 
 // newAzureMachineService populates all the services based on input scope
@@ -62,6 +67,8 @@ func newAzureMachineService(machineScope *scope.MachineScope, clusterScope *scop
 		virtualMachinesSvc:   virtualmachines.NewService(clusterScope, machineScope),
 		disksSvc:             disks.NewService(clusterScope),
 		publicIPsSvc:         publicips.NewService(machineScope),
+		storageAccountsSvc:   storageaccounts.NewService(clusterScope),
+		vmExtensionsSvc:      virtualmachineextensions.NewService(clusterScope),
 	}
 }
 
@@ -89,7 +96,10 @@ func (s *azureMachineService) Reconcile(ctx context.Context) (*infrav1.VM, error
 // Delete deletes all the services in pre determined order
 func (s *azureMachineService) Delete(ctx context.Context) error {
 	vmSpec := &virtualmachines.Spec{
-		Name: s.machineScope.Name(),
+		Name:                        s.machineScope.Name(),
+		Identity:                    s.machineScope.AzureMachine.Spec.Identity,
+		SystemAssignedIdentityRoles: s.machineScope.AzureMachine.Spec.SystemAssignedIdentityRoles,
+		RoleAssignmentIDs:           s.machineScope.AzureMachine.Status.RoleAssignmentIDs,
 	}
 
 	err := s.virtualMachinesSvc.Delete(ctx, vmSpec)
@@ -273,18 +283,25 @@ func (s *azureMachineService) reconcileVirtualMachine(ctx context.Context, nicNa
 		return nil, errors.Wrap(err, "failed to retrieve bootstrap data")
 	}
 
+	bootDiagnosticsStorageURI, err := s.reconcileBootDiagnosticsStorage(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to reconcile boot diagnostics storage account")
+	}
+
 	vmSpec := &virtualmachines.Spec{
-		Name:                   s.machineScope.Name(),
-		NICName:                nicName,
-		SSHKeyData:             string(decoded),
-		Size:                   s.machineScope.AzureMachine.Spec.VMSize,
-		OSDisk:                 s.machineScope.AzureMachine.Spec.OSDisk,
-		Image:                  image,
-		CustomData:             bootstrapData,
-		Zone:                   vmZone,
-		Identity:               s.machineScope.AzureMachine.Spec.Identity,
-		UserAssignedIdentities: s.machineScope.AzureMachine.Spec.UserAssignedIdentities,
-		SpotVMOptions:          s.machineScope.AzureMachine.Spec.SpotVMOptions,
+		Name:                        s.machineScope.Name(),
+		NICName:                     nicName,
+		SSHKeyData:                  string(decoded),
+		Size:                        s.machineScope.AzureMachine.Spec.VMSize,
+		OSDisk:                      s.machineScope.AzureMachine.Spec.OSDisk,
+		Image:                       image,
+		CustomData:                  bootstrapData,
+		Zone:                        vmZone,
+		Identity:                    s.machineScope.AzureMachine.Spec.Identity,
+		UserAssignedIdentities:      s.machineScope.AzureMachine.Spec.UserAssignedIdentities,
+		SystemAssignedIdentityRoles: s.machineScope.AzureMachine.Spec.SystemAssignedIdentityRoles,
+		SpotVMOptions:               s.machineScope.AzureMachine.Spec.SpotVMOptions,
+		BootDiagnosticsStorageURI:   bootDiagnosticsStorageURI,
 	}
 
 	err = s.virtualMachinesSvc.Reconcile(ctx, vmSpec)
@@ -311,6 +328,109 @@ func (s *azureMachineService) reconcileVirtualMachine(ctx context.Context, nicNa
 	return newVM, nil
 }
 
+// reconcileRoleAssignments assigns the configured roles to the system-assigned identity of the VM, deletes the
+// assignments of roles that were removed and records the assignments in the AzureMachine status.
+func (s *azureMachineService) reconcileRoleAssignments(ctx context.Context) error {
+	vmSpec := &virtualmachines.Spec{
+		Name:                        s.machineScope.Name(),
+		Identity:                    s.machineScope.AzureMachine.Spec.Identity,
+		SystemAssignedIdentityRoles: s.machineScope.AzureMachine.Spec.SystemAssignedIdentityRoles,
+		RoleAssignmentIDs:           s.machineScope.AzureMachine.Status.RoleAssignmentIDs,
+	}
+	ids, err := s.virtualMachinesSvc.ReconcileRoleAssignments(ctx, vmSpec)
+	if err != nil {
+		return err
+	}
+	s.machineScope.SetRoleAssignmentIDs(ids)
+	return nil
+}
+
+// reconcileBootDiagnosticsStorage returns the blob endpoint of the storage account used for boot diagnostics,
+// creating the provider managed storage account if the AzureMachine does not specify a storage account.
+func (s *azureMachineService) reconcileBootDiagnosticsStorage(ctx context.Context) (string, error) {
+	bootDiagnostics := s.machineScope.AzureMachine.Spec.BootDiagnostics
+	if bootDiagnostics == nil {
+		return "", nil
+	}
+	if bootDiagnostics.StorageAccountURI != "" {
+		return bootDiagnostics.StorageAccountURI, nil
+	}
+
+	accountSpec := &storageaccounts.Spec{
+		Name: azure.GenerateBootDiagnosticsStorageAccountName(s.clusterScope.SubscriptionID(), s.clusterScope.ResourceGroup(), s.clusterScope.ClusterName()),
+	}
+	if err := s.storageAccountsSvc.Reconcile(ctx, accountSpec); err != nil {
+		return "", err
+	}
+	return s.storageAccountsSvc.GetBlobEndpoint(ctx, accountSpec)
+}
+
+// reconcileVMExtensions installs the VM extensions declared on the AzureMachine, removes the ones that were
+// installed previously but are no longer declared, and returns the observed state of the declared extensions.
+func (s *azureMachineService) reconcileVMExtensions(ctx context.Context) ([]infrav1.VMExtensionStatus, error) {
+	declared := make(map[string]struct{})
+	var statuses []infrav1.VMExtensionStatus
+	for _, extension := range s.machineScope.AzureMachine.Spec.VMExtensions {
+		declared[extension.Name] = struct{}{}
+
+		protectedSettings, err := s.machineScope.GetVMExtensionProtectedSettings(ctx, extension)
+		if err != nil {
+			return nil, err
+		}
+
+		settings, err := converters.ExtensionSettingsToMap(extension.Settings)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid settings of VM extension %s", extension.Name)
+		}
+
+		extSpec := &virtualmachineextensions.Spec{
+			Name:              extension.Name,
+			VMName:            s.machineScope.Name(),
+			Publisher:         extension.Publisher,
+			Type:              extension.Type,
+			Version:           extension.Version,
+			Settings:          settings,
+			ProtectedSettings: protectedSettings,
+		}
+		if err := s.vmExtensionsSvc.Reconcile(ctx, extSpec); err != nil {
+			return nil, errors.Wrapf(err, "failed to reconcile VM extension %s", extension.Name)
+		}
+
+		status, err := s.vmExtensionsSvc.Get(ctx, extSpec)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get VM extension %s", extension.Name)
+		}
+		statuses = append(statuses, *status)
+	}
+
+	for _, status := range s.machineScope.AzureMachine.Status.VMExtensions {
+		if _, ok := declared[status.Name]; ok {
+			continue
+		}
+		extSpec := &virtualmachineextensions.Spec{
+			Name:   status.Name,
+			VMName: s.machineScope.Name(),
+		}
+		if err := s.vmExtensionsSvc.Delete(ctx, extSpec); err != nil {
+			return nil, errors.Wrapf(err, "failed to delete VM extension %s", status.Name)
+		}
+	}
+
+	return statuses, nil
+}
+
+// GetSerialConsoleLog downloads the boot diagnostics serial console log of the VM.
+func (s *azureMachineService) GetSerialConsoleLog(ctx context.Context) ([]byte, error) {
+	vmSpec := &virtualmachines.Spec{
+		Name: s.machineScope.Name(),
+	}
+	blobURI, err := s.virtualMachinesSvc.GetSerialConsoleLogURI(ctx, vmSpec)
+	if err != nil {
+		return nil, err
+	}
+	return s.storageAccountsSvc.GetBlob(ctx, blobURI)
+}
+
 // GetControlPlaneMachines retrieves all non-deleted control plane nodes from a MachineList
 func GetControlPlaneMachines(machineList *clusterv1.MachineList) []*clusterv1.Machine {
 	var cpm []*clusterv1.Machine
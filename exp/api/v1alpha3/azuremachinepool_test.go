@@ -18,8 +18,12 @@ package v1alpha3_test
 
 import (
 	"testing"
+	"time"
 
+	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
 	exp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1alpha3"
@@ -79,6 +83,375 @@ func TestAzureMachinePool_Validate(t *testing.T) {
 				g.Expect(actual.Error()).To(gomega.ContainSubstring("You must supply a ID, Marketplace or SharedGallery image details"))
 			},
 		},
+		{
+			Name: "HasValidBootDiagnostics",
+			Factory: func(_ *gomega.GomegaWithT) *exp.AzureMachinePool {
+				return &exp.AzureMachinePool{
+					Spec: exp.AzureMachinePoolSpec{
+						Template: exp.AzureMachineTemplate{
+							BootDiagnostics: &infrav1.BootDiagnostics{
+								StorageAccountURI: "https://mystorageaccount.blob.core.windows.net/",
+							},
+						},
+					},
+				}
+			},
+			Expect: func(g *gomega.GomegaWithT, actual error) {
+				g.Expect(actual).ToNot(gomega.HaveOccurred())
+			},
+		},
+		{
+			Name: "HasInvalidBootDiagnostics",
+			Factory: func(_ *gomega.GomegaWithT) *exp.AzureMachinePool {
+				return &exp.AzureMachinePool{
+					Spec: exp.AzureMachinePoolSpec{
+						Template: exp.AzureMachineTemplate{
+							BootDiagnostics: &infrav1.BootDiagnostics{
+								StorageAccountURI: "mystorageaccount",
+							},
+						},
+					},
+				}
+			},
+			Expect: func(g *gomega.GomegaWithT, actual error) {
+				g.Expect(actual).To(gomega.HaveOccurred())
+				g.Expect(actual.Error()).To(gomega.ContainSubstring("the storage account URI must be a valid https URL"))
+			},
+		},
+		{
+			Name: "HasValidUserAssignedIdentity",
+			Factory: func(_ *gomega.GomegaWithT) *exp.AzureMachinePool {
+				return &exp.AzureMachinePool{
+					Spec: exp.AzureMachinePoolSpec{
+						Template: exp.AzureMachineTemplate{
+							Identity: infrav1.VMIdentityUserAssigned,
+							UserAssignedIdentities: []infrav1.UserAssignedIdentity{
+								{
+									ProviderID: "azure:///subscriptions/123/resourcegroups/my-rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/my-identity",
+								},
+							},
+						},
+					},
+				}
+			},
+			Expect: func(g *gomega.GomegaWithT, actual error) {
+				g.Expect(actual).ToNot(gomega.HaveOccurred())
+			},
+		},
+		{
+			Name: "HasUserAssignedIdentityTypeWithoutIdentities",
+			Factory: func(_ *gomega.GomegaWithT) *exp.AzureMachinePool {
+				return &exp.AzureMachinePool{
+					Spec: exp.AzureMachinePoolSpec{
+						Template: exp.AzureMachineTemplate{
+							Identity: infrav1.VMIdentityUserAssigned,
+						},
+					},
+				}
+			},
+			Expect: func(g *gomega.GomegaWithT, actual error) {
+				g.Expect(actual).To(gomega.HaveOccurred())
+				g.Expect(actual.Error()).To(gomega.ContainSubstring("userAssignedIdentities"))
+			},
+		},
+		{
+			Name: "HasValidSystemAssignedIdentityRoles",
+			Factory: func(_ *gomega.GomegaWithT) *exp.AzureMachinePool {
+				return &exp.AzureMachinePool{
+					Spec: exp.AzureMachinePoolSpec{
+						Template: exp.AzureMachineTemplate{
+							Identity: infrav1.VMIdentitySystemAssigned,
+							SystemAssignedIdentityRoles: []infrav1.SystemAssignedIdentityRole{
+								{
+									DefinitionID: "acdd72a7-3385-48ef-bd42-f606fba81ae7",
+								},
+							},
+						},
+					},
+				}
+			},
+			Expect: func(g *gomega.GomegaWithT, actual error) {
+				g.Expect(actual).ToNot(gomega.HaveOccurred())
+			},
+		},
+		{
+			Name: "HasSystemAssignedIdentityRolesWithoutSystemAssignedIdentity",
+			Factory: func(_ *gomega.GomegaWithT) *exp.AzureMachinePool {
+				return &exp.AzureMachinePool{
+					Spec: exp.AzureMachinePoolSpec{
+						Template: exp.AzureMachineTemplate{
+							SystemAssignedIdentityRoles: []infrav1.SystemAssignedIdentityRole{
+								{
+									DefinitionID: "acdd72a7-3385-48ef-bd42-f606fba81ae7",
+								},
+							},
+						},
+					},
+				}
+			},
+			Expect: func(g *gomega.GomegaWithT, actual error) {
+				g.Expect(actual).To(gomega.HaveOccurred())
+				g.Expect(actual.Error()).To(gomega.ContainSubstring("systemAssignedIdentityRoles"))
+			},
+		},
+		{
+			Name: "HasValidRollingUpdateStrategy",
+			Factory: func(_ *gomega.GomegaWithT) *exp.AzureMachinePool {
+				maxSurge := intstr.FromString("25%")
+				maxUnavailable := intstr.FromInt(0)
+				return &exp.AzureMachinePool{
+					Spec: exp.AzureMachinePoolSpec{
+						Strategy: exp.AzureMachinePoolDeploymentStrategy{
+							Type: exp.RollingUpdateAzureMachinePoolDeploymentStrategyType,
+							RollingUpdate: &exp.MachineRollingUpdateDeployment{
+								MaxSurge:       &maxSurge,
+								MaxUnavailable: &maxUnavailable,
+							},
+						},
+					},
+				}
+			},
+			Expect: func(g *gomega.GomegaWithT, actual error) {
+				g.Expect(actual).ToNot(gomega.HaveOccurred())
+			},
+		},
+		{
+			Name: "HasRollingUpdateStrategyWithZeroSurgeAndUnavailable",
+			Factory: func(_ *gomega.GomegaWithT) *exp.AzureMachinePool {
+				maxSurge := intstr.FromString("0%")
+				maxUnavailable := intstr.FromInt(0)
+				return &exp.AzureMachinePool{
+					Spec: exp.AzureMachinePoolSpec{
+						Strategy: exp.AzureMachinePoolDeploymentStrategy{
+							Type: exp.RollingUpdateAzureMachinePoolDeploymentStrategyType,
+							RollingUpdate: &exp.MachineRollingUpdateDeployment{
+								MaxSurge:       &maxSurge,
+								MaxUnavailable: &maxUnavailable,
+							},
+						},
+					},
+				}
+			},
+			Expect: func(g *gomega.GomegaWithT, actual error) {
+				g.Expect(actual).To(gomega.HaveOccurred())
+				g.Expect(actual.Error()).To(gomega.ContainSubstring("maxSurge and maxUnavailable may not both be 0"))
+			},
+		},
+		{
+			Name: "HasRollingUpdateStrategyWithInvalidMaxSurge",
+			Factory: func(_ *gomega.GomegaWithT) *exp.AzureMachinePool {
+				maxSurge := intstr.FromString("one")
+				return &exp.AzureMachinePool{
+					Spec: exp.AzureMachinePoolSpec{
+						Strategy: exp.AzureMachinePoolDeploymentStrategy{
+							Type: exp.RollingUpdateAzureMachinePoolDeploymentStrategyType,
+							RollingUpdate: &exp.MachineRollingUpdateDeployment{
+								MaxSurge: &maxSurge,
+							},
+						},
+					},
+				}
+			},
+			Expect: func(g *gomega.GomegaWithT, actual error) {
+				g.Expect(actual).To(gomega.HaveOccurred())
+				g.Expect(actual.Error()).To(gomega.ContainSubstring("must be an integer or a percentage"))
+			},
+		},
+		{
+			Name: "HasManualStrategyWithRollingUpdate",
+			Factory: func(_ *gomega.GomegaWithT) *exp.AzureMachinePool {
+				return &exp.AzureMachinePool{
+					Spec: exp.AzureMachinePoolSpec{
+						Strategy: exp.AzureMachinePoolDeploymentStrategy{
+							Type:          exp.ManualAzureMachinePoolDeploymentStrategyType,
+							RollingUpdate: &exp.MachineRollingUpdateDeployment{},
+						},
+					},
+				}
+			},
+			Expect: func(g *gomega.GomegaWithT, actual error) {
+				g.Expect(actual).To(gomega.HaveOccurred())
+				g.Expect(actual.Error()).To(gomega.ContainSubstring("rollingUpdate may only be set with the RollingUpdate strategy"))
+			},
+		},
+		{
+			Name: "HasValidZones",
+			Factory: func(_ *gomega.GomegaWithT) *exp.AzureMachinePool {
+				return &exp.AzureMachinePool{
+					Spec: exp.AzureMachinePoolSpec{
+						Zones:       []string{"1", "2", "3"},
+						ZoneBalance: to.BoolPtr(true),
+					},
+				}
+			},
+			Expect: func(g *gomega.GomegaWithT, actual error) {
+				g.Expect(actual).ToNot(gomega.HaveOccurred())
+			},
+		},
+		{
+			Name: "HasDuplicateZones",
+			Factory: func(_ *gomega.GomegaWithT) *exp.AzureMachinePool {
+				return &exp.AzureMachinePool{
+					Spec: exp.AzureMachinePoolSpec{
+						Zones: []string{"1", "1"},
+					},
+				}
+			},
+			Expect: func(g *gomega.GomegaWithT, actual error) {
+				g.Expect(actual).To(gomega.HaveOccurred())
+				g.Expect(actual.Error()).To(gomega.ContainSubstring("Duplicate value"))
+			},
+		},
+		{
+			Name: "HasZoneBalanceWithoutZones",
+			Factory: func(_ *gomega.GomegaWithT) *exp.AzureMachinePool {
+				return &exp.AzureMachinePool{
+					Spec: exp.AzureMachinePoolSpec{
+						ZoneBalance: to.BoolPtr(true),
+					},
+				}
+			},
+			Expect: func(g *gomega.GomegaWithT, actual error) {
+				g.Expect(actual).To(gomega.HaveOccurred())
+				g.Expect(actual.Error()).To(gomega.ContainSubstring("zoneBalance may only be enabled when zones are set"))
+			},
+		},
+		{
+			Name: "HasValidHealth",
+			Factory: func(_ *gomega.GomegaWithT) *exp.AzureMachinePool {
+				return &exp.AzureMachinePool{
+					Spec: exp.AzureMachinePoolSpec{
+						Health: &exp.AzureMachinePoolHealth{
+							RequestPath:      "/healthz",
+							AutomaticRepairs: true,
+							GracePeriod:      &metav1.Duration{Duration: time.Hour},
+						},
+					},
+				}
+			},
+			Expect: func(g *gomega.GomegaWithT, actual error) {
+				g.Expect(actual).ToNot(gomega.HaveOccurred())
+			},
+		},
+		{
+			Name: "HasInvalidHealth",
+			Factory: func(_ *gomega.GomegaWithT) *exp.AzureMachinePool {
+				return &exp.AzureMachinePool{
+					Spec: exp.AzureMachinePoolSpec{
+						Health: &exp.AzureMachinePoolHealth{
+							RequestPath: "healthz",
+							GracePeriod: &metav1.Duration{Duration: 10 * time.Minute},
+						},
+					},
+				}
+			},
+			Expect: func(g *gomega.GomegaWithT, actual error) {
+				g.Expect(actual).To(gomega.HaveOccurred())
+				g.Expect(actual.Error()).To(gomega.ContainSubstring("must start with /"))
+				g.Expect(actual.Error()).To(gomega.ContainSubstring("must be between 30m0s and 1h30m0s"))
+			},
+		},
+		{
+			Name: "HasValidTerminateNotificationTimeout",
+			Factory: func(_ *gomega.GomegaWithT) *exp.AzureMachinePool {
+				return &exp.AzureMachinePool{
+					Spec: exp.AzureMachinePoolSpec{
+						TerminateNotificationTimeout: &metav1.Duration{Duration: 10 * time.Minute},
+					},
+				}
+			},
+			Expect: func(g *gomega.GomegaWithT, actual error) {
+				g.Expect(actual).ToNot(gomega.HaveOccurred())
+			},
+		},
+		{
+			Name: "HasTooLongTerminateNotificationTimeout",
+			Factory: func(_ *gomega.GomegaWithT) *exp.AzureMachinePool {
+				return &exp.AzureMachinePool{
+					Spec: exp.AzureMachinePoolSpec{
+						TerminateNotificationTimeout: &metav1.Duration{Duration: 20 * time.Minute},
+					},
+				}
+			},
+			Expect: func(g *gomega.GomegaWithT, actual error) {
+				g.Expect(actual).To(gomega.HaveOccurred())
+				g.Expect(actual.Error()).To(gomega.ContainSubstring("must be between 5m0s and 15m0s"))
+			},
+		},
+		{
+			Name: "HasTerminateNotificationTimeoutWithSeconds",
+			Factory: func(_ *gomega.GomegaWithT) *exp.AzureMachinePool {
+				return &exp.AzureMachinePool{
+					Spec: exp.AzureMachinePoolSpec{
+						TerminateNotificationTimeout: &metav1.Duration{Duration: 7*time.Minute + 30*time.Second},
+					},
+				}
+			},
+			Expect: func(g *gomega.GomegaWithT, actual error) {
+				g.Expect(actual).To(gomega.HaveOccurred())
+				g.Expect(actual.Error()).To(gomega.ContainSubstring("must be a whole number of minutes"))
+			},
+		},
+		{
+			Name: "HasGracePeriodWithSeconds",
+			Factory: func(_ *gomega.GomegaWithT) *exp.AzureMachinePool {
+				return &exp.AzureMachinePool{
+					Spec: exp.AzureMachinePoolSpec{
+						Health: &exp.AzureMachinePoolHealth{
+							GracePeriod: &metav1.Duration{Duration: 45*time.Minute + 30*time.Second},
+						},
+					},
+				}
+			},
+			Expect: func(g *gomega.GomegaWithT, actual error) {
+				g.Expect(actual).To(gomega.HaveOccurred())
+				g.Expect(actual.Error()).To(gomega.ContainSubstring("must be a whole number of minutes"))
+			},
+		},
+		{
+			Name: "HasFlexibleOrchestrationMode",
+			Factory: func(_ *gomega.GomegaWithT) *exp.AzureMachinePool {
+				return &exp.AzureMachinePool{
+					Spec: exp.AzureMachinePoolSpec{
+						OrchestrationMode: exp.FlexibleOrchestrationMode,
+						Zones:             []string{"1", "2"},
+					},
+				}
+			},
+			Expect: func(g *gomega.GomegaWithT, actual error) {
+				g.Expect(actual).ToNot(gomega.HaveOccurred())
+			},
+		},
+		{
+			Name: "HasHealthWithFlexibleOrchestrationMode",
+			Factory: func(_ *gomega.GomegaWithT) *exp.AzureMachinePool {
+				return &exp.AzureMachinePool{
+					Spec: exp.AzureMachinePoolSpec{
+						OrchestrationMode: exp.FlexibleOrchestrationMode,
+						Health:            &exp.AzureMachinePoolHealth{},
+					},
+				}
+			},
+			Expect: func(g *gomega.GomegaWithT, actual error) {
+				g.Expect(actual).To(gomega.HaveOccurred())
+				g.Expect(actual.Error()).To(gomega.ContainSubstring("health monitoring is not supported with the Flexible orchestration mode"))
+			},
+		},
+		{
+			Name: "HasSystemAssignedIdentityWithFlexibleOrchestrationMode",
+			Factory: func(_ *gomega.GomegaWithT) *exp.AzureMachinePool {
+				return &exp.AzureMachinePool{
+					Spec: exp.AzureMachinePoolSpec{
+						OrchestrationMode: exp.FlexibleOrchestrationMode,
+						Template:          exp.AzureMachineTemplate{Identity: infrav1.VMIdentitySystemAssigned},
+					},
+				}
+			},
+			Expect: func(g *gomega.GomegaWithT, actual error) {
+				g.Expect(actual).To(gomega.HaveOccurred())
+				g.Expect(actual.Error()).To(gomega.ContainSubstring("not supported with the Flexible orchestration mode"))
+			},
+		},
 	}
 
 	for _, c := range cases {
@@ -92,3 +465,97 @@ func TestAzureMachinePool_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestAzureMachinePool_ValidateUpdate(t *testing.T) {
+	cases := []struct {
+		Name    string
+		Old     exp.AzureMachinePoolSpec
+		New     exp.AzureMachinePoolSpec
+		WantErr string
+	}{
+		{
+			Name: "CanChangeScaleInPolicy",
+			Old:  exp.AzureMachinePoolSpec{Zones: []string{"1", "2"}},
+			New:  exp.AzureMachinePoolSpec{Zones: []string{"1", "2"}, ScaleInPolicy: exp.OldestVMScaleInPolicy},
+		},
+		{
+			Name:    "CannotChangeZones",
+			Old:     exp.AzureMachinePoolSpec{Zones: []string{"1", "2"}},
+			New:     exp.AzureMachinePoolSpec{Zones: []string{"1", "2", "3"}},
+			WantErr: "zones cannot be changed",
+		},
+		{
+			Name: "CannotAddSpotVMOptions",
+			Old:  exp.AzureMachinePoolSpec{},
+			New: exp.AzureMachinePoolSpec{
+				Template: exp.AzureMachineTemplate{SpotVMOptions: &infrav1.SpotVMOptions{}},
+			},
+			WantErr: "spotVMOptions cannot be added or removed",
+		},
+		{
+			Name:    "CannotChangeOrchestrationMode",
+			Old:     exp.AzureMachinePoolSpec{},
+			New:     exp.AzureMachinePoolSpec{OrchestrationMode: exp.FlexibleOrchestrationMode},
+			WantErr: "orchestrationMode cannot be changed",
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			t.Parallel()
+			g := gomega.NewGomegaWithT(t)
+			old := &exp.AzureMachinePool{Spec: c.Old}
+			amp := &exp.AzureMachinePool{Spec: c.New}
+			err := amp.ValidateImmutableFields(old)
+			if c.WantErr == "" {
+				g.Expect(err).ToNot(gomega.HaveOccurred())
+			} else {
+				g.Expect(err).To(gomega.HaveOccurred())
+				g.Expect(err.Error()).To(gomega.ContainSubstring(c.WantErr))
+			}
+		})
+	}
+}
+
+func TestAzureMachinePool_Default(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	amp := new(exp.AzureMachinePool)
+	amp.Default()
+	g.Expect(amp.Spec.Strategy.Type).To(gomega.Equal(exp.ManualAzureMachinePoolDeploymentStrategyType))
+	g.Expect(amp.Spec.Strategy.RollingUpdate).To(gomega.BeNil())
+	g.Expect(amp.Spec.OrchestrationMode).To(gomega.Equal(exp.UniformOrchestrationMode))
+
+	amp = &exp.AzureMachinePool{
+		Spec: exp.AzureMachinePoolSpec{
+			Strategy: exp.AzureMachinePoolDeploymentStrategy{
+				Type: exp.RollingUpdateAzureMachinePoolDeploymentStrategyType,
+			},
+		},
+	}
+	amp.Default()
+	g.Expect(amp.Spec.Strategy.RollingUpdate.MaxSurge).To(gomega.Equal(intstr.ValueOrDefault(nil, intstr.FromInt(1))))
+	g.Expect(amp.Spec.Strategy.RollingUpdate.MaxUnavailable).To(gomega.Equal(intstr.ValueOrDefault(nil, intstr.FromInt(0))))
+
+	amp = &exp.AzureMachinePool{
+		Spec: exp.AzureMachinePoolSpec{
+			Strategy: exp.AzureMachinePoolDeploymentStrategy{
+				Type: exp.ManualAzureMachinePoolDeploymentStrategyType,
+			},
+		},
+	}
+	amp.Default()
+	g.Expect(amp.Spec.Strategy.RollingUpdate).To(gomega.BeNil())
+	g.Expect(amp.Spec.Health).To(gomega.BeNil())
+
+	amp = &exp.AzureMachinePool{
+		Spec: exp.AzureMachinePoolSpec{
+			Health: &exp.AzureMachinePoolHealth{},
+		},
+	}
+	amp.Default()
+	g.Expect(*amp.Spec.Health.Port).To(gomega.Equal(int32(10248)))
+	g.Expect(amp.Spec.Health.RequestPath).To(gomega.Equal("/healthz"))
+	g.Expect(amp.Spec.Health.GracePeriod.Duration).To(gomega.Equal(30 * time.Minute))
+}
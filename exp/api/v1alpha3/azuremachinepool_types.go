@@ -17,12 +17,76 @@ limitations under the License.
 package v1alpha3
 
 import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"sigs.k8s.io/cluster-api/errors"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
 )
 
+const (
+	// RollingUpdateAzureMachinePoolDeploymentStrategyType replaces the outdated instances of an AzureMachinePool
+	// in batches, honoring MaxSurge and MaxUnavailable.
+	RollingUpdateAzureMachinePoolDeploymentStrategyType AzureMachinePoolDeploymentStrategyType = "RollingUpdate"
+
+	// ManualAzureMachinePoolDeploymentStrategyType only updates the model of the Virtual Machine Scale Set,
+	// leaving the replacement of existing instances to the user.
+	ManualAzureMachinePoolDeploymentStrategyType AzureMachinePoolDeploymentStrategyType = "Manual"
+
+	// DefaultScaleInPolicy balances the Virtual Machine Scale Set across zones and fault domains, then removes
+	// the newest instances.
+	DefaultScaleInPolicy ScaleInPolicy = "Default"
+
+	// NewestVMScaleInPolicy removes the newest instances, after balancing the Virtual Machine Scale Set across zones.
+	NewestVMScaleInPolicy ScaleInPolicy = "NewestVM"
+
+	// OldestVMScaleInPolicy removes the oldest instances, after balancing the Virtual Machine Scale Set across zones.
+	OldestVMScaleInPolicy ScaleInPolicy = "OldestVM"
+
+	// DefaultHealthProbePort is the healthz port of the kubelet.
+	DefaultHealthProbePort int32 = 10248
+
+	// DefaultHealthProbeRequestPath is the path of the healthz endpoint of the kubelet.
+	DefaultHealthProbeRequestPath = "/healthz"
+
+	// UnhealthyInstanceHealthState is the health state of an instance which fails the health probe of the
+	// Application Health extension.
+	UnhealthyInstanceHealthState = "Unhealthy"
+
+	// UniformOrchestrationMode lets Azure create the instances of the Virtual Machine Scale Set from its model.
+	UniformOrchestrationMode OrchestrationMode = "Uniform"
+
+	// FlexibleOrchestrationMode creates every instance of the Virtual Machine Scale Set as a virtual machine with its
+	// own network interface, added to the Virtual Machine Scale Set.
+	FlexibleOrchestrationMode OrchestrationMode = "Flexible"
+
+	// ModelTagKey is the tag holding the hash of the virtual machine model of an AzureMachinePool in the Flexible
+	// orchestration mode. It is set on the Virtual Machine Scale Set and on every instance created from the model.
+	ModelTagKey = infrav1.NameAzureProviderPrefix + "model"
+
+	// DeleteInstanceAnnotation marks the Node of an AzureMachinePool instance for deletion. The Node is drained and
+	// the instance is deleted, reducing the number of instances if the replicas of the MachinePool were reduced,
+	// or replacing the instance otherwise.
+	DeleteInstanceAnnotation = "exp.infrastructure.cluster.x-k8s.io/delete-instance"
+)
+
+const (
+	// MinAutomaticRepairsGracePeriod is the minimum grace period of automatic repairs allowed by Azure.
+	MinAutomaticRepairsGracePeriod = 30 * time.Minute
+
+	// MaxAutomaticRepairsGracePeriod is the maximum grace period of automatic repairs allowed by Azure.
+	MaxAutomaticRepairsGracePeriod = 90 * time.Minute
+
+	// MinTerminateNotificationTimeout is the minimum terminate notification timeout allowed by Azure.
+	MinTerminateNotificationTimeout = 5 * time.Minute
+
+	// MaxTerminateNotificationTimeout is the maximum terminate notification timeout allowed by Azure.
+	MaxTerminateNotificationTimeout = 15 * time.Minute
+)
+
 type (
 	AzureMachineTemplate struct {
 		// VMSize is the size of the Virtual Machine to build.
@@ -47,6 +111,163 @@ type (
 		// If AcceleratedNetworking is set to true with a VMSize that does not support it, Azure will return an error.
 		// +optional
 		AcceleratedNetworking *bool `json:"acceleratedNetworking,omitempty"`
+
+		// BootDiagnostics enables boot diagnostics for the Virtual Machine Scale Set instances.
+		// +optional
+		BootDiagnostics *infrav1.BootDiagnostics `json:"bootDiagnostics,omitempty"`
+
+		// VMExtensions is a list of virtual machine extensions to install on every instance of the
+		// Virtual Machine Scale Set.
+		// +optional
+		VMExtensions []infrav1.VMExtension `json:"vmExtensions,omitempty"`
+
+		// Identity is the type of identity used for the Virtual Machine Scale Set.
+		// The type 'SystemAssigned' is an implicitly created identity.
+		// The type 'UserAssigned' is a standalone Azure resource provided by the user
+		// and assigned to the Virtual Machine Scale Set.
+		// +kubebuilder:default=None
+		// +optional
+		Identity infrav1.VMIdentity `json:"identity,omitempty"`
+
+		// UserAssignedIdentities is a list of standalone Azure identities provided by the user
+		// The lifecycle of a user-assigned identity is managed separately from the lifecycle of
+		// the AzureMachinePool.
+		// +optional
+		UserAssignedIdentities []infrav1.UserAssignedIdentity `json:"userAssignedIdentities,omitempty"`
+
+		// SystemAssignedIdentityRoles is a list of roles assigned to the system-assigned identity of the
+		// Virtual Machine Scale Set. If omitted, the identity is assigned the Contributor role on the resource group
+		// of the cluster.
+		// Only valid with the 'SystemAssigned' identity type.
+		// +optional
+		SystemAssignedIdentityRoles []infrav1.SystemAssignedIdentityRole `json:"systemAssignedIdentityRoles,omitempty"`
+
+		// SpotVMOptions allows the ability to specify the instances of the Virtual Machine Scale Set should be Spot VMs.
+		// Spot instances are deleted when Azure needs the capacity back or the price exceeds the maximum price.
+		// +optional
+		SpotVMOptions *infrav1.SpotVMOptions `json:"spotVMOptions,omitempty"`
+	}
+
+	// AzureMachinePoolHealth configures the health monitoring of the instances of an AzureMachinePool.
+	AzureMachinePoolHealth struct {
+		// Port is the port of the health endpoint probed on every instance by the Application Health extension.
+		// Defaults to 10248, the healthz port of the kubelet.
+		// +kubebuilder:validation:Minimum=1
+		// +kubebuilder:validation:Maximum=65535
+		// +optional
+		Port *int32 `json:"port,omitempty"`
+
+		// RequestPath is the path of the health endpoint probed on every instance. Defaults to /healthz.
+		// +optional
+		RequestPath string `json:"requestPath,omitempty"`
+
+		// AutomaticRepairs enables the automatic repair of unhealthy instances, which Azure replaces with new
+		// instances once the grace period has passed.
+		// +optional
+		AutomaticRepairs bool `json:"automaticRepairs,omitempty"`
+
+		// GracePeriod is the time for which automatic repairs are suspended after an instance is created or its
+		// state changes, so that the instance can become healthy. Must be a whole number of minutes between 30
+		// and 90 minutes.
+		// Defaults to 30 minutes.
+		// +optional
+		GracePeriod *metav1.Duration `json:"gracePeriod,omitempty"`
+	}
+
+	// ScaleInPolicy decides which instances of a Virtual Machine Scale Set are removed when it is scaled in.
+	// +kubebuilder:validation:Enum=Default;NewestVM;OldestVM
+	ScaleInPolicy string
+
+	// OrchestrationMode is the orchestration mode of a Virtual Machine Scale Set.
+	// +kubebuilder:validation:Enum=Uniform;Flexible
+	OrchestrationMode string
+
+	// AzureMachinePoolDeploymentStrategyType is the type of strategy used to replace the instances of an
+	// AzureMachinePool that do not run the latest model of the Virtual Machine Scale Set.
+	AzureMachinePoolDeploymentStrategyType string
+
+	// AzureMachinePoolDeploymentStrategy describes how to replace the instances of an AzureMachinePool
+	// that do not run the latest model of the Virtual Machine Scale Set.
+	AzureMachinePoolDeploymentStrategy struct {
+		// Type of deployment. Currently the only supported strategies are
+		// "RollingUpdate" and "Manual".
+		// Default is Manual.
+		// +kubebuilder:validation:Enum=RollingUpdate;Manual
+		// +optional
+		Type AzureMachinePoolDeploymentStrategyType `json:"type,omitempty"`
+
+		// Rolling update config params. Present only if
+		// AzureMachinePoolDeploymentStrategyType = RollingUpdate.
+		// +optional
+		RollingUpdate *MachineRollingUpdateDeployment `json:"rollingUpdate,omitempty"`
+	}
+
+	// MachineRollingUpdateDeployment is used to control the desired behavior of a rolling update.
+	MachineRollingUpdateDeployment struct {
+		// The maximum number of instances that can be unavailable during the update.
+		// Value can be an absolute number (ex: 5) or a percentage of desired
+		// instances (ex: 10%).
+		// Absolute number is calculated from percentage by rounding down.
+		// This can not be 0 if MaxSurge is 0.
+		// Defaults to 0.
+		// An instance is available when it is provisioned and its Node is Ready.
+		// +optional
+		MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+
+		// The maximum number of instances that can be created above the
+		// desired number of instances while outdated instances are replaced.
+		// Value can be an absolute number (ex: 5) or a percentage of
+		// desired instances (ex: 10%).
+		// This can not be 0 if MaxUnavailable is 0.
+		// Absolute number is calculated from percentage by rounding up.
+		// Defaults to 1.
+		// +optional
+		MaxSurge *intstr.IntOrString `json:"maxSurge,omitempty"`
+	}
+
+	// AzureMachinePoolInstanceStatus provides status information for each instance in the Virtual Machine Scale Set.
+	AzureMachinePoolInstanceStatus struct {
+		// ProviderID is the provider identification of the instance.
+		// +optional
+		ProviderID string `json:"providerID,omitempty"`
+
+		// InstanceID is the identification of the instance within the Virtual Machine Scale Set.
+		// +optional
+		InstanceID string `json:"instanceID,omitempty"`
+
+		// InstanceName is the name of the instance.
+		// +optional
+		InstanceName string `json:"instanceName,omitempty"`
+
+		// ProvisioningState is the provisioning state of the instance.
+		// +optional
+		ProvisioningState *infrav1.VMState `json:"provisioningState,omitempty"`
+
+		// LatestModelApplied indicates whether the instance runs the latest model of the Virtual Machine Scale Set.
+		// +optional
+		LatestModelApplied bool `json:"latestModelApplied"`
+
+		// NodeRef is a reference to the Node of the instance.
+		// +optional
+		NodeRef *corev1.ObjectReference `json:"nodeRef,omitempty"`
+
+		// HealthState is the health of the instance reported by the Application Health extension, one of
+		// Healthy, Unhealthy, Initializing or Unknown. Empty when health monitoring is disabled.
+		// +optional
+		HealthState string `json:"healthState,omitempty"`
+
+		// Version is the Kubernetes version of the Node of the instance.
+		// +optional
+		Version string `json:"version,omitempty"`
+
+		// NodeReady is true when the Node of the instance is Ready.
+		// +optional
+		NodeReady bool `json:"nodeReady"`
+
+		// TerminationApproved is true once the Node of the instance was drained while Azure was deleting the
+		// instance, and the Scheduled Event delaying the deletion was approved.
+		// +optional
+		TerminationApproved bool `json:"terminationApproved,omitempty"`
 	}
 
 	// AzureMachinePoolSpec defines the desired state of AzureMachinePool
@@ -70,6 +291,53 @@ type (
 		// This field must match the provider IDs as seen on the node objects corresponding to a machine pool's machine instances.
 		// +optional
 		ProviderIDList []string `json:"providerIDList,omitempty"`
+
+		// The deployment strategy to use to replace existing instances with new ones
+		// running the latest model of the Virtual Machine Scale Set.
+		// +optional
+		Strategy AzureMachinePoolDeploymentStrategy `json:"strategy,omitempty"`
+
+		// Zones is the list of availability zones the instances of the Virtual Machine Scale Set are spread across.
+		// The zones must be supported by the VM size in the location and cannot be changed once the Virtual Machine
+		// Scale Set is created. If omitted, the instances are not zonal.
+		// +optional
+		Zones []string `json:"zones,omitempty"`
+
+		// ZoneBalance forces a strictly even distribution of the instances across zones, even when a zone is
+		// unavailable. Only valid when zones are set.
+		// +optional
+		ZoneBalance *bool `json:"zoneBalance,omitempty"`
+
+		// ScaleInPolicy decides which instances are removed when the Virtual Machine Scale Set is scaled in.
+		// +optional
+		ScaleInPolicy ScaleInPolicy `json:"scaleInPolicy,omitempty"`
+
+		// Health installs the Application Health extension on the instances of the Virtual Machine Scale Set to
+		// monitor their health, and optionally repairs unhealthy instances.
+		// +optional
+		Health *AzureMachinePoolHealth `json:"health,omitempty"`
+
+		// TerminateNotificationTimeout enables terminate notifications on the Virtual Machine Scale Set, which delay
+		// the deletion of an instance by Azure for the timeout so that its Node can be drained first.
+		// Must be a whole number of minutes between 5 and 15 minutes.
+		// +optional
+		TerminateNotificationTimeout *metav1.Duration `json:"terminateNotificationTimeout,omitempty"`
+
+		// OrchestrationMode is the orchestration mode of the Virtual Machine Scale Set. In the Uniform mode, Azure
+		// creates the instances from the model of the Virtual Machine Scale Set. In the Flexible mode, the controller
+		// creates every instance as a virtual machine with its own network interface and adds it to the Virtual
+		// Machine Scale Set. Overprovisioning, zone balance, health monitoring, VM extensions and system-assigned
+		// identities are not supported in the Flexible mode.
+		// Cannot be changed once the Virtual Machine Scale Set is created. Defaults to Uniform.
+		// +kubebuilder:default=Uniform
+		// +optional
+		OrchestrationMode OrchestrationMode `json:"orchestrationMode,omitempty"`
+
+		// Overprovision enables overprovisioning of the Virtual Machine Scale Set, where Azure creates more instances
+		// than requested and deletes the extra instances once the requested instances are provisioned.
+		// Defaults to false, as the extra instances would start joining the cluster before they are deleted.
+		// +optional
+		Overprovision *bool `json:"overprovision,omitempty"`
 	}
 
 	// AzureMachinePoolStatus defines the observed state of AzureMachinePool
@@ -86,6 +354,20 @@ type (
 		// +optional
 		ProvisioningState *infrav1.VMState `json:"provisioningState,omitempty"`
 
+		// VMExtensions is the observed state of the virtual machine extensions of the Virtual Machine Scale Set.
+		// +optional
+		VMExtensions []infrav1.VMExtensionStatus `json:"vmExtensions,omitempty"`
+
+		// Instances is the observed state of the instances of the Virtual Machine Scale Set.
+		// +optional
+		Instances []AzureMachinePoolInstanceStatus `json:"instances,omitempty"`
+
+		// RoleAssignmentIDs are the IDs of the role assignments made for the system-assigned identity of the
+		// Virtual Machine Scale Set. They are kept so the assignments of roles removed from
+		// SystemAssignedIdentityRoles can be deleted.
+		// +optional
+		RoleAssignmentIDs []string `json:"roleAssignmentIDs,omitempty"`
+
 		// ErrorReason will be set in the event that there is a terminal problem
 		// reconciling the MachinePool and will contain a succinct value suitable
 		// for machine interpretation.
@@ -158,3 +440,9 @@ type (
 func init() {
 	SchemeBuilder.Register(&AzureMachinePool{}, &AzureMachinePoolList{})
 }
+
+// IsFlexible returns true if the Virtual Machine Scale Set uses the Flexible orchestration mode. Pools created before
+// the orchestration mode existed use the Uniform mode.
+func (s AzureMachinePoolSpec) IsFlexible() bool {
+	return s.OrchestrationMode == FlexibleOrchestrationMode
+}
@@ -17,8 +17,15 @@ limitations under the License.
 package v1alpha3
 
 import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	ctrl "sigs.k8s.io/controller-runtime"
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
@@ -43,6 +50,50 @@ var _ webhook.Defaulter = &AzureMachinePool{}
 // Default implements webhook.Defaulter so a webhook will be registered for the type
 func (amp *AzureMachinePool) Default() {
 	azuremachinepoollog.Info("default", "name", amp.Name)
+	amp.Spec.Strategy.Default()
+	if amp.Spec.OrchestrationMode == "" {
+		amp.Spec.OrchestrationMode = UniformOrchestrationMode
+	}
+	if amp.Spec.Health != nil {
+		amp.Spec.Health.Default()
+	}
+}
+
+// Default sets the default values of the health monitoring
+func (h *AzureMachinePoolHealth) Default() {
+	if h.Port == nil {
+		port := DefaultHealthProbePort
+		h.Port = &port
+	}
+	if h.RequestPath == "" {
+		h.RequestPath = DefaultHealthProbeRequestPath
+	}
+	if h.GracePeriod == nil {
+		h.GracePeriod = &metav1.Duration{Duration: MinAutomaticRepairsGracePeriod}
+	}
+}
+
+// Default sets the default values of the deployment strategy
+func (s *AzureMachinePoolDeploymentStrategy) Default() {
+	// Pools created before deployment strategies existed were never rolled, so instances are only replaced when
+	// RollingUpdate is requested explicitly.
+	if s.Type == "" {
+		s.Type = ManualAzureMachinePoolDeploymentStrategyType
+	}
+	if s.Type != RollingUpdateAzureMachinePoolDeploymentStrategyType {
+		return
+	}
+	if s.RollingUpdate == nil {
+		s.RollingUpdate = &MachineRollingUpdateDeployment{}
+	}
+	if s.RollingUpdate.MaxSurge == nil {
+		maxSurge := intstr.FromInt(1)
+		s.RollingUpdate.MaxSurge = &maxSurge
+	}
+	if s.RollingUpdate.MaxUnavailable == nil {
+		maxUnavailable := intstr.FromInt(0)
+		s.RollingUpdate.MaxUnavailable = &maxUnavailable
+	}
 }
 
 // +kubebuilder:webhook:verbs=create;update,path=/validate-exp-cluster-x-k8s-io-x-k8s-io-v1alpha3-azuremachinepool,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=exp.cluster.x-k8s.io.x-k8s.io,resources=azuremachinepools,versions=v1alpha3,name=vazuremachinepool.kb.io,sideEffects=None
@@ -58,6 +109,9 @@ func (amp *AzureMachinePool) ValidateCreate() error {
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
 func (amp *AzureMachinePool) ValidateUpdate(old runtime.Object) error {
 	azuremachinepoollog.Info("validate update", "name", amp.Name)
+	if err := amp.ValidateImmutableFields(old.(*AzureMachinePool)); err != nil {
+		return err
+	}
 	return amp.Validate()
 }
 
@@ -71,6 +125,14 @@ func (amp *AzureMachinePool) ValidateDelete() error {
 func (amp *AzureMachinePool) Validate() error {
 	validators := []func() error{
 		amp.ValidateImage,
+		amp.ValidateBootDiagnostics,
+		amp.ValidateVMExtensions,
+		amp.ValidateIdentity,
+		amp.ValidateStrategy,
+		amp.ValidateZones,
+		amp.ValidateHealth,
+		amp.ValidateTerminateNotificationTimeout,
+		amp.ValidateOrchestrationMode,
 	}
 
 	var errs []error
@@ -99,3 +161,200 @@ func (amp *AzureMachinePool) ValidateImage() error {
 	}
 	return nil
 }
+
+// ValidateBootDiagnostics of an AzureMachinePool
+func (amp *AzureMachinePool) ValidateBootDiagnostics() error {
+	if errs := infrav1.ValidateBootDiagnostics(amp.Spec.Template.BootDiagnostics, field.NewPath("bootDiagnostics")); len(errs) > 0 {
+		agg := kerrors.NewAggregate(errs.ToAggregate().Errors())
+		azuremachinepoollog.Info("Invalid boot diagnostics: %s", agg.Error())
+		return agg
+	}
+	return nil
+}
+
+// ValidateVMExtensions of an AzureMachinePool
+func (amp *AzureMachinePool) ValidateVMExtensions() error {
+	if errs := infrav1.ValidateVMExtensions(amp.Spec.Template.VMExtensions, field.NewPath("vmExtensions")); len(errs) > 0 {
+		agg := kerrors.NewAggregate(errs.ToAggregate().Errors())
+		azuremachinepoollog.Info("Invalid VM extensions: %s", agg.Error())
+		return agg
+	}
+	return nil
+}
+
+// ValidateIdentity of an AzureMachinePool
+func (amp *AzureMachinePool) ValidateIdentity() error {
+	template := amp.Spec.Template
+	errs := infrav1.ValidateUserAssignedIdentity(template.Identity, template.UserAssignedIdentities, field.NewPath("userAssignedIdentities"))
+	errs = append(errs, infrav1.ValidateSystemAssignedIdentityRoles(template.Identity, template.SystemAssignedIdentityRoles, field.NewPath("systemAssignedIdentityRoles"))...)
+	if len(errs) > 0 {
+		agg := kerrors.NewAggregate(errs.ToAggregate().Errors())
+		azuremachinepoollog.Info("Invalid identity: %s", agg.Error())
+		return agg
+	}
+	return nil
+}
+
+// ValidateStrategy of an AzureMachinePool
+func (amp *AzureMachinePool) ValidateStrategy() error {
+	strategy := amp.Spec.Strategy
+	fldPath := field.NewPath("strategy")
+	var errs field.ErrorList
+	if strategy.Type == ManualAzureMachinePoolDeploymentStrategyType && strategy.RollingUpdate != nil {
+		errs = append(errs, field.Forbidden(fldPath.Child("rollingUpdate"), "rollingUpdate may only be set with the RollingUpdate strategy"))
+	}
+	if strategy.RollingUpdate != nil {
+		rollingUpdatePath := fldPath.Child("rollingUpdate")
+		maxSurge, surgeErrs := validateIntOrPercent(strategy.RollingUpdate.MaxSurge, rollingUpdatePath.Child("maxSurge"))
+		errs = append(errs, surgeErrs...)
+		maxUnavailable, unavailableErrs := validateIntOrPercent(strategy.RollingUpdate.MaxUnavailable, rollingUpdatePath.Child("maxUnavailable"))
+		errs = append(errs, unavailableErrs...)
+		if len(surgeErrs) == 0 && len(unavailableErrs) == 0 && strategy.RollingUpdate.MaxSurge != nil &&
+			strategy.RollingUpdate.MaxUnavailable != nil && maxSurge == 0 && maxUnavailable == 0 {
+			errs = append(errs, field.Invalid(rollingUpdatePath, strategy.RollingUpdate, "maxSurge and maxUnavailable may not both be 0"))
+		}
+	}
+	if len(errs) > 0 {
+		agg := kerrors.NewAggregate(errs.ToAggregate().Errors())
+		azuremachinepoollog.Info("Invalid strategy: %s", agg.Error())
+		return agg
+	}
+	return nil
+}
+
+// ValidateZones of an AzureMachinePool
+func (amp *AzureMachinePool) ValidateZones() error {
+	fldPath := field.NewPath("zones")
+	var errs field.ErrorList
+	seen := make(map[string]bool, len(amp.Spec.Zones))
+	for i, zone := range amp.Spec.Zones {
+		if zone == "" {
+			errs = append(errs, field.Required(fldPath.Index(i), "zone must not be empty"))
+		} else if seen[zone] {
+			errs = append(errs, field.Duplicate(fldPath.Index(i), zone))
+		}
+		seen[zone] = true
+	}
+	if amp.Spec.ZoneBalance != nil && *amp.Spec.ZoneBalance && len(amp.Spec.Zones) == 0 {
+		errs = append(errs, field.Forbidden(field.NewPath("zoneBalance"), "zoneBalance may only be enabled when zones are set"))
+	}
+	if len(errs) > 0 {
+		agg := kerrors.NewAggregate(errs.ToAggregate().Errors())
+		azuremachinepoollog.Info("Invalid zones: %s", agg.Error())
+		return agg
+	}
+	return nil
+}
+
+// ValidateHealth of an AzureMachinePool
+func (amp *AzureMachinePool) ValidateHealth() error {
+	health := amp.Spec.Health
+	if health == nil {
+		return nil
+	}
+	fldPath := field.NewPath("health")
+	var errs field.ErrorList
+	if health.RequestPath != "" && !strings.HasPrefix(health.RequestPath, "/") {
+		errs = append(errs, field.Invalid(fldPath.Child("requestPath"), health.RequestPath, "must start with /"))
+	}
+	if health.GracePeriod != nil && (health.GracePeriod.Duration < MinAutomaticRepairsGracePeriod || health.GracePeriod.Duration > MaxAutomaticRepairsGracePeriod) {
+		errs = append(errs, field.Invalid(fldPath.Child("gracePeriod"), health.GracePeriod.Duration.String(),
+			fmt.Sprintf("must be between %s and %s", MinAutomaticRepairsGracePeriod, MaxAutomaticRepairsGracePeriod)))
+	}
+	if health.GracePeriod != nil && health.GracePeriod.Duration%time.Minute != 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("gracePeriod"), health.GracePeriod.Duration.String(), "must be a whole number of minutes"))
+	}
+	if len(errs) > 0 {
+		agg := kerrors.NewAggregate(errs.ToAggregate().Errors())
+		azuremachinepoollog.Info("Invalid health: %s", agg.Error())
+		return agg
+	}
+	return nil
+}
+
+// ValidateTerminateNotificationTimeout of an AzureMachinePool
+func (amp *AzureMachinePool) ValidateTerminateNotificationTimeout() error {
+	timeout := amp.Spec.TerminateNotificationTimeout
+	if timeout == nil {
+		return nil
+	}
+	var err error
+	switch {
+	case timeout.Duration < MinTerminateNotificationTimeout || timeout.Duration > MaxTerminateNotificationTimeout:
+		err = field.Invalid(field.NewPath("terminateNotificationTimeout"), timeout.Duration.String(),
+			fmt.Sprintf("must be between %s and %s", MinTerminateNotificationTimeout, MaxTerminateNotificationTimeout))
+	case timeout.Duration%time.Minute != 0:
+		// Azure takes the timeout as an ISO 8601 duration in minutes
+		err = field.Invalid(field.NewPath("terminateNotificationTimeout"), timeout.Duration.String(), "must be a whole number of minutes")
+	default:
+		return nil
+	}
+	azuremachinepoollog.Info("Invalid terminate notification timeout: %s", err.Error())
+	return err
+}
+
+// ValidateOrchestrationMode of an AzureMachinePool
+func (amp *AzureMachinePool) ValidateOrchestrationMode() error {
+	if amp.Spec.OrchestrationMode != FlexibleOrchestrationMode {
+		return nil
+	}
+	const detail = "not supported with the Flexible orchestration mode"
+	var errs field.ErrorList
+	if amp.Spec.Overprovision != nil && *amp.Spec.Overprovision {
+		errs = append(errs, field.Forbidden(field.NewPath("overprovision"), "overprovisioning is "+detail))
+	}
+	if amp.Spec.ZoneBalance != nil && *amp.Spec.ZoneBalance {
+		errs = append(errs, field.Forbidden(field.NewPath("zoneBalance"), "zone balance is "+detail))
+	}
+	if amp.Spec.Health != nil {
+		errs = append(errs, field.Forbidden(field.NewPath("health"), "health monitoring is "+detail))
+	}
+	if len(amp.Spec.Template.VMExtensions) > 0 {
+		errs = append(errs, field.Forbidden(field.NewPath("template", "vmExtensions"), "VM extensions are "+detail))
+	}
+	if amp.Spec.Template.Identity == infrav1.VMIdentitySystemAssigned {
+		errs = append(errs, field.Forbidden(field.NewPath("template", "identity"), "the SystemAssigned identity is "+detail))
+	}
+	if len(errs) > 0 {
+		agg := kerrors.NewAggregate(errs.ToAggregate().Errors())
+		azuremachinepoollog.Info("Invalid orchestration mode: %s", agg.Error())
+		return agg
+	}
+	return nil
+}
+
+// ValidateImmutableFields of an AzureMachinePool, which cannot be changed once the Virtual Machine Scale Set is created
+func (amp *AzureMachinePool) ValidateImmutableFields(old *AzureMachinePool) error {
+	var errs field.ErrorList
+	if !reflect.DeepEqual(amp.Spec.Zones, old.Spec.Zones) {
+		errs = append(errs, field.Forbidden(field.NewPath("zones"), "zones cannot be changed"))
+	}
+	if amp.Spec.IsFlexible() != old.Spec.IsFlexible() {
+		errs = append(errs, field.Forbidden(field.NewPath("orchestrationMode"), "orchestrationMode cannot be changed"))
+	}
+	if (amp.Spec.Template.SpotVMOptions == nil) != (old.Spec.Template.SpotVMOptions == nil) {
+		errs = append(errs, field.Forbidden(field.NewPath("template", "spotVMOptions"), "spotVMOptions cannot be added or removed"))
+	}
+	if len(errs) > 0 {
+		agg := kerrors.NewAggregate(errs.ToAggregate().Errors())
+		azuremachinepoollog.Info("Invalid update: %s", agg.Error())
+		return agg
+	}
+	return nil
+}
+
+// validateIntOrPercent validates that value is a non-negative number or percentage and returns its value for
+// 100 desired instances, so zero values can be detected.
+func validateIntOrPercent(value *intstr.IntOrString, fldPath *field.Path) (int, field.ErrorList) {
+	if value == nil {
+		return 0, nil
+	}
+	scaled, err := intstr.GetValueFromIntOrPercent(value, 100, true)
+	if err != nil {
+		return 0, field.ErrorList{field.Invalid(fldPath, value.String(), "must be an integer or a percentage")}
+	}
+	if scaled < 0 {
+		return 0, field.ErrorList{field.Invalid(fldPath, value.String(), "must not be negative")}
+	}
+	return scaled, nil
+}
@@ -26,13 +26,36 @@ type AzureManagedClusterSpec struct {
 	// ControlPlaneEndpoint represents the endpoint used to communicate with the control plane.
 	// +optional
 	ControlPlaneEndpoint clusterv1.APIEndpoint `json:"controlPlaneEndpoint"`
+
+	// Subnets are additional subnets to create in the virtual network of the AzureManagedControlPlane, for machine
+	// pools that place their nodes in a subnet of their own. Requires the AzureManagedControlPlane to have a
+	// virtual network.
+	// +optional
+	Subnets []ManagedControlPlaneSubnet `json:"subnets,omitempty"`
+
+	// LogAnalyticsWorkspaceID is the resource ID of an existing Log Analytics workspace to send the container logs
+	// and metrics of the cluster to. It enables the omsagent add-on, unless the AzureManagedControlPlane configures
+	// that add-on itself.
+	// +optional
+	LogAnalyticsWorkspaceID string `json:"logAnalyticsWorkspaceID,omitempty"`
+
+	// ContainerRegistryIDs are the resource IDs of Azure Container Registries the nodes of the cluster pull images
+	// from. The kubelet identity of the cluster is assigned the AcrPull role on each of them.
+	// +optional
+	ContainerRegistryIDs []string `json:"containerRegistryIDs,omitempty"`
 }
 
 // AzureManagedClusterStatus defines the observed state of AzureManagedCluster
 type AzureManagedClusterStatus struct {
-	// Ready is true when the provider resource is ready.
+	// Ready is true once the resource group, virtual network and subnets of the cluster exist, the control plane is
+	// ready and the container registries are attached.
 	// +optional
 	Ready bool `json:"ready,omitempty"`
+
+	// AttachedContainerRegistryIDs are the resource IDs of the container registries the kubelet identity of the
+	// cluster has been assigned the AcrPull role on.
+	// +optional
+	AttachedContainerRegistryIDs []string `json:"attachedContainerRegistryIDs,omitempty"`
 }
 
 // +kubebuilder:object:root=true
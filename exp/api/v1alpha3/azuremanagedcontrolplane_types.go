@@ -35,6 +35,11 @@ type AzureManagedControlPlaneSpec struct {
 	// SubscriotionID is the GUID of the Azure subscription to hold this cluster.
 	SubscriptionID string `json:"subscriptionID,omitempty"`
 
+	// IdentityRef references the AzureClusterIdentity whose credentials are used to manage the AKS cluster. If
+	// unset, the credentials of the controller's environment are used.
+	// +optional
+	IdentityRef *corev1.ObjectReference `json:"identityRef,omitempty"`
+
 	// Location is a string matching one of the canonical Azure region names. Examples: "westus2", "eastus".
 	Location string `json:"location"`
 
@@ -55,14 +60,316 @@ type AzureManagedControlPlaneSpec struct {
 	// +kubebuilder:validation:Enum=Calico;Azure
 	NetworkPolicy *string `json:"networkPolicy,omitempty"`
 
+	// OutboundType is the method used for egress from the cluster. Possible values are 'loadBalancer',
+	// 'userDefinedRouting' and 'managedNATGateway'. Defaults to loadBalancer. userDefinedRouting requires a
+	// VirtualNetwork whose subnet has a route table with a default route, managedNATGateway a virtual network
+	// managed by AKS. Cannot be changed once the cluster is created.
+	// +kubebuilder:validation:Enum=loadBalancer;userDefinedRouting;managedNATGateway
+	// +optional
+	OutboundType *string `json:"outboundType,omitempty"`
+
+	// LoadBalancerProfile configures the outbound connectivity of the load balancer of the cluster.
+	// Only used with the loadBalancer outbound type.
+	// +optional
+	LoadBalancerProfile *LoadBalancerProfile `json:"loadBalancerProfile,omitempty"`
+
+	// NATGatewayProfile configures the NAT gateway AKS manages for the cluster.
+	// Only used with the managedNATGateway outbound type.
+	// +optional
+	NATGatewayProfile *NATGatewayProfile `json:"natGatewayProfile,omitempty"`
+
 	// SSHPublicKey is a string literal containing an ssh public key.
 	SSHPublicKey string `json:"sshPublicKey"`
 
+	// WindowsProfile configures the administrator account of the Windows nodes of the cluster. Required to add
+	// Windows node pools, and can only be set when the cluster is created.
+	// +optional
+	WindowsProfile *ManagedControlPlaneWindowsProfile `json:"windowsProfile,omitempty"`
+
+	// NodeResourceGroup is the name of the resource group AKS creates for the nodes and other infrastructure of the
+	// cluster. Defaults to MC_<resource group>_<cluster name>_<location>. Cannot be changed once the cluster is created.
+	// +optional
+	NodeResourceGroup string `json:"nodeResourceGroup,omitempty"`
+
+	// SKU is the pricing tier of the AKS control plane. Defaults to the Free tier, which has no uptime SLA.
+	// +optional
+	SKU *ManagedControlPlaneSKU `json:"sku,omitempty"`
+
+	// Identity configures user-assigned identities for the cluster. AKS creates system-assigned identities for the
+	// control plane and kubelets if not set.
+	// +optional
+	Identity *ManagedControlPlaneIdentity `json:"identity,omitempty"`
+
+	// AutoUpgradeChannel is the channel AKS automatically upgrades the cluster with: none, patch, stable, rapid or
+	// node-image. Upgrades of the Kubernetes version aren't reflected in the version fields, which have to be raised
+	// to follow them. Left as it is if not set.
+	// +kubebuilder:validation:Enum=none;patch;stable;rapid;node-image
+	// +optional
+	AutoUpgradeChannel *string `json:"autoUpgradeChannel,omitempty"`
+
+	// MaintenanceWindow restricts when AKS performs planned maintenance, such as automatic upgrades. Left as it is
+	// if not set; a window without any times removes it.
+	// +optional
+	MaintenanceWindow *MaintenanceWindow `json:"maintenanceWindow,omitempty"`
+
+	// APIServerAccessProfile is the access profile for the AKS API server.
+	// +optional
+	APIServerAccessProfile *APIServerAccessProfile `json:"apiServerAccessProfile,omitempty"`
+
+	// AADProfile integrates the cluster with Azure Active Directory, so that users log in to it with their AAD
+	// identity. The kubeconfig Secret of the cluster then holds a user kubeconfig that requires an AAD login.
+	// +optional
+	AADProfile *AADProfile `json:"aadProfile,omitempty"`
+
+	// EnableRBAC enables Kubernetes role-based access control. Defaults to true, and is required by AADProfile.
+	// Cannot be changed once the cluster is created.
+	// +optional
+	EnableRBAC *bool `json:"enableRBAC,omitempty"`
+
+	// StoreAdminKubeconfig stores the admin kubeconfig of the cluster, which bypasses AAD, in the Secret
+	// <cluster name>-admin-kubeconfig. Not supported with DisableLocalAccounts.
+	// +optional
+	StoreAdminKubeconfig *bool `json:"storeAdminKubeconfig,omitempty"`
+
+	// DisableLocalAccounts disables the admin credentials of the cluster, so that it can only be reached with an AAD
+	// identity. Requires AADProfile. Left as it is if not set.
+	// +optional
+	DisableLocalAccounts *bool `json:"disableLocalAccounts,omitempty"`
+
+	// AddonProfiles are the AKS add-ons of the cluster. Add-ons that aren't listed are left as they are, so
+	// that add-ons enabled outside of Cluster API are kept. List an add-on with enabled false to disable it.
+	// +optional
+	AddonProfiles []AddonProfile `json:"addonProfiles,omitempty"`
+
+	// VirtualNetwork is the virtual network and subnet to place the nodes of the AKS cluster in.
+	// AKS creates a virtual network for the cluster if none is given.
+	// +optional
+	VirtualNetwork *ManagedControlPlaneVirtualNetwork `json:"virtualNetwork,omitempty"`
+
+	// Adopt imports an existing AKS cluster with the name of the AzureManagedControlPlane instead of creating one.
+	// Before the cluster is reconciled for the first time, its version and network, access and identity settings
+	// are copied into this spec, and an AzureManagedMachinePool and MachinePool are created for each of its agent
+	// pools. The AKS cluster isn't changed while it is adopted.
+	// +optional
+	Adopt bool `json:"adopt,omitempty"`
+
 	// DefaultPoolRef is the specification for the default pool, without which an AKS cluster cannot be created.
+	// Defaults to the first system pool of an adopted cluster.
 	// TODO(ace): consider defaulting and making optional pointer?
 	DefaultPoolRef corev1.LocalObjectReference `json:"defaultPoolRef"`
 }
 
+// ManagedControlPlaneSKU is the pricing tier of an AKS control plane.
+type ManagedControlPlaneSKU struct {
+	// Tier is the tier of the control plane. Paid adds the financially backed uptime SLA of the API server.
+	// +kubebuilder:validation:Enum=Free;Paid
+	Tier string `json:"tier"`
+}
+
+// ManagedControlPlaneIdentity references the user-assigned identities of an AKS cluster by their resource IDs.
+type ManagedControlPlaneIdentity struct {
+	// ControlPlaneIdentityID is the resource ID of the user-assigned identity of the control plane, which
+	// manages the Azure resources of the cluster.
+	ControlPlaneIdentityID string `json:"controlPlaneIdentityID"`
+
+	// KubeletIdentityID is the resource ID of the user-assigned identity the kubelets use, e.g. to pull images
+	// from a container registry. The control plane identity is granted the Managed Identity Operator role on it
+	// unless it already has that role. Defaults to an identity AKS creates in the node resource group. Cannot be
+	// changed once the cluster is created.
+	// +optional
+	KubeletIdentityID string `json:"kubeletIdentityID,omitempty"`
+}
+
+// MaintenanceWindow is the planned maintenance window of an AKS cluster.
+type MaintenanceWindow struct {
+	// TimeInWeek are the hours of the week in which maintenance is allowed. Maintenance is allowed at any time if
+	// none are given.
+	// +optional
+	TimeInWeek []TimeInWeek `json:"timeInWeek,omitempty"`
+
+	// NotAllowedTime are the time spans in which maintenance is not allowed, overriding TimeInWeek.
+	// +optional
+	NotAllowedTime []TimeSpan `json:"notAllowedTime,omitempty"`
+}
+
+// TimeInWeek are hours of a day of the week.
+type TimeInWeek struct {
+	// Day is the day of the week.
+	// +kubebuilder:validation:Enum=Sunday;Monday;Tuesday;Wednesday;Thursday;Friday;Saturday
+	Day string `json:"day"`
+
+	// HourSlots are the hours of the day, from 0 to 23 in UTC, each standing for the hour it starts.
+	// +kubebuilder:validation:MinItems=1
+	HourSlots []int32 `json:"hourSlots"`
+}
+
+// TimeSpan is the time between a start and an end.
+type TimeSpan struct {
+	// Start is the start of the time span.
+	Start metav1.Time `json:"start"`
+
+	// End is the end of the time span.
+	End metav1.Time `json:"end"`
+}
+
+// APIServerAccessProfile controls access to the AKS API server.
+type APIServerAccessProfile struct {
+	// AuthorizedIPRanges are the IP ranges in CIDR notation that are allowed to access the API server.
+	// Access is not restricted if none are given. Not supported by private clusters.
+	// +optional
+	AuthorizedIPRanges []string `json:"authorizedIPRanges,omitempty"`
+
+	// EnablePrivateCluster creates the cluster with an API server that is only reachable from within its
+	// virtual network. This can only be set when the cluster is created.
+	// +optional
+	EnablePrivateCluster *bool `json:"enablePrivateCluster,omitempty"`
+
+	// PrivateDNSZone is the private DNS zone of a private cluster: System to let AKS create one in the node
+	// resource group, None to use public DNS, or the resource ID of an existing private DNS zone. Defaults to
+	// System. This can only be set when the cluster is created.
+	// +optional
+	PrivateDNSZone *string `json:"privateDNSZone,omitempty"`
+}
+
+// ManagedControlPlaneWindowsProfile configures the Windows nodes of an AKS cluster.
+type ManagedControlPlaneWindowsProfile struct {
+	// AdminCredentialsSecretName is the name of a Secret in the same namespace whose username and password keys
+	// hold the credentials of the administrator account of the Windows nodes. Changing the password in the Secret
+	// changes it on the nodes; the username cannot be changed once the cluster is created.
+	AdminCredentialsSecretName string `json:"adminCredentialsSecretName"`
+}
+
+// LoadBalancerProfile configures the outbound connectivity of the Standard load balancer of an AKS cluster.
+// At most one of ManagedOutboundIPs, OutboundIPs and OutboundIPPrefixes can be set.
+type LoadBalancerProfile struct {
+	// ManagedOutboundIPs is the number of outbound public IPs AKS creates for the load balancer. Defaults to 1.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	ManagedOutboundIPs *int32 `json:"managedOutboundIPs,omitempty"`
+
+	// OutboundIPs are the resource IDs of existing public IPs to use for outbound connections.
+	// +optional
+	OutboundIPs []string `json:"outboundIPs,omitempty"`
+
+	// OutboundIPPrefixes are the resource IDs of existing public IP prefixes to use for outbound connections.
+	// +optional
+	OutboundIPPrefixes []string `json:"outboundIPPrefixes,omitempty"`
+
+	// AllocatedOutboundPorts is the number of SNAT ports allocated per node. Defaults to 0, which lets Azure
+	// allocate ports dynamically based on the number of nodes.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=64000
+	// +optional
+	AllocatedOutboundPorts *int32 `json:"allocatedOutboundPorts,omitempty"`
+
+	// IdleTimeoutInMinutes is the idle timeout of outbound flows. Defaults to 30 minutes.
+	// +kubebuilder:validation:Minimum=4
+	// +kubebuilder:validation:Maximum=120
+	// +optional
+	IdleTimeoutInMinutes *int32 `json:"idleTimeoutInMinutes,omitempty"`
+}
+
+// NATGatewayProfile configures the outbound connectivity of the NAT gateway AKS manages for a cluster.
+type NATGatewayProfile struct {
+	// ManagedOutboundIPs is the number of outbound public IPs AKS creates for the NAT gateway. Defaults to 1.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=16
+	// +optional
+	ManagedOutboundIPs *int32 `json:"managedOutboundIPs,omitempty"`
+
+	// IdleTimeoutInMinutes is the idle timeout of outbound flows. Defaults to 4 minutes.
+	// +kubebuilder:validation:Minimum=4
+	// +kubebuilder:validation:Maximum=120
+	// +optional
+	IdleTimeoutInMinutes *int32 `json:"idleTimeoutInMinutes,omitempty"`
+}
+
+// AADProfile describes the managed Azure Active Directory integration of an AKS cluster.
+type AADProfile struct {
+	// AdminGroupObjectIDs are the object IDs of the AAD groups whose members are cluster admins.
+	// +optional
+	AdminGroupObjectIDs []string `json:"adminGroupObjectIDs,omitempty"`
+
+	// TenantID is the ID of the AAD tenant users log in with. Defaults to the tenant of the subscription.
+	// +optional
+	TenantID string `json:"tenantID,omitempty"`
+
+	// EnableAzureRBAC authorizes AAD users and groups with Azure role assignments instead of Kubernetes role
+	// bindings. Left as it is if not set.
+	// +optional
+	EnableAzureRBAC *bool `json:"enableAzureRBAC,omitempty"`
+}
+
+// AddonProfile describes an AKS add-on.
+type AddonProfile struct {
+	// Name is the name of the add-on, for example omsagent, azurepolicy, ingressApplicationGateway or
+	// azureKeyvaultSecretsProvider.
+	Name string `json:"name"`
+
+	// Enabled enables or disables the add-on.
+	Enabled bool `json:"enabled"`
+
+	// Config is the key-value configuration of the add-on, for example the logAnalyticsWorkspaceResourceID of
+	// the omsagent add-on.
+	// +optional
+	Config map[string]string `json:"config,omitempty"`
+}
+
+// AddonProfileStatus is the observed state of an enabled AKS add-on.
+type AddonProfileStatus struct {
+	// Name is the name of the add-on.
+	Name string `json:"name"`
+
+	// Identity is the user-assigned identity AKS created for the add-on, if it uses one.
+	// +optional
+	Identity *AddonIdentity `json:"identity,omitempty"`
+}
+
+// AddonIdentity is the user-assigned identity of an AKS add-on.
+type AddonIdentity struct {
+	// ResourceID is the resource ID of the identity.
+	// +optional
+	ResourceID string `json:"resourceID,omitempty"`
+
+	// ClientID is the client ID of the identity.
+	// +optional
+	ClientID string `json:"clientID,omitempty"`
+
+	// ObjectID is the object ID of the identity.
+	// +optional
+	ObjectID string `json:"objectID,omitempty"`
+}
+
+// ManagedControlPlaneVirtualNetwork describes the virtual network of an AKS cluster. The virtual network and its
+// subnet are created if they don't exist.
+type ManagedControlPlaneVirtualNetwork struct {
+	// Name is the name of the virtual network.
+	Name string `json:"name"`
+
+	// ResourceGroup is the name of the resource group of the virtual network.
+	// Defaults to the resource group of the AKS cluster.
+	// +optional
+	ResourceGroup string `json:"resourceGroup,omitempty"`
+
+	// CIDRBlock is the CIDR block of the virtual network, used when the virtual network is created.
+	// +optional
+	CIDRBlock string `json:"cidrBlock,omitempty"`
+
+	// Subnet is the subnet the nodes of agent pools without a subnet of their own are placed in.
+	Subnet ManagedControlPlaneSubnet `json:"subnet"`
+}
+
+// ManagedControlPlaneSubnet describes a subnet of the virtual network of an AKS cluster.
+type ManagedControlPlaneSubnet struct {
+	// Name is the name of the subnet.
+	Name string `json:"name"`
+
+	// CIDRBlock is the CIDR block of the subnet, used when the subnet is created.
+	// +optional
+	CIDRBlock string `json:"cidrBlock,omitempty"`
+}
+
 // AzureManagedControlPlaneStatus defines the observed state of AzureManagedControlPlane
 type AzureManagedControlPlaneStatus struct {
 	// Ready is true when the provider resource is ready.
@@ -74,6 +381,27 @@ type AzureManagedControlPlaneStatus struct {
 	// In the AzureManagedControlPlane implementation, these are identical.
 	// +optional
 	Initialized bool `json:"initialized,omitempty"`
+
+	// Version is the Kubernetes version the AKS control plane is running.
+	// +optional
+	Version string `json:"version,omitempty"`
+
+	// NodeResourceGroup is the name of the resource group that holds the nodes of the AKS cluster.
+	// +optional
+	NodeResourceGroup string `json:"nodeResourceGroup,omitempty"`
+
+	// AddonProfiles are the enabled add-ons of the cluster and the identities they use.
+	// +optional
+	AddonProfiles []AddonProfileStatus `json:"addonProfiles,omitempty"`
+
+	// KubeletIdentityObjectID is the object ID of the managed identity the kubelets of the cluster use to access
+	// Azure resources, such as container registries.
+	// +optional
+	KubeletIdentityObjectID string `json:"kubeletIdentityObjectID,omitempty"`
+
+	// Conditions defines current service state of the AzureManagedControlPlane.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -99,6 +427,16 @@ type AzureManagedControlPlaneList struct {
 	Items           []AzureManagedControlPlane `json:"items"`
 }
 
+// GetConditions returns the list of conditions for an AzureManagedControlPlane API object.
+func (m *AzureManagedControlPlane) GetConditions() clusterv1.Conditions {
+	return m.Status.Conditions
+}
+
+// SetConditions will set the given conditions on an AzureManagedControlPlane object.
+func (m *AzureManagedControlPlane) SetConditions(conditions clusterv1.Conditions) {
+	m.Status.Conditions = conditions
+}
+
 func init() {
 	SchemeBuilder.Register(&AzureManagedControlPlane{}, &AzureManagedControlPlaneList{})
 }
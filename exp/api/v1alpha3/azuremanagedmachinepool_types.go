@@ -18,9 +18,13 @@ package v1alpha3
 
 import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	capierrors "sigs.k8s.io/cluster-api/errors"
 )
 
+// NodeImageVersionLatest is the node image version that keeps the nodes of an agent pool on the latest node image.
+const NodeImageVersionLatest = "latest"
+
 // AzureManagedMachinePoolSpec defines the desired state of AzureManagedMachinePool
 type AzureManagedMachinePoolSpec struct {
 	// SKU is the size of the VMs in the node pool.
@@ -30,6 +34,70 @@ type AzureManagedMachinePoolSpec struct {
 	// If you specify 0, it will apply the default osDisk size according to the vmSize specified.
 	OSDiskSizeGB *int32 `json:"osDiskSizeGB,omitempty"`
 
+	// SubnetName is the name of an existing subnet in the virtual network of the AzureManagedControlPlane to place
+	// the nodes of this pool in. Defaults to the subnet of the virtual network. Cannot be changed once the pool is created.
+	// +optional
+	SubnetName *string `json:"subnetName,omitempty"`
+
+	// OSType is the operating system of the nodes of the pool. Windows node pools require the Azure network plugin and
+	// a WindowsProfile on the AzureManagedControlPlane, cannot be System node pools, and have names of at most 6
+	// characters. Defaults to Linux. Cannot be changed once the pool is created.
+	// +kubebuilder:validation:Enum=Linux;Windows
+	// +optional
+	OSType *string `json:"osType,omitempty"`
+
+	// Mode is the mode of the node pool. A cluster needs at least one System node pool to run critical system pods.
+	// Defaults to System for the first node pool of the cluster and to User for any other node pool.
+	// +kubebuilder:validation:Enum=System;User
+	// +optional
+	Mode string `json:"mode,omitempty"`
+
+	// EnableAutoScaling enables the AKS cluster autoscaler for the node pool. While it is enabled, the number of
+	// nodes is managed by the autoscaler within MinCount and MaxCount, and the replicas of the MachinePool are ignored.
+	// +optional
+	EnableAutoScaling *bool `json:"enableAutoScaling,omitempty"`
+
+	// MinCount is the minimum number of nodes for the autoscaler. Required if EnableAutoScaling is true.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MinCount *int32 `json:"minCount,omitempty"`
+
+	// MaxCount is the maximum number of nodes for the autoscaler. Required if EnableAutoScaling is true.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxCount *int32 `json:"maxCount,omitempty"`
+
+	// NodeLabels are the Kubernetes labels applied to every node of the pool.
+	// +optional
+	NodeLabels map[string]string `json:"nodeLabels,omitempty"`
+
+	// NodeTaints are the Kubernetes taints applied to every node of the pool, in the form key=value:NoSchedule.
+	// +optional
+	NodeTaints []string `json:"nodeTaints,omitempty"`
+
+	// MaxPods is the maximum number of pods that can run on a node. Defaults to the AKS default for the network
+	// plugin of the cluster. Cannot be changed once the pool is created.
+	// +optional
+	MaxPods *int32 `json:"maxPods,omitempty"`
+
+	// AvailabilityZones is the list of availability zones to spread the nodes of the pool across.
+	// Cannot be changed once the pool is created.
+	// +optional
+	AvailabilityZones []string `json:"availabilityZones,omitempty"`
+
+	// NodeImageVersion is the minimum node image version of the pool, for example AKSUbuntu-1604-2020.09.16, or
+	// latest to keep the pool on the latest node image. When the nodes run an older version, they are upgraded to
+	// the latest node image AKS offers, which must be at least this version. The latest version is reported in
+	// status.latestNodeImageVersion.
+	// +optional
+	NodeImageVersion string `json:"nodeImageVersion,omitempty"`
+
+	// MaxSurge is the number or percentage of extra nodes added to the pool while it is upgraded, for example 1 or 33%.
+	// Percentages can't exceed 100%. Defaults to the AKS default of one node.
+	// +kubebuilder:validation:Pattern=`^([1-9][0-9]*|[1-9][0-9]?%|100%)$`
+	// +optional
+	MaxSurge *string `json:"maxSurge,omitempty"`
+
 	// ProviderIDList is the unique identifier as specified by the cloud provider.
 	// +optional
 	ProviderIDList []string `json:"providerIDList,omitempty"`
@@ -45,6 +113,14 @@ type AzureManagedMachinePoolStatus struct {
 	// +optional
 	Replicas int32 `json:"replicas"`
 
+	// NodeImageVersion is the node image version the nodes of the pool run.
+	// +optional
+	NodeImageVersion string `json:"nodeImageVersion,omitempty"`
+
+	// LatestNodeImageVersion is the latest node image version AKS offers for the pool.
+	// +optional
+	LatestNodeImageVersion string `json:"latestNodeImageVersion,omitempty"`
+
 	// Any transient errors that occur during the reconciliation of Machines
 	// can be added as events to the Machine object and/or logged in the
 	// controller's output.
@@ -56,6 +132,10 @@ type AzureManagedMachinePoolStatus struct {
 	// controller's output.
 	// +optional
 	ErrorMessage *string `json:"errorMessage,omitempty"`
+
+	// Conditions defines current service state of the AzureManagedMachinePool.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -81,6 +161,16 @@ type AzureManagedMachinePoolList struct {
 	Items           []AzureManagedMachinePool `json:"items"`
 }
 
+// GetConditions returns the list of conditions for an AzureManagedMachinePool API object.
+func (m *AzureManagedMachinePool) GetConditions() clusterv1.Conditions {
+	return m.Status.Conditions
+}
+
+// SetConditions will set the given conditions on an AzureManagedMachinePool object.
+func (m *AzureManagedMachinePool) SetConditions(conditions clusterv1.Conditions) {
+	m.Status.Conditions = conditions
+}
+
 func init() {
 	SchemeBuilder.Register(&AzureManagedMachinePool{}, &AzureManagedMachinePoolList{})
 }
@@ -0,0 +1,53 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+
+// Conditions and condition Reasons for AKS control planes and node pools.
+
+const (
+	// KubernetesVersionUpgradedCondition reports whether the Kubernetes version running in AKS matches the desired version.
+	KubernetesVersionUpgradedCondition clusterv1.ConditionType = "KubernetesVersionUpgraded"
+
+	// NodeImageUpgradedCondition reports whether the nodes of an AKS node pool run the desired node image version.
+	NodeImageUpgradedCondition clusterv1.ConditionType = "NodeImageUpgraded"
+
+	// UpgradingReason (Severity=Info) documents an upgrade to the desired Kubernetes or node image version that is in
+	// progress.
+	UpgradingReason = "Upgrading"
+
+	// UpgradeVersionUnavailableReason (Severity=Error) documents a desired Kubernetes or node image version that AKS
+	// doesn't offer as an upgrade from the current version.
+	UpgradeVersionUnavailableReason = "UpgradeVersionUnavailable"
+
+	// UpgradeFailedReason (Severity=Error) documents a failure while upgrading to the desired Kubernetes or node
+	// image version.
+	UpgradeFailedReason = "UpgradeFailed"
+
+	// WaitingForControlPlaneUpgradeReason (Severity=Info) documents a node pool upgrade that is waiting for the
+	// control plane to run the desired Kubernetes version.
+	WaitingForControlPlaneUpgradeReason = "WaitingForControlPlaneUpgrade"
+)
+
+const (
+	// AdoptedCondition reports whether an existing AKS cluster was imported into the AzureManagedControlPlane.
+	AdoptedCondition clusterv1.ConditionType = "Adopted"
+
+	// AdoptionFailedReason (Severity=Error) documents an existing AKS cluster that couldn't be adopted.
+	AdoptionFailedReason = "AdoptionFailed"
+)
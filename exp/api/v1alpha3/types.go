@@ -22,11 +22,13 @@ import (
 
 type (
 	VMSSVM struct {
-		ID               string          `json:"id,omitempty"`
-		InstanceID       string          `json:"instanceID,omitempty"`
-		Name             string          `json:"name,omitempty"`
-		AvailabilityZone string          `json:"availabilityZone,omitempty"`
-		State            infrav1.VMState `json:"vmState,omitempty"`
+		ID                 string          `json:"id,omitempty"`
+		InstanceID         string          `json:"instanceID,omitempty"`
+		Name               string          `json:"name,omitempty"`
+		AvailabilityZone   string          `json:"availabilityZone,omitempty"`
+		State              infrav1.VMState `json:"vmState,omitempty"`
+		LatestModelApplied bool            `json:"latestModelApplied,omitempty"`
+		HealthState        string          `json:"healthState,omitempty"`
 	}
 
 	VMSS struct {
@@ -40,5 +42,8 @@ type (
 		Identity  infrav1.VMIdentity `json:"identity,omitempty"`
 		Tags      infrav1.Tags       `json:"tags,omitempty"`
 		Instances []VMSSVM           `json:"instances,omitempty"`
+
+		// VMExtensions is the observed state of the extensions of the scale set model.
+		VMExtensions []infrav1.VMExtensionStatus `json:"vmExtensions,omitempty"`
 	}
 )
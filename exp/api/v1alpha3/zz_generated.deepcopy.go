@@ -21,11 +21,127 @@ limitations under the License.
 package v1alpha3
 
 import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	apiv1alpha3 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
+	cluster_apiapiv1alpha3 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/errors"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AADProfile) DeepCopyInto(out *AADProfile) {
+	*out = *in
+	if in.AdminGroupObjectIDs != nil {
+		in, out := &in.AdminGroupObjectIDs, &out.AdminGroupObjectIDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.EnableAzureRBAC != nil {
+		in, out := &in.EnableAzureRBAC, &out.EnableAzureRBAC
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AADProfile.
+func (in *AADProfile) DeepCopy() *AADProfile {
+	if in == nil {
+		return nil
+	}
+	out := new(AADProfile)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *APIServerAccessProfile) DeepCopyInto(out *APIServerAccessProfile) {
+	*out = *in
+	if in.AuthorizedIPRanges != nil {
+		in, out := &in.AuthorizedIPRanges, &out.AuthorizedIPRanges
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.EnablePrivateCluster != nil {
+		in, out := &in.EnablePrivateCluster, &out.EnablePrivateCluster
+		*out = new(bool)
+		**out = **in
+	}
+	if in.PrivateDNSZone != nil {
+		in, out := &in.PrivateDNSZone, &out.PrivateDNSZone
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new APIServerAccessProfile.
+func (in *APIServerAccessProfile) DeepCopy() *APIServerAccessProfile {
+	if in == nil {
+		return nil
+	}
+	out := new(APIServerAccessProfile)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AddonIdentity) DeepCopyInto(out *AddonIdentity) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AddonIdentity.
+func (in *AddonIdentity) DeepCopy() *AddonIdentity {
+	if in == nil {
+		return nil
+	}
+	out := new(AddonIdentity)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AddonProfile) DeepCopyInto(out *AddonProfile) {
+	*out = *in
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AddonProfile.
+func (in *AddonProfile) DeepCopy() *AddonProfile {
+	if in == nil {
+		return nil
+	}
+	out := new(AddonProfile)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AddonProfileStatus) DeepCopyInto(out *AddonProfileStatus) {
+	*out = *in
+	if in.Identity != nil {
+		in, out := &in.Identity, &out.Identity
+		*out = new(AddonIdentity)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AddonProfileStatus.
+func (in *AddonProfileStatus) DeepCopy() *AddonProfileStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AddonProfileStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AzureMachinePool) DeepCopyInto(out *AzureMachinePool) {
 	*out = *in
@@ -53,6 +169,76 @@ func (in *AzureMachinePool) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AzureMachinePoolDeploymentStrategy) DeepCopyInto(out *AzureMachinePoolDeploymentStrategy) {
+	*out = *in
+	if in.RollingUpdate != nil {
+		in, out := &in.RollingUpdate, &out.RollingUpdate
+		*out = new(MachineRollingUpdateDeployment)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureMachinePoolDeploymentStrategy.
+func (in *AzureMachinePoolDeploymentStrategy) DeepCopy() *AzureMachinePoolDeploymentStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(AzureMachinePoolDeploymentStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AzureMachinePoolHealth) DeepCopyInto(out *AzureMachinePoolHealth) {
+	*out = *in
+	if in.Port != nil {
+		in, out := &in.Port, &out.Port
+		*out = new(int32)
+		**out = **in
+	}
+	if in.GracePeriod != nil {
+		in, out := &in.GracePeriod, &out.GracePeriod
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureMachinePoolHealth.
+func (in *AzureMachinePoolHealth) DeepCopy() *AzureMachinePoolHealth {
+	if in == nil {
+		return nil
+	}
+	out := new(AzureMachinePoolHealth)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AzureMachinePoolInstanceStatus) DeepCopyInto(out *AzureMachinePoolInstanceStatus) {
+	*out = *in
+	if in.ProvisioningState != nil {
+		in, out := &in.ProvisioningState, &out.ProvisioningState
+		*out = new(apiv1alpha3.VMState)
+		**out = **in
+	}
+	if in.NodeRef != nil {
+		in, out := &in.NodeRef, &out.NodeRef
+		*out = new(corev1.ObjectReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureMachinePoolInstanceStatus.
+func (in *AzureMachinePoolInstanceStatus) DeepCopy() *AzureMachinePoolInstanceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AzureMachinePoolInstanceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AzureMachinePoolList) DeepCopyInto(out *AzureMachinePoolList) {
 	*out = *in
@@ -101,6 +287,32 @@ func (in *AzureMachinePoolSpec) DeepCopyInto(out *AzureMachinePoolSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	in.Strategy.DeepCopyInto(&out.Strategy)
+	if in.Zones != nil {
+		in, out := &in.Zones, &out.Zones
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ZoneBalance != nil {
+		in, out := &in.ZoneBalance, &out.ZoneBalance
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Health != nil {
+		in, out := &in.Health, &out.Health
+		*out = new(AzureMachinePoolHealth)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TerminateNotificationTimeout != nil {
+		in, out := &in.TerminateNotificationTimeout, &out.TerminateNotificationTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Overprovision != nil {
+		in, out := &in.Overprovision, &out.Overprovision
+		*out = new(bool)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureMachinePoolSpec.
@@ -121,6 +333,23 @@ func (in *AzureMachinePoolStatus) DeepCopyInto(out *AzureMachinePoolStatus) {
 		*out = new(apiv1alpha3.VMState)
 		**out = **in
 	}
+	if in.VMExtensions != nil {
+		in, out := &in.VMExtensions, &out.VMExtensions
+		*out = make([]apiv1alpha3.VMExtensionStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.Instances != nil {
+		in, out := &in.Instances, &out.Instances
+		*out = make([]AzureMachinePoolInstanceStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.RoleAssignmentIDs != nil {
+		in, out := &in.RoleAssignmentIDs, &out.RoleAssignmentIDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.FailureReason != nil {
 		in, out := &in.FailureReason, &out.FailureReason
 		*out = new(errors.MachineStatusError)
@@ -157,6 +386,33 @@ func (in *AzureMachineTemplate) DeepCopyInto(out *AzureMachineTemplate) {
 		*out = new(bool)
 		**out = **in
 	}
+	if in.BootDiagnostics != nil {
+		in, out := &in.BootDiagnostics, &out.BootDiagnostics
+		*out = new(apiv1alpha3.BootDiagnostics)
+		**out = **in
+	}
+	if in.VMExtensions != nil {
+		in, out := &in.VMExtensions, &out.VMExtensions
+		*out = make([]apiv1alpha3.VMExtension, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.UserAssignedIdentities != nil {
+		in, out := &in.UserAssignedIdentities, &out.UserAssignedIdentities
+		*out = make([]apiv1alpha3.UserAssignedIdentity, len(*in))
+		copy(*out, *in)
+	}
+	if in.SystemAssignedIdentityRoles != nil {
+		in, out := &in.SystemAssignedIdentityRoles, &out.SystemAssignedIdentityRoles
+		*out = make([]apiv1alpha3.SystemAssignedIdentityRole, len(*in))
+		copy(*out, *in)
+	}
+	if in.SpotVMOptions != nil {
+		in, out := &in.SpotVMOptions, &out.SpotVMOptions
+		*out = new(apiv1alpha3.SpotVMOptions)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureMachineTemplate.
@@ -174,8 +430,8 @@ func (in *AzureManagedCluster) DeepCopyInto(out *AzureManagedCluster) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureManagedCluster.
@@ -232,6 +488,16 @@ func (in *AzureManagedClusterList) DeepCopyObject() runtime.Object {
 func (in *AzureManagedClusterSpec) DeepCopyInto(out *AzureManagedClusterSpec) {
 	*out = *in
 	out.ControlPlaneEndpoint = in.ControlPlaneEndpoint
+	if in.Subnets != nil {
+		in, out := &in.Subnets, &out.Subnets
+		*out = make([]ManagedControlPlaneSubnet, len(*in))
+		copy(*out, *in)
+	}
+	if in.ContainerRegistryIDs != nil {
+		in, out := &in.ContainerRegistryIDs, &out.ContainerRegistryIDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureManagedClusterSpec.
@@ -247,6 +513,11 @@ func (in *AzureManagedClusterSpec) DeepCopy() *AzureManagedClusterSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AzureManagedClusterStatus) DeepCopyInto(out *AzureManagedClusterStatus) {
 	*out = *in
+	if in.AttachedContainerRegistryIDs != nil {
+		in, out := &in.AttachedContainerRegistryIDs, &out.AttachedContainerRegistryIDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureManagedClusterStatus.
@@ -265,7 +536,7 @@ func (in *AzureManagedControlPlane) DeepCopyInto(out *AzureManagedControlPlane)
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureManagedControlPlane.
@@ -321,6 +592,11 @@ func (in *AzureManagedControlPlaneList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AzureManagedControlPlaneSpec) DeepCopyInto(out *AzureManagedControlPlaneSpec) {
 	*out = *in
+	if in.IdentityRef != nil {
+		in, out := &in.IdentityRef, &out.IdentityRef
+		*out = new(corev1.ObjectReference)
+		**out = **in
+	}
 	out.ControlPlaneEndpoint = in.ControlPlaneEndpoint
 	if in.AdditionalTags != nil {
 		in, out := &in.AdditionalTags, &out.AdditionalTags
@@ -339,6 +615,83 @@ func (in *AzureManagedControlPlaneSpec) DeepCopyInto(out *AzureManagedControlPla
 		*out = new(string)
 		**out = **in
 	}
+	if in.OutboundType != nil {
+		in, out := &in.OutboundType, &out.OutboundType
+		*out = new(string)
+		**out = **in
+	}
+	if in.LoadBalancerProfile != nil {
+		in, out := &in.LoadBalancerProfile, &out.LoadBalancerProfile
+		*out = new(LoadBalancerProfile)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NATGatewayProfile != nil {
+		in, out := &in.NATGatewayProfile, &out.NATGatewayProfile
+		*out = new(NATGatewayProfile)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.WindowsProfile != nil {
+		in, out := &in.WindowsProfile, &out.WindowsProfile
+		*out = new(ManagedControlPlaneWindowsProfile)
+		**out = **in
+	}
+	if in.SKU != nil {
+		in, out := &in.SKU, &out.SKU
+		*out = new(ManagedControlPlaneSKU)
+		**out = **in
+	}
+	if in.Identity != nil {
+		in, out := &in.Identity, &out.Identity
+		*out = new(ManagedControlPlaneIdentity)
+		**out = **in
+	}
+	if in.AutoUpgradeChannel != nil {
+		in, out := &in.AutoUpgradeChannel, &out.AutoUpgradeChannel
+		*out = new(string)
+		**out = **in
+	}
+	if in.MaintenanceWindow != nil {
+		in, out := &in.MaintenanceWindow, &out.MaintenanceWindow
+		*out = new(MaintenanceWindow)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.APIServerAccessProfile != nil {
+		in, out := &in.APIServerAccessProfile, &out.APIServerAccessProfile
+		*out = new(APIServerAccessProfile)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AADProfile != nil {
+		in, out := &in.AADProfile, &out.AADProfile
+		*out = new(AADProfile)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.EnableRBAC != nil {
+		in, out := &in.EnableRBAC, &out.EnableRBAC
+		*out = new(bool)
+		**out = **in
+	}
+	if in.StoreAdminKubeconfig != nil {
+		in, out := &in.StoreAdminKubeconfig, &out.StoreAdminKubeconfig
+		*out = new(bool)
+		**out = **in
+	}
+	if in.DisableLocalAccounts != nil {
+		in, out := &in.DisableLocalAccounts, &out.DisableLocalAccounts
+		*out = new(bool)
+		**out = **in
+	}
+	if in.AddonProfiles != nil {
+		in, out := &in.AddonProfiles, &out.AddonProfiles
+		*out = make([]AddonProfile, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.VirtualNetwork != nil {
+		in, out := &in.VirtualNetwork, &out.VirtualNetwork
+		*out = new(ManagedControlPlaneVirtualNetwork)
+		**out = **in
+	}
 	out.DefaultPoolRef = in.DefaultPoolRef
 }
 
@@ -355,6 +708,20 @@ func (in *AzureManagedControlPlaneSpec) DeepCopy() *AzureManagedControlPlaneSpec
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AzureManagedControlPlaneStatus) DeepCopyInto(out *AzureManagedControlPlaneStatus) {
 	*out = *in
+	if in.AddonProfiles != nil {
+		in, out := &in.AddonProfiles, &out.AddonProfiles
+		*out = make([]AddonProfileStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(cluster_apiapiv1alpha3.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureManagedControlPlaneStatus.
@@ -434,6 +801,58 @@ func (in *AzureManagedMachinePoolSpec) DeepCopyInto(out *AzureManagedMachinePool
 		*out = new(int32)
 		**out = **in
 	}
+	if in.SubnetName != nil {
+		in, out := &in.SubnetName, &out.SubnetName
+		*out = new(string)
+		**out = **in
+	}
+	if in.OSType != nil {
+		in, out := &in.OSType, &out.OSType
+		*out = new(string)
+		**out = **in
+	}
+	if in.EnableAutoScaling != nil {
+		in, out := &in.EnableAutoScaling, &out.EnableAutoScaling
+		*out = new(bool)
+		**out = **in
+	}
+	if in.MinCount != nil {
+		in, out := &in.MinCount, &out.MinCount
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxCount != nil {
+		in, out := &in.MaxCount, &out.MaxCount
+		*out = new(int32)
+		**out = **in
+	}
+	if in.NodeLabels != nil {
+		in, out := &in.NodeLabels, &out.NodeLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.NodeTaints != nil {
+		in, out := &in.NodeTaints, &out.NodeTaints
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MaxPods != nil {
+		in, out := &in.MaxPods, &out.MaxPods
+		*out = new(int32)
+		**out = **in
+	}
+	if in.AvailabilityZones != nil {
+		in, out := &in.AvailabilityZones, &out.AvailabilityZones
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MaxSurge != nil {
+		in, out := &in.MaxSurge, &out.MaxSurge
+		*out = new(string)
+		**out = **in
+	}
 	if in.ProviderIDList != nil {
 		in, out := &in.ProviderIDList, &out.ProviderIDList
 		*out = make([]string, len(*in))
@@ -464,6 +883,13 @@ func (in *AzureManagedMachinePoolStatus) DeepCopyInto(out *AzureManagedMachinePo
 		*out = new(string)
 		**out = **in
 	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(cluster_apiapiv1alpha3.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureManagedMachinePoolStatus.
@@ -476,6 +902,238 @@ func (in *AzureManagedMachinePoolStatus) DeepCopy() *AzureManagedMachinePoolStat
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LoadBalancerProfile) DeepCopyInto(out *LoadBalancerProfile) {
+	*out = *in
+	if in.ManagedOutboundIPs != nil {
+		in, out := &in.ManagedOutboundIPs, &out.ManagedOutboundIPs
+		*out = new(int32)
+		**out = **in
+	}
+	if in.OutboundIPs != nil {
+		in, out := &in.OutboundIPs, &out.OutboundIPs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.OutboundIPPrefixes != nil {
+		in, out := &in.OutboundIPPrefixes, &out.OutboundIPPrefixes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AllocatedOutboundPorts != nil {
+		in, out := &in.AllocatedOutboundPorts, &out.AllocatedOutboundPorts
+		*out = new(int32)
+		**out = **in
+	}
+	if in.IdleTimeoutInMinutes != nil {
+		in, out := &in.IdleTimeoutInMinutes, &out.IdleTimeoutInMinutes
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadBalancerProfile.
+func (in *LoadBalancerProfile) DeepCopy() *LoadBalancerProfile {
+	if in == nil {
+		return nil
+	}
+	out := new(LoadBalancerProfile)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineRollingUpdateDeployment) DeepCopyInto(out *MachineRollingUpdateDeployment) {
+	*out = *in
+	if in.MaxUnavailable != nil {
+		in, out := &in.MaxUnavailable, &out.MaxUnavailable
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.MaxSurge != nil {
+		in, out := &in.MaxSurge, &out.MaxSurge
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineRollingUpdateDeployment.
+func (in *MachineRollingUpdateDeployment) DeepCopy() *MachineRollingUpdateDeployment {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineRollingUpdateDeployment)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaintenanceWindow) DeepCopyInto(out *MaintenanceWindow) {
+	*out = *in
+	if in.TimeInWeek != nil {
+		in, out := &in.TimeInWeek, &out.TimeInWeek
+		*out = make([]TimeInWeek, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.NotAllowedTime != nil {
+		in, out := &in.NotAllowedTime, &out.NotAllowedTime
+		*out = make([]TimeSpan, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaintenanceWindow.
+func (in *MaintenanceWindow) DeepCopy() *MaintenanceWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(MaintenanceWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedControlPlaneIdentity) DeepCopyInto(out *ManagedControlPlaneIdentity) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedControlPlaneIdentity.
+func (in *ManagedControlPlaneIdentity) DeepCopy() *ManagedControlPlaneIdentity {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedControlPlaneIdentity)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedControlPlaneSKU) DeepCopyInto(out *ManagedControlPlaneSKU) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedControlPlaneSKU.
+func (in *ManagedControlPlaneSKU) DeepCopy() *ManagedControlPlaneSKU {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedControlPlaneSKU)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedControlPlaneSubnet) DeepCopyInto(out *ManagedControlPlaneSubnet) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedControlPlaneSubnet.
+func (in *ManagedControlPlaneSubnet) DeepCopy() *ManagedControlPlaneSubnet {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedControlPlaneSubnet)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedControlPlaneVirtualNetwork) DeepCopyInto(out *ManagedControlPlaneVirtualNetwork) {
+	*out = *in
+	out.Subnet = in.Subnet
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedControlPlaneVirtualNetwork.
+func (in *ManagedControlPlaneVirtualNetwork) DeepCopy() *ManagedControlPlaneVirtualNetwork {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedControlPlaneVirtualNetwork)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedControlPlaneWindowsProfile) DeepCopyInto(out *ManagedControlPlaneWindowsProfile) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedControlPlaneWindowsProfile.
+func (in *ManagedControlPlaneWindowsProfile) DeepCopy() *ManagedControlPlaneWindowsProfile {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedControlPlaneWindowsProfile)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NATGatewayProfile) DeepCopyInto(out *NATGatewayProfile) {
+	*out = *in
+	if in.ManagedOutboundIPs != nil {
+		in, out := &in.ManagedOutboundIPs, &out.ManagedOutboundIPs
+		*out = new(int32)
+		**out = **in
+	}
+	if in.IdleTimeoutInMinutes != nil {
+		in, out := &in.IdleTimeoutInMinutes, &out.IdleTimeoutInMinutes
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NATGatewayProfile.
+func (in *NATGatewayProfile) DeepCopy() *NATGatewayProfile {
+	if in == nil {
+		return nil
+	}
+	out := new(NATGatewayProfile)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TimeInWeek) DeepCopyInto(out *TimeInWeek) {
+	*out = *in
+	if in.HourSlots != nil {
+		in, out := &in.HourSlots, &out.HourSlots
+		*out = make([]int32, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TimeInWeek.
+func (in *TimeInWeek) DeepCopy() *TimeInWeek {
+	if in == nil {
+		return nil
+	}
+	out := new(TimeInWeek)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TimeSpan) DeepCopyInto(out *TimeSpan) {
+	*out = *in
+	in.Start.DeepCopyInto(&out.Start)
+	in.End.DeepCopyInto(&out.End)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TimeSpan.
+func (in *TimeSpan) DeepCopy() *TimeSpan {
+	if in == nil {
+		return nil
+	}
+	out := new(TimeSpan)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VMSS) DeepCopyInto(out *VMSS) {
 	*out = *in
@@ -497,6 +1155,11 @@ func (in *VMSS) DeepCopyInto(out *VMSS) {
 		*out = make([]VMSSVM, len(*in))
 		copy(*out, *in)
 	}
+	if in.VMExtensions != nil {
+		in, out := &in.VMExtensions, &out.VMExtensions
+		*out = make([]apiv1alpha3.VMExtensionStatus, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VMSS.
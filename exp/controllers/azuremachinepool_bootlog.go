@@ -0,0 +1,117 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/scope"
+	"sigs.k8s.io/cluster-api-provider-azure/controllers"
+	infrav1exp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1alpha3"
+)
+
+// InstanceBootLogSecretName returns the name of the Secret holding the serial console log of an instance of an
+// AzureMachinePool, e.g. capz-mp-0-3-boot-log for the instance capz-mp-0_3.
+func InstanceBootLogSecretName(instanceName string) string {
+	return controllers.BootLogSecretName(strings.ToLower(strings.ReplaceAll(instanceName, "_", "-")))
+}
+
+// reconcileBootLogs captures the serial console log of the provisioned instances which have not become a Node within
+// the boot log capture timeout into Secrets owned by the AzureMachinePool, so that bootstrap failures can be debugged
+// without access to the instances or the Azure portal. It returns when to check the instances still within the timeout.
+func (r *AzureMachinePoolReconciler) reconcileBootLogs(ctx context.Context, machinePoolScope *scope.MachinePoolScope, ams *azureMachinePoolService, instances []infrav1exp.AzureMachinePoolInstanceStatus) (reconcile.Result, error) {
+	if r.BootLogCaptureTimeout <= 0 {
+		return reconcile.Result{}, nil
+	}
+
+	var (
+		requeueAfter time.Duration
+		errs         []error
+	)
+	for _, instance := range instances {
+		if instance.NodeRef != nil || instance.ProvisioningState == nil || *instance.ProvisioningState != infrav1.VMStateSucceeded {
+			continue
+		}
+		remaining, err := r.reconcileInstanceBootLog(ctx, machinePoolScope, ams, instance)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if remaining > 0 && (requeueAfter == 0 || remaining < requeueAfter) {
+			requeueAfter = remaining
+		}
+	}
+	return reconcile.Result{RequeueAfter: requeueAfter}, kerrors.NewAggregate(errs)
+}
+
+// reconcileInstanceBootLog captures the serial console log of an instance without a Node once the boot log capture
+// timeout has elapsed since it was provisioned, and returns the time remaining until then otherwise.
+func (r *AzureMachinePoolReconciler) reconcileInstanceBootLog(ctx context.Context, machinePoolScope *scope.MachinePoolScope, ams *azureMachinePoolService, instance infrav1exp.AzureMachinePoolInstanceStatus) (time.Duration, error) {
+	secretName := InstanceBootLogSecretName(instance.InstanceName)
+	err := r.Get(ctx, client.ObjectKey{Namespace: machinePoolScope.AzureMachinePool.Namespace, Name: secretName}, &corev1.Secret{})
+	if err == nil {
+		// the serial console log has already been captured
+		return 0, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return 0, errors.Wrapf(err, "failed to get boot log secret %s/%s", machinePoolScope.AzureMachinePool.Namespace, secretName)
+	}
+
+	bootDiagnostics, err := ams.getInstanceBootDiagnostics(ctx, instance.InstanceID)
+	if err != nil {
+		return 0, err
+	}
+	if bootDiagnostics.ProvisionedTime.IsZero() {
+		return 0, nil
+	}
+	if remaining := time.Until(bootDiagnostics.ProvisionedTime.Add(r.BootLogCaptureTimeout)); remaining > 0 {
+		return remaining, nil
+	}
+
+	machinePoolScope.Info("Instance has not become a Node, capturing serial console log", "instance", instance.InstanceID, "timeout", r.BootLogCaptureTimeout)
+	bootLog, err := ams.getSerialConsoleLog(ctx, bootDiagnostics.SerialConsoleLogURI)
+	if err != nil {
+		r.Recorder.Eventf(machinePoolScope.AzureMachinePool, corev1.EventTypeWarning, "BootLogCaptureFailed", "Failed to capture serial console log of instance %s: %v", instance.InstanceID, err)
+		return 0, errors.Wrapf(err, "failed to capture serial console log of instance %s", instance.InstanceID)
+	}
+
+	owner := metav1.OwnerReference{
+		APIVersion: infrav1exp.GroupVersion.String(),
+		Kind:       "AzureMachinePool",
+		Name:       machinePoolScope.AzureMachinePool.Name,
+		UID:        machinePoolScope.AzureMachinePool.UID,
+	}
+	secret := controllers.NewBootLogSecret(secretName, machinePoolScope.AzureMachinePool.Namespace, machinePoolScope.ClusterName(), owner, bootLog)
+	if err := r.Create(ctx, secret); err != nil && !apierrors.IsAlreadyExists(err) {
+		return 0, errors.Wrapf(err, "failed to create boot log secret %s/%s", machinePoolScope.AzureMachinePool.Namespace, secretName)
+	}
+
+	r.Recorder.Eventf(machinePoolScope.AzureMachinePool, corev1.EventTypeWarning, "BootLogCaptured",
+		"Instance %s did not become a Node within %s, serial console log captured to Secret %s", instance.InstanceID, r.BootLogCaptureTimeout, secretName)
+	return 0, nil
+}
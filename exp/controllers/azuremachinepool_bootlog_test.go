@@ -0,0 +1,198 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2021-07-01/compute"
+	"github.com/Azure/azure-sdk-for-go/services/storage/mgmt/2019-06-01/storage"
+	"github.com/Azure/go-autorest/autorest/date"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/golang/mock/gomock"
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/scope"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/scalesets/mock_scalesets"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/storageaccounts/mock_storageaccounts"
+	"sigs.k8s.io/cluster-api-provider-azure/controllers"
+	infrav1exp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1alpha3"
+)
+
+func TestInstanceBootLogSecretName(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	g.Expect(InstanceBootLogSecretName("capz-mp-0_3")).To(gomega.Equal("capz-mp-0-3-boot-log"))
+	g.Expect(InstanceBootLogSecretName("capz-mp-0-3")).To(gomega.Equal("capz-mp-0-3-boot-log"))
+}
+
+func TestReconcileBootLogs(t *testing.T) {
+	const blobURI = "https://mystorageaccount.blob.core.windows.net/bootdiagnostics-capzmp0/capz-mp-0_1.serialconsole.log"
+
+	succeeded := infrav1.VMStateSucceeded
+	creating := infrav1.VMStateCreating
+
+	testcases := []struct {
+		name           string
+		instance       infrav1exp.AzureMachinePoolInstanceStatus
+		expectRequeue  bool
+		expectCaptured bool
+		expect         func(vmss *mock_scalesets.MockClientMockRecorder, sa *mock_storageaccounts.MockClientMockRecorder)
+	}{
+		{
+			name: "instance became a node",
+			instance: infrav1exp.AzureMachinePoolInstanceStatus{
+				InstanceID:        "1",
+				InstanceName:      "capz-mp-0_1",
+				ProvisioningState: &succeeded,
+				NodeRef:           &corev1.ObjectReference{Name: "capz-mp-0000001"},
+			},
+			expect: func(vmss *mock_scalesets.MockClientMockRecorder, sa *mock_storageaccounts.MockClientMockRecorder) {
+			},
+		},
+		{
+			name: "instance is being provisioned",
+			instance: infrav1exp.AzureMachinePoolInstanceStatus{
+				InstanceID:        "1",
+				InstanceName:      "capz-mp-0_1",
+				ProvisioningState: &creating,
+			},
+			expect: func(vmss *mock_scalesets.MockClientMockRecorder, sa *mock_storageaccounts.MockClientMockRecorder) {
+			},
+		},
+		{
+			name: "timeout not reached yet",
+			instance: infrav1exp.AzureMachinePoolInstanceStatus{
+				InstanceID:        "1",
+				InstanceName:      "capz-mp-0_1",
+				ProvisioningState: &succeeded,
+			},
+			expectRequeue: true,
+			expect: func(vmss *mock_scalesets.MockClientMockRecorder, sa *mock_storageaccounts.MockClientMockRecorder) {
+				vmss.GetInstanceView(gomock.Any(), "my-rg", "capz-mp-0", "1").Return(compute.VirtualMachineScaleSetVMInstanceView{
+					Statuses: &[]compute.InstanceViewStatus{
+						{Code: to.StringPtr("ProvisioningState/succeeded"), Time: &date.Time{Time: time.Now()}},
+					},
+				}, nil)
+			},
+		},
+		{
+			name: "capture serial console log after timeout",
+			instance: infrav1exp.AzureMachinePoolInstanceStatus{
+				InstanceID:        "1",
+				InstanceName:      "capz-mp-0_1",
+				ProvisioningState: &succeeded,
+			},
+			expectCaptured: true,
+			expect: func(vmss *mock_scalesets.MockClientMockRecorder, sa *mock_storageaccounts.MockClientMockRecorder) {
+				vmss.GetInstanceView(gomock.Any(), "my-rg", "capz-mp-0", "1").Return(compute.VirtualMachineScaleSetVMInstanceView{
+					Statuses: &[]compute.InstanceViewStatus{
+						{Code: to.StringPtr("ProvisioningState/succeeded"), Time: &date.Time{Time: time.Now().Add(-time.Hour)}},
+					},
+					BootDiagnostics: &compute.BootDiagnosticsInstanceView{
+						SerialConsoleLogBlobURI: to.StringPtr(blobURI),
+					},
+				}, nil)
+				sa.List(gomock.Any()).Return([]storage.Account{
+					{
+						ID:   to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Storage/storageAccounts/mystorageaccount"),
+						Name: to.StringPtr("mystorageaccount"),
+					},
+				}, nil)
+				sa.ListServiceSAS(gomock.Any(), "my-rg", "mystorageaccount", gomock.Any()).Return("sig=abc", nil)
+				sa.GetBlob(gomock.Any(), blobURI+"?sig=abc").Return([]byte("kubeadm join failed"), nil)
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := gomega.NewWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			vmssMock := mock_scalesets.NewMockClient(mockCtrl)
+			saMock := mock_storageaccounts.NewMockClient(mockCtrl)
+			tc.expect(vmssMock.EXPECT(), saMock.EXPECT())
+
+			scheme := runtime.NewScheme()
+			g.Expect(clientgoscheme.AddToScheme(scheme)).To(gomega.Succeed())
+			c := fake.NewFakeClientWithScheme(scheme)
+
+			clusterScope := &scope.ClusterScope{
+				Cluster: &clusterv1.Cluster{
+					ObjectMeta: metav1.ObjectMeta{Name: "my-cluster"},
+				},
+				AzureCluster: &infrav1.AzureCluster{
+					Spec: infrav1.AzureClusterSpec{ResourceGroup: "my-rg"},
+				},
+			}
+			machinePoolScope := &scope.MachinePoolScope{
+				Logger:       log.Log,
+				ClusterScope: clusterScope,
+				AzureMachinePool: &infrav1exp.AzureMachinePool{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "capz-mp-0",
+						Namespace: "default",
+					},
+				},
+			}
+			ams := newAzureMachinePoolService(machinePoolScope, clusterScope)
+			ams.virtualMachinesScaleSetSvc.Client = vmssMock
+			ams.storageAccountsSvc.Client = saMock
+			r := &AzureMachinePoolReconciler{
+				Client:                c,
+				Log:                   log.Log,
+				Recorder:              record.NewFakeRecorder(10),
+				BootLogCaptureTimeout: controllers.DefaultBootLogCaptureTimeout,
+			}
+
+			instances := []infrav1exp.AzureMachinePoolInstanceStatus{tc.instance}
+			result, err := r.reconcileBootLogs(context.TODO(), machinePoolScope, ams, instances)
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+			g.Expect(result.RequeueAfter > 0).To(gomega.Equal(tc.expectRequeue))
+
+			secret := &corev1.Secret{}
+			err = c.Get(context.TODO(), client.ObjectKey{Namespace: "default", Name: "capz-mp-0-1-boot-log"}, secret)
+			if tc.expectCaptured {
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+				g.Expect(string(secret.Data[controllers.BootLogSecretKey])).To(gomega.Equal("kubeadm join failed"))
+				g.Expect(secret.OwnerReferences).To(gomega.HaveLen(1))
+				g.Expect(secret.OwnerReferences[0].Kind).To(gomega.Equal("AzureMachinePool"))
+				g.Expect(secret.Labels).To(gomega.HaveKeyWithValue(clusterv1.ClusterLabelName, "my-cluster"))
+
+				// the serial console log is only captured once
+				_, err = r.reconcileBootLogs(context.TODO(), machinePoolScope, ams, instances)
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+			} else {
+				g.Expect(err).To(gomega.HaveOccurred())
+			}
+		})
+	}
+}
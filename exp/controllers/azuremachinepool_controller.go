@@ -26,6 +26,7 @@ import (
 	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -47,8 +48,11 @@ import (
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
 	azure "sigs.k8s.io/cluster-api-provider-azure/cloud"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/converters"
 	"sigs.k8s.io/cluster-api-provider-azure/cloud/scope"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/availabilityzones"
 	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/scalesets"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/storageaccounts"
 	"sigs.k8s.io/cluster-api-provider-azure/controllers"
 	infrav1exp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1alpha3"
 	"sigs.k8s.io/cluster-api-provider-azure/util/reconciler"
@@ -62,6 +66,9 @@ type (
 		Scheme           *runtime.Scheme
 		Recorder         record.EventRecorder
 		ReconcileTimeout time.Duration
+		// BootLogCaptureTimeout is the duration after which the serial console log of an instance of an
+		// AzureMachinePool with boot diagnostics enabled is captured if it has not become a Node. Zero disables it.
+		BootLogCaptureTimeout time.Duration
 	}
 
 	// azureMachinePoolService provides structure and behavior around the operations needed to reconcile Azure Machine Pools
@@ -69,6 +76,8 @@ type (
 		machinePoolScope           *scope.MachinePoolScope
 		clusterScope               *scope.ClusterScope
 		virtualMachinesScaleSetSvc *scalesets.Service
+		storageAccountsSvc         *storageaccounts.Service
+		availabilityZonesSvc       azure.GetterService
 	}
 
 	// annotationReaderWriter provides an interface to read and write annotations
@@ -130,7 +139,7 @@ func (r *AzureMachinePoolReconciler) SetupWithManager(mgr ctrl.Manager, options
 // +kubebuilder:rbac:groups=exp.infrastructure.cluster.x-k8s.io,resources=azuremachinepools/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=exp.cluster.x-k8s.io,resources=machinepools;machinepools/status,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=events,verbs=get;list;watch;create;update;patch
-// +kubebuilder:rbac:groups="",resources=secrets;,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets;,verbs=get;list;watch;create
 
 func (r *AzureMachinePoolReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result, reterr error) {
 	ctx, cancel := context.WithTimeout(context.Background(), reconciler.DefaultedLoopTimeout(r.ReconcileTimeout))
@@ -188,6 +197,7 @@ func (r *AzureMachinePoolReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result,
 	// Create the cluster scope
 	clusterScope, err := scope.NewClusterScope(scope.ClusterScopeParams{
 		Client:       r.Client,
+		Context:      ctx,
 		Logger:       logger,
 		Cluster:      cluster,
 		AzureCluster: azureCluster,
@@ -252,12 +262,63 @@ func (r *AzureMachinePoolReconciler) reconcileNormal(ctx context.Context, machin
 
 	ams := newAzureMachinePoolService(machinePoolScope, clusterScope)
 
+	// Delete the instances marked for deletion, and choose the instances removed on scale-in rather than letting
+	// Azure pick them when the capacity of the scale set is reduced.
+	deleted, err := r.reconcileInstanceDeletion(ctx, machinePoolScope, clusterScope, ams)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
 	// Get or create the virtual machine.
 	vmss, err := ams.CreateOrUpdate(ctx)
 	if err != nil {
 		return reconcile.Result{}, err
 	}
 
+	nodes, nodesErr := r.getWorkloadClusterNodes(ctx, clusterScope)
+	if nodesErr != nil {
+		machinePoolScope.V(2).Info("Unable to get the Nodes of the workload cluster", "error", nodesErr.Error())
+	}
+	previousInstances := machinePoolScope.AzureMachinePool.Status.Instances
+	instances := getInstanceStatuses(vmss, nodes)
+
+	// Replace the instances which do not run the latest model of the scale set.
+	rollingUpdate := getRollingUpdate(machinePoolScope.AzureMachinePool.Spec.Strategy)
+	if rollingUpdate != nil && hasOutdatedInstances(instances) {
+		if nodesErr != nil {
+			return reconcile.Result{}, errors.Wrap(nodesErr, "failed to get the Nodes of the workload cluster to replace outdated instances")
+		}
+		outdated, err := ams.outdatedInstancesToDelete(rollingUpdate, instances)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		if len(outdated) > 0 {
+			if err := r.deleteInstances(ctx, machinePoolScope, clusterScope, ams, instances, outdated); err != nil {
+				return reconcile.Result{}, errors.Wrap(err, "failed to delete outdated instances")
+			}
+			vmss.Instances = withoutInstances(vmss.Instances, outdated)
+			instances = getInstanceStatuses(vmss, nodes)
+			deleted = append(deleted, outdated...)
+		}
+	}
+	r.recordInstanceHealthEvents(machinePoolScope.AzureMachinePool, previousInstances, instances, deleted)
+
+	// Drain the Nodes of the instances Azure is about to delete while the terminate notification delays their deletion.
+	terminateNotifications := machinePoolScope.AzureMachinePool.Spec.TerminateNotificationTimeout != nil
+	if terminateNotifications && nodesErr == nil {
+		r.drainTerminatingInstances(ctx, machinePoolScope, clusterScope, ams, previousInstances, instances)
+	}
+	machinePoolScope.AzureMachinePool.Status.Instances = instances
+
+	// Capture the serial console log of the instances which failed to become a Node.
+	var result reconcile.Result
+	if machinePoolScope.AzureMachinePool.Spec.Template.BootDiagnostics != nil && nodesErr == nil {
+		result, err = r.reconcileBootLogs(ctx, machinePoolScope, ams, instances)
+		if err != nil {
+			return reconcile.Result{}, errors.Wrap(err, "failed to capture boot logs")
+		}
+	}
+
 	// Make sure Spec.ProviderID is always set.
 	machinePoolScope.AzureMachinePool.Spec.ProviderID = fmt.Sprintf("azure:///%s", vmss.ID)
 	providerIDList := make([]string, len(vmss.Instances))
@@ -271,6 +332,12 @@ func (r *AzureMachinePoolReconciler) reconcileNormal(ctx context.Context, machin
 	machinePoolScope.AzureMachinePool.Spec.ProviderIDList = providerIDList
 	machinePoolScope.AzureMachinePool.Status.ProvisioningState = &vmss.State
 	machinePoolScope.AzureMachinePool.Status.Replicas = int32(len(providerIDList))
+	machinePoolScope.AzureMachinePool.Status.VMExtensions = vmss.VMExtensions
+	for _, extension := range vmss.VMExtensions {
+		if extension.ProvisioningState == string(infrav1.VMStateFailed) {
+			r.Recorder.Eventf(machinePoolScope.AzureMachinePool, corev1.EventTypeWarning, "FailedVMExtension", "VM extension %s is in failed state", extension.Name)
+		}
+	}
 	machinePoolScope.SetAnnotation("cluster-api-provider-azure", "true")
 
 	switch vmss.State {
@@ -290,7 +357,50 @@ func (r *AzureMachinePoolReconciler) reconcileNormal(ctx context.Context, machin
 		return reconcile.Result{}, errors.Errorf("failed to ensure tags: %+v", err)
 	}
 
-	return reconcile.Result{}, nil
+	if rollingUpdate != nil && hasOutdatedInstances(instances) {
+		machinePoolScope.Info("Replacing outdated instances", "requeueAfter", rollingUpdateRequeueInterval)
+		return reconcile.Result{RequeueAfter: rollingUpdateRequeueInterval}, nil
+	}
+
+	if terminateNotifications {
+		// terminate notifications are only delivered to the instances themselves, so poll the scale set for instances
+		// being deleted often enough to drain them before the notification times out
+		return reconcile.Result{RequeueAfter: terminateNotificationPollInterval}, nil
+	}
+
+	return result, nil
+}
+
+// reconcileInstanceDeletion drains and deletes the instances of an existing scale set which are marked for deletion
+// or exceed its desired capacity, and returns their IDs.
+func (r *AzureMachinePoolReconciler) reconcileInstanceDeletion(ctx context.Context, machinePoolScope *scope.MachinePoolScope, clusterScope *scope.ClusterScope, ams *azureMachinePoolService) ([]string, error) {
+	vmss, err := ams.Get(ctx)
+	if err != nil || vmss == nil {
+		return nil, err
+	}
+
+	nodes, err := r.getWorkloadClusterNodes(ctx, clusterScope)
+	if err != nil {
+		// without the Nodes, instances can neither be drained nor chosen, so leave scale-in to Azure
+		machinePoolScope.V(2).Info("Unable to get the Nodes of the workload cluster, skipping instance deletion", "error", err.Error())
+		return nil, nil
+	}
+
+	capacity, err := ams.getCapacity(ams.desiredReplicas())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get VMSS capacity")
+	}
+
+	instances := getInstanceStatuses(vmss, nodes)
+	instanceIDs := selectInstancesToDelete(instances, getInstancesMarkedForDeletion(instances, nodes), int(capacity), machinePoolScope.AzureMachinePool.Spec.ScaleInPolicy)
+	if len(instanceIDs) == 0 {
+		return nil, nil
+	}
+	machinePoolScope.Info("Deleting instances", "instances", instanceIDs)
+	if err := r.deleteInstances(ctx, machinePoolScope, clusterScope, ams, instances, instanceIDs); err != nil {
+		return nil, err
+	}
+	return instanceIDs, nil
 }
 
 func (r *AzureMachinePoolReconciler) reconcileDelete(ctx context.Context, machinePoolScope *scope.MachinePoolScope, clusterScope *scope.ClusterScope) (_ reconcile.Result, reterr error) {
@@ -485,15 +595,14 @@ func newAzureMachinePoolService(machinePoolScope *scope.MachinePoolScope, cluste
 		machinePoolScope:           machinePoolScope,
 		clusterScope:               clusterScope,
 		virtualMachinesScaleSetSvc: scalesets.NewService(machinePoolScope),
+		storageAccountsSvc:         storageaccounts.NewService(clusterScope),
+		availabilityZonesSvc:       availabilityzones.NewService(clusterScope),
 	}
 }
 
 func (s *azureMachinePoolService) CreateOrUpdate(ctx context.Context) (*infrav1exp.VMSS, error) {
 	ampSpec := s.machinePoolScope.AzureMachinePool.Spec
-	var replicas int64
-	if s.machinePoolScope.MachinePool.Spec.Replicas != nil {
-		replicas = int64(to.Int32(s.machinePoolScope.MachinePool.Spec.Replicas))
-	}
+	replicas := s.desiredReplicas()
 
 	decoded, err := base64.StdEncoding.DecodeString(ampSpec.Template.SSHPublicKey)
 	if err != nil {
@@ -510,28 +619,68 @@ func (s *azureMachinePoolService) CreateOrUpdate(ctx context.Context) (*infrav1e
 		return nil, errors.Wrap(err, "failed to retrieve bootstrap data")
 	}
 
+	bootDiagnosticsStorageURI, err := s.reconcileBootDiagnosticsStorage(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to reconcile boot diagnostics storage account")
+	}
+
+	vmExtensions, err := s.getVMExtensions(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get VM extensions")
+	}
+
+	capacity, err := s.getCapacity(replicas)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get VMSS capacity")
+	}
+
+	if err := s.validateZones(ctx); err != nil {
+		return nil, err
+	}
+
+	healthProbe, automaticRepairsGracePeriod := s.getHealth()
+
 	vmssSpec := &scalesets.Spec{
-		Name:                   s.machinePoolScope.Name(),
-		ResourceGroup:          s.clusterScope.ResourceGroup(),
-		Location:               s.clusterScope.Location(),
-		ClusterName:            s.clusterScope.ClusterName(),
-		MachinePoolName:        s.machinePoolScope.Name(),
-		Sku:                    ampSpec.Template.VMSize,
-		Capacity:               replicas,
-		SSHKeyData:             string(decoded),
-		Image:                  image,
-		OSDisk:                 ampSpec.Template.OSDisk,
-		CustomData:             bootstrapData,
-		AdditionalTags:         s.machinePoolScope.AdditionalTags(),
-		SubnetID:               s.clusterScope.AzureCluster.Spec.NetworkSpec.GetNodeSubnet().ID,
-		PublicLoadBalancerName: s.clusterScope.ClusterName(),
-		AcceleratedNetworking:  ampSpec.Template.AcceleratedNetworking,
+		Name:                        s.machinePoolScope.Name(),
+		ResourceGroup:               s.clusterScope.ResourceGroup(),
+		Location:                    s.clusterScope.Location(),
+		ClusterName:                 s.clusterScope.ClusterName(),
+		MachinePoolName:             s.machinePoolScope.Name(),
+		Sku:                         ampSpec.Template.VMSize,
+		Capacity:                    capacity,
+		SSHKeyData:                  string(decoded),
+		Image:                       image,
+		OSDisk:                      ampSpec.Template.OSDisk,
+		CustomData:                  bootstrapData,
+		AdditionalTags:              s.machinePoolScope.AdditionalTags(),
+		SubnetID:                    s.clusterScope.AzureCluster.Spec.NetworkSpec.GetNodeSubnet().ID,
+		PublicLoadBalancerName:      s.clusterScope.ClusterName(),
+		AcceleratedNetworking:       ampSpec.Template.AcceleratedNetworking,
+		BootDiagnosticsStorageURI:   bootDiagnosticsStorageURI,
+		VMExtensions:                vmExtensions,
+		SubscriptionID:              s.clusterScope.SubscriptionID(),
+		Identity:                    ampSpec.Template.Identity,
+		UserAssignedIdentities:      ampSpec.Template.UserAssignedIdentities,
+		SystemAssignedIdentityRoles: ampSpec.Template.SystemAssignedIdentityRoles,
+		RoleAssignmentIDs:           s.machinePoolScope.AzureMachinePool.Status.RoleAssignmentIDs,
+		Zones:                       ampSpec.Zones,
+		ZoneBalance:                 ampSpec.ZoneBalance,
+		SpotVMOptions:               ampSpec.Template.SpotVMOptions,
+		ScaleInPolicy:               ampSpec.ScaleInPolicy,
+		Overprovision:               ampSpec.Overprovision,
+		HealthProbe:                 healthProbe,
+		AutomaticRepairsGracePeriod: automaticRepairsGracePeriod,
+		OrchestrationMode:           ampSpec.OrchestrationMode,
+	}
+	if ampSpec.TerminateNotificationTimeout != nil {
+		vmssSpec.TerminateNotificationTimeout = &ampSpec.TerminateNotificationTimeout.Duration
 	}
 
 	err = s.virtualMachinesScaleSetSvc.Reconcile(ctx, vmssSpec)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to create or get machine")
 	}
+	s.machinePoolScope.AzureMachinePool.Status.RoleAssignmentIDs = scalesets.RoleAssignmentIDs(vmssSpec)
 
 	newVMSS, err := s.virtualMachinesScaleSetSvc.Get(ctx, vmssSpec)
 	if err != nil {
@@ -556,11 +705,206 @@ func (s *azureMachinePoolService) CreateOrUpdate(ctx context.Context) (*infrav1e
 	return newVMSS, nil
 }
 
+// getHealth returns the health endpoint probed on the instances and the grace period of automatic repairs, applying
+// the defaults of the AzureMachinePool health monitoring.
+func (s *azureMachinePoolService) getHealth() (*scalesets.HealthProbeSpec, *time.Duration) {
+	health := s.machinePoolScope.AzureMachinePool.Spec.Health
+	if health == nil {
+		return nil, nil
+	}
+	health = health.DeepCopy()
+	health.Default()
+
+	healthProbe := &scalesets.HealthProbeSpec{
+		Port:        *health.Port,
+		RequestPath: health.RequestPath,
+	}
+	if !health.AutomaticRepairs {
+		return healthProbe, nil
+	}
+	return healthProbe, &health.GracePeriod.Duration
+}
+
+// validateZones checks that the zones of the AzureMachinePool are supported by its VM size in the location.
+func (s *azureMachinePoolService) validateZones(ctx context.Context) error {
+	zones := s.machinePoolScope.AzureMachinePool.Spec.Zones
+	if len(zones) == 0 {
+		return nil
+	}
+
+	vmSize := s.machinePoolScope.AzureMachinePool.Spec.Template.VMSize
+	location := s.clusterScope.Location()
+	zonesInterface, err := s.availabilityZonesSvc.Get(ctx, &availabilityzones.Spec{
+		VMSize: to.StringPtr(vmSize),
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to check availability zones for %s in region %s", vmSize, location)
+	}
+	var supported []string
+	if zonesInterface != nil {
+		var ok bool
+		if supported, ok = zonesInterface.([]string); !ok {
+			return errors.New("availability zones Get returned invalid interface")
+		}
+	}
+
+	var unsupported []string
+	for _, zone := range zones {
+		if !containsString(supported, zone) {
+			unsupported = append(unsupported, zone)
+		}
+	}
+	if len(unsupported) > 0 {
+		return errors.Errorf("availability zones %v are not supported for VM size %s in region %s, supported zones are %v", unsupported, vmSize, location, supported)
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// reconcileBootDiagnosticsStorage returns the blob endpoint of the storage account used for boot diagnostics,
+// creating the provider managed storage account if the AzureMachinePool does not specify a storage account.
+func (s *azureMachinePoolService) reconcileBootDiagnosticsStorage(ctx context.Context) (string, error) {
+	bootDiagnostics := s.machinePoolScope.AzureMachinePool.Spec.Template.BootDiagnostics
+	if bootDiagnostics == nil {
+		return "", nil
+	}
+	if bootDiagnostics.StorageAccountURI != "" {
+		return bootDiagnostics.StorageAccountURI, nil
+	}
+
+	accountSpec := &storageaccounts.Spec{
+		Name: azure.GenerateBootDiagnosticsStorageAccountName(s.clusterScope.SubscriptionID(), s.clusterScope.ResourceGroup(), s.clusterScope.ClusterName()),
+	}
+	if err := s.storageAccountsSvc.Reconcile(ctx, accountSpec); err != nil {
+		return "", err
+	}
+	return s.storageAccountsSvc.GetBlobEndpoint(ctx, accountSpec)
+}
+
+// getCapacity returns the capacity of the scale set, adding the surge instances of the rolling update while
+// outdated instances are being replaced.
+func (s *azureMachinePoolService) getCapacity(replicas int64) (int64, error) {
+	amp := s.machinePoolScope.AzureMachinePool
+	rollingUpdate := getRollingUpdate(amp.Spec.Strategy)
+	if rollingUpdate == nil || !hasOutdatedInstances(amp.Status.Instances) {
+		return replicas, nil
+	}
+	surge, err := maxSurge(rollingUpdate, int(replicas))
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get max surge")
+	}
+	return replicas + int64(surge), nil
+}
+
+// outdatedInstancesToDelete returns the IDs of the outdated instances of the scale set which can be deleted without
+// making more instances unavailable than allowed by the rolling update. The deleted instances are replaced with
+// instances running the latest model on the next reconciliation.
+func (s *azureMachinePoolService) outdatedInstancesToDelete(rollingUpdate *infrav1exp.MachineRollingUpdateDeployment, instances []infrav1exp.AzureMachinePoolInstanceStatus) ([]string, error) {
+	unavailable, err := maxUnavailable(rollingUpdate, int(s.desiredReplicas()))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get max unavailable")
+	}
+	return selectOutdatedInstancesToDelete(instances, int(s.desiredReplicas())-unavailable), nil
+}
+
+// desiredReplicas returns the number of replicas of the MachinePool.
+func (s *azureMachinePoolService) desiredReplicas() int64 {
+	if s.machinePoolScope.MachinePool.Spec.Replicas == nil {
+		return 0
+	}
+	return int64(*s.machinePoolScope.MachinePool.Spec.Replicas)
+}
+
+// deleteInstances deletes the instances of the scale set with the provided IDs.
+func (s *azureMachinePoolService) deleteInstances(ctx context.Context, instanceIDs []string) error {
+	vmssSpec := &scalesets.Spec{
+		Name:              s.machinePoolScope.Name(),
+		ResourceGroup:     s.clusterScope.ResourceGroup(),
+		OrchestrationMode: s.machinePoolScope.AzureMachinePool.Spec.OrchestrationMode,
+	}
+	return s.virtualMachinesScaleSetSvc.DeleteInstances(ctx, vmssSpec, instanceIDs)
+}
+
+// approveTermination approves the Scheduled Event delaying the deletion of the instance of the scale set with the
+// provided ID.
+func (s *azureMachinePoolService) approveTermination(ctx context.Context, instanceID string) error {
+	vmssSpec := &scalesets.Spec{
+		Name:              s.machinePoolScope.Name(),
+		ResourceGroup:     s.clusterScope.ResourceGroup(),
+		OSDisk:            s.machinePoolScope.AzureMachinePool.Spec.Template.OSDisk,
+		OrchestrationMode: s.machinePoolScope.AzureMachinePool.Spec.OrchestrationMode,
+	}
+	return s.virtualMachinesScaleSetSvc.ApproveTermination(ctx, vmssSpec, instanceID)
+}
+
+// getInstanceBootDiagnostics returns the boot diagnostics data of the instance of the scale set with the provided ID.
+func (s *azureMachinePoolService) getInstanceBootDiagnostics(ctx context.Context, instanceID string) (*scalesets.InstanceBootDiagnostics, error) {
+	vmssSpec := &scalesets.Spec{
+		Name:              s.machinePoolScope.Name(),
+		ResourceGroup:     s.clusterScope.ResourceGroup(),
+		OrchestrationMode: s.machinePoolScope.AzureMachinePool.Spec.OrchestrationMode,
+	}
+	return s.virtualMachinesScaleSetSvc.GetInstanceBootDiagnostics(ctx, vmssSpec, instanceID)
+}
+
+// getSerialConsoleLog downloads the serial console log of an instance of the scale set from its blob.
+func (s *azureMachinePoolService) getSerialConsoleLog(ctx context.Context, blobURI string) ([]byte, error) {
+	if blobURI == "" {
+		return nil, errors.New("boot diagnostics serial console log is not available")
+	}
+	return s.storageAccountsSvc.GetBlob(ctx, blobURI)
+}
+
+// getVMExtensions returns the VM extensions of the scale set. It returns nil when no extensions are declared
+// or were previously installed, so the extensions of the scale set are left untouched.
+func (s *azureMachinePoolService) getVMExtensions(ctx context.Context) ([]scalesets.ExtensionSpec, error) {
+	amp := s.machinePoolScope.AzureMachinePool
+	if len(amp.Spec.Template.VMExtensions) == 0 && len(amp.Status.VMExtensions) == 0 {
+		return nil, nil
+	}
+
+	extensions := make([]scalesets.ExtensionSpec, 0, len(amp.Spec.Template.VMExtensions))
+	for _, extension := range amp.Spec.Template.VMExtensions {
+		protectedSettings, err := s.machinePoolScope.GetVMExtensionProtectedSettings(ctx, extension)
+		if err != nil {
+			return nil, err
+		}
+
+		settings, err := converters.ExtensionSettingsToMap(extension.Settings)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid settings of VM extension %s", extension.Name)
+		}
+		extensions = append(extensions, scalesets.ExtensionSpec{
+			Name:              extension.Name,
+			Publisher:         extension.Publisher,
+			Type:              extension.Type,
+			Version:           extension.Version,
+			Settings:          settings,
+			ProtectedSettings: protectedSettings,
+		})
+	}
+	return extensions, nil
+}
+
 // Delete reconciles all the services in pre determined order
 func (s *azureMachinePoolService) Delete(ctx context.Context) error {
+	template := s.machinePoolScope.AzureMachinePool.Spec.Template
 	vmssSpec := &scalesets.Spec{
-		Name:          s.machinePoolScope.Name(),
-		ResourceGroup: s.clusterScope.ResourceGroup(),
+		Name:                        s.machinePoolScope.Name(),
+		ResourceGroup:               s.clusterScope.ResourceGroup(),
+		SubscriptionID:              s.clusterScope.SubscriptionID(),
+		Identity:                    template.Identity,
+		SystemAssignedIdentityRoles: template.SystemAssignedIdentityRoles,
+		RoleAssignmentIDs:           s.machinePoolScope.AzureMachinePool.Status.RoleAssignmentIDs,
+		OrchestrationMode:           s.machinePoolScope.AzureMachinePool.Spec.OrchestrationMode,
 	}
 
 	err := s.virtualMachinesScaleSetSvc.Delete(ctx, vmssSpec)
@@ -574,7 +918,8 @@ func (s *azureMachinePoolService) Delete(ctx context.Context) error {
 // Get fetches a VMSS if it exists
 func (s *azureMachinePoolService) Get(ctx context.Context) (*infrav1exp.VMSS, error) {
 	vmssSpec := &scalesets.Spec{
-		Name: s.machinePoolScope.Name(),
+		Name:          s.machinePoolScope.Name(),
+		ResourceGroup: s.clusterScope.ResourceGroup(),
 	}
 
 	vmss, err := s.virtualMachinesScaleSetSvc.Get(ctx, vmssSpec)
@@ -0,0 +1,350 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+	"sigs.k8s.io/cluster-api/controllers/noderefutil"
+	"sigs.k8s.io/cluster-api/controllers/remote"
+	kubedrain "sigs.k8s.io/cluster-api/third_party/kubernetes-drain"
+	"sigs.k8s.io/cluster-api/util"
+
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/scope"
+	infrav1exp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1alpha3"
+)
+
+const (
+	// drainTimeout is the time after which the drain of a Node is retried on the next reconciliation, so that other
+	// AzureMachinePools can be reconciled in the meantime.
+	drainTimeout = 20 * time.Second
+
+	// terminateNotificationPollInterval is the interval at which a scale set with terminate notifications is checked
+	// for instances being deleted by Azure. It is well below the minimum terminate notification timeout.
+	terminateNotificationPollInterval = time.Minute
+)
+
+// getInstanceStatuses returns the status of the instances of the scale set, including a reference to their Node and
+// its readiness and Kubernetes version.
+func getInstanceStatuses(vmss *infrav1exp.VMSS, nodes []corev1.Node) []infrav1exp.AzureMachinePoolInstanceStatus {
+	nodesByProviderID := make(map[string]corev1.Node, len(nodes))
+	for _, node := range nodes {
+		nodesByProviderID[normalizeProviderID(node.Spec.ProviderID)] = node
+	}
+
+	instances := make([]infrav1exp.AzureMachinePoolInstanceStatus, 0, len(vmss.Instances))
+	for _, vm := range vmss.Instances {
+		state := vm.State
+		instance := infrav1exp.AzureMachinePoolInstanceStatus{
+			ProviderID:         fmt.Sprintf("azure:///%s", vm.ID),
+			InstanceID:         vm.InstanceID,
+			InstanceName:       vm.Name,
+			ProvisioningState:  &state,
+			LatestModelApplied: vm.LatestModelApplied,
+			HealthState:        vm.HealthState,
+		}
+		if node, ok := nodesByProviderID[normalizeProviderID(vm.ID)]; ok {
+			instance.NodeRef = &corev1.ObjectReference{
+				APIVersion: corev1.SchemeGroupVersion.String(),
+				Kind:       "Node",
+				Name:       node.Name,
+				UID:        node.UID,
+			}
+			instance.Version = node.Status.NodeInfo.KubeletVersion
+			instance.NodeReady = util.IsNodeReady(&node)
+		}
+		instances = append(instances, instance)
+	}
+	return instances
+}
+
+// getInstanceHealthChanges returns the IDs of the instances which became unhealthy since the previous status, and
+// of the unhealthy instances which were removed from the scale set without being deleted by the controller, which
+// happens when Azure repairs them.
+func getInstanceHealthChanges(previous, current []infrav1exp.AzureMachinePoolInstanceStatus, deleted []string) (unhealthy, repaired []string) {
+	previouslyUnhealthy := make(map[string]bool)
+	for _, instance := range previous {
+		if instance.HealthState == infrav1exp.UnhealthyInstanceHealthState {
+			previouslyUnhealthy[instance.InstanceID] = true
+		}
+	}
+
+	remaining := make(map[string]bool, len(current))
+	for _, instance := range current {
+		remaining[instance.InstanceID] = true
+		if instance.HealthState == infrav1exp.UnhealthyInstanceHealthState && !previouslyUnhealthy[instance.InstanceID] {
+			unhealthy = append(unhealthy, instance.InstanceID)
+		}
+	}
+	for _, id := range deleted {
+		remaining[id] = true
+	}
+
+	for _, instance := range previous {
+		if previouslyUnhealthy[instance.InstanceID] && !remaining[instance.InstanceID] {
+			repaired = append(repaired, instance.InstanceID)
+		}
+	}
+	return unhealthy, repaired
+}
+
+// recordInstanceHealthEvents emits events for the instances which became unhealthy or were repaired by Azure.
+func (r *AzureMachinePoolReconciler) recordInstanceHealthEvents(amp *infrav1exp.AzureMachinePool, previous, current []infrav1exp.AzureMachinePoolInstanceStatus, deleted []string) {
+	unhealthy, repaired := getInstanceHealthChanges(previous, current, deleted)
+	for _, id := range unhealthy {
+		r.Recorder.Eventf(amp, corev1.EventTypeWarning, "UnhealthyInstance", "Instance %s is unhealthy", id)
+	}
+	for _, id := range repaired {
+		r.Recorder.Eventf(amp, corev1.EventTypeNormal, "RepairedInstance", "Unhealthy instance %s was replaced by automatic repairs", id)
+	}
+}
+
+// getInstancesMarkedForDeletion returns the IDs of the instances whose Node has the delete instance annotation.
+func getInstancesMarkedForDeletion(instances []infrav1exp.AzureMachinePoolInstanceStatus, nodes []corev1.Node) map[string]bool {
+	markedNodes := make(map[string]bool)
+	for _, node := range nodes {
+		if _, ok := node.Annotations[infrav1exp.DeleteInstanceAnnotation]; ok {
+			markedNodes[node.Name] = true
+		}
+	}
+
+	marked := make(map[string]bool)
+	for _, instance := range instances {
+		if instance.NodeRef != nil && markedNodes[instance.NodeRef.Name] {
+			marked[instance.InstanceID] = true
+		}
+	}
+	return marked
+}
+
+// selectInstancesToDelete returns the IDs of the instances to delete so that the scale set has no more than capacity
+// instances. Instances marked for deletion are always deleted. On scale-in, the remaining instances are deleted in
+// order of preference: unavailable instances first, then instances not running the latest model, then the oldest
+// instances with the OldestVM scale-in policy or the newest instances otherwise.
+func selectInstancesToDelete(instances []infrav1exp.AzureMachinePoolInstanceStatus, marked map[string]bool, capacity int, policy infrav1exp.ScaleInPolicy) []string {
+	var instanceIDs []string
+	var candidates []infrav1exp.AzureMachinePoolInstanceStatus
+	for _, instance := range instances {
+		if marked[instance.InstanceID] {
+			instanceIDs = append(instanceIDs, instance.InstanceID)
+		} else {
+			candidates = append(candidates, instance)
+		}
+	}
+
+	scaleIn := len(candidates) - capacity
+	if scaleIn <= 0 {
+		return instanceIDs
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if isAvailable(candidates[i]) != isAvailable(candidates[j]) {
+			return !isAvailable(candidates[i])
+		}
+		if candidates[i].LatestModelApplied != candidates[j].LatestModelApplied {
+			return !candidates[i].LatestModelApplied
+		}
+		if policy == infrav1exp.OldestVMScaleInPolicy {
+			return instanceIDNumber(candidates[i].InstanceID) < instanceIDNumber(candidates[j].InstanceID)
+		}
+		return instanceIDNumber(candidates[i].InstanceID) > instanceIDNumber(candidates[j].InstanceID)
+	})
+	for _, instance := range candidates[:scaleIn] {
+		instanceIDs = append(instanceIDs, instance.InstanceID)
+	}
+	return instanceIDs
+}
+
+// instanceIDNumber returns the number of a scale set instance ID, which increases as instances are created. The
+// instances of a scale set in the Flexible orchestration mode are identified by the name of their VM, which ends with
+// the number.
+func instanceIDNumber(instanceID string) int {
+	number, err := strconv.Atoi(instanceID[strings.LastIndex(instanceID, "-")+1:])
+	if err != nil {
+		return -1
+	}
+	return number
+}
+
+// withoutInstances returns the instances of the scale set without the instances with the provided IDs.
+func withoutInstances(instances []infrav1exp.VMSSVM, instanceIDs []string) []infrav1exp.VMSSVM {
+	deleted := make(map[string]bool, len(instanceIDs))
+	for _, id := range instanceIDs {
+		deleted[id] = true
+	}
+	remaining := make([]infrav1exp.VMSSVM, 0, len(instances))
+	for _, instance := range instances {
+		if !deleted[instance.InstanceID] {
+			remaining = append(remaining, instance)
+		}
+	}
+	return remaining
+}
+
+// normalizeProviderID returns a provider ID which can be compared to the provider ID of a Node, as the casing of the
+// resource group of the ID of a scale set instance is not consistent with the one used by the cloud provider.
+func normalizeProviderID(providerID string) string {
+	return strings.ToLower(strings.TrimLeft(strings.TrimPrefix(providerID, "azure://"), "/"))
+}
+
+// getWorkloadClusterNodes lists the Nodes of the workload cluster.
+func (r *AzureMachinePoolReconciler) getWorkloadClusterNodes(ctx context.Context, clusterScope *scope.ClusterScope) ([]corev1.Node, error) {
+	workloadClient, err := remote.NewClusterClient(ctx, r.Client, util.ObjectKey(clusterScope.Cluster), r.Scheme)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create workload cluster client")
+	}
+	nodes := &corev1.NodeList{}
+	if err := workloadClient.List(ctx, nodes); err != nil {
+		return nil, errors.Wrap(err, "failed to list Nodes of the workload cluster")
+	}
+	return nodes.Items, nil
+}
+
+// deleteInstances cordons and drains the Nodes of the instances with the provided IDs before deleting the instances.
+func (r *AzureMachinePoolReconciler) deleteInstances(ctx context.Context, machinePoolScope *scope.MachinePoolScope, clusterScope *scope.ClusterScope, ams *azureMachinePoolService, instances []infrav1exp.AzureMachinePoolInstanceStatus, instanceIDs []string) error {
+	toDelete := make(map[string]bool, len(instanceIDs))
+	for _, id := range instanceIDs {
+		toDelete[id] = true
+	}
+	for _, instance := range instances {
+		if !toDelete[instance.InstanceID] || instance.NodeRef == nil {
+			continue
+		}
+		if err := r.drainNode(ctx, clusterScope, instance.NodeRef.Name); err != nil {
+			r.Recorder.Eventf(machinePoolScope.AzureMachinePool, corev1.EventTypeWarning, "FailedDrainNode", "Failed to drain Node %s of instance %s: %v", instance.NodeRef.Name, instance.InstanceID, err)
+			return err
+		}
+	}
+
+	if err := ams.deleteInstances(ctx, instanceIDs); err != nil {
+		r.Recorder.Eventf(machinePoolScope.AzureMachinePool, corev1.EventTypeWarning, "FailedDeleteInstances", "Failed to delete instances %s: %v", strings.Join(instanceIDs, ", "), err)
+		return err
+	}
+	r.Recorder.Eventf(machinePoolScope.AzureMachinePool, corev1.EventTypeNormal, "DeletedInstances", "Deleted instances %s", strings.Join(instanceIDs, ", "))
+	return nil
+}
+
+// getTerminatingInstances returns the instances with a Node which are being deleted.
+func getTerminatingInstances(instances []infrav1exp.AzureMachinePoolInstanceStatus) []infrav1exp.AzureMachinePoolInstanceStatus {
+	var terminating []infrav1exp.AzureMachinePoolInstanceStatus
+	for _, instance := range instances {
+		if instance.NodeRef != nil && isDeleting(instance) {
+			terminating = append(terminating, instance)
+		}
+	}
+	return terminating
+}
+
+// drainTerminatingInstances cordons and drains the Nodes of the instances being deleted by Azure, for example on
+// automatic repairs or on a scale-in not initiated by the controller, then approves the Scheduled Events delaying
+// their deletion. Failures are reported as events, as the instances are deleted whether their Nodes are drained or
+// not once the terminate notification times out. Instances whose termination was approved are skipped.
+func (r *AzureMachinePoolReconciler) drainTerminatingInstances(ctx context.Context, machinePoolScope *scope.MachinePoolScope, clusterScope *scope.ClusterScope, ams *azureMachinePoolService, previous, instances []infrav1exp.AzureMachinePoolInstanceStatus) {
+	approved := make(map[string]bool)
+	for _, instance := range previous {
+		if instance.TerminationApproved {
+			approved[instance.InstanceID] = true
+		}
+	}
+
+	for _, instance := range getTerminatingInstances(instances) {
+		if approved[instance.InstanceID] {
+			continue
+		}
+		if err := r.drainNode(ctx, clusterScope, instance.NodeRef.Name); err != nil {
+			r.Recorder.Eventf(machinePoolScope.AzureMachinePool, corev1.EventTypeWarning, "FailedDrainNode", "Failed to drain Node %s of terminating instance %s: %v", instance.NodeRef.Name, instance.InstanceID, err)
+			continue
+		}
+		machinePoolScope.V(2).Info("Drained Node of terminating instance", "instance", instance.InstanceID, "node", instance.NodeRef.Name)
+		if err := ams.approveTermination(ctx, instance.InstanceID); err != nil {
+			r.Recorder.Eventf(machinePoolScope.AzureMachinePool, corev1.EventTypeWarning, "FailedApproveTermination", "Failed to approve termination of instance %s: %v", instance.InstanceID, err)
+			continue
+		}
+		approved[instance.InstanceID] = true
+	}
+
+	for i := range instances {
+		instances[i].TerminationApproved = approved[instances[i].InstanceID]
+	}
+}
+
+// drainNode cordons the Node and evicts its pods.
+func (r *AzureMachinePoolReconciler) drainNode(ctx context.Context, clusterScope *scope.ClusterScope, nodeName string) error {
+	restConfig, err := remote.RESTConfig(ctx, r.Client, util.ObjectKey(clusterScope.Cluster))
+	if err != nil {
+		return errors.Wrap(err, "failed to get workload cluster REST config")
+	}
+	kubeClient, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return errors.Wrap(err, "failed to create workload cluster client")
+	}
+
+	node, err := kubeClient.CoreV1().Nodes().Get(nodeName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		// the Node has already been deleted
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to get Node %s", nodeName)
+	}
+
+	drainer := &kubedrain.Helper{
+		Client:              kubeClient,
+		Force:               true,
+		IgnoreAllDaemonSets: true,
+		DeleteLocalData:     true,
+		GracePeriodSeconds:  -1,
+		Timeout:             drainTimeout,
+		Out:                 writer{klog.Info},
+		ErrOut:              writer{klog.Error},
+	}
+	if noderefutil.IsNodeUnreachable(node) {
+		// pods of an unreachable Node never terminate, so stop waiting for them after some time
+		drainer.SkipWaitForDeleteTimeoutSeconds = 60 * 5
+	}
+
+	if err := kubedrain.RunCordonOrUncordon(drainer, node, true); err != nil {
+		return errors.Wrapf(err, "failed to cordon Node %s", nodeName)
+	}
+	if err := kubedrain.RunNodeDrain(drainer, nodeName); err != nil {
+		return errors.Wrapf(err, "failed to drain Node %s", nodeName)
+	}
+	klog.V(2).Infof("drained Node %s", nodeName)
+	return nil
+}
+
+// writer implements io.Writer interface as a pass-through for klog.
+type writer struct {
+	logFunc func(args ...interface{})
+}
+
+// Write passes string(p) into writer's logFunc and always returns len(p)
+func (w writer) Write(p []byte) (n int, err error) {
+	w.logFunc(string(p))
+	return len(p), nil
+}
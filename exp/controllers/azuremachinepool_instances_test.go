@@ -0,0 +1,262 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
+	infrav1exp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1alpha3"
+)
+
+func TestGetInstanceStatuses(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	vmss := &infrav1exp.VMSS{
+		Instances: []infrav1exp.VMSSVM{
+			{
+				ID:                 "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachineScaleSets/pool/virtualMachines/0",
+				InstanceID:         "0",
+				Name:               "pool_0",
+				State:              infrav1.VMStateSucceeded,
+				LatestModelApplied: true,
+				HealthState:        "Healthy",
+			},
+			{
+				ID:         "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachineScaleSets/pool/virtualMachines/1",
+				InstanceID: "1",
+				Name:       "pool_1",
+				State:      infrav1.VMStateCreating,
+			},
+		},
+	}
+	nodes := []corev1.Node{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "pool000000",
+				UID:  "node-uid",
+			},
+			Spec: corev1.NodeSpec{
+				ProviderID: "azure:///subscriptions/123/resourceGroups/MY-RG/providers/Microsoft.Compute/virtualMachineScaleSets/pool/virtualMachines/0",
+			},
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{
+					{
+						Type:   corev1.NodeReady,
+						Status: corev1.ConditionTrue,
+					},
+				},
+				NodeInfo: corev1.NodeSystemInfo{
+					KubeletVersion: "v1.18.3",
+				},
+			},
+		},
+	}
+
+	instances := getInstanceStatuses(vmss, nodes)
+	g.Expect(instances).To(gomega.HaveLen(2))
+	g.Expect(instances[0].ProviderID).To(gomega.Equal("azure:////subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Compute/virtualMachineScaleSets/pool/virtualMachines/0"))
+	g.Expect(instances[0].InstanceName).To(gomega.Equal("pool_0"))
+	g.Expect(instances[0].LatestModelApplied).To(gomega.BeTrue())
+	g.Expect(instances[0].NodeReady).To(gomega.BeTrue())
+	g.Expect(instances[0].Version).To(gomega.Equal("v1.18.3"))
+	g.Expect(instances[0].HealthState).To(gomega.Equal("Healthy"))
+	g.Expect(instances[0].NodeRef).To(gomega.Equal(&corev1.ObjectReference{
+		APIVersion: "v1",
+		Kind:       "Node",
+		Name:       "pool000000",
+		UID:        "node-uid",
+	}))
+	g.Expect(*instances[1].ProvisioningState).To(gomega.Equal(infrav1.VMStateCreating))
+	g.Expect(instances[1].NodeReady).To(gomega.BeFalse())
+	g.Expect(instances[1].NodeRef).To(gomega.BeNil())
+	g.Expect(hasOutdatedInstances(instances)).To(gomega.BeTrue())
+
+	remaining := withoutInstances(vmss.Instances, []string{"1"})
+	g.Expect(remaining).To(gomega.HaveLen(1))
+	g.Expect(remaining[0].InstanceID).To(gomega.Equal("0"))
+}
+
+func TestGetInstancesMarkedForDeletion(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	instances := []infrav1exp.AzureMachinePoolInstanceStatus{
+		{
+			InstanceID: "0",
+			NodeRef:    &corev1.ObjectReference{Name: "pool000000"},
+		},
+		{
+			InstanceID: "1",
+			NodeRef:    &corev1.ObjectReference{Name: "pool000001"},
+		},
+		{
+			InstanceID: "2",
+		},
+	}
+	nodes := []corev1.Node{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "pool000000",
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "pool000001",
+				Annotations: map[string]string{
+					infrav1exp.DeleteInstanceAnnotation: "",
+				},
+			},
+		},
+	}
+
+	g.Expect(getInstancesMarkedForDeletion(instances, nodes)).To(gomega.Equal(map[string]bool{"1": true}))
+}
+
+func TestSelectInstancesToDelete(t *testing.T) {
+	succeeded := infrav1.VMStateSucceeded
+	failed := infrav1.VMStateFailed
+	instance := func(id string, state *infrav1.VMState, latest, ready bool) infrav1exp.AzureMachinePoolInstanceStatus {
+		return infrav1exp.AzureMachinePoolInstanceStatus{
+			InstanceID:         id,
+			ProvisioningState:  state,
+			LatestModelApplied: latest,
+			NodeReady:          ready,
+		}
+	}
+
+	testcases := []struct {
+		name      string
+		instances []infrav1exp.AzureMachinePoolInstanceStatus
+		marked    map[string]bool
+		capacity  int
+		policy    infrav1exp.ScaleInPolicy
+		expected  []string
+	}{
+		{
+			name: "nothing to delete when at capacity",
+			instances: []infrav1exp.AzureMachinePoolInstanceStatus{
+				instance("0", &succeeded, true, true),
+				instance("1", &succeeded, true, true),
+			},
+			capacity: 2,
+			expected: nil,
+		},
+		{
+			name: "deletes marked instances",
+			instances: []infrav1exp.AzureMachinePoolInstanceStatus{
+				instance("0", &succeeded, true, true),
+				instance("1", &succeeded, true, true),
+			},
+			marked:   map[string]bool{"0": true},
+			capacity: 2,
+			expected: []string{"0"},
+		},
+		{
+			name: "deletes the newest instances on scale-in",
+			instances: []infrav1exp.AzureMachinePoolInstanceStatus{
+				instance("2", &succeeded, true, true),
+				instance("10", &succeeded, true, true),
+				instance("5", &succeeded, true, true),
+			},
+			capacity: 1,
+			expected: []string{"10", "5"},
+		},
+		{
+			name: "deletes the oldest instances on scale-in with the OldestVM policy",
+			instances: []infrav1exp.AzureMachinePoolInstanceStatus{
+				instance("2", &succeeded, true, true),
+				instance("10", &succeeded, true, true),
+				instance("5", &succeeded, true, true),
+			},
+			capacity: 1,
+			policy:   infrav1exp.OldestVMScaleInPolicy,
+			expected: []string{"2", "5"},
+		},
+		{
+			name: "prefers unavailable then outdated instances on scale-in",
+			instances: []infrav1exp.AzureMachinePoolInstanceStatus{
+				instance("0", &succeeded, false, true),
+				instance("1", &succeeded, true, true),
+				instance("2", &failed, true, false),
+				instance("3", &succeeded, true, true),
+			},
+			capacity: 2,
+			expected: []string{"2", "0"},
+		},
+		{
+			name: "marked instances count towards scale-in",
+			instances: []infrav1exp.AzureMachinePoolInstanceStatus{
+				instance("0", &succeeded, true, true),
+				instance("1", &succeeded, true, true),
+				instance("2", &succeeded, true, true),
+			},
+			marked:   map[string]bool{"0": true},
+			capacity: 1,
+			expected: []string{"0", "2"},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := gomega.NewWithT(t)
+			g.Expect(selectInstancesToDelete(tc.instances, tc.marked, tc.capacity, tc.policy)).To(gomega.Equal(tc.expected))
+		})
+	}
+}
+
+func TestGetInstanceHealthChanges(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	previous := []infrav1exp.AzureMachinePoolInstanceStatus{
+		{InstanceID: "0", HealthState: "Healthy"},
+		{InstanceID: "1", HealthState: "Unhealthy"},
+		{InstanceID: "2", HealthState: "Unhealthy"},
+		{InstanceID: "3", HealthState: "Unhealthy"},
+		{InstanceID: "4", HealthState: "Unhealthy"},
+	}
+	current := []infrav1exp.AzureMachinePoolInstanceStatus{
+		{InstanceID: "0", HealthState: "Unhealthy"},
+		{InstanceID: "1", HealthState: "Unhealthy"},
+		{InstanceID: "5", HealthState: "Initializing"},
+	}
+
+	unhealthy, repaired := getInstanceHealthChanges(previous, current, []string{"3"})
+	g.Expect(unhealthy).To(gomega.Equal([]string{"0"}))
+	g.Expect(repaired).To(gomega.Equal([]string{"2", "4"}))
+}
+
+func TestGetTerminatingInstances(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	succeeded := infrav1.VMStateSucceeded
+	deleting := infrav1.VMStateDeleting
+	instances := []infrav1exp.AzureMachinePoolInstanceStatus{
+		{InstanceID: "0", ProvisioningState: &succeeded, NodeRef: &corev1.ObjectReference{Name: "pool000000"}},
+		{InstanceID: "1", ProvisioningState: &deleting, NodeRef: &corev1.ObjectReference{Name: "pool000001"}},
+		{InstanceID: "2", ProvisioningState: &deleting},
+		{InstanceID: "3"},
+	}
+
+	terminating := getTerminatingInstances(instances)
+	g.Expect(terminating).To(gomega.HaveLen(1))
+	g.Expect(terminating[0].InstanceID).To(gomega.Equal("1"))
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
+	infrav1exp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1alpha3"
+)
+
+// rollingUpdateRequeueInterval is the interval at which an AzureMachinePool is reconciled while outdated instances
+// are being replaced, as the readiness of the Nodes of the workload cluster is not watched.
+const rollingUpdateRequeueInterval = 30 * time.Second
+
+// getRollingUpdate returns the rolling update parameters of the deployment strategy with their default values, or
+// nil if the instances of the AzureMachinePool are not replaced by the controller.
+func getRollingUpdate(strategy infrav1exp.AzureMachinePoolDeploymentStrategy) *infrav1exp.MachineRollingUpdateDeployment {
+	defaulted := strategy.DeepCopy()
+	defaulted.Default()
+	if defaulted.Type != infrav1exp.RollingUpdateAzureMachinePoolDeploymentStrategyType {
+		return nil
+	}
+	return defaulted.RollingUpdate
+}
+
+// maxSurge returns the number of instances that can be created above the desired number of instances.
+func maxSurge(rollingUpdate *infrav1exp.MachineRollingUpdateDeployment, desired int) (int, error) {
+	return intstr.GetValueFromIntOrPercent(rollingUpdate.MaxSurge, desired, true)
+}
+
+// maxUnavailable returns the number of desired instances that can be unavailable during a rolling update.
+func maxUnavailable(rollingUpdate *infrav1exp.MachineRollingUpdateDeployment, desired int) (int, error) {
+	return intstr.GetValueFromIntOrPercent(rollingUpdate.MaxUnavailable, desired, false)
+}
+
+// hasOutdatedInstances returns true if any of the instances does not run the latest model of the scale set.
+func hasOutdatedInstances(instances []infrav1exp.AzureMachinePoolInstanceStatus) bool {
+	for _, instance := range instances {
+		if !instance.LatestModelApplied {
+			return true
+		}
+	}
+	return false
+}
+
+// isAvailable returns true if the instance is provisioned, its Node is Ready and it is not reported unhealthy.
+func isAvailable(instance infrav1exp.AzureMachinePoolInstanceStatus) bool {
+	return instance.ProvisioningState != nil && *instance.ProvisioningState == infrav1.VMStateSucceeded && instance.NodeReady &&
+		instance.HealthState != infrav1exp.UnhealthyInstanceHealthState
+}
+
+// isDeleting returns true if the instance is being deleted.
+func isDeleting(instance infrav1exp.AzureMachinePoolInstanceStatus) bool {
+	return instance.ProvisioningState != nil && *instance.ProvisioningState == infrav1.VMStateDeleting
+}
+
+// selectOutdatedInstancesToDelete returns the IDs of the outdated instances that can be deleted while keeping at least
+// minAvailable instances available. Outdated instances that are unavailable are always deleted, as deleting them does
+// not reduce the availability of the pool, unless they are already being deleted.
+func selectOutdatedInstancesToDelete(instances []infrav1exp.AzureMachinePoolInstanceStatus, minAvailable int) []string {
+	var available int
+	for _, instance := range instances {
+		if isAvailable(instance) {
+			available++
+		}
+	}
+
+	var instanceIDs []string
+	for _, instance := range instances {
+		if !instance.LatestModelApplied && !isAvailable(instance) && !isDeleting(instance) {
+			instanceIDs = append(instanceIDs, instance.InstanceID)
+		}
+	}
+	for _, instance := range instances {
+		if available <= minAvailable {
+			break
+		}
+		if !instance.LatestModelApplied && isAvailable(instance) {
+			instanceIDs = append(instanceIDs, instance.InstanceID)
+			available--
+		}
+	}
+	return instanceIDs
+}
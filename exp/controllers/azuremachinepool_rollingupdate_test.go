@@ -0,0 +1,152 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
+	infrav1exp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1alpha3"
+)
+
+func TestGetRollingUpdate(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	g.Expect(getRollingUpdate(infrav1exp.AzureMachinePoolDeploymentStrategy{})).To(gomega.BeNil())
+
+	rollingUpdate := getRollingUpdate(infrav1exp.AzureMachinePoolDeploymentStrategy{
+		Type: infrav1exp.RollingUpdateAzureMachinePoolDeploymentStrategyType,
+	})
+	g.Expect(rollingUpdate).ToNot(gomega.BeNil())
+	surge, err := maxSurge(rollingUpdate, 3)
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(surge).To(gomega.Equal(1))
+	unavailable, err := maxUnavailable(rollingUpdate, 3)
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(unavailable).To(gomega.Equal(0))
+
+	percent := intstr.FromString("50%")
+	rollingUpdate = getRollingUpdate(infrav1exp.AzureMachinePoolDeploymentStrategy{
+		Type: infrav1exp.RollingUpdateAzureMachinePoolDeploymentStrategyType,
+		RollingUpdate: &infrav1exp.MachineRollingUpdateDeployment{
+			MaxSurge:       &percent,
+			MaxUnavailable: &percent,
+		},
+	})
+	surge, err = maxSurge(rollingUpdate, 3)
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(surge).To(gomega.Equal(2))
+	unavailable, err = maxUnavailable(rollingUpdate, 3)
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(unavailable).To(gomega.Equal(1))
+
+	g.Expect(getRollingUpdate(infrav1exp.AzureMachinePoolDeploymentStrategy{
+		Type: infrav1exp.ManualAzureMachinePoolDeploymentStrategyType,
+	})).To(gomega.BeNil())
+}
+
+func TestSelectOutdatedInstancesToDelete(t *testing.T) {
+	succeeded := infrav1.VMStateSucceeded
+	creating := infrav1.VMStateCreating
+	deleting := infrav1.VMStateDeleting
+	instance := func(id string, state *infrav1.VMState, latest, ready bool) infrav1exp.AzureMachinePoolInstanceStatus {
+		return infrav1exp.AzureMachinePoolInstanceStatus{
+			InstanceID:         id,
+			ProvisioningState:  state,
+			LatestModelApplied: latest,
+			NodeReady:          ready,
+		}
+	}
+
+	testcases := []struct {
+		name         string
+		instances    []infrav1exp.AzureMachinePoolInstanceStatus
+		minAvailable int
+		expected     []string
+	}{
+		{
+			name: "nothing to delete when all instances run the latest model",
+			instances: []infrav1exp.AzureMachinePoolInstanceStatus{
+				instance("0", &succeeded, true, true),
+				instance("1", &succeeded, true, true),
+			},
+			minAvailable: 1,
+			expected:     nil,
+		},
+		{
+			name: "waits for surge instances before deleting available instances",
+			instances: []infrav1exp.AzureMachinePoolInstanceStatus{
+				instance("0", &succeeded, false, true),
+				instance("1", &succeeded, false, true),
+				instance("2", &creating, true, false),
+			},
+			minAvailable: 2,
+			expected:     nil,
+		},
+		{
+			name: "deletes outdated instances once surge instances are available",
+			instances: []infrav1exp.AzureMachinePoolInstanceStatus{
+				instance("0", &succeeded, false, true),
+				instance("1", &succeeded, false, true),
+				instance("2", &succeeded, true, true),
+			},
+			minAvailable: 2,
+			expected:     []string{"0"},
+		},
+		{
+			name: "deletes up to max unavailable outdated instances",
+			instances: []infrav1exp.AzureMachinePoolInstanceStatus{
+				instance("0", &succeeded, false, true),
+				instance("1", &succeeded, false, true),
+				instance("2", &succeeded, false, true),
+			},
+			minAvailable: 1,
+			expected:     []string{"0", "1"},
+		},
+		{
+			name: "always deletes unavailable outdated instances",
+			instances: []infrav1exp.AzureMachinePoolInstanceStatus{
+				instance("0", &succeeded, false, true),
+				instance("1", &succeeded, false, false),
+				instance("2", nil, false, false),
+			},
+			minAvailable: 1,
+			expected:     []string{"1", "2"},
+		},
+		{
+			name: "skips outdated instances that are already being deleted",
+			instances: []infrav1exp.AzureMachinePoolInstanceStatus{
+				instance("0", &succeeded, false, true),
+				instance("1", &deleting, false, false),
+				instance("2", &succeeded, true, true),
+			},
+			minAvailable: 2,
+			expected:     nil,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := gomega.NewWithT(t)
+			g.Expect(selectOutdatedInstancesToDelete(tc.instances, tc.minAvailable)).To(gomega.Equal(tc.expected))
+		})
+	}
+}
@@ -28,7 +28,6 @@ import (
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/util"
 	"sigs.k8s.io/cluster-api/util/annotations"
-	"sigs.k8s.io/cluster-api/util/patch"
 	"sigs.k8s.io/cluster-api/util/predicates"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -37,6 +36,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/scope"
 	infrav1exp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1alpha3"
 	"sigs.k8s.io/cluster-api-provider-azure/util/reconciler"
 )
@@ -70,11 +70,26 @@ func (r *AzureManagedClusterReconciler) SetupWithManager(mgr ctrl.Manager, optio
 	); err != nil {
 		return errors.Wrapf(err, "failed adding a watch for ready clusters")
 	}
+
+	// Add a watch on AzureManagedControlPlane to follow its readiness and kubelet identity.
+	azureManagedControlPlaneMapper, err := AzureManagedControlPlaneToAzureManagedClusterMapper(r.Client, r.Log)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create mapper for AzureManagedControlPlanes")
+	}
+	if err = c.Watch(
+		&source.Kind{Type: &infrav1exp.AzureManagedControlPlane{}},
+		&handler.EnqueueRequestsFromMapFunc{
+			ToRequests: azureManagedControlPlaneMapper,
+		},
+	); err != nil {
+		return errors.Wrapf(err, "failed adding a watch for AzureManagedControlPlanes")
+	}
 	return nil
 }
 
 // +kubebuilder:rbac:groups=exp.infrastructure.cluster.x-k8s.io,resources=azuremanagedclusters,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=exp.infrastructure.cluster.x-k8s.io,resources=azuremanagedclusters/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=exp.infrastructure.cluster.x-k8s.io,resources=azuremanagedcontrolplanes,verbs=get;list;watch
 // +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusters;clusters/status,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
 
@@ -123,17 +138,44 @@ func (r *AzureManagedClusterReconciler) Reconcile(req ctrl.Request) (_ ctrl.Resu
 
 	log = log.WithValues("controlPlane", controlPlaneRef.Name)
 
-	patchhelper, err := patch.NewHelper(aksCluster, r.Client)
+	// The control plane deletes the infrastructure once the AKS cluster is gone, reconciling it during teardown would
+	// recreate it.
+	if !cluster.DeletionTimestamp.IsZero() || !controlPlane.DeletionTimestamp.IsZero() {
+		log.Info("Cluster or control plane is being deleted. Won't reconcile infrastructure")
+		return reconcile.Result{}, nil
+	}
+
+	// Create the scope.
+	mcpScope, err := scope.NewManagedControlPlaneScope(scope.ManagedControlPlaneScopeParams{
+		Client:       r.Client,
+		Context:      ctx,
+		Logger:       log,
+		Cluster:      cluster,
+		ControlPlane: controlPlane,
+		InfraCluster: aksCluster,
+		PatchTarget:  aksCluster,
+	})
+	if err != nil {
+		return reconcile.Result{}, errors.Errorf("failed to create scope: %+v", err)
+	}
+
+	// The resource group and virtual network are needed before the AKS cluster can be created in them.
+	aksReconciler := newAzureManagedClusterReconciler(mcpScope)
+	if err := aksReconciler.Reconcile(ctx, mcpScope); err != nil {
+		return reconcile.Result{}, errors.Wrapf(err, "error reconciling infrastructure of AzureManagedCluster %s/%s", aksCluster.Namespace, aksCluster.Name)
+	}
+
+	// The container registries can only be attached once AKS has created the kubelet identity.
+	registriesAttached, err := aksReconciler.ReconcileContainerRegistries(ctx, mcpScope)
 	if err != nil {
-		return reconcile.Result{}, errors.Wrap(err, "failed to init patch helper")
+		return reconcile.Result{}, errors.Wrapf(err, "error attaching container registries of AzureManagedCluster %s/%s", aksCluster.Namespace, aksCluster.Name)
 	}
 
-	// Match whatever the control plane says. We should also enqueue
-	// requests from control plane to infra cluster to keep this accurate
-	aksCluster.Status.Ready = controlPlane.Status.Ready
+	// Match whatever the control plane says, the control plane watch keeps this accurate.
+	aksCluster.Status.Ready = controlPlane.Status.Ready && registriesAttached
 	aksCluster.Spec.ControlPlaneEndpoint = controlPlane.Spec.ControlPlaneEndpoint
 
-	if err := patchhelper.Patch(ctx, aksCluster); err != nil {
+	if err := mcpScope.PatchObject(ctx); err != nil {
 		return reconcile.Result{}, err
 	}
 
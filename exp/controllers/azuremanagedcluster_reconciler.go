@@ -0,0 +1,226 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/pkg/errors"
+	azure "sigs.k8s.io/cluster-api-provider-azure/cloud"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/converters"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/scope"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/groups"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/roleassignments"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/subnets"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/virtualnetworks"
+	infrav1exp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1alpha3"
+)
+
+// azureManagedClusterReconciler are list of services required by the managed cluster controller
+type azureManagedClusterReconciler struct {
+	groupsSvc          azure.OldService
+	vnetSvc            *virtualnetworks.Service
+	subnetsSvc         azure.OldService
+	roleAssignmentsSvc *roleassignments.Service
+}
+
+// newAzureManagedClusterReconciler populates all the services based on input scope
+func newAzureManagedClusterReconciler(scope *scope.ManagedControlPlaneScope) *azureManagedClusterReconciler {
+	return &azureManagedClusterReconciler{
+		groupsSvc:          groups.NewService(scope),
+		vnetSvc:            virtualnetworks.NewService(scope),
+		subnetsSvc:         subnets.NewService(scope),
+		roleAssignmentsSvc: roleassignments.NewService(scope),
+	}
+}
+
+// Reconcile reconciles the resource group of the AKS cluster, and the virtual network and subnets of the AKS cluster
+// if the AzureManagedControlPlane has a virtual network.
+func (r *azureManagedClusterReconciler) Reconcile(ctx context.Context, scope *scope.ManagedControlPlaneScope) error {
+	if err := r.groupsSvc.Reconcile(ctx, nil); err != nil {
+		return errors.Wrapf(err, "failed to reconcile resource group %s", scope.ResourceGroup())
+	}
+
+	if scope.Vnet() == nil {
+		if scope.InfraCluster != nil && len(scope.InfraCluster.Spec.Subnets) > 0 {
+			return errors.Errorf("AzureManagedCluster %s has subnets, but AzureManagedControlPlane %s has no virtual network", scope.InfraCluster.Name, scope.ControlPlane.Name)
+		}
+		return nil
+	}
+
+	vnetSpec := &virtualnetworks.Spec{
+		ResourceGroup: scope.Vnet().ResourceGroup,
+		Name:          scope.Vnet().Name,
+		CIDR:          scope.Vnet().CidrBlock,
+	}
+	if err := r.vnetSvc.Reconcile(ctx, vnetSpec); err != nil {
+		return errors.Wrapf(err, "failed to reconcile virtual network %s", vnetSpec.Name)
+	}
+
+	subnetSpec := &subnets.Spec{
+		Name:     scope.NodeSubnet().Name,
+		CIDR:     scope.NodeSubnet().CidrBlock,
+		VnetName: scope.Vnet().Name,
+		Role:     scope.NodeSubnet().Role,
+	}
+	if err := r.subnetsSvc.Reconcile(ctx, subnetSpec); err != nil {
+		return errors.Wrapf(err, "failed to reconcile subnet %s", subnetSpec.Name)
+	}
+
+	for _, subnet := range getAdditionalSubnets(scope) {
+		subnetSpec := &subnets.Spec{
+			Name:     subnet.Name,
+			CIDR:     subnet.CIDRBlock,
+			VnetName: scope.Vnet().Name,
+		}
+		if err := r.subnetsSvc.Reconcile(ctx, subnetSpec); err != nil {
+			return errors.Wrapf(err, "failed to reconcile subnet %s", subnetSpec.Name)
+		}
+	}
+
+	return nil
+}
+
+// ReconcileContainerRegistries assigns the kubelet identity of the AKS cluster the AcrPull role on the container
+// registries of the AzureManagedCluster, unless it already has it, and removes it from registries that are no longer
+// listed. It returns false while the kubelet identity isn't known yet.
+func (r *azureManagedClusterReconciler) ReconcileContainerRegistries(ctx context.Context, scope *scope.ManagedControlPlaneScope) (bool, error) {
+	infraCluster := scope.InfraCluster
+	principalID := scope.ControlPlane.Status.KubeletIdentityObjectID
+	if len(infraCluster.Spec.ContainerRegistryIDs) > 0 && principalID == "" {
+		return false, nil
+	}
+
+	clusterID := azure.ManagedClusterID(scope.SubscriptionID(), scope.ResourceGroup(), scope.ControlPlane.Name)
+	listed := make(map[string]bool, len(infraCluster.Spec.ContainerRegistryIDs))
+	for _, registryID := range infraCluster.Spec.ContainerRegistryIDs {
+		listed[registryID] = true
+		roleSpec, err := roleassignments.SpecForContainerRegistry(clusterID, registryID, principalID)
+		if err != nil {
+			return false, err
+		}
+		if err := r.roleAssignmentsSvc.ReconcileGrant(ctx, &roleSpec); err != nil {
+			return false, errors.Wrapf(err, "failed to attach container registry %s", registryID)
+		}
+	}
+
+	for _, registryID := range infraCluster.Status.AttachedContainerRegistryIDs {
+		if listed[registryID] {
+			continue
+		}
+		roleSpec, err := roleassignments.SpecForContainerRegistry(clusterID, registryID, principalID)
+		if err != nil {
+			return false, err
+		}
+		if err := r.roleAssignmentsSvc.Delete(ctx, &roleSpec); err != nil {
+			return false, errors.Wrapf(err, "failed to detach container registry %s", registryID)
+		}
+	}
+
+	infraCluster.Status.AttachedContainerRegistryIDs = append([]string{}, infraCluster.Spec.ContainerRegistryIDs...)
+	return true, nil
+}
+
+// getAdditionalSubnets returns the subnets of the AzureManagedCluster besides the node subnet of the control plane.
+func getAdditionalSubnets(scope *scope.ManagedControlPlaneScope) []infrav1exp.ManagedControlPlaneSubnet {
+	if scope.InfraCluster == nil {
+		return nil
+	}
+	var additional []infrav1exp.ManagedControlPlaneSubnet
+	for _, subnet := range scope.InfraCluster.Spec.Subnets {
+		if subnet.Name != scope.NodeSubnet().Name {
+			additional = append(additional, subnet)
+		}
+	}
+	return additional
+}
+
+// Delete detaches the container registries of the AKS cluster, and deletes its virtual network, subnets and resource
+// group if they were created by the provider. It must only be called once the AKS cluster is deleted, since AKS
+// doesn't release a subnet that is in use.
+func (r *azureManagedClusterReconciler) Delete(ctx context.Context, scope *scope.ManagedControlPlaneScope) error {
+	if scope.InfraCluster != nil {
+		clusterID := azure.ManagedClusterID(scope.SubscriptionID(), scope.ResourceGroup(), scope.ControlPlane.Name)
+		for _, registryID := range scope.InfraCluster.Status.AttachedContainerRegistryIDs {
+			roleSpec, err := roleassignments.SpecForContainerRegistry(clusterID, registryID, "")
+			if err != nil {
+				return err
+			}
+			if err := r.roleAssignmentsSvc.Delete(ctx, &roleSpec); err != nil {
+				return errors.Wrapf(err, "failed to detach container registry %s", registryID)
+			}
+		}
+	}
+
+	if err := r.deleteNetwork(ctx, scope); err != nil {
+		return err
+	}
+
+	if err := r.groupsSvc.Delete(ctx, nil); err != nil {
+		return errors.Wrapf(err, "failed to delete resource group %s", scope.ResourceGroup())
+	}
+
+	return nil
+}
+
+// deleteNetwork deletes the virtual network and subnets of the AKS cluster, if they were created by the provider.
+func (r *azureManagedClusterReconciler) deleteNetwork(ctx context.Context, scope *scope.ManagedControlPlaneScope) error {
+	if scope.Vnet() == nil {
+		return nil
+	}
+
+	// The virtual network is only deleted if the provider created it, which is recorded in its tags.
+	vnet, err := r.vnetSvc.Client.Get(ctx, scope.Vnet().ResourceGroup, scope.Vnet().Name)
+	if err != nil {
+		if azure.ResourceNotFound(err) {
+			// already deleted
+			return nil
+		}
+		return errors.Wrapf(err, "failed to get virtual network %s", scope.Vnet().Name)
+	}
+	scope.Vnet().ID = to.String(vnet.ID)
+	scope.Vnet().Tags = converters.MapToTags(vnet.Tags)
+
+	for _, subnet := range getAdditionalSubnets(scope) {
+		subnetSpec := &subnets.Spec{
+			Name:     subnet.Name,
+			VnetName: scope.Vnet().Name,
+		}
+		if err := r.subnetsSvc.Delete(ctx, subnetSpec); err != nil {
+			return errors.Wrapf(err, "failed to delete subnet %s", subnetSpec.Name)
+		}
+	}
+
+	subnetSpec := &subnets.Spec{
+		Name:     scope.NodeSubnet().Name,
+		VnetName: scope.Vnet().Name,
+	}
+	if err := r.subnetsSvc.Delete(ctx, subnetSpec); err != nil {
+		return errors.Wrapf(err, "failed to delete subnet %s", subnetSpec.Name)
+	}
+
+	vnetSpec := &virtualnetworks.Spec{
+		ResourceGroup: scope.Vnet().ResourceGroup,
+		Name:          scope.Vnet().Name,
+	}
+	if err := r.vnetSvc.Delete(ctx, vnetSpec); err != nil {
+		return errors.Wrapf(err, "failed to delete virtual network %s", vnetSpec.Name)
+	}
+
+	return nil
+}
@@ -0,0 +1,362 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/profiles/2019-03-01/authorization/mgmt/authorization"
+	"github.com/Azure/azure-sdk-for-go/services/network/mgmt/2019-06-01/network"
+	"github.com/Azure/azure-sdk-for-go/services/resources/mgmt/2019-05-01/resources"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/golang/mock/gomock"
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/klogr"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-azure/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/scope"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/groups"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/groups/mock_groups"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/roleassignments"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/roleassignments/mock_roleassignments"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/subnets"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/subnets/mock_subnets"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/virtualnetworks"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/virtualnetworks/mock_virtualnetworks"
+	infrav1exp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1alpha3"
+)
+
+const (
+	testRegistryID      = "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.ContainerRegistry/registries/myregistry"
+	testOtherRegistryID = "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.ContainerRegistry/registries/otherregistry"
+)
+
+func TestAzureManagedClusterReconcilerDelete(t *testing.T) {
+	testcases := []struct {
+		name         string
+		infraCluster *infrav1exp.AzureManagedCluster
+		expect       func(g *mock_groups.MockClientMockRecorder, v *mock_virtualnetworks.MockClientMockRecorder, s *mock_subnets.MockClientMockRecorder, ra *mock_roleassignments.MockClientMockRecorder)
+	}{
+		{
+			name: "deletes managed virtual network",
+			expect: func(g *mock_groups.MockClientMockRecorder, v *mock_virtualnetworks.MockClientMockRecorder, s *mock_subnets.MockClientMockRecorder, ra *mock_roleassignments.MockClientMockRecorder) {
+				v.Get(context.TODO(), "my-rg", "my-vnet").Return(network.VirtualNetwork{
+					ID: to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/virtualNetworks/my-vnet"),
+					Tags: map[string]*string{
+						infrav1.ClusterTagKey("my-cluster"): to.StringPtr(string(infrav1.ResourceLifecycleOwned)),
+					},
+				}, nil)
+				s.Delete(context.TODO(), "my-rg", "my-vnet", "my-subnet").Return(nil)
+				v.Delete(context.TODO(), "my-rg", "my-vnet").Return(nil)
+				g.Get(context.TODO(), "my-rg").Return(resources.Group{}, nil)
+			},
+		},
+		{
+			name: "keeps existing virtual network",
+			expect: func(g *mock_groups.MockClientMockRecorder, v *mock_virtualnetworks.MockClientMockRecorder, s *mock_subnets.MockClientMockRecorder, ra *mock_roleassignments.MockClientMockRecorder) {
+				v.Get(context.TODO(), "my-rg", "my-vnet").Return(network.VirtualNetwork{
+					ID: to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/virtualNetworks/my-vnet"),
+				}, nil)
+				g.Get(context.TODO(), "my-rg").Return(resources.Group{}, nil)
+			},
+		},
+		{
+			name: "virtual network already deleted",
+			expect: func(g *mock_groups.MockClientMockRecorder, v *mock_virtualnetworks.MockClientMockRecorder, s *mock_subnets.MockClientMockRecorder, ra *mock_roleassignments.MockClientMockRecorder) {
+				v.Get(context.TODO(), "my-rg", "my-vnet").Return(network.VirtualNetwork{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not Found"))
+				g.Get(context.TODO(), "my-rg").Return(resources.Group{}, nil)
+			},
+		},
+		{
+			name: "resource group already deleted",
+			expect: func(g *mock_groups.MockClientMockRecorder, v *mock_virtualnetworks.MockClientMockRecorder, s *mock_subnets.MockClientMockRecorder, ra *mock_roleassignments.MockClientMockRecorder) {
+				v.Get(context.TODO(), "my-rg", "my-vnet").Return(network.VirtualNetwork{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not Found"))
+				g.Get(context.TODO(), "my-rg").Return(resources.Group{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not Found"))
+			},
+		},
+		{
+			name: "deletes managed resource group, additional subnets and container registry role assignments",
+			infraCluster: &infrav1exp.AzureManagedCluster{
+				Spec: infrav1exp.AzureManagedClusterSpec{
+					Subnets: []infrav1exp.ManagedControlPlaneSubnet{
+						{Name: "my-subnet"},
+						{Name: "other-subnet"},
+					},
+				},
+				Status: infrav1exp.AzureManagedClusterStatus{
+					AttachedContainerRegistryIDs: []string{testRegistryID},
+				},
+			},
+			expect: func(g *mock_groups.MockClientMockRecorder, v *mock_virtualnetworks.MockClientMockRecorder, s *mock_subnets.MockClientMockRecorder, ra *mock_roleassignments.MockClientMockRecorder) {
+				ra.Delete(context.TODO(), testRegistryID, gomock.Any()).Return(authorization.RoleAssignment{}, nil)
+				v.Get(context.TODO(), "my-rg", "my-vnet").Return(network.VirtualNetwork{
+					ID: to.StringPtr("/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/virtualNetworks/my-vnet"),
+					Tags: map[string]*string{
+						infrav1.ClusterTagKey("my-cluster"): to.StringPtr(string(infrav1.ResourceLifecycleOwned)),
+					},
+				}, nil)
+				s.Delete(context.TODO(), "my-rg", "my-vnet", "other-subnet").Return(nil)
+				s.Delete(context.TODO(), "my-rg", "my-vnet", "my-subnet").Return(nil)
+				v.Delete(context.TODO(), "my-rg", "my-vnet").Return(nil)
+				g.Get(context.TODO(), "my-rg").Return(resources.Group{
+					Tags: map[string]*string{
+						infrav1.ClusterTagKey("my-cluster"): to.StringPtr(string(infrav1.ResourceLifecycleOwned)),
+					},
+				}, nil)
+				g.Delete(context.TODO(), "my-rg").Return(nil)
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := gomega.NewWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			groupsMock := mock_groups.NewMockClient(mockCtrl)
+			vnetMock := mock_virtualnetworks.NewMockClient(mockCtrl)
+			subnetMock := mock_subnets.NewMockClient(mockCtrl)
+			roleAssignmentsMock := mock_roleassignments.NewMockClient(mockCtrl)
+
+			tc.expect(groupsMock.EXPECT(), vnetMock.EXPECT(), subnetMock.EXPECT(), roleAssignmentsMock.EXPECT())
+
+			s := newManagedClusterTestScope(tc.infraCluster)
+			r := &azureManagedClusterReconciler{
+				groupsSvc:          &groups.Service{Scope: s, Client: groupsMock},
+				vnetSvc:            &virtualnetworks.Service{Scope: s, Client: vnetMock},
+				subnetsSvc:         &subnets.Service{Scope: s, Client: subnetMock},
+				roleAssignmentsSvc: &roleassignments.Service{Client: roleAssignmentsMock},
+			}
+
+			g.Expect(r.Delete(context.TODO(), s)).To(gomega.Succeed())
+		})
+	}
+}
+
+func TestAzureManagedClusterReconcileDuringTeardown(t *testing.T) {
+	testcases := []struct {
+		name                 string
+		deletingCluster      bool
+		deletingControlPlane bool
+	}{
+		{
+			name:            "cluster is being deleted",
+			deletingCluster: true,
+		},
+		{
+			name:                 "control plane is being deleted",
+			deletingControlPlane: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := gomega.NewWithT(t)
+
+			cluster := &clusterv1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Namespace: "default"},
+				Spec: clusterv1.ClusterSpec{
+					ControlPlaneRef: &corev1.ObjectReference{Name: "my-aks"},
+				},
+			}
+			controlPlane := &infrav1exp.AzureManagedControlPlane{
+				ObjectMeta: metav1.ObjectMeta{Name: "my-aks", Namespace: "default"},
+			}
+			now := metav1.Now()
+			if tc.deletingCluster {
+				cluster.DeletionTimestamp = &now
+			}
+			if tc.deletingControlPlane {
+				controlPlane.DeletionTimestamp = &now
+			}
+			aksCluster := &infrav1exp.AzureManagedCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "my-cluster",
+					Namespace: "default",
+					OwnerReferences: []metav1.OwnerReference{
+						{APIVersion: clusterv1.GroupVersion.String(), Kind: "Cluster", Name: "my-cluster"},
+					},
+				},
+			}
+
+			r := &AzureManagedClusterReconciler{
+				Client: fake.NewFakeClientWithScheme(newScheme(g), cluster, controlPlane, aksCluster),
+				Log:    klogr.New(),
+			}
+
+			// Creating the scope would need Azure credentials, so it must not be reached.
+			result, err := r.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "my-cluster"}})
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+			g.Expect(result).To(gomega.Equal(ctrl.Result{}))
+		})
+	}
+}
+
+func TestReconcileContainerRegistries(t *testing.T) {
+	testcases := []struct {
+		name                     string
+		kubeletIdentityObjectID  string
+		registryIDs              []string
+		attachedRegistryIDs      []string
+		expect                   func(ra *mock_roleassignments.MockClientMockRecorder)
+		expectedAttached         bool
+		expectedAttachedRegistry []string
+		expectedErr              string
+	}{
+		{
+			name:                     "no container registries",
+			expect:                   func(ra *mock_roleassignments.MockClientMockRecorder) {},
+			expectedAttached:         true,
+			expectedAttachedRegistry: []string{},
+		},
+		{
+			name:        "waits for the kubelet identity",
+			registryIDs: []string{testRegistryID},
+			expect:      func(ra *mock_roleassignments.MockClientMockRecorder) {},
+		},
+		{
+			name:                    "attaches container registry",
+			kubeletIdentityObjectID: "kubelet-object-id",
+			registryIDs:             []string{testRegistryID},
+			expect: func(ra *mock_roleassignments.MockClientMockRecorder) {
+				ra.ListForScope(context.TODO(), testRegistryID, "principalId eq 'kubelet-object-id'").Return(nil, nil)
+				ra.Get(context.TODO(), testRegistryID, gomock.Any()).Return(authorization.RoleAssignment{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not Found"))
+				ra.Create(context.TODO(), testRegistryID, gomock.Any(), authorization.RoleAssignmentCreateParameters{
+					Properties: &authorization.RoleAssignmentProperties{
+						RoleDefinitionID: to.StringPtr("/subscriptions/123/providers/Microsoft.Authorization/roleDefinitions/" + roleassignments.AcrPullRoleID),
+						PrincipalID:      to.StringPtr("kubelet-object-id"),
+					},
+				}).Return(authorization.RoleAssignment{}, nil)
+			},
+			expectedAttached:         true,
+			expectedAttachedRegistry: []string{testRegistryID},
+		},
+		{
+			name:                    "keeps AcrPull role granted outside of Cluster API",
+			kubeletIdentityObjectID: "kubelet-object-id",
+			registryIDs:             []string{testRegistryID},
+			expect: func(ra *mock_roleassignments.MockClientMockRecorder) {
+				ra.ListForScope(context.TODO(), testRegistryID, "principalId eq 'kubelet-object-id'").Return([]authorization.RoleAssignment{
+					{
+						Name: to.StringPtr("pre-granted"),
+						Properties: &authorization.RoleAssignmentPropertiesWithScope{
+							RoleDefinitionID: to.StringPtr("/subscriptions/123/providers/Microsoft.Authorization/roleDefinitions/" + roleassignments.AcrPullRoleID),
+							PrincipalID:      to.StringPtr("kubelet-object-id"),
+						},
+					},
+				}, nil)
+			},
+			expectedAttached:         true,
+			expectedAttachedRegistry: []string{testRegistryID},
+		},
+		{
+			name:                    "detaches container registry that is no longer listed",
+			kubeletIdentityObjectID: "kubelet-object-id",
+			registryIDs:             []string{testRegistryID},
+			attachedRegistryIDs:     []string{testRegistryID, testOtherRegistryID},
+			expect: func(ra *mock_roleassignments.MockClientMockRecorder) {
+				ra.ListForScope(context.TODO(), testRegistryID, "principalId eq 'kubelet-object-id'").Return(nil, nil)
+				ra.Get(context.TODO(), testRegistryID, gomock.Any()).Return(authorization.RoleAssignment{
+					Properties: &authorization.RoleAssignmentPropertiesWithScope{
+						RoleDefinitionID: to.StringPtr("/subscriptions/123/providers/Microsoft.Authorization/roleDefinitions/" + roleassignments.AcrPullRoleID),
+						PrincipalID:      to.StringPtr("kubelet-object-id"),
+					},
+				}, nil)
+				ra.Delete(context.TODO(), testOtherRegistryID, gomock.Any()).Return(authorization.RoleAssignment{}, nil)
+			},
+			expectedAttached:         true,
+			expectedAttachedRegistry: []string{testRegistryID},
+		},
+		{
+			name:                    "invalid container registry ID",
+			kubeletIdentityObjectID: "kubelet-object-id",
+			registryIDs:             []string{"myregistry"},
+			expect:                  func(ra *mock_roleassignments.MockClientMockRecorder) {},
+			expectedErr:             "invalid container registry ID myregistry",
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := gomega.NewWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			roleAssignmentsMock := mock_roleassignments.NewMockClient(mockCtrl)
+
+			tc.expect(roleAssignmentsMock.EXPECT())
+
+			s := newManagedClusterTestScope(&infrav1exp.AzureManagedCluster{
+				Spec: infrav1exp.AzureManagedClusterSpec{
+					ContainerRegistryIDs: tc.registryIDs,
+				},
+				Status: infrav1exp.AzureManagedClusterStatus{
+					AttachedContainerRegistryIDs: tc.attachedRegistryIDs,
+				},
+			})
+			s.ControlPlane.Status.KubeletIdentityObjectID = tc.kubeletIdentityObjectID
+			r := &azureManagedClusterReconciler{
+				roleAssignmentsSvc: &roleassignments.Service{Client: roleAssignmentsMock},
+			}
+
+			attached, err := r.ReconcileContainerRegistries(context.TODO(), s)
+			if tc.expectedErr != "" {
+				g.Expect(err).To(gomega.MatchError(tc.expectedErr))
+				return
+			}
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+			g.Expect(attached).To(gomega.Equal(tc.expectedAttached))
+			if tc.expectedAttached {
+				g.Expect(s.InfraCluster.Status.AttachedContainerRegistryIDs).To(gomega.Equal(tc.expectedAttachedRegistry))
+			}
+		})
+	}
+}
+
+func newManagedClusterTestScope(infraCluster *infrav1exp.AzureManagedCluster) *scope.ManagedControlPlaneScope {
+	return &scope.ManagedControlPlaneScope{
+		Logger:       klogr.New(),
+		AzureClients: scope.AzureClients{SubscriptionID: "123"},
+		Cluster: &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "my-cluster"},
+		},
+		ControlPlane: &infrav1exp.AzureManagedControlPlane{
+			ObjectMeta: metav1.ObjectMeta{Name: "my-aks"},
+			Spec: infrav1exp.AzureManagedControlPlaneSpec{
+				ResourceGroup: "my-rg",
+				VirtualNetwork: &infrav1exp.ManagedControlPlaneVirtualNetwork{
+					Name: "my-vnet",
+					Subnet: infrav1exp.ManagedControlPlaneSubnet{
+						Name: "my-subnet",
+					},
+				},
+			},
+		},
+		InfraCluster: infraCluster,
+	}
+}
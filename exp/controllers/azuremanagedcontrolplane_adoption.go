@@ -0,0 +1,356 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2021-07-01/containerservice"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	clusterv1exp "sigs.k8s.io/cluster-api/exp/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	azure "sigs.k8s.io/cluster-api-provider-azure/cloud"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/scope"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/managedclusters"
+	infrav1exp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1alpha3"
+)
+
+// reconcileAdoption imports an existing AKS cluster into the AzureManagedControlPlane of the scope, and creates the
+// machine pools of its agent pools. The AKS cluster itself is left untouched; it is reconciled against the imported
+// spec from the next reconcile on.
+func (r *AzureManagedControlPlaneReconciler) reconcileAdoption(ctx context.Context, scope *scope.ManagedControlPlaneScope) (reconcile.Result, error) {
+	scope.Logger.Info("Adopting existing AKS cluster")
+
+	managedClusterSpec := &managedclusters.Spec{
+		Name:          scope.ControlPlane.Name,
+		ResourceGroup: scope.ControlPlane.Spec.ResourceGroup,
+	}
+	result, err := managedclusters.NewService(scope).Get(ctx, managedClusterSpec)
+	if err != nil {
+		if azure.ResourceNotFound(err) {
+			err = errors.Errorf("AKS cluster %s to adopt doesn't exist in resource group %s", managedClusterSpec.Name, managedClusterSpec.ResourceGroup)
+		}
+		conditions.MarkFalse(scope.ControlPlane, infrav1exp.AdoptedCondition, infrav1exp.AdoptionFailedReason, clusterv1.ConditionSeverityError, "%v", err)
+		return reconcile.Result{}, errors.Wrapf(err, "failed to fetch managed cluster")
+	}
+	managedCluster, ok := result.(containerservice.ManagedCluster)
+	if !ok {
+		return reconcile.Result{}, fmt.Errorf("expected containerservice ManagedCluster object")
+	}
+
+	if err := adoptManagedCluster(scope.ControlPlane, managedCluster); err != nil {
+		conditions.MarkFalse(scope.ControlPlane, infrav1exp.AdoptedCondition, infrav1exp.AdoptionFailedReason, clusterv1.ConditionSeverityError, "%v", err)
+		return reconcile.Result{}, errors.Wrapf(err, "failed to adopt managed cluster %s", managedClusterSpec.Name)
+	}
+
+	machinePools, infraMachinePools := getAdoptedMachinePools(scope.Cluster, scope.ControlPlane, managedCluster)
+	if err := checkAdoptedMachinePoolConflicts(ctx, r.Client, scope.Cluster, machinePools, infraMachinePools); err != nil {
+		conditions.MarkFalse(scope.ControlPlane, infrav1exp.AdoptedCondition, infrav1exp.AdoptionFailedReason, clusterv1.ConditionSeverityError, "%v", err)
+		return reconcile.Result{}, errors.Wrapf(err, "failed to adopt managed cluster %s", managedClusterSpec.Name)
+	}
+	for i := range infraMachinePools {
+		// Pools that already have an AzureManagedMachinePool are left as they are.
+		if err := r.Client.Create(ctx, infraMachinePools[i]); err != nil {
+			if apierrors.IsAlreadyExists(err) {
+				continue
+			}
+			return reconcile.Result{}, errors.Wrapf(err, "failed to create AzureManagedMachinePool %s", infraMachinePools[i].Name)
+		}
+		if err := r.Client.Create(ctx, machinePools[i]); err != nil && !apierrors.IsAlreadyExists(err) {
+			return reconcile.Result{}, errors.Wrapf(err, "failed to create MachinePool %s", machinePools[i].Name)
+		}
+	}
+
+	conditions.MarkTrue(scope.ControlPlane, infrav1exp.AdoptedCondition)
+	scope.Logger.Info("Adopted existing AKS cluster", "version", scope.ControlPlane.Spec.Version, "agentPools", len(infraMachinePools))
+	return reconcile.Result{Requeue: true}, nil
+}
+
+// adoptManagedCluster copies the settings of an existing AKS cluster into an AzureManagedControlPlane, so that
+// reconciling it doesn't change the cluster. Clusters with settings CAPZ would overwrite are rejected.
+func adoptManagedCluster(controlPlane *infrav1exp.AzureManagedControlPlane, managedCluster containerservice.ManagedCluster) error {
+	properties := managedCluster.ManagedClusterProperties
+	if properties == nil {
+		return errors.New("managed cluster has no properties")
+	}
+	if managedCluster.Identity == nil || (managedCluster.Identity.Type != containerservice.ResourceIdentityTypeSystemAssigned && managedCluster.Identity.Type != containerservice.ResourceIdentityTypeUserAssigned) {
+		return errors.New("only clusters with a managed identity can be adopted")
+	}
+	if properties.AadProfile != nil && !to.Bool(properties.AadProfile.Managed) {
+		return errors.New("clusters with legacy Azure AD integration can't be adopted, migrate them to AKS-managed Azure AD first")
+	}
+	if properties.NetworkProfile != nil && strings.EqualFold(string(properties.NetworkProfile.LoadBalancerSku), string(containerservice.LoadBalancerSkuBasic)) {
+		return errors.New("clusters with a Basic load balancer can't be adopted")
+	}
+
+	spec := &controlPlane.Spec
+	spec.Version = to.String(properties.KubernetesVersion)
+
+	// Keep the tags of the cluster, tags already declared take precedence.
+	if len(managedCluster.Tags) > 0 {
+		tags := to.StringMap(managedCluster.Tags)
+		for key, value := range spec.AdditionalTags {
+			tags[key] = value
+		}
+		spec.AdditionalTags = tags
+	}
+	spec.NodeResourceGroup = to.String(properties.NodeResourceGroup)
+	spec.EnableRBAC = properties.EnableRBAC
+	spec.DisableLocalAccounts = properties.DisableLocalAccounts
+
+	// The kubelet identity is kept as it is when the cluster is updated, so only the control plane identity is imported.
+	if managedCluster.Identity.Type == containerservice.ResourceIdentityTypeUserAssigned {
+		for id := range managedCluster.Identity.UserAssignedIdentities {
+			spec.Identity = &infrav1exp.ManagedControlPlaneIdentity{ControlPlaneIdentityID: id}
+		}
+	}
+
+	if properties.AutoUpgradeProfile != nil && properties.AutoUpgradeProfile.UpgradeChannel != "" {
+		spec.AutoUpgradeChannel = to.StringPtr(string(properties.AutoUpgradeProfile.UpgradeChannel))
+	}
+
+	if managedCluster.Sku != nil && managedCluster.Sku.Tier != "" {
+		spec.SKU = &infrav1exp.ManagedControlPlaneSKU{
+			Tier: string(managedCluster.Sku.Tier),
+		}
+	}
+
+	if properties.LinuxProfile != nil && properties.LinuxProfile.SSH != nil && properties.LinuxProfile.SSH.PublicKeys != nil {
+		if keys := *properties.LinuxProfile.SSH.PublicKeys; len(keys) > 0 {
+			spec.SSHPublicKey = to.String(keys[0].KeyData)
+		}
+	}
+
+	if network := properties.NetworkProfile; network != nil {
+		if network.NetworkPlugin != "" {
+			spec.NetworkPlugin = to.StringPtr(strings.Title(string(network.NetworkPlugin)))
+		}
+		if network.NetworkPolicy != "" {
+			spec.NetworkPolicy = to.StringPtr(strings.Title(string(network.NetworkPolicy)))
+		}
+		if network.OutboundType != "" {
+			spec.OutboundType = to.StringPtr(string(network.OutboundType))
+		}
+		if profile := network.NatGatewayProfile; profile != nil && network.OutboundType == containerservice.OutboundTypeManagedNATGateway {
+			spec.NATGatewayProfile = &infrav1exp.NATGatewayProfile{
+				IdleTimeoutInMinutes: profile.IdleTimeoutInMinutes,
+			}
+			if profile.ManagedOutboundIPProfile != nil {
+				spec.NATGatewayProfile.ManagedOutboundIPs = profile.ManagedOutboundIPProfile.Count
+			}
+		}
+	}
+
+	if profile := properties.APIServerAccessProfile; profile != nil {
+		spec.APIServerAccessProfile = &infrav1exp.APIServerAccessProfile{
+			EnablePrivateCluster: profile.EnablePrivateCluster,
+			PrivateDNSZone:       profile.PrivateDNSZone,
+		}
+		if profile.AuthorizedIPRanges != nil {
+			spec.APIServerAccessProfile.AuthorizedIPRanges = *profile.AuthorizedIPRanges
+		}
+	}
+
+	if profile := properties.AadProfile; profile != nil {
+		spec.AADProfile = &infrav1exp.AADProfile{
+			TenantID:        to.String(profile.TenantID),
+			EnableAzureRBAC: profile.EnableAzureRBAC,
+		}
+		if profile.AdminGroupObjectIDs != nil {
+			spec.AADProfile.AdminGroupObjectIDs = *profile.AdminGroupObjectIDs
+		}
+	}
+
+	defaultPool := getAdoptedDefaultPool(managedCluster)
+	if defaultPool == nil {
+		return errors.New("managed cluster has no agent pools")
+	}
+	if spec.DefaultPoolRef.Name == "" {
+		spec.DefaultPoolRef.Name = to.String(defaultPool.Name)
+	}
+	if defaultPool.VnetSubnetID != nil {
+		vnet, err := parseSubnetID(*defaultPool.VnetSubnetID)
+		if err != nil {
+			return err
+		}
+		spec.VirtualNetwork = vnet
+	}
+
+	return nil
+}
+
+// getAdoptedMachinePools returns a MachinePool and AzureManagedMachinePool for each agent pool of an adopted AKS
+// cluster, matching its current state.
+func getAdoptedMachinePools(cluster *clusterv1.Cluster, controlPlane *infrav1exp.AzureManagedControlPlane, managedCluster containerservice.ManagedCluster) ([]*clusterv1exp.MachinePool, []*infrav1exp.AzureManagedMachinePool) {
+	if managedCluster.ManagedClusterProperties == nil || managedCluster.AgentPoolProfiles == nil {
+		return nil, nil
+	}
+
+	var machinePools []*clusterv1exp.MachinePool
+	var infraMachinePools []*infrav1exp.AzureManagedMachinePool
+	for _, pool := range *managedCluster.AgentPoolProfiles {
+		name := to.String(pool.Name)
+		infraMachinePool := &infrav1exp.AzureManagedMachinePool{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: controlPlane.Namespace,
+				Labels: map[string]string{
+					clusterv1.ClusterLabelName: cluster.Name,
+				},
+			},
+			Spec: infrav1exp.AzureManagedMachinePoolSpec{
+				SKU:               to.String(pool.VMSize),
+				OSDiskSizeGB:      pool.OsDiskSizeGB,
+				Mode:              string(pool.Mode),
+				MaxPods:           pool.MaxPods,
+				NodeLabels:        to.StringMap(pool.NodeLabels),
+				AvailabilityZones: to.StringSlice(pool.AvailabilityZones),
+				NodeTaints:        to.StringSlice(pool.NodeTaints),
+			},
+		}
+		if len(infraMachinePool.Spec.NodeLabels) == 0 {
+			infraMachinePool.Spec.NodeLabels = nil
+		}
+		if pool.OsType == containerservice.OSTypeWindows {
+			infraMachinePool.Spec.OSType = to.StringPtr(string(pool.OsType))
+		}
+		if to.Bool(pool.EnableAutoScaling) {
+			infraMachinePool.Spec.EnableAutoScaling = to.BoolPtr(true)
+			infraMachinePool.Spec.MinCount = pool.MinCount
+			infraMachinePool.Spec.MaxCount = pool.MaxCount
+		}
+		if vnet := controlPlane.Spec.VirtualNetwork; vnet != nil && pool.VnetSubnetID != nil {
+			if subnet, err := parseSubnetID(*pool.VnetSubnetID); err == nil && subnet.Subnet.Name != vnet.Subnet.Name {
+				infraMachinePool.Spec.SubnetName = to.StringPtr(subnet.Subnet.Name)
+			}
+		}
+
+		machinePool := &clusterv1exp.MachinePool{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: controlPlane.Namespace,
+				Labels: map[string]string{
+					clusterv1.ClusterLabelName: cluster.Name,
+				},
+			},
+			Spec: clusterv1exp.MachinePoolSpec{
+				ClusterName: cluster.Name,
+				Replicas:    pool.Count,
+				Template: clusterv1.MachineTemplateSpec{
+					Spec: clusterv1.MachineSpec{
+						ClusterName: cluster.Name,
+						Bootstrap: clusterv1.Bootstrap{
+							DataSecretName: to.StringPtr(""),
+						},
+						InfrastructureRef: corev1.ObjectReference{
+							APIVersion: infrav1exp.GroupVersion.String(),
+							Kind:       "AzureManagedMachinePool",
+							Name:       name,
+						},
+					},
+				},
+			},
+		}
+		if pool.OrchestratorVersion != nil {
+			machinePool.Spec.Template.Spec.Version = to.StringPtr("v" + *pool.OrchestratorVersion)
+		}
+
+		machinePools = append(machinePools, machinePool)
+		infraMachinePools = append(infraMachinePools, infraMachinePool)
+	}
+	return machinePools, infraMachinePools
+}
+
+// checkAdoptedMachinePoolConflicts returns an error if a MachinePool or AzureManagedMachinePool of an adopted AKS
+// cluster already exists for another cluster. They are named after their agent pool, so adopting a second cluster
+// with the same agent pool names in the same namespace would otherwise pick up the machine pools of the first one.
+func checkAdoptedMachinePoolConflicts(ctx context.Context, c client.Client, cluster *clusterv1.Cluster, machinePools []*clusterv1exp.MachinePool, infraMachinePools []*infrav1exp.AzureManagedMachinePool) error {
+	for i := range infraMachinePools {
+		key := client.ObjectKey{Namespace: infraMachinePools[i].Namespace, Name: infraMachinePools[i].Name}
+		if err := checkAdoptedObjectConflict(ctx, c, cluster.Name, key, &infrav1exp.AzureManagedMachinePool{}, "AzureManagedMachinePool"); err != nil {
+			return err
+		}
+	}
+	for i := range machinePools {
+		key := client.ObjectKey{Namespace: machinePools[i].Namespace, Name: machinePools[i].Name}
+		if err := checkAdoptedObjectConflict(ctx, c, cluster.Name, key, &clusterv1exp.MachinePool{}, "MachinePool"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkAdoptedObjectConflict returns an error if the object with the given key exists and doesn't belong to the cluster.
+func checkAdoptedObjectConflict(ctx context.Context, c client.Client, clusterName string, key client.ObjectKey, existing adoptedObject, kind string) error {
+	if err := c.Get(ctx, key, existing); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "failed to get %s %s", kind, key.Name)
+	}
+	if owner := existing.GetLabels()[clusterv1.ClusterLabelName]; owner != clusterName {
+		return errors.Errorf("%s %s already exists and belongs to cluster %q", kind, key.Name, owner)
+	}
+	return nil
+}
+
+// adoptedObject is an object created when adopting an AKS cluster.
+type adoptedObject interface {
+	runtime.Object
+	metav1.Object
+}
+
+// getAdoptedDefaultPool returns the first system pool of an AKS cluster, or its first pool if it has none.
+func getAdoptedDefaultPool(managedCluster containerservice.ManagedCluster) *containerservice.ManagedClusterAgentPoolProfile {
+	if managedCluster.AgentPoolProfiles == nil || len(*managedCluster.AgentPoolProfiles) == 0 {
+		return nil
+	}
+	pools := *managedCluster.AgentPoolProfiles
+	for i := range pools {
+		if pools[i].Mode == containerservice.AgentPoolModeSystem {
+			return &pools[i]
+		}
+	}
+	return &pools[0]
+}
+
+// parseSubnetID returns the virtual network and subnet of a subnet resource ID.
+func parseSubnetID(id string) (*infrav1exp.ManagedControlPlaneVirtualNetwork, error) {
+	// /subscriptions/<id>/resourceGroups/<group>/providers/Microsoft.Network/virtualNetworks/<vnet>/subnets/<subnet>
+	parts := strings.Split(strings.TrimPrefix(id, "/"), "/")
+	if len(parts) != 10 || !strings.EqualFold(parts[2], "resourceGroups") || !strings.EqualFold(parts[6], "virtualNetworks") || !strings.EqualFold(parts[8], "subnets") {
+		return nil, errors.Errorf("invalid subnet ID %s", id)
+	}
+	return &infrav1exp.ManagedControlPlaneVirtualNetwork{
+		Name:          parts[7],
+		ResourceGroup: parts[3],
+		Subnet: infrav1exp.ManagedControlPlaneSubnet{
+			Name: parts[9],
+		},
+	}, nil
+}
@@ -0,0 +1,323 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2021-07-01/containerservice"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	clusterv1exp "sigs.k8s.io/cluster-api/exp/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1exp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1alpha3"
+)
+
+const adoptedSubnetID = "/subscriptions/123/resourceGroups/my-vnet-rg/providers/Microsoft.Network/virtualNetworks/my-vnet/subnets/"
+
+func newAdoptableManagedCluster() containerservice.ManagedCluster {
+	return containerservice.ManagedCluster{
+		Identity: &containerservice.ManagedClusterIdentity{
+			Type: containerservice.ResourceIdentityTypeSystemAssigned,
+		},
+		Sku: &containerservice.ManagedClusterSKU{
+			Name: containerservice.ManagedClusterSKUNameBasic,
+			Tier: containerservice.ManagedClusterSKUTierPaid,
+		},
+		ManagedClusterProperties: &containerservice.ManagedClusterProperties{
+			KubernetesVersion: to.StringPtr("1.17.7"),
+			NodeResourceGroup: to.StringPtr("MC_my-rg_my-cluster_westus2"),
+			EnableRBAC:        to.BoolPtr(true),
+			LinuxProfile: &containerservice.LinuxProfile{
+				AdminUsername: to.StringPtr("azureuser"),
+				SSH: &containerservice.SSHConfiguration{
+					PublicKeys: &[]containerservice.SSHPublicKey{{KeyData: to.StringPtr("ssh-rsa AAAA")}},
+				},
+			},
+			NetworkProfile: &containerservice.NetworkProfile{
+				NetworkPlugin:   containerservice.NetworkPluginAzure,
+				NetworkPolicy:   containerservice.NetworkPolicyCalico,
+				OutboundType:    containerservice.OutboundTypeLoadBalancer,
+				LoadBalancerSku: containerservice.LoadBalancerSkuStandard,
+			},
+			AadProfile: &containerservice.ManagedClusterAADProfile{
+				Managed:             to.BoolPtr(true),
+				AdminGroupObjectIDs: &[]string{"00000000-0000-0000-0000-000000000000"},
+				TenantID:            to.StringPtr("11111111-1111-1111-1111-111111111111"),
+			},
+			AgentPoolProfiles: &[]containerservice.ManagedClusterAgentPoolProfile{
+				{
+					Name:                to.StringPtr("user1"),
+					Count:               to.Int32Ptr(3),
+					VMSize:              to.StringPtr(string(containerservice.VMSizeTypesStandardD4sV3)),
+					OsDiskSizeGB:        to.Int32Ptr(128),
+					OsType:              containerservice.OSTypeLinux,
+					Mode:                containerservice.AgentPoolModeUser,
+					OrchestratorVersion: to.StringPtr("1.17.7"),
+					VnetSubnetID:        to.StringPtr(adoptedSubnetID + "user-subnet"),
+					EnableAutoScaling:   to.BoolPtr(true),
+					MinCount:            to.Int32Ptr(1),
+					MaxCount:            to.Int32Ptr(5),
+					NodeLabels:          map[string]*string{"workload": to.StringPtr("batch")},
+					NodeTaints:          &[]string{"sku=gpu:NoSchedule"},
+				},
+				{
+					Name:                to.StringPtr("system"),
+					Count:               to.Int32Ptr(2),
+					VMSize:              to.StringPtr(string(containerservice.VMSizeTypesStandardD2sV3)),
+					OsType:              containerservice.OSTypeLinux,
+					Mode:                containerservice.AgentPoolModeSystem,
+					OrchestratorVersion: to.StringPtr("1.17.7"),
+					VnetSubnetID:        to.StringPtr(adoptedSubnetID + "my-subnet"),
+					MaxPods:             to.Int32Ptr(30),
+					AvailabilityZones:   &[]string{"1", "2"},
+				},
+			},
+		},
+	}
+}
+
+func TestAdoptManagedCluster(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	controlPlane := &infrav1exp.AzureManagedControlPlane{
+		Spec: infrav1exp.AzureManagedControlPlaneSpec{
+			Adopt:          true,
+			AdditionalTags: map[string]string{"env": "prod"},
+		},
+	}
+	managedCluster := newAdoptableManagedCluster()
+	managedCluster.Tags = map[string]*string{
+		"env":   to.StringPtr("dev"),
+		"owner": to.StringPtr("team-a"),
+	}
+	g.Expect(adoptManagedCluster(controlPlane, managedCluster)).To(gomega.Succeed())
+	g.Expect(controlPlane.Spec.AdditionalTags).To(gomega.Equal(map[string]string{"env": "prod", "owner": "team-a"}))
+	g.Expect(controlPlane.Spec.Version).To(gomega.Equal("1.17.7"))
+	g.Expect(controlPlane.Spec.SKU).To(gomega.Equal(&infrav1exp.ManagedControlPlaneSKU{Tier: "Paid"}))
+	g.Expect(controlPlane.Spec.NodeResourceGroup).To(gomega.Equal("MC_my-rg_my-cluster_westus2"))
+	g.Expect(controlPlane.Spec.EnableRBAC).To(gomega.Equal(to.BoolPtr(true)))
+	g.Expect(controlPlane.Spec.SSHPublicKey).To(gomega.Equal("ssh-rsa AAAA"))
+	g.Expect(controlPlane.Spec.NetworkPlugin).To(gomega.Equal(to.StringPtr("Azure")))
+	g.Expect(controlPlane.Spec.NetworkPolicy).To(gomega.Equal(to.StringPtr("Calico")))
+	g.Expect(controlPlane.Spec.OutboundType).To(gomega.Equal(to.StringPtr("loadBalancer")))
+	g.Expect(controlPlane.Spec.AADProfile).To(gomega.Equal(&infrav1exp.AADProfile{
+		AdminGroupObjectIDs: []string{"00000000-0000-0000-0000-000000000000"},
+		TenantID:            "11111111-1111-1111-1111-111111111111",
+	}))
+	g.Expect(controlPlane.Spec.DefaultPoolRef.Name).To(gomega.Equal("system"))
+	g.Expect(controlPlane.Spec.VirtualNetwork).To(gomega.Equal(&infrav1exp.ManagedControlPlaneVirtualNetwork{
+		Name:          "my-vnet",
+		ResourceGroup: "my-vnet-rg",
+		Subnet: infrav1exp.ManagedControlPlaneSubnet{
+			Name: "my-subnet",
+		},
+	}))
+}
+
+func TestAdoptManagedClusterWithUserAssignedIdentity(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	const identityID = "/subscriptions/123/resourceGroups/identity-rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/control-plane"
+	controlPlane := &infrav1exp.AzureManagedControlPlane{}
+	managedCluster := newAdoptableManagedCluster()
+	managedCluster.Identity = &containerservice.ManagedClusterIdentity{
+		Type: containerservice.ResourceIdentityTypeUserAssigned,
+		UserAssignedIdentities: map[string]*containerservice.ManagedClusterIdentityUserAssignedIdentitiesValue{
+			identityID: {},
+		},
+	}
+	managedCluster.AutoUpgradeProfile = &containerservice.ManagedClusterAutoUpgradeProfile{
+		UpgradeChannel: containerservice.UpgradeChannelStable,
+	}
+	managedCluster.APIServerAccessProfile = &containerservice.ManagedClusterAPIServerAccessProfile{
+		EnablePrivateCluster: to.BoolPtr(true),
+		PrivateDNSZone:       to.StringPtr("system"),
+	}
+	g.Expect(adoptManagedCluster(controlPlane, managedCluster)).To(gomega.Succeed())
+	g.Expect(controlPlane.Spec.Identity).To(gomega.Equal(&infrav1exp.ManagedControlPlaneIdentity{ControlPlaneIdentityID: identityID}))
+	g.Expect(controlPlane.Spec.AutoUpgradeChannel).To(gomega.Equal(to.StringPtr("stable")))
+	g.Expect(controlPlane.Spec.APIServerAccessProfile.PrivateDNSZone).To(gomega.Equal(to.StringPtr("system")))
+}
+
+func TestAdoptManagedClusterRefused(t *testing.T) {
+	testcases := []struct {
+		name   string
+		modify func(mc *containerservice.ManagedCluster)
+	}{
+		{
+			name: "service principal cluster",
+			modify: func(mc *containerservice.ManagedCluster) {
+				mc.Identity = nil
+			},
+		},
+		{
+			name: "identity disabled",
+			modify: func(mc *containerservice.ManagedCluster) {
+				mc.Identity.Type = containerservice.ResourceIdentityTypeNone
+			},
+		},
+		{
+			name: "legacy Azure AD integration",
+			modify: func(mc *containerservice.ManagedCluster) {
+				mc.AadProfile.Managed = nil
+			},
+		},
+		{
+			name: "Basic load balancer",
+			modify: func(mc *containerservice.ManagedCluster) {
+				mc.NetworkProfile.LoadBalancerSku = containerservice.LoadBalancerSkuBasic
+			},
+		},
+		{
+			name: "no agent pools",
+			modify: func(mc *containerservice.ManagedCluster) {
+				mc.AgentPoolProfiles = nil
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := gomega.NewWithT(t)
+			managedCluster := newAdoptableManagedCluster()
+			tc.modify(&managedCluster)
+			g.Expect(adoptManagedCluster(&infrav1exp.AzureManagedControlPlane{}, managedCluster)).NotTo(gomega.Succeed())
+		})
+	}
+}
+
+func TestGetAdoptedMachinePools(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "my-cluster"}}
+	controlPlane := &infrav1exp.AzureManagedControlPlane{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Namespace: "default"},
+	}
+	managedCluster := newAdoptableManagedCluster()
+	g.Expect(adoptManagedCluster(controlPlane, managedCluster)).To(gomega.Succeed())
+
+	machinePools, infraMachinePools := getAdoptedMachinePools(cluster, controlPlane, managedCluster)
+	g.Expect(machinePools).To(gomega.HaveLen(2))
+	g.Expect(infraMachinePools).To(gomega.HaveLen(2))
+
+	g.Expect(infraMachinePools[0].Name).To(gomega.Equal("user1"))
+	g.Expect(infraMachinePools[0].Namespace).To(gomega.Equal("default"))
+	g.Expect(infraMachinePools[0].Spec).To(gomega.Equal(infrav1exp.AzureManagedMachinePoolSpec{
+		SKU:               "Standard_D4s_v3",
+		OSDiskSizeGB:      to.Int32Ptr(128),
+		SubnetName:        to.StringPtr("user-subnet"),
+		Mode:              "User",
+		EnableAutoScaling: to.BoolPtr(true),
+		MinCount:          to.Int32Ptr(1),
+		MaxCount:          to.Int32Ptr(5),
+		NodeLabels:        map[string]string{"workload": "batch"},
+		NodeTaints:        []string{"sku=gpu:NoSchedule"},
+	}))
+	g.Expect(infraMachinePools[1].Spec).To(gomega.Equal(infrav1exp.AzureManagedMachinePoolSpec{
+		SKU:               "Standard_D2s_v3",
+		Mode:              "System",
+		MaxPods:           to.Int32Ptr(30),
+		AvailabilityZones: []string{"1", "2"},
+	}))
+
+	g.Expect(machinePools[0].Name).To(gomega.Equal("user1"))
+	g.Expect(machinePools[0].Labels).To(gomega.HaveKeyWithValue(clusterv1.ClusterLabelName, "my-cluster"))
+	g.Expect(machinePools[0].Spec.ClusterName).To(gomega.Equal("my-cluster"))
+	g.Expect(machinePools[0].Spec.Replicas).To(gomega.Equal(to.Int32Ptr(3)))
+	g.Expect(machinePools[0].Spec.Template.Spec.Version).To(gomega.Equal(to.StringPtr("v1.17.7")))
+	g.Expect(machinePools[0].Spec.Template.Spec.InfrastructureRef.Kind).To(gomega.Equal("AzureManagedMachinePool"))
+	g.Expect(machinePools[0].Spec.Template.Spec.InfrastructureRef.Name).To(gomega.Equal("user1"))
+}
+
+func TestCheckAdoptedMachinePoolConflicts(t *testing.T) {
+	testcases := []struct {
+		name          string
+		existing      []runtime.Object
+		expectedError string
+	}{
+		{
+			name: "no existing machine pools",
+		},
+		{
+			name: "machine pools of the same cluster",
+			existing: []runtime.Object{
+				newAdoptedObject(&infrav1exp.AzureManagedMachinePool{}, "my-cluster"),
+				newAdoptedObject(&clusterv1exp.MachinePool{}, "my-cluster"),
+			},
+		},
+		{
+			name: "AzureManagedMachinePool of another cluster",
+			existing: []runtime.Object{
+				newAdoptedObject(&infrav1exp.AzureManagedMachinePool{}, "other-cluster"),
+			},
+			expectedError: `AzureManagedMachinePool nodepool1 already exists and belongs to cluster "other-cluster"`,
+		},
+		{
+			name: "MachinePool of another cluster",
+			existing: []runtime.Object{
+				newAdoptedObject(&clusterv1exp.MachinePool{}, "other-cluster"),
+			},
+			expectedError: `MachinePool nodepool1 already exists and belongs to cluster "other-cluster"`,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := gomega.NewWithT(t)
+			kClient := fake.NewFakeClientWithScheme(newScheme(g), tc.existing...)
+
+			cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "my-cluster"}}
+			machinePools := []*clusterv1exp.MachinePool{{ObjectMeta: metav1.ObjectMeta{Name: "nodepool1", Namespace: "default"}}}
+			infraMachinePools := []*infrav1exp.AzureManagedMachinePool{{ObjectMeta: metav1.ObjectMeta{Name: "nodepool1", Namespace: "default"}}}
+
+			err := checkAdoptedMachinePoolConflicts(context.TODO(), kClient, cluster, machinePools, infraMachinePools)
+			if tc.expectedError != "" {
+				g.Expect(err).To(gomega.MatchError(tc.expectedError))
+			} else {
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+			}
+		})
+	}
+}
+
+func newAdoptedObject(obj adoptedObject, clusterName string) runtime.Object {
+	obj.SetName("nodepool1")
+	obj.SetNamespace("default")
+	obj.SetLabels(map[string]string{clusterv1.ClusterLabelName: clusterName})
+	return obj
+}
+
+func TestParseSubnetID(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	vnet, err := parseSubnetID(adoptedSubnetID + "my-subnet")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(vnet.Name).To(gomega.Equal("my-vnet"))
+	g.Expect(vnet.ResourceGroup).To(gomega.Equal("my-vnet-rg"))
+	g.Expect(vnet.Subnet.Name).To(gomega.Equal("my-subnet"))
+
+	_, err = parseSubnetID("/subscriptions/123/resourceGroups/my-rg")
+	g.Expect(err).To(gomega.HaveOccurred())
+}
@@ -28,6 +28,7 @@ import (
 	clusterv1exp "sigs.k8s.io/cluster-api/exp/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/util"
 	"sigs.k8s.io/cluster-api/util/annotations"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/predicates"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -157,6 +158,7 @@ func (r *AzureManagedMachinePoolReconciler) Reconcile(req ctrl.Request) (_ ctrl.
 	// Create the scope.
 	mcpScope, err := scope.NewManagedControlPlaneScope(scope.ManagedControlPlaneScopeParams{
 		Client:           r.Client,
+		Context:          ctx,
 		Logger:           log,
 		ControlPlane:     controlPlane,
 		Cluster:          ownerCluster,
@@ -168,6 +170,13 @@ func (r *AzureManagedMachinePoolReconciler) Reconcile(req ctrl.Request) (_ ctrl.
 		return reconcile.Result{}, errors.Errorf("failed to create scope: %+v", err)
 	}
 
+	// Always patch when exiting so we can persist changes to status
+	defer func() {
+		if err := mcpScope.PatchObject(ctx); err != nil && reterr == nil {
+			reterr = err
+		}
+	}()
+
 	// Handle deleted clusters
 	if !infraPool.DeletionTimestamp.IsZero() {
 		return r.reconcileDelete(ctx, mcpScope)
@@ -194,6 +203,16 @@ func (r *AzureManagedMachinePoolReconciler) reconcileNormal(ctx context.Context,
 	// No errors, so mark us ready so the Cluster API Cluster Controller can pull it
 	scope.InfraMachinePool.Status.Ready = true
 
+	// Check back until the control plane has been upgraded and the agent pool can follow.
+	switch conditions.GetReason(scope.InfraMachinePool, infrav1exp.KubernetesVersionUpgradedCondition) {
+	case infrav1exp.UpgradingReason, infrav1exp.WaitingForControlPlaneUpgradeReason:
+		return reconcile.Result{RequeueAfter: upgradePollInterval}, nil
+	}
+	// Likewise until the nodes run the new node image.
+	if conditions.GetReason(scope.InfraMachinePool, infrav1exp.NodeImageUpgradedCondition) == infrav1exp.UpgradingReason {
+		return reconcile.Result{RequeueAfter: upgradePollInterval}, nil
+	}
+
 	return reconcile.Result{}, nil
 }
 
@@ -207,9 +226,5 @@ func (r *AzureManagedMachinePoolReconciler) reconcileDelete(ctx context.Context,
 	// Cluster is deleted so remove the finalizer.
 	controllerutil.RemoveFinalizer(scope.InfraMachinePool, infrav1.ClusterFinalizer)
 
-	if err := scope.PatchObject(ctx); err != nil {
-		return reconcile.Result{}, err
-	}
-
 	return reconcile.Result{}, nil
 }
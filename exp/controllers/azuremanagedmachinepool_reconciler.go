@@ -19,20 +19,27 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"strings"
 
-	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2021-07-01/compute"
+	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2021-07-01/containerservice"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/blang/semver"
 	"github.com/pkg/errors"
 	azure "sigs.k8s.io/cluster-api-provider-azure/cloud"
 	"sigs.k8s.io/cluster-api-provider-azure/cloud/scope"
 	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/agentpools"
 	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/scalesets"
+	infrav1exp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1alpha3"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 // azureManagedMachinePoolReconciler are list of services required by cluster controller
 type azureManagedMachinePoolReconciler struct {
 	kubeclient    client.Client
-	agentPoolsSvc azure.OldService
+	agentPoolsSvc *agentpools.Service
 	scaleSetsSvc  NodeLister
 }
 
@@ -60,22 +67,65 @@ func (r *azureManagedMachinePoolReconciler) Reconcile(ctx context.Context, scope
 		Cluster:       scope.ControlPlane.Name,
 		SKU:           scope.InfraMachinePool.Spec.SKU,
 		Replicas:      1,
-		Version:       scope.MachinePool.Spec.Template.Spec.Version,
+
+		OSType:            to.String(scope.InfraMachinePool.Spec.OSType),
+		Mode:              scope.InfraMachinePool.Spec.Mode,
+		EnableAutoScaling: to.Bool(scope.InfraMachinePool.Spec.EnableAutoScaling),
+		MinCount:          scope.InfraMachinePool.Spec.MinCount,
+		MaxCount:          scope.InfraMachinePool.Spec.MaxCount,
+		NodeLabels:        scope.InfraMachinePool.Spec.NodeLabels,
+		NodeTaints:        scope.InfraMachinePool.Spec.NodeTaints,
+		MaxPods:           scope.InfraMachinePool.Spec.MaxPods,
+		AvailabilityZones: scope.InfraMachinePool.Spec.AvailabilityZones,
+		MaxSurge:          scope.InfraMachinePool.Spec.MaxSurge,
+	}
+
+	if err := validateWindowsPool(scope, scope.InfraMachinePool); err != nil {
+		return err
+	}
+
+	if scope.MachinePool.Spec.Template.Spec.Version != nil {
+		agentPoolSpec.Version = to.StringPtr(strings.TrimPrefix(*scope.MachinePool.Spec.Template.Spec.Version, "v"))
 	}
 
 	if scope.InfraMachinePool.Spec.OSDiskSizeGB != nil {
 		agentPoolSpec.OSDiskSizeGB = *scope.InfraMachinePool.Spec.OSDiskSizeGB
 	}
 
+	subnetID, err := getAgentPoolSubnetID(scope, scope.InfraMachinePool)
+	if err != nil {
+		return err
+	}
+	agentPoolSpec.VnetSubnetID = subnetID
+
 	if scope.MachinePool.Spec.Replicas != nil {
 		agentPoolSpec.Replicas = *scope.MachinePool.Spec.Replicas
 	}
 
+	upgrading, err := r.reconcileVersion(ctx, scope, agentPoolSpec)
+	if err != nil {
+		return errors.Wrapf(err, "failed to reconcile Kubernetes version of machine pool %s", scope.InfraMachinePool.Name)
+	}
+
 	if err := r.agentPoolsSvc.Reconcile(ctx, agentPoolSpec); err != nil {
+		if upgrading {
+			conditions.MarkFalse(scope.InfraMachinePool, infrav1exp.KubernetesVersionUpgradedCondition, infrav1exp.UpgradeFailedReason, clusterv1.ConditionSeverityError, "%v", err)
+		}
 		return errors.Wrapf(err, "failed to reconcile machine pool %s", scope.InfraMachinePool.Name)
 	}
 
-	nodeResourceGroup := fmt.Sprintf("MC_%s_%s_%s", scope.ControlPlane.Spec.ResourceGroup, scope.ControlPlane.Name, scope.ControlPlane.Spec.Location)
+	if err := r.reconcileVersionStatus(ctx, scope, agentPoolSpec); err != nil {
+		return errors.Wrapf(err, "failed to reconcile Kubernetes version of machine pool %s", scope.InfraMachinePool.Name)
+	}
+
+	if err := r.reconcileNodeImage(ctx, scope, agentPoolSpec); err != nil {
+		return errors.Wrapf(err, "failed to reconcile node image of machine pool %s", scope.InfraMachinePool.Name)
+	}
+
+	nodeResourceGroup := scope.ControlPlane.Status.NodeResourceGroup
+	if nodeResourceGroup == "" {
+		return errors.Errorf("node resource group of AzureManagedControlPlane %s is not known yet", scope.ControlPlane.Name)
+	}
 	vmss, err := r.scaleSetsSvc.List(ctx, nodeResourceGroup)
 	if err != nil {
 		return errors.Wrapf(err, "failed to list vmss in resource group %s", nodeResourceGroup)
@@ -113,6 +163,180 @@ func (r *azureManagedMachinePoolReconciler) Reconcile(ctx context.Context, scope
 	return nil
 }
 
+// reconcileVersion upgrades an existing agent pool only once the control plane runs the desired Kubernetes version,
+// as AKS doesn't allow node pools to run a newer version than the control plane. Until then the agent pool is held
+// at its current version, so that other changes to the pool are still applied.
+func (r *azureManagedMachinePoolReconciler) reconcileVersion(ctx context.Context, scope *scope.ManagedControlPlaneScope, agentPoolSpec *agentpools.Spec) (bool, error) {
+	if agentPoolSpec.Version == nil {
+		return false, nil
+	}
+
+	existingPool, err := r.getAgentPool(ctx, agentPoolSpec)
+	if err != nil || existingPool == nil {
+		return false, err
+	}
+
+	currentVersion := to.String(existingPool.OrchestratorVersion)
+	if currentVersion == "" || currentVersion == *agentPoolSpec.Version {
+		return false, nil
+	}
+
+	if !controlPlaneSupportsVersion(scope.ControlPlane.Status.Version, *agentPoolSpec.Version) {
+		conditions.MarkFalse(scope.InfraMachinePool, infrav1exp.KubernetesVersionUpgradedCondition, infrav1exp.WaitingForControlPlaneUpgradeReason, clusterv1.ConditionSeverityInfo,
+			"waiting for the control plane to run Kubernetes version %s", *agentPoolSpec.Version)
+		agentPoolSpec.Version = &currentVersion
+		return false, nil
+	}
+
+	scope.Logger.Info("Upgrading agent pool", "from", currentVersion, "to", *agentPoolSpec.Version)
+	conditions.MarkFalse(scope.InfraMachinePool, infrav1exp.KubernetesVersionUpgradedCondition, infrav1exp.UpgradingReason, clusterv1.ConditionSeverityInfo,
+		"upgrading agent pool from Kubernetes version %s to %s", currentVersion, *agentPoolSpec.Version)
+	return true, nil
+}
+
+// reconcileVersionStatus marks the Kubernetes version of the agent pool as upgraded once it runs the desired version.
+func (r *azureManagedMachinePoolReconciler) reconcileVersionStatus(ctx context.Context, scope *scope.ManagedControlPlaneScope, agentPoolSpec *agentpools.Spec) error {
+	if scope.MachinePool.Spec.Template.Spec.Version == nil {
+		return nil
+	}
+
+	pool, err := r.getAgentPool(ctx, agentPoolSpec)
+	if err != nil || pool == nil {
+		return err
+	}
+
+	if to.String(pool.OrchestratorVersion) == strings.TrimPrefix(*scope.MachinePool.Spec.Template.Spec.Version, "v") {
+		conditions.MarkTrue(scope.InfraMachinePool, infrav1exp.KubernetesVersionUpgradedCondition)
+	}
+	return nil
+}
+
+// reconcileNodeImage reports the node image versions of the agent pool, and upgrades its nodes to the latest node
+// image when they run a version older than the desired one. AKS can only upgrade to the latest node image.
+func (r *azureManagedMachinePoolReconciler) reconcileNodeImage(ctx context.Context, scope *scope.ManagedControlPlaneScope, agentPoolSpec *agentpools.Spec) error {
+	pool, err := r.getAgentPool(ctx, agentPoolSpec)
+	if err != nil || pool == nil {
+		return err
+	}
+
+	currentVersion := to.String(pool.NodeImageVersion)
+	latestVersion, err := r.agentPoolsSvc.GetLatestNodeImageVersion(ctx, agentPoolSpec)
+	if err != nil {
+		return err
+	}
+	scope.InfraMachinePool.Status.NodeImageVersion = currentVersion
+	scope.InfraMachinePool.Status.LatestNodeImageVersion = latestVersion
+
+	desiredVersion := scope.InfraMachinePool.Spec.NodeImageVersion
+	if desiredVersion == "" {
+		return nil
+	}
+	if desiredVersion == infrav1exp.NodeImageVersionLatest {
+		desiredVersion = latestVersion
+	}
+	if cmp, ok := compareNodeImageVersions(currentVersion, desiredVersion); ok && cmp >= 0 {
+		conditions.MarkTrue(scope.InfraMachinePool, infrav1exp.NodeImageUpgradedCondition)
+		return nil
+	}
+
+	// The agent pool can't be changed while it is being upgraded, check back once it is done.
+	if ps := to.String(pool.ProvisioningState); ps != "Canceled" && ps != "Failed" && ps != "Succeeded" {
+		return nil
+	}
+
+	if cmp, ok := compareNodeImageVersions(latestVersion, desiredVersion); !ok || cmp < 0 {
+		conditions.MarkFalse(scope.InfraMachinePool, infrav1exp.NodeImageUpgradedCondition, infrav1exp.UpgradeVersionUnavailableReason, clusterv1.ConditionSeverityError,
+			"node image version %s is not available, the latest node image version is %s", desiredVersion, latestVersion)
+		return nil
+	}
+
+	scope.Logger.Info("Upgrading agent pool node image", "from", currentVersion, "to", latestVersion)
+	if err := r.agentPoolsSvc.UpgradeNodeImageVersion(ctx, agentPoolSpec); err != nil {
+		conditions.MarkFalse(scope.InfraMachinePool, infrav1exp.NodeImageUpgradedCondition, infrav1exp.UpgradeFailedReason, clusterv1.ConditionSeverityError, "%v", err)
+		return err
+	}
+	conditions.MarkFalse(scope.InfraMachinePool, infrav1exp.NodeImageUpgradedCondition, infrav1exp.UpgradingReason, clusterv1.ConditionSeverityInfo,
+		"upgrading agent pool from node image version %s to %s", currentVersion, latestVersion)
+	return nil
+}
+
+// compareNodeImageVersions compares two node image versions of the same image, such as AKSUbuntu-1604-2020.09.16,
+// by their date. It returns false if the versions are of different images.
+func compareNodeImageVersions(a, b string) (int, bool) {
+	if a == b {
+		return 0, true
+	}
+	i, j := strings.LastIndex(a, "-"), strings.LastIndex(b, "-")
+	if i < 0 || j < 0 || a[:i] != b[:j] {
+		return 0, false
+	}
+	return strings.Compare(a[i+1:], b[j+1:]), true
+}
+
+// getAgentPool returns the properties of an existing agent pool, or nil if it doesn't exist yet.
+func (r *azureManagedMachinePoolReconciler) getAgentPool(ctx context.Context, agentPoolSpec *agentpools.Spec) (*containerservice.ManagedClusterAgentPoolProfileProperties, error) {
+	result, err := r.agentPoolsSvc.Get(ctx, agentPoolSpec)
+	if err != nil {
+		if azure.ResourceNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "failed to get existing agent pool")
+	}
+
+	pool, ok := result.(containerservice.AgentPool)
+	if !ok {
+		return nil, errors.New("expected containerservice AgentPool object")
+	}
+	return pool.ManagedClusterAgentPoolProfileProperties, nil
+}
+
+// getAgentPoolSubnetID returns the ID of the subnet to place the nodes of an agent pool in, or an empty string if
+// AKS manages the virtual network of the cluster.
+func getAgentPoolSubnetID(scope *scope.ManagedControlPlaneScope, pool *infrav1exp.AzureManagedMachinePool) (string, error) {
+	vnet := scope.Vnet()
+	if vnet == nil {
+		if pool.Spec.SubnetName != nil {
+			return "", errors.Errorf("machine pool %s has a subnet, but AzureManagedControlPlane %s has no virtual network", pool.Name, scope.ControlPlane.Name)
+		}
+		return "", nil
+	}
+
+	subnetName := scope.NodeSubnet().Name
+	if pool.Spec.SubnetName != nil {
+		subnetName = *pool.Spec.SubnetName
+	}
+	return azure.SubnetID(scope.SubscriptionID(), vnet.ResourceGroup, vnet.Name, subnetName), nil
+}
+
+// validateWindowsPool checks that the cluster of a Windows agent pool can run Windows nodes.
+func validateWindowsPool(scope *scope.ManagedControlPlaneScope, pool *infrav1exp.AzureManagedMachinePool) error {
+	if to.String(pool.Spec.OSType) != string(containerservice.OSTypeWindows) {
+		return nil
+	}
+	if plugin := scope.ControlPlane.Spec.NetworkPlugin; plugin != nil && !strings.EqualFold(*plugin, string(containerservice.NetworkPluginAzure)) {
+		return errors.Errorf("Windows machine pool %s requires the Azure network plugin", pool.Name)
+	}
+	if scope.ControlPlane.Spec.WindowsProfile == nil {
+		return errors.Errorf("Windows machine pool %s requires a Windows profile on AzureManagedControlPlane %s", pool.Name, scope.ControlPlane.Name)
+	}
+	return nil
+}
+
+// controlPlaneSupportsVersion returns true if the control plane runs a Kubernetes version at least as new as the
+// given version.
+func controlPlaneSupportsVersion(controlPlaneVersion, version string) bool {
+	cpVersion, err := semver.ParseTolerant(controlPlaneVersion)
+	if err != nil {
+		return false
+	}
+	poolVersion, err := semver.ParseTolerant(version)
+	if err != nil {
+		// Leave it to AKS to reject an invalid version.
+		return true
+	}
+	return poolVersion.LTE(cpVersion)
+}
+
 // Delete reconciles all the services in pre determined order
 func (r *azureManagedMachinePoolReconciler) Delete(ctx context.Context, scope *scope.ManagedControlPlaneScope) error {
 	agentPoolSpec := &agentpools.Spec{
@@ -0,0 +1,370 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2021-07-01/containerservice"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/golang/mock/gomock"
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/klogr"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/scope"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/agentpools"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/agentpools/mock_agentpools"
+	infrav1exp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1alpha3"
+)
+
+func TestControlPlaneSupportsVersion(t *testing.T) {
+	testcases := []struct {
+		name                string
+		controlPlaneVersion string
+		version             string
+		expected            bool
+	}{
+		{
+			name:                "same version",
+			controlPlaneVersion: "1.17.7",
+			version:             "1.17.7",
+			expected:            true,
+		},
+		{
+			name:                "older version",
+			controlPlaneVersion: "1.17.7",
+			version:             "v1.16.9",
+			expected:            true,
+		},
+		{
+			name:                "newer version",
+			controlPlaneVersion: "1.16.9",
+			version:             "1.17.7",
+			expected:            false,
+		},
+		{
+			name:                "control plane version not yet known",
+			controlPlaneVersion: "",
+			version:             "1.17.7",
+			expected:            false,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := gomega.NewWithT(t)
+			g.Expect(controlPlaneSupportsVersion(tc.controlPlaneVersion, tc.version)).To(gomega.Equal(tc.expected))
+		})
+	}
+}
+
+func TestGetAgentPoolSubnetID(t *testing.T) {
+	vnet := &infrav1exp.ManagedControlPlaneVirtualNetwork{
+		Name: "my-vnet",
+		Subnet: infrav1exp.ManagedControlPlaneSubnet{
+			Name: "my-subnet",
+		},
+	}
+
+	testcases := []struct {
+		name          string
+		vnet          *infrav1exp.ManagedControlPlaneVirtualNetwork
+		subnetName    *string
+		expected      string
+		expectedError string
+	}{
+		{
+			name:     "AKS managed virtual network",
+			expected: "",
+		},
+		{
+			name:     "default subnet",
+			vnet:     vnet,
+			expected: "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/virtualNetworks/my-vnet/subnets/my-subnet",
+		},
+		{
+			name:       "subnet of the pool",
+			vnet:       vnet,
+			subnetName: to.StringPtr("my-pool-subnet"),
+			expected:   "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.Network/virtualNetworks/my-vnet/subnets/my-pool-subnet",
+		},
+		{
+			name:          "subnet of the pool without virtual network",
+			subnetName:    to.StringPtr("my-pool-subnet"),
+			expectedError: "machine pool my-pool has a subnet, but AzureManagedControlPlane my-cluster-control-plane has no virtual network",
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := gomega.NewWithT(t)
+
+			s := &scope.ManagedControlPlaneScope{
+				AzureClients: scope.AzureClients{SubscriptionID: "123"},
+				ControlPlane: &infrav1exp.AzureManagedControlPlane{
+					ObjectMeta: metav1.ObjectMeta{Name: "my-cluster-control-plane"},
+					Spec: infrav1exp.AzureManagedControlPlaneSpec{
+						ResourceGroup:  "my-rg",
+						VirtualNetwork: tc.vnet,
+					},
+				},
+			}
+			pool := &infrav1exp.AzureManagedMachinePool{
+				ObjectMeta: metav1.ObjectMeta{Name: "my-pool"},
+				Spec: infrav1exp.AzureManagedMachinePoolSpec{
+					SubnetName: tc.subnetName,
+				},
+			}
+
+			subnetID, err := getAgentPoolSubnetID(s, pool)
+			if tc.expectedError != "" {
+				g.Expect(err).To(gomega.MatchError(tc.expectedError))
+			} else {
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+				g.Expect(subnetID).To(gomega.Equal(tc.expected))
+			}
+		})
+	}
+}
+
+func TestValidateWindowsPool(t *testing.T) {
+	windowsProfile := &infrav1exp.ManagedControlPlaneWindowsProfile{
+		AdminCredentialsSecretName: "my-cluster-windows-admin",
+	}
+
+	testcases := []struct {
+		name           string
+		osType         *string
+		networkPlugin  *string
+		windowsProfile *infrav1exp.ManagedControlPlaneWindowsProfile
+		expectedError  string
+	}{
+		{
+			name:          "Linux pool",
+			networkPlugin: to.StringPtr("kubenet"),
+		},
+		{
+			name:           "Windows pool",
+			osType:         to.StringPtr("Windows"),
+			windowsProfile: windowsProfile,
+		},
+		{
+			name:           "Windows pool with kubenet",
+			osType:         to.StringPtr("Windows"),
+			networkPlugin:  to.StringPtr("kubenet"),
+			windowsProfile: windowsProfile,
+			expectedError:  "Windows machine pool my-pool requires the Azure network plugin",
+		},
+		{
+			name:          "Windows pool without Windows profile",
+			osType:        to.StringPtr("Windows"),
+			networkPlugin: to.StringPtr("azure"),
+			expectedError: "Windows machine pool my-pool requires a Windows profile on AzureManagedControlPlane my-cluster-control-plane",
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := gomega.NewWithT(t)
+
+			s := &scope.ManagedControlPlaneScope{
+				ControlPlane: &infrav1exp.AzureManagedControlPlane{
+					ObjectMeta: metav1.ObjectMeta{Name: "my-cluster-control-plane"},
+					Spec: infrav1exp.AzureManagedControlPlaneSpec{
+						NetworkPlugin:  tc.networkPlugin,
+						WindowsProfile: tc.windowsProfile,
+					},
+				},
+			}
+			pool := &infrav1exp.AzureManagedMachinePool{
+				ObjectMeta: metav1.ObjectMeta{Name: "my-pool"},
+				Spec: infrav1exp.AzureManagedMachinePoolSpec{
+					OSType: tc.osType,
+				},
+			}
+
+			err := validateWindowsPool(s, pool)
+			if tc.expectedError != "" {
+				g.Expect(err).To(gomega.MatchError(tc.expectedError))
+			} else {
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestReconcileNodeImage(t *testing.T) {
+	testcases := []struct {
+		name              string
+		desiredVersion    string
+		currentVersion    string
+		latestVersion     string
+		provisioningState string
+		upgradeError      error
+		expectUpgrade     bool
+		expectedReason    string
+		expectedUpgraded  bool
+	}{
+		{
+			name:           "no desired version",
+			currentVersion: "AKSUbuntu-1604-2020.09.02",
+			latestVersion:  "AKSUbuntu-1604-2020.09.16",
+		},
+		{
+			name:             "desired version running",
+			desiredVersion:   "AKSUbuntu-1604-2020.09.16",
+			currentVersion:   "AKSUbuntu-1604-2020.09.16",
+			latestVersion:    "AKSUbuntu-1604-2020.09.16",
+			expectedUpgraded: true,
+		},
+		{
+			name:           "upgrade to latest version",
+			desiredVersion: "AKSUbuntu-1604-2020.09.16",
+			currentVersion: "AKSUbuntu-1604-2020.09.02",
+			latestVersion:  "AKSUbuntu-1604-2020.09.16",
+			expectUpgrade:  true,
+			expectedReason: infrav1exp.UpgradingReason,
+		},
+		{
+			name:           "upgrade fails",
+			desiredVersion: "AKSUbuntu-1604-2020.09.16",
+			currentVersion: "AKSUbuntu-1604-2020.09.02",
+			latestVersion:  "AKSUbuntu-1604-2020.09.16",
+			upgradeError:   errors.New("conflict"),
+			expectUpgrade:  true,
+			expectedReason: infrav1exp.UpgradeFailedReason,
+		},
+		{
+			name:           "desired version older than the latest",
+			desiredVersion: "AKSUbuntu-1604-2020.09.09",
+			currentVersion: "AKSUbuntu-1604-2020.09.02",
+			latestVersion:  "AKSUbuntu-1604-2020.09.16",
+			expectUpgrade:  true,
+			expectedReason: infrav1exp.UpgradingReason,
+		},
+		{
+			name:             "newer version than desired running",
+			desiredVersion:   "AKSUbuntu-1604-2020.09.09",
+			currentVersion:   "AKSUbuntu-1604-2020.09.16",
+			latestVersion:    "AKSUbuntu-1604-2020.09.16",
+			expectedUpgraded: true,
+		},
+		{
+			name:           "desired version newer than the latest",
+			desiredVersion: "AKSUbuntu-1604-2020.09.23",
+			currentVersion: "AKSUbuntu-1604-2020.09.02",
+			latestVersion:  "AKSUbuntu-1604-2020.09.16",
+			expectedReason: infrav1exp.UpgradeVersionUnavailableReason,
+		},
+		{
+			name:           "desired version of another image",
+			desiredVersion: "AKSUbuntu-1804-2020.09.09",
+			currentVersion: "AKSUbuntu-1604-2020.09.02",
+			latestVersion:  "AKSUbuntu-1604-2020.09.16",
+			expectedReason: infrav1exp.UpgradeVersionUnavailableReason,
+		},
+		{
+			name:           "upgrade to latest",
+			desiredVersion: infrav1exp.NodeImageVersionLatest,
+			currentVersion: "AKSUbuntu-1604-2020.09.02",
+			latestVersion:  "AKSUbuntu-1604-2020.09.16",
+			expectUpgrade:  true,
+			expectedReason: infrav1exp.UpgradingReason,
+		},
+		{
+			name:             "latest running",
+			desiredVersion:   infrav1exp.NodeImageVersionLatest,
+			currentVersion:   "AKSUbuntu-1604-2020.09.16",
+			latestVersion:    "AKSUbuntu-1604-2020.09.16",
+			expectedUpgraded: true,
+		},
+		{
+			name:              "upgrade in progress",
+			desiredVersion:    "AKSUbuntu-1604-2020.09.16",
+			currentVersion:    "AKSUbuntu-1604-2020.09.02",
+			latestVersion:     "AKSUbuntu-1604-2020.09.16",
+			provisioningState: "UpgradingNodeImageVersion",
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := gomega.NewWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			agentpoolsMock := mock_agentpools.NewMockClient(mockCtrl)
+
+			provisioningState := tc.provisioningState
+			if provisioningState == "" {
+				provisioningState = "Succeeded"
+			}
+			agentpoolsMock.EXPECT().Get(gomock.Any(), "my-rg", "my-cluster", "my-pool").Return(containerservice.AgentPool{
+				ManagedClusterAgentPoolProfileProperties: &containerservice.ManagedClusterAgentPoolProfileProperties{
+					ProvisioningState: to.StringPtr(provisioningState),
+					NodeImageVersion:  to.StringPtr(tc.currentVersion),
+				},
+			}, nil)
+			agentpoolsMock.EXPECT().GetUpgradeProfile(gomock.Any(), "my-rg", "my-cluster", "my-pool").Return(containerservice.AgentPoolUpgradeProfile{
+				AgentPoolUpgradeProfileProperties: &containerservice.AgentPoolUpgradeProfileProperties{
+					LatestNodeImageVersion: to.StringPtr(tc.latestVersion),
+				},
+			}, nil)
+			if tc.expectUpgrade {
+				agentpoolsMock.EXPECT().UpgradeNodeImageVersion(gomock.Any(), "my-rg", "my-cluster", "my-pool").Return(tc.upgradeError)
+			}
+
+			pool := &infrav1exp.AzureManagedMachinePool{
+				ObjectMeta: metav1.ObjectMeta{Name: "my-pool"},
+				Spec: infrav1exp.AzureManagedMachinePoolSpec{
+					NodeImageVersion: tc.desiredVersion,
+				},
+			}
+			s := &scope.ManagedControlPlaneScope{
+				Logger:           klogr.New(),
+				InfraMachinePool: pool,
+			}
+			r := &azureManagedMachinePoolReconciler{
+				agentPoolsSvc: &agentpools.Service{Client: agentpoolsMock},
+			}
+
+			err := r.reconcileNodeImage(context.TODO(), s, &agentpools.Spec{
+				Name:          "my-pool",
+				ResourceGroup: "my-rg",
+				Cluster:       "my-cluster",
+			})
+			if tc.upgradeError != nil {
+				g.Expect(err).To(gomega.HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+			}
+			g.Expect(pool.Status.NodeImageVersion).To(gomega.Equal(tc.currentVersion))
+			g.Expect(pool.Status.LatestNodeImageVersion).To(gomega.Equal(tc.latestVersion))
+			g.Expect(conditions.IsTrue(pool, infrav1exp.NodeImageUpgradedCondition)).To(gomega.Equal(tc.expectedUpgraded))
+			if tc.expectedReason != "" {
+				g.Expect(conditions.GetReason(pool, infrav1exp.NodeImageUpgradedCondition)).To(gomega.Equal(tc.expectedReason))
+			}
+		})
+	}
+}
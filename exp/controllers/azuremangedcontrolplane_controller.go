@@ -27,6 +27,7 @@ import (
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/util"
 	"sigs.k8s.io/cluster-api/util/annotations"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/predicates"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -42,6 +43,10 @@ import (
 	"sigs.k8s.io/cluster-api-provider-azure/util/reconciler"
 )
 
+// upgradePollInterval is the interval at which an AKS control plane or node pool is checked while it waits for an
+// upgrade to finish.
+const upgradePollInterval = time.Minute
+
 // AzureManagedControlPlaneReconciler reconciles a AzureManagedControlPlane object
 type AzureManagedControlPlaneReconciler struct {
 	client.Client
@@ -91,6 +96,10 @@ func (r *AzureManagedControlPlaneReconciler) SetupWithManager(mgr ctrl.Manager,
 // +kubebuilder:rbac:groups=exp.infrastructure.cluster.x-k8s.io,resources=azuremanagedcontrolplanes,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=exp.infrastructure.cluster.x-k8s.io,resources=azuremanagedcontrolplanes/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusters;clusters/status,verbs=get;list;watch
+// +kubebuilder:rbac:groups=exp.cluster.x-k8s.io,resources=machinepools,verbs=get;list;watch;create
+// +kubebuilder:rbac:groups=exp.infrastructure.cluster.x-k8s.io,resources=azuremanagedmachinepools,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=azureclusteridentities,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
 
 func (r *AzureManagedControlPlaneReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result, reterr error) {
 	ctx, cancel := context.WithTimeout(context.Background(), reconciler.DefaultedLoopTimeout(r.ReconcileTimeout))
@@ -125,6 +134,27 @@ func (r *AzureManagedControlPlaneReconciler) Reconcile(req ctrl.Request) (_ ctrl
 		return ctrl.Result{}, nil
 	}
 
+	// Import an existing AKS cluster before anything is reconciled against it.
+	if azureControlPlane.Spec.Adopt && !conditions.IsTrue(azureControlPlane, infrav1exp.AdoptedCondition) && azureControlPlane.DeletionTimestamp.IsZero() {
+		adoptionScope, err := scope.NewManagedControlPlaneScope(scope.ManagedControlPlaneScopeParams{
+			Client:       r.Client,
+			Context:      ctx,
+			Logger:       log,
+			Cluster:      cluster,
+			ControlPlane: azureControlPlane,
+			PatchTarget:  azureControlPlane,
+		})
+		if err != nil {
+			return reconcile.Result{}, errors.Errorf("failed to create scope: %+v", err)
+		}
+		defer func() {
+			if err := adoptionScope.PatchObject(ctx); err != nil && reterr == nil {
+				reterr = err
+			}
+		}()
+		return r.reconcileAdoption(ctx, adoptionScope)
+	}
+
 	// fetch default pool
 	defaultPoolKey := client.ObjectKey{
 		Name:      azureControlPlane.Spec.DefaultPoolRef.Name,
@@ -151,12 +181,30 @@ func (r *AzureManagedControlPlaneReconciler) Reconcile(req ctrl.Request) (_ ctrl
 
 	log = log.WithValues("machinePool", ownerPool.Name)
 
+	// fetch the AzureManagedCluster, which carries the infrastructure shared with the AKS cluster
+	var infraCluster *infrav1exp.AzureManagedCluster
+	if ref := cluster.Spec.InfrastructureRef; ref != nil {
+		infraCluster = &infrav1exp.AzureManagedCluster{}
+		infraClusterKey := client.ObjectKey{
+			Name:      ref.Name,
+			Namespace: cluster.Namespace,
+		}
+		if err := r.Client.Get(ctx, infraClusterKey, infraCluster); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return reconcile.Result{}, errors.Wrapf(err, "failed to fetch AzureManagedCluster")
+			}
+			infraCluster = nil
+		}
+	}
+
 	// Create the scope.
 	mcpScope, err := scope.NewManagedControlPlaneScope(scope.ManagedControlPlaneScopeParams{
 		Client:           r.Client,
+		Context:          ctx,
 		Logger:           log,
 		Cluster:          cluster,
 		ControlPlane:     azureControlPlane,
+		InfraCluster:     infraCluster,
 		MachinePool:      ownerPool,
 		InfraMachinePool: defaultPool,
 		PatchTarget:      azureControlPlane,
@@ -199,6 +247,11 @@ func (r *AzureManagedControlPlaneReconciler) reconcileNormal(ctx context.Context
 	scope.ControlPlane.Status.Ready = true
 	scope.ControlPlane.Status.Initialized = true
 
+	// AKS doesn't update a managed cluster while an operation is in progress, so check back until the upgrade is done.
+	if conditions.GetReason(scope.ControlPlane, infrav1exp.KubernetesVersionUpgradedCondition) == infrav1exp.UpgradingReason {
+		return reconcile.Result{RequeueAfter: upgradePollInterval}, nil
+	}
+
 	return reconcile.Result{}, nil
 }
 
@@ -19,33 +19,57 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 
-	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2020-02-01/containerservice"
+	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2021-07-01/containerservice"
+	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientcmdv1 "k8s.io/client-go/tools/clientcmd/api/v1"
 	azure "sigs.k8s.io/cluster-api-provider-azure/cloud"
 	"sigs.k8s.io/cluster-api-provider-azure/cloud/scope"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/identities"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/maintenanceconfigurations"
 	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/managedclusters"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/roleassignments"
 	infrav1exp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1alpha3"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/secret"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/yaml"
 )
 
+// adminKubeconfigPurpose is the purpose of the Secret holding the admin kubeconfig of an AKS cluster.
+const adminKubeconfigPurpose secret.Purpose = "admin-kubeconfig"
+
+// kubeletIdentityName is the key of the identity of the kubelets in the identity profile of an AKS cluster.
+const kubeletIdentityName = "kubeletidentity"
+
+// logAnalyticsAddonName is the name of the AKS add-on that sends container logs and metrics to Log Analytics.
+const logAnalyticsAddonName = "omsagent"
+
 // azureManagedControlPlaneReconciler are list of services required by cluster controller
 type azureManagedControlPlaneReconciler struct {
-	kubeclient         client.Client
-	managedClustersSvc *managedclusters.Service
+	kubeclient                   client.Client
+	managedClustersSvc           *managedclusters.Service
+	maintenanceConfigurationsSvc *maintenanceconfigurations.Service
+	identitiesSvc                *identities.Service
+	roleAssignmentsSvc           *roleassignments.Service
 }
 
 // newAzureManagedControlPlaneReconciler populates all the services based on input scope
 func newAzureManagedControlPlaneReconciler(scope *scope.ManagedControlPlaneScope) *azureManagedControlPlaneReconciler {
 	return &azureManagedControlPlaneReconciler{
-		kubeclient:         scope.Client,
-		managedClustersSvc: managedclusters.NewService(scope),
+		kubeclient:                   scope.Client,
+		managedClustersSvc:           managedclusters.NewService(scope),
+		maintenanceConfigurationsSvc: maintenanceconfigurations.NewService(scope),
+		identitiesSvc:                identities.NewService(scope),
+		roleAssignmentsSvc:           roleassignments.NewService(scope),
 	}
 }
 
@@ -60,6 +84,79 @@ func (r *azureManagedControlPlaneReconciler) Reconcile(ctx context.Context, scop
 		NetworkPlugin: scope.ControlPlane.Spec.NetworkPlugin,
 		NetworkPolicy: scope.ControlPlane.Spec.NetworkPolicy,
 		SSHPublicKey:  scope.ControlPlane.Spec.SSHPublicKey,
+
+		NodeResourceGroup:  scope.ControlPlane.Spec.NodeResourceGroup,
+		OutboundType:       scope.ControlPlane.Spec.OutboundType,
+		AutoUpgradeChannel: scope.ControlPlane.Spec.AutoUpgradeChannel,
+	}
+
+	if scope.ControlPlane.Spec.WindowsProfile != nil {
+		username, password, err := scope.GetWindowsAdminCredentials(ctx)
+		if err != nil {
+			return err
+		}
+		managedClusterSpec.WindowsProfile = &managedclusters.WindowsProfile{
+			AdminUsername: username,
+			AdminPassword: password,
+		}
+	}
+
+	if scope.ControlPlane.Spec.SKU != nil {
+		managedClusterSpec.SKUTier = scope.ControlPlane.Spec.SKU.Tier
+	}
+
+	if profile := scope.ControlPlane.Spec.LoadBalancerProfile; profile != nil {
+		managedClusterSpec.LoadBalancerProfile = &managedclusters.LoadBalancerProfile{
+			ManagedOutboundIPs:     profile.ManagedOutboundIPs,
+			OutboundIPs:            profile.OutboundIPs,
+			OutboundIPPrefixes:     profile.OutboundIPPrefixes,
+			AllocatedOutboundPorts: profile.AllocatedOutboundPorts,
+			IdleTimeoutInMinutes:   profile.IdleTimeoutInMinutes,
+		}
+	}
+
+	if profile := scope.ControlPlane.Spec.NATGatewayProfile; profile != nil {
+		managedClusterSpec.NATGatewayProfile = &managedclusters.NATGatewayProfile{
+			ManagedOutboundIPs:   profile.ManagedOutboundIPs,
+			IdleTimeoutInMinutes: profile.IdleTimeoutInMinutes,
+		}
+	}
+
+	if profile := scope.ControlPlane.Spec.APIServerAccessProfile; profile != nil {
+		managedClusterSpec.APIServerAccessProfile = &managedclusters.APIServerAccessProfile{
+			AuthorizedIPRanges:   profile.AuthorizedIPRanges,
+			EnablePrivateCluster: profile.EnablePrivateCluster,
+			PrivateDNSZone:       profile.PrivateDNSZone,
+		}
+	}
+
+	if profile := scope.ControlPlane.Spec.AADProfile; profile != nil {
+		managedClusterSpec.AADProfile = &managedclusters.AADProfile{
+			AdminGroupObjectIDs: profile.AdminGroupObjectIDs,
+			TenantID:            profile.TenantID,
+			EnableAzureRBAC:     profile.EnableAzureRBAC,
+		}
+	}
+	managedClusterSpec.EnableRBAC = scope.ControlPlane.Spec.EnableRBAC
+	managedClusterSpec.DisableLocalAccounts = scope.ControlPlane.Spec.DisableLocalAccounts
+	if to.Bool(managedClusterSpec.DisableLocalAccounts) && to.Bool(scope.ControlPlane.Spec.StoreAdminKubeconfig) {
+		return errors.New("storeAdminKubeconfig is not supported when local accounts are disabled")
+	}
+
+	for _, addon := range scope.ControlPlane.Spec.AddonProfiles {
+		managedClusterSpec.AddonProfiles = append(managedClusterSpec.AddonProfiles, managedclusters.AddonProfile{
+			Name:    addon.Name,
+			Enabled: addon.Enabled,
+			Config:  addon.Config,
+		})
+	}
+	if addon := getLogAnalyticsAddonProfile(scope); addon != nil {
+		managedClusterSpec.AddonProfiles = append(managedClusterSpec.AddonProfiles, *addon)
+	}
+
+	scope.Logger.V(2).Info("Reconciling identities")
+	if err := r.reconcileIdentity(ctx, scope, managedClusterSpec); err != nil {
+		return errors.Wrapf(err, "failed to reconcile identities")
 	}
 
 	scope.Logger.V(2).Info("Reconciling managed cluster")
@@ -97,10 +194,45 @@ func (r *azureManagedControlPlaneReconciler) Delete(ctx context.Context, scope *
 		return errors.Wrapf(err, "failed to delete managed cluster %s", scope.ControlPlane.Name)
 	}
 
+	// The infrastructure shared with the AKS cluster is reconciled by the AzureManagedCluster, but can only be deleted
+	// once the AKS cluster is gone, which happens before the AzureManagedCluster is deleted.
+	if err := newAzureManagedClusterReconciler(scope).Delete(ctx, scope); err != nil {
+		return errors.Wrapf(err, "failed to delete infrastructure of managed cluster %s", scope.ControlPlane.Name)
+	}
+
 	return nil
 }
 
+// getLogAnalyticsAddonProfile returns the omsagent add-on for the Log Analytics workspace of the AzureManagedCluster,
+// unless the workspace isn't set or the AzureManagedControlPlane configures the add-on itself.
+func getLogAnalyticsAddonProfile(scope *scope.ManagedControlPlaneScope) *managedclusters.AddonProfile {
+	if scope.InfraCluster == nil || scope.InfraCluster.Spec.LogAnalyticsWorkspaceID == "" {
+		return nil
+	}
+	for _, addon := range scope.ControlPlane.Spec.AddonProfiles {
+		if strings.EqualFold(addon.Name, logAnalyticsAddonName) {
+			return nil
+		}
+	}
+	return &managedclusters.AddonProfile{
+		Name:    logAnalyticsAddonName,
+		Enabled: true,
+		Config: map[string]string{
+			"logAnalyticsWorkspaceResourceID": scope.InfraCluster.Spec.LogAnalyticsWorkspaceID,
+		},
+	}
+}
+
 func (r *azureManagedControlPlaneReconciler) reconcileManagedCluster(ctx context.Context, scope *scope.ManagedControlPlaneScope, managedClusterSpec *managedclusters.Spec) error {
+	// With user defined routing, egress goes through the route table of the cluster's own subnet.
+	if to.String(managedClusterSpec.OutboundType) == string(containerservice.OutboundTypeUserDefinedRouting) && scope.Vnet() == nil {
+		return errors.New("the userDefinedRouting outbound type requires a virtual network")
+	}
+	// AKS attaches the NAT gateway to the virtual network it manages, custom virtual networks need their own.
+	if to.String(managedClusterSpec.OutboundType) == string(containerservice.OutboundTypeManagedNATGateway) && scope.Vnet() != nil {
+		return errors.New("the managedNATGateway outbound type requires a virtual network managed by AKS")
+	}
+
 	if net := scope.Cluster.Spec.ClusterNetwork; net != nil {
 		if net.Services != nil {
 			// A user may provide zero or one CIDR blocks. If they provide an empty array,
@@ -124,7 +256,7 @@ func (r *azureManagedControlPlaneReconciler) reconcileManagedCluster(ctx context
 		}
 	}
 
-	_, err := r.managedClustersSvc.Get(ctx, managedClusterSpec)
+	existingResult, err := r.managedClustersSvc.Get(ctx, managedClusterSpec)
 	// Transient or other failure not due to 404
 	if err != nil && !azure.ResourceNotFound(err) {
 		return errors.Wrapf(err, "failed to fetch existing managed cluster")
@@ -140,12 +272,27 @@ func (r *azureManagedControlPlaneReconciler) reconcileManagedCluster(ctx context
 			SKU:          scope.InfraMachinePool.Spec.SKU,
 			Replicas:     1,
 			OSDiskSizeGB: 0,
+
+			OSType:            to.String(scope.InfraMachinePool.Spec.OSType),
+			Mode:              scope.InfraMachinePool.Spec.Mode,
+			EnableAutoScaling: to.Bool(scope.InfraMachinePool.Spec.EnableAutoScaling),
+			MinCount:          scope.InfraMachinePool.Spec.MinCount,
+			MaxCount:          scope.InfraMachinePool.Spec.MaxCount,
+			NodeLabels:        scope.InfraMachinePool.Spec.NodeLabels,
+			NodeTaints:        scope.InfraMachinePool.Spec.NodeTaints,
+			MaxPods:           scope.InfraMachinePool.Spec.MaxPods,
+			AvailabilityZones: scope.InfraMachinePool.Spec.AvailabilityZones,
 		}
 
 		// Set optional values
 		if scope.InfraMachinePool.Spec.OSDiskSizeGB != nil {
 			defaultPoolSpec.OSDiskSizeGB = *scope.InfraMachinePool.Spec.OSDiskSizeGB
 		}
+		subnetID, err := getAgentPoolSubnetID(scope, scope.InfraMachinePool)
+		if err != nil {
+			return err
+		}
+		defaultPoolSpec.VnetSubnetID = subnetID
 		if scope.MachinePool.Spec.Replicas != nil {
 			defaultPoolSpec.Replicas = *scope.MachinePool.Spec.Replicas
 		}
@@ -154,13 +301,156 @@ func (r *azureManagedControlPlaneReconciler) reconcileManagedCluster(ctx context
 		managedClusterSpec.AgentPools = []managedclusters.PoolSpec{defaultPoolSpec}
 	}
 
+	upgrading := false
+	if err == nil {
+		existingMC, ok := existingResult.(containerservice.ManagedCluster)
+		if !ok {
+			return fmt.Errorf("expected containerservice ManagedCluster object")
+		}
+		if upgrading, err = r.reconcileVersion(ctx, scope, managedClusterSpec, existingMC); err != nil {
+			return errors.Wrapf(err, "failed to reconcile Kubernetes version of managed cluster %s", scope.ControlPlane.Name)
+		}
+	}
+
 	// Send to Azure for create/update.
 	if err := r.managedClustersSvc.Reconcile(ctx, managedClusterSpec); err != nil {
+		if upgrading {
+			conditions.MarkFalse(scope.ControlPlane, infrav1exp.KubernetesVersionUpgradedCondition, infrav1exp.UpgradeFailedReason, clusterv1.ConditionSeverityError, "%v", err)
+		}
 		return errors.Wrapf(err, "failed to reconcile managed cluster %s", scope.ControlPlane.Name)
 	}
+
+	if err := r.reconcileMaintenanceWindow(ctx, scope); err != nil {
+		return errors.Wrapf(err, "failed to reconcile maintenance window of managed cluster %s", scope.ControlPlane.Name)
+	}
+
+	// Fetch the cluster again to report the version it is running.
+	managedClusterResult, err := r.managedClustersSvc.Get(ctx, managedClusterSpec)
+	if err != nil {
+		return errors.Wrapf(err, "failed to fetch managed cluster")
+	}
+	managedCluster, ok := managedClusterResult.(containerservice.ManagedCluster)
+	if !ok {
+		return fmt.Errorf("expected containerservice ManagedCluster object")
+	}
+	if managedCluster.ManagedClusterProperties != nil {
+		scope.ControlPlane.Status.NodeResourceGroup = to.String(managedCluster.NodeResourceGroup)
+		scope.ControlPlane.Status.AddonProfiles = getAddonProfileStatuses(managedCluster.AddonProfiles)
+		if kubeletIdentity, ok := managedCluster.IdentityProfile[kubeletIdentityName]; ok && kubeletIdentity != nil {
+			scope.ControlPlane.Status.KubeletIdentityObjectID = to.String(kubeletIdentity.ObjectID)
+		}
+		setVersionStatus(scope, managedCluster)
+	}
+
 	return nil
 }
 
+// setVersionStatus reports the Kubernetes version of the managed cluster once AKS finished provisioning it. AKS
+// reports the target version as soon as an upgrade starts, so until the provisioning state is Succeeded the
+// KubernetesVersionUpgraded condition stays false with the Upgrading reason and the controller checks back.
+func setVersionStatus(scope *scope.ManagedControlPlaneScope, managedCluster containerservice.ManagedCluster) {
+	version := to.String(managedCluster.KubernetesVersion)
+	desiredVersion := strings.TrimPrefix(scope.ControlPlane.Spec.Version, "v")
+	if ps := to.String(managedCluster.ProvisioningState); ps != "Succeeded" {
+		if version == desiredVersion && scope.ControlPlane.Status.Version != desiredVersion {
+			conditions.MarkFalse(scope.ControlPlane, infrav1exp.KubernetesVersionUpgradedCondition, infrav1exp.UpgradingReason, clusterv1.ConditionSeverityInfo,
+				"Waiting for managed cluster to be provisioned with Kubernetes version %s, provisioning state is %s", desiredVersion, ps)
+		}
+		return
+	}
+
+	scope.ControlPlane.Status.Version = version
+	if version == desiredVersion {
+		conditions.MarkTrue(scope.ControlPlane, infrav1exp.KubernetesVersionUpgradedCondition)
+	}
+}
+
+// reconcileIdentity resolves the user-assigned identities of the control plane. A user-assigned kubelet identity
+// requires the control plane identity to have the Managed Identity Operator role on it, which is granted unless the
+// control plane identity already has it, e.g. through a role assignment on the resource group of the identities.
+func (r *azureManagedControlPlaneReconciler) reconcileIdentity(ctx context.Context, scope *scope.ManagedControlPlaneScope, managedClusterSpec *managedclusters.Spec) error {
+	identity := scope.ControlPlane.Spec.Identity
+	if identity == nil {
+		return nil
+	}
+	managedClusterSpec.ControlPlaneIdentityID = identity.ControlPlaneIdentityID
+	if identity.KubeletIdentityID == "" {
+		return nil
+	}
+
+	controlPlaneIdentity, err := r.identitiesSvc.GetByID(ctx, identity.ControlPlaneIdentityID)
+	if err != nil {
+		return err
+	}
+	kubeletIdentity, err := r.identitiesSvc.GetByID(ctx, identity.KubeletIdentityID)
+	if err != nil {
+		return err
+	}
+
+	clusterID := azure.ManagedClusterID(scope.SubscriptionID(), scope.ResourceGroup(), scope.ControlPlane.Name)
+	roleSpec, err := roleassignments.SpecForManagedIdentity(clusterID, identity.KubeletIdentityID, to.String(controlPlaneIdentity.ObjectID))
+	if err != nil {
+		return err
+	}
+	if err := r.roleAssignmentsSvc.ReconcileGrant(ctx, &roleSpec); err != nil {
+		return errors.Wrapf(err, "failed to grant the control plane identity the Managed Identity Operator role on the kubelet identity")
+	}
+
+	managedClusterSpec.KubeletIdentity = &kubeletIdentity
+	return nil
+}
+
+// reconcileMaintenanceWindow applies the maintenance window of the control plane to the managed cluster. A window
+// without any times removes the maintenance configuration, one that isn't declared is left as it is.
+func (r *azureManagedControlPlaneReconciler) reconcileMaintenanceWindow(ctx context.Context, scope *scope.ManagedControlPlaneScope) error {
+	window := scope.ControlPlane.Spec.MaintenanceWindow
+	if window == nil {
+		return nil
+	}
+
+	maintenanceConfigurationSpec := &maintenanceconfigurations.Spec{
+		Name:              maintenanceconfigurations.DefaultName,
+		ResourceGroup:     scope.ControlPlane.Spec.ResourceGroup,
+		Cluster:           scope.ControlPlane.Name,
+		MaintenanceWindow: *window,
+	}
+	if len(window.TimeInWeek) == 0 && len(window.NotAllowedTime) == 0 {
+		return r.maintenanceConfigurationsSvc.Delete(ctx, maintenanceConfigurationSpec)
+	}
+	return r.maintenanceConfigurationsSvc.Reconcile(ctx, maintenanceConfigurationSpec)
+}
+
+// reconcileVersion validates a change of the Kubernetes version of an existing managed cluster against the
+// upgrades AKS offers from its current version. A version that isn't available holds the control plane at its
+// current version, so that other changes to the managed cluster are still applied.
+func (r *azureManagedControlPlaneReconciler) reconcileVersion(ctx context.Context, scope *scope.ManagedControlPlaneScope, managedClusterSpec *managedclusters.Spec, existingMC containerservice.ManagedCluster) (bool, error) {
+	if existingMC.ManagedClusterProperties == nil || existingMC.KubernetesVersion == nil {
+		return false, nil
+	}
+
+	currentVersion := *existingMC.KubernetesVersion
+	if currentVersion == managedClusterSpec.Version {
+		return false, nil
+	}
+
+	availableVersions, err := r.managedClustersSvc.GetAvailableUpgrades(ctx, managedClusterSpec.ResourceGroup, managedClusterSpec.Name)
+	if err != nil {
+		return false, err
+	}
+
+	if !containsString(availableVersions, managedClusterSpec.Version) {
+		conditions.MarkFalse(scope.ControlPlane, infrav1exp.KubernetesVersionUpgradedCondition, infrav1exp.UpgradeVersionUnavailableReason, clusterv1.ConditionSeverityError,
+			"Kubernetes version %s is not an available upgrade from %s, available upgrades are [%s]", managedClusterSpec.Version, currentVersion, strings.Join(availableVersions, ", "))
+		managedClusterSpec.Version = currentVersion
+		return false, nil
+	}
+
+	scope.Logger.Info("Upgrading managed cluster control plane", "from", currentVersion, "to", managedClusterSpec.Version)
+	conditions.MarkFalse(scope.ControlPlane, infrav1exp.KubernetesVersionUpgradedCondition, infrav1exp.UpgradingReason, clusterv1.ConditionSeverityInfo,
+		"upgrading control plane from Kubernetes version %s to %s", currentVersion, managedClusterSpec.Version)
+	return true, nil
+}
+
 func (r *azureManagedControlPlaneReconciler) reconcileEndpoint(ctx context.Context, scope *scope.ManagedControlPlaneScope, managedClusterSpec *managedclusters.Spec) error {
 	// Fetch newly updated cluster
 	managedClusterResult, err := r.managedClustersSvc.Get(ctx, managedClusterSpec)
@@ -173,29 +463,29 @@ func (r *azureManagedControlPlaneReconciler) reconcileEndpoint(ctx context.Conte
 		return fmt.Errorf("expected containerservice ManagedCluster object")
 	}
 
-	old := scope.ControlPlane.DeepCopyObject()
-
+	// The endpoint is persisted with the rest of the control plane by the scope's patch helper.
 	scope.ControlPlane.Spec.ControlPlaneEndpoint = clusterv1.APIEndpoint{
-		Host: *managedCluster.ManagedClusterProperties.Fqdn,
+		Host: getAPIServerFQDN(managedCluster),
 		Port: 443,
 	}
 
-	if err := r.kubeclient.Patch(ctx, scope.ControlPlane, client.MergeFrom(old)); err != nil {
-		return errors.Wrapf(err, "failed to set control plane endpoint")
-	}
-
 	return nil
 }
 
 func (r *azureManagedControlPlaneReconciler) reconcileKubeconfig(ctx context.Context, scope *scope.ManagedControlPlaneScope, managedClusterSpec *managedclusters.Spec) error {
-	// Always fetch credentials in case of rotation
-	data, err := r.managedClustersSvc.GetCredentials(ctx, managedClusterSpec.ResourceGroup, managedClusterSpec.Name)
+	// Always fetch credentials in case of rotation. Clusters integrated with AAD get the user
+	// kubeconfig, so that the admin credentials are only stored on request.
+	getCredentials := r.managedClustersSvc.GetCredentials
+	if managedClusterSpec.AADProfile != nil {
+		getCredentials = r.managedClustersSvc.GetUserCredentials
+	}
+	data, err := r.getKubeconfig(ctx, managedClusterSpec, getCredentials)
 	if err != nil {
-		return errors.Wrapf(err, "failed to get credentials for managed cluster")
+		return err
 	}
 
 	// Construct and store secret
-	kubeconfig := makeKubeconfig(scope.Cluster, scope.ControlPlane)
+	kubeconfig := makeKubeconfig(scope.Cluster, scope.ControlPlane, secret.Kubeconfig)
 	if _, err := controllerutil.CreateOrUpdate(ctx, r.kubeclient, kubeconfig, func() error {
 		kubeconfig.Data = map[string][]byte{
 			secret.KubeconfigDataName: data,
@@ -204,13 +494,117 @@ func (r *azureManagedControlPlaneReconciler) reconcileKubeconfig(ctx context.Con
 	}); err != nil {
 		return errors.Wrapf(err, "failed to kubeconfig secret for cluster")
 	}
+
+	adminKubeconfig := makeKubeconfig(scope.Cluster, scope.ControlPlane, adminKubeconfigPurpose)
+	if !to.Bool(scope.ControlPlane.Spec.StoreAdminKubeconfig) {
+		key := client.ObjectKey{Namespace: adminKubeconfig.Namespace, Name: adminKubeconfig.Name}
+		if err := r.kubeclient.Get(ctx, key, adminKubeconfig); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		if err := r.kubeclient.Delete(ctx, adminKubeconfig); err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete admin kubeconfig secret for cluster")
+		}
+		return nil
+	}
+
+	adminData, err := r.getKubeconfig(ctx, managedClusterSpec, r.managedClustersSvc.GetCredentials)
+	if err != nil {
+		return err
+	}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.kubeclient, adminKubeconfig, func() error {
+		adminKubeconfig.Data = map[string][]byte{
+			secret.KubeconfigDataName: adminData,
+		}
+		return nil
+	}); err != nil {
+		return errors.Wrapf(err, "failed to admin kubeconfig secret for cluster")
+	}
 	return nil
 }
 
-func makeKubeconfig(cluster *clusterv1.Cluster, controlPlane *infrav1exp.AzureManagedControlPlane) *corev1.Secret {
+// getKubeconfig fetches a kubeconfig of a managed cluster with the given credentials function. The kubeconfig of a
+// private cluster is pointed at its private endpoint.
+func (r *azureManagedControlPlaneReconciler) getKubeconfig(ctx context.Context, managedClusterSpec *managedclusters.Spec, getCredentials func(context.Context, string, string) ([]byte, error)) ([]byte, error) {
+	data, err := getCredentials(ctx, managedClusterSpec.ResourceGroup, managedClusterSpec.Name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get credentials for managed cluster")
+	}
+
+	if !isPrivateCluster(managedClusterSpec) {
+		return data, nil
+	}
+
+	managedClusterResult, err := r.managedClustersSvc.Get(ctx, managedClusterSpec)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to fetch managed cluster")
+	}
+	managedCluster, ok := managedClusterResult.(containerservice.ManagedCluster)
+	if !ok {
+		return nil, fmt.Errorf("expected containerservice ManagedCluster object")
+	}
+	if data, err = setKubeconfigServer(data, getAPIServerFQDN(managedCluster)); err != nil {
+		return nil, errors.Wrapf(err, "failed to set private endpoint in kubeconfig")
+	}
+	return data, nil
+}
+
+// getAddonProfileStatuses returns the enabled add-ons of a managed cluster and their identities, sorted by name.
+func getAddonProfileStatuses(addonProfiles map[string]*containerservice.ManagedClusterAddonProfile) []infrav1exp.AddonProfileStatus {
+	var statuses []infrav1exp.AddonProfileStatus
+	for name, profile := range addonProfiles {
+		if profile == nil || !to.Bool(profile.Enabled) {
+			continue
+		}
+		status := infrav1exp.AddonProfileStatus{Name: name}
+		if profile.Identity != nil {
+			status.Identity = &infrav1exp.AddonIdentity{
+				ResourceID: to.String(profile.Identity.ResourceID),
+				ClientID:   to.String(profile.Identity.ClientID),
+				ObjectID:   to.String(profile.Identity.ObjectID),
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+// isPrivateCluster returns true if the managed cluster has an API server that is only reachable from within its
+// virtual network.
+func isPrivateCluster(managedClusterSpec *managedclusters.Spec) bool {
+	return managedClusterSpec.APIServerAccessProfile != nil && to.Bool(managedClusterSpec.APIServerAccessProfile.EnablePrivateCluster)
+}
+
+// getAPIServerFQDN returns the FQDN of the API server of a managed cluster, which is the private FQDN for private
+// clusters.
+func getAPIServerFQDN(managedCluster containerservice.ManagedCluster) string {
+	if managedCluster.ManagedClusterProperties == nil {
+		return ""
+	}
+	if managedCluster.APIServerAccessProfile != nil && to.Bool(managedCluster.APIServerAccessProfile.EnablePrivateCluster) && managedCluster.PrivateFQDN != nil {
+		return *managedCluster.PrivateFQDN
+	}
+	return to.String(managedCluster.Fqdn)
+}
+
+// setKubeconfigServer points every cluster in a kubeconfig at the API server with the given FQDN.
+func setKubeconfigServer(data []byte, fqdn string) ([]byte, error) {
+	config := &clientcmdv1.Config{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, errors.Wrap(err, "failed to parse kubeconfig")
+	}
+	for i := range config.Clusters {
+		config.Clusters[i].Cluster.Server = fmt.Sprintf("https://%s:443", fqdn)
+	}
+	return yaml.Marshal(config)
+}
+
+func makeKubeconfig(cluster *clusterv1.Cluster, controlPlane *infrav1exp.AzureManagedControlPlane, purpose secret.Purpose) *corev1.Secret {
 	return &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      secret.Name(cluster.Name, secret.Kubeconfig),
+			Name:      secret.Name(cluster.Name, purpose),
 			Namespace: cluster.Namespace,
 			OwnerReferences: []metav1.OwnerReference{
 				*metav1.NewControllerRef(controlPlane, infrav1exp.GroupVersion.WithKind("AzureManagedControlPlane")),
@@ -0,0 +1,674 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/profiles/2019-03-01/authorization/mgmt/authorization"
+	"github.com/Azure/azure-sdk-for-go/services/containerservice/mgmt/2021-07-01/containerservice"
+	"github.com/Azure/azure-sdk-for-go/services/msi/mgmt/2018-11-30/msi"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/gofrs/uuid"
+	"github.com/golang/mock/gomock"
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/klogr"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/secret"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/scope"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/identities"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/identities/mock_identities"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/maintenanceconfigurations"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/maintenanceconfigurations/mock_maintenanceconfigurations"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/managedclusters"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/managedclusters/mock_managedclusters"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/roleassignments"
+	"sigs.k8s.io/cluster-api-provider-azure/cloud/services/roleassignments/mock_roleassignments"
+	infrav1exp "sigs.k8s.io/cluster-api-provider-azure/exp/api/v1alpha3"
+)
+
+func TestAzureManagedControlPlaneReconcileVersion(t *testing.T) {
+	upgradeProfile := containerservice.ManagedClusterUpgradeProfile{
+		ManagedClusterUpgradeProfileProperties: &containerservice.ManagedClusterUpgradeProfileProperties{
+			ControlPlaneProfile: &containerservice.ManagedClusterPoolUpgradeProfile{
+				KubernetesVersion: to.StringPtr("1.16.9"),
+				Upgrades: &[]containerservice.ManagedClusterPoolUpgradeProfileUpgradesItem{
+					{KubernetesVersion: to.StringPtr("1.17.7")},
+				},
+			},
+		},
+	}
+
+	testcases := []struct {
+		name              string
+		currentVersion    string
+		desiredVersion    string
+		expectedUpgrading bool
+		expectedVersion   string
+		expectedReason    string
+		expect            func(m *mock_managedclusters.MockClientMockRecorder)
+	}{
+		{
+			name:            "version unchanged",
+			currentVersion:  "1.16.9",
+			desiredVersion:  "1.16.9",
+			expectedVersion: "1.16.9",
+			expect:          func(m *mock_managedclusters.MockClientMockRecorder) {},
+		},
+		{
+			name:              "available upgrade",
+			currentVersion:    "1.16.9",
+			desiredVersion:    "1.17.7",
+			expectedUpgrading: true,
+			expectedVersion:   "1.17.7",
+			expectedReason:    infrav1exp.UpgradingReason,
+			expect: func(m *mock_managedclusters.MockClientMockRecorder) {
+				m.GetUpgradeProfile(context.TODO(), "my-rg", "my-cluster").Return(upgradeProfile, nil)
+			},
+		},
+		{
+			name:            "unavailable upgrade holds the current version",
+			currentVersion:  "1.16.9",
+			desiredVersion:  "1.18.4",
+			expectedVersion: "1.16.9",
+			expectedReason:  infrav1exp.UpgradeVersionUnavailableReason,
+			expect: func(m *mock_managedclusters.MockClientMockRecorder) {
+				m.GetUpgradeProfile(context.TODO(), "my-rg", "my-cluster").Return(upgradeProfile, nil)
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := gomega.NewWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			managedclusterMock := mock_managedclusters.NewMockClient(mockCtrl)
+
+			tc.expect(managedclusterMock.EXPECT())
+
+			r := &azureManagedControlPlaneReconciler{
+				managedClustersSvc: &managedclusters.Service{Client: managedclusterMock},
+			}
+			s := &scope.ManagedControlPlaneScope{
+				Logger:       klogr.New(),
+				ControlPlane: &infrav1exp.AzureManagedControlPlane{},
+			}
+			spec := &managedclusters.Spec{
+				Name:          "my-cluster",
+				ResourceGroup: "my-rg",
+				Version:       tc.desiredVersion,
+			}
+			existing := containerservice.ManagedCluster{
+				ManagedClusterProperties: &containerservice.ManagedClusterProperties{
+					KubernetesVersion: to.StringPtr(tc.currentVersion),
+				},
+			}
+
+			upgrading, err := r.reconcileVersion(context.TODO(), s, spec, existing)
+			g.Expect(err).NotTo(gomega.HaveOccurred())
+			g.Expect(upgrading).To(gomega.Equal(tc.expectedUpgrading))
+			g.Expect(spec.Version).To(gomega.Equal(tc.expectedVersion))
+			if tc.expectedReason == "" {
+				g.Expect(conditions.Has(s.ControlPlane, infrav1exp.KubernetesVersionUpgradedCondition)).To(gomega.BeFalse())
+			} else {
+				g.Expect(conditions.IsFalse(s.ControlPlane, infrav1exp.KubernetesVersionUpgradedCondition)).To(gomega.BeTrue())
+				g.Expect(conditions.GetReason(s.ControlPlane, infrav1exp.KubernetesVersionUpgradedCondition)).To(gomega.Equal(tc.expectedReason))
+			}
+			if tc.expectedReason == infrav1exp.UpgradeVersionUnavailableReason {
+				g.Expect(*conditions.GetSeverity(s.ControlPlane, infrav1exp.KubernetesVersionUpgradedCondition)).To(gomega.Equal(clusterv1.ConditionSeverityError))
+			}
+		})
+	}
+}
+
+func TestSetVersionStatus(t *testing.T) {
+	testcases := []struct {
+		name              string
+		provisioningState string
+		version           string
+		statusVersion     string
+		expectedVersion   string
+		expectedReason    string
+	}{
+		{
+			name:              "upgrade succeeded",
+			provisioningState: "Succeeded",
+			version:           "1.17.7",
+			statusVersion:     "1.16.9",
+			expectedVersion:   "1.17.7",
+		},
+		{
+			name:              "upgrade in progress",
+			provisioningState: "Upgrading",
+			version:           "1.17.7",
+			statusVersion:     "1.16.9",
+			expectedVersion:   "1.16.9",
+			expectedReason:    infrav1exp.UpgradingReason,
+		},
+		{
+			name:              "upgrade failed",
+			provisioningState: "Failed",
+			version:           "1.17.7",
+			statusVersion:     "1.16.9",
+			expectedVersion:   "1.16.9",
+			expectedReason:    infrav1exp.UpgradingReason,
+		},
+		{
+			name:              "creation in progress",
+			provisioningState: "Creating",
+			version:           "1.17.7",
+			expectedReason:    infrav1exp.UpgradingReason,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := gomega.NewWithT(t)
+			s := &scope.ManagedControlPlaneScope{
+				ControlPlane: &infrav1exp.AzureManagedControlPlane{
+					Spec:   infrav1exp.AzureManagedControlPlaneSpec{Version: "v1.17.7"},
+					Status: infrav1exp.AzureManagedControlPlaneStatus{Version: tc.statusVersion},
+				},
+			}
+			managedCluster := containerservice.ManagedCluster{
+				ManagedClusterProperties: &containerservice.ManagedClusterProperties{
+					ProvisioningState: to.StringPtr(tc.provisioningState),
+					KubernetesVersion: to.StringPtr(tc.version),
+				},
+			}
+
+			setVersionStatus(s, managedCluster)
+			g.Expect(s.ControlPlane.Status.Version).To(gomega.Equal(tc.expectedVersion))
+			if tc.expectedReason == "" {
+				g.Expect(conditions.IsTrue(s.ControlPlane, infrav1exp.KubernetesVersionUpgradedCondition)).To(gomega.BeTrue())
+			} else {
+				g.Expect(conditions.IsFalse(s.ControlPlane, infrav1exp.KubernetesVersionUpgradedCondition)).To(gomega.BeTrue())
+				g.Expect(conditions.GetReason(s.ControlPlane, infrav1exp.KubernetesVersionUpgradedCondition)).To(gomega.Equal(tc.expectedReason))
+			}
+		})
+	}
+}
+
+func TestAzureManagedControlPlaneReconcileMaintenanceWindow(t *testing.T) {
+	testcases := []struct {
+		name   string
+		window *infrav1exp.MaintenanceWindow
+		expect func(m *mock_maintenanceconfigurations.MockClientMockRecorder)
+	}{
+		{
+			name:   "not declared",
+			expect: func(m *mock_maintenanceconfigurations.MockClientMockRecorder) {},
+		},
+		{
+			name:   "without any times",
+			window: &infrav1exp.MaintenanceWindow{},
+			expect: func(m *mock_maintenanceconfigurations.MockClientMockRecorder) {
+				m.Delete(context.TODO(), "my-rg", "my-cluster", "default").Return(nil)
+			},
+		},
+		{
+			name: "with times",
+			window: &infrav1exp.MaintenanceWindow{
+				TimeInWeek: []infrav1exp.TimeInWeek{{Day: "Sunday", HourSlots: []int32{0, 1}}},
+			},
+			expect: func(m *mock_maintenanceconfigurations.MockClientMockRecorder) {
+				config := containerservice.MaintenanceConfiguration{
+					MaintenanceConfigurationProperties: &containerservice.MaintenanceConfigurationProperties{
+						TimeInWeek:     &[]containerservice.TimeInWeek{{Day: containerservice.WeekDaySunday, HourSlots: &[]int32{0, 1}}},
+						NotAllowedTime: &[]containerservice.TimeSpan{},
+					},
+				}
+				m.Get(context.TODO(), "my-rg", "my-cluster", "default").Return(containerservice.MaintenanceConfiguration{}, nil)
+				m.CreateOrUpdate(context.TODO(), "my-rg", "my-cluster", "default", config).Return(nil)
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := gomega.NewWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			maintenanceConfigurationsMock := mock_maintenanceconfigurations.NewMockClient(mockCtrl)
+
+			tc.expect(maintenanceConfigurationsMock.EXPECT())
+
+			r := &azureManagedControlPlaneReconciler{
+				maintenanceConfigurationsSvc: &maintenanceconfigurations.Service{Client: maintenanceConfigurationsMock},
+			}
+			s := &scope.ManagedControlPlaneScope{
+				ControlPlane: &infrav1exp.AzureManagedControlPlane{
+					ObjectMeta: metav1.ObjectMeta{Name: "my-cluster"},
+					Spec: infrav1exp.AzureManagedControlPlaneSpec{
+						ResourceGroup:     "my-rg",
+						MaintenanceWindow: tc.window,
+					},
+				},
+			}
+
+			g.Expect(r.reconcileMaintenanceWindow(context.TODO(), s)).To(gomega.Succeed())
+		})
+	}
+}
+
+func TestAzureManagedControlPlaneReconcileIdentity(t *testing.T) {
+	const (
+		controlPlaneIdentityID = "/subscriptions/456/resourceGroups/identity-rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/control-plane"
+		kubeletIdentityID      = "/subscriptions/456/resourceGroups/identity-rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/kubelet"
+	)
+	managedIdentityOperatorRoleID := "/subscriptions/456/providers/Microsoft.Authorization/roleDefinitions/" + roleassignments.ManagedIdentityOperatorRoleID
+	controlPlanePrincipalID := uuid.Must(uuid.FromString("11111111-1111-1111-1111-111111111111"))
+	kubeletClientID := uuid.Must(uuid.FromString("22222222-2222-2222-2222-222222222222"))
+	kubeletPrincipalID := uuid.Must(uuid.FromString("33333333-3333-3333-3333-333333333333"))
+	controlPlaneMSI := msi.Identity{
+		ID: to.StringPtr(controlPlaneIdentityID),
+		UserAssignedIdentityProperties: &msi.UserAssignedIdentityProperties{
+			ClientID:    &kubeletClientID,
+			PrincipalID: &controlPlanePrincipalID,
+		},
+	}
+	kubeletMSI := msi.Identity{
+		ID: to.StringPtr(kubeletIdentityID),
+		UserAssignedIdentityProperties: &msi.UserAssignedIdentityProperties{
+			ClientID:    &kubeletClientID,
+			PrincipalID: &kubeletPrincipalID,
+		},
+	}
+	kubeletIdentity := containerservice.UserAssignedIdentity{
+		ResourceID: to.StringPtr(kubeletIdentityID),
+		ClientID:   to.StringPtr(kubeletClientID.String()),
+		ObjectID:   to.StringPtr(kubeletPrincipalID.String()),
+	}
+	principalFilter := "principalId eq '" + controlPlanePrincipalID.String() + "'"
+
+	testcases := []struct {
+		name                    string
+		identity                *infrav1exp.ManagedControlPlaneIdentity
+		expect                  func(i *mock_identities.MockClientMockRecorder, ra *mock_roleassignments.MockClientMockRecorder)
+		expectedKubeletIdentity *containerservice.UserAssignedIdentity
+	}{
+		{
+			name:   "system-assigned identities",
+			expect: func(i *mock_identities.MockClientMockRecorder, ra *mock_roleassignments.MockClientMockRecorder) {},
+		},
+		{
+			name:     "user-assigned control plane identity",
+			identity: &infrav1exp.ManagedControlPlaneIdentity{ControlPlaneIdentityID: controlPlaneIdentityID},
+			expect:   func(i *mock_identities.MockClientMockRecorder, ra *mock_roleassignments.MockClientMockRecorder) {},
+		},
+		{
+			name: "user-assigned kubelet identity with a pre-granted role",
+			identity: &infrav1exp.ManagedControlPlaneIdentity{
+				ControlPlaneIdentityID: controlPlaneIdentityID,
+				KubeletIdentityID:      kubeletIdentityID,
+			},
+			expect: func(i *mock_identities.MockClientMockRecorder, ra *mock_roleassignments.MockClientMockRecorder) {
+				i.Get(context.TODO(), "456", "identity-rg", "control-plane").Return(controlPlaneMSI, nil)
+				i.Get(context.TODO(), "456", "identity-rg", "kubelet").Return(kubeletMSI, nil)
+				ra.ListForScope(context.TODO(), kubeletIdentityID, principalFilter).Return([]authorization.RoleAssignment{
+					{
+						Name: to.StringPtr("pre-granted"),
+						Properties: &authorization.RoleAssignmentPropertiesWithScope{
+							RoleDefinitionID: to.StringPtr(managedIdentityOperatorRoleID),
+							PrincipalID:      to.StringPtr(controlPlanePrincipalID.String()),
+						},
+					},
+				}, nil)
+			},
+			expectedKubeletIdentity: &kubeletIdentity,
+		},
+		{
+			name: "user-assigned kubelet identity without the role",
+			identity: &infrav1exp.ManagedControlPlaneIdentity{
+				ControlPlaneIdentityID: controlPlaneIdentityID,
+				KubeletIdentityID:      kubeletIdentityID,
+			},
+			expect: func(i *mock_identities.MockClientMockRecorder, ra *mock_roleassignments.MockClientMockRecorder) {
+				i.Get(context.TODO(), "456", "identity-rg", "control-plane").Return(controlPlaneMSI, nil)
+				i.Get(context.TODO(), "456", "identity-rg", "kubelet").Return(kubeletMSI, nil)
+				ra.ListForScope(context.TODO(), kubeletIdentityID, principalFilter).Return(nil, nil)
+				ra.Get(context.TODO(), kubeletIdentityID, gomock.Any()).Return(authorization.RoleAssignment{}, autorest.NewErrorWithResponse("", "", &http.Response{StatusCode: 404}, "Not Found"))
+				ra.Create(context.TODO(), kubeletIdentityID, gomock.Any(), authorization.RoleAssignmentCreateParameters{
+					Properties: &authorization.RoleAssignmentProperties{
+						RoleDefinitionID: to.StringPtr(managedIdentityOperatorRoleID),
+						PrincipalID:      to.StringPtr(controlPlanePrincipalID.String()),
+					},
+				}).Return(authorization.RoleAssignment{}, nil)
+			},
+			expectedKubeletIdentity: &kubeletIdentity,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := gomega.NewWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			identitiesMock := mock_identities.NewMockClient(mockCtrl)
+			roleAssignmentsMock := mock_roleassignments.NewMockClient(mockCtrl)
+
+			tc.expect(identitiesMock.EXPECT(), roleAssignmentsMock.EXPECT())
+
+			r := &azureManagedControlPlaneReconciler{
+				identitiesSvc:      &identities.Service{Client: identitiesMock},
+				roleAssignmentsSvc: &roleassignments.Service{Client: roleAssignmentsMock},
+			}
+			s := &scope.ManagedControlPlaneScope{
+				AzureClients: scope.AzureClients{SubscriptionID: "123"},
+				ControlPlane: &infrav1exp.AzureManagedControlPlane{
+					ObjectMeta: metav1.ObjectMeta{Name: "my-cluster"},
+					Spec: infrav1exp.AzureManagedControlPlaneSpec{
+						ResourceGroup: "my-rg",
+						Identity:      tc.identity,
+					},
+				},
+			}
+
+			managedClusterSpec := &managedclusters.Spec{}
+			g.Expect(r.reconcileIdentity(context.TODO(), s, managedClusterSpec)).To(gomega.Succeed())
+			if tc.identity != nil {
+				g.Expect(managedClusterSpec.ControlPlaneIdentityID).To(gomega.Equal(controlPlaneIdentityID))
+			} else {
+				g.Expect(managedClusterSpec.ControlPlaneIdentityID).To(gomega.BeEmpty())
+			}
+			g.Expect(managedClusterSpec.KubeletIdentity).To(gomega.Equal(tc.expectedKubeletIdentity))
+		})
+	}
+}
+
+func TestGetAPIServerFQDN(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	publicCluster := containerservice.ManagedCluster{
+		ManagedClusterProperties: &containerservice.ManagedClusterProperties{
+			Fqdn: to.StringPtr("my-cluster-7a3c9f2e.hcp.southcentralus.azmk8s.io"),
+		},
+	}
+	g.Expect(getAPIServerFQDN(publicCluster)).To(gomega.Equal("my-cluster-7a3c9f2e.hcp.southcentralus.azmk8s.io"))
+
+	privateCluster := containerservice.ManagedCluster{
+		ManagedClusterProperties: &containerservice.ManagedClusterProperties{
+			PrivateFQDN: to.StringPtr("my-cluster-5e8d1b4a.b2f4c6d8-1a3e-4f5b-9c7d-2e4f6a8b0c1d.privatelink.southcentralus.azmk8s.io"),
+			APIServerAccessProfile: &containerservice.ManagedClusterAPIServerAccessProfile{
+				EnablePrivateCluster: to.BoolPtr(true),
+			},
+		},
+	}
+	g.Expect(getAPIServerFQDN(privateCluster)).To(gomega.Equal("my-cluster-5e8d1b4a.b2f4c6d8-1a3e-4f5b-9c7d-2e4f6a8b0c1d.privatelink.southcentralus.azmk8s.io"))
+}
+
+func TestSetKubeconfigServer(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	kubeconfig := []byte(`apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://my-cluster-7a3c9f2e.hcp.southcentralus.azmk8s.io:443
+  name: my-cluster
+contexts:
+- context:
+    cluster: my-cluster
+    user: clusterAdmin_foo-bar_my-cluster
+  name: my-cluster-admin
+current-context: my-cluster-admin
+users:
+- name: clusterAdmin_foo-bar_my-cluster
+  user:
+    token: 0123456789abcdef
+`)
+
+	data, err := setKubeconfigServer(kubeconfig, "my-cluster-5e8d1b4a.privatelink.southcentralus.azmk8s.io")
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+
+	config, err := clientcmd.Load(data)
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(config.Clusters["my-cluster"].Server).To(gomega.Equal("https://my-cluster-5e8d1b4a.privatelink.southcentralus.azmk8s.io:443"))
+	g.Expect(config.AuthInfos["clusterAdmin_foo-bar_my-cluster"].Token).To(gomega.Equal("0123456789abcdef"))
+}
+
+func TestAzureManagedControlPlaneReconcileKubeconfig(t *testing.T) {
+	testcases := []struct {
+		name                 string
+		aadProfile           *managedclusters.AADProfile
+		storeAdminKubeconfig *bool
+		existingAdminSecret  bool
+		expectedKubeconfig   string
+		expectedAdmin        string
+		expect               func(m *mock_managedclusters.MockClientMockRecorder)
+	}{
+		{
+			name:               "admin kubeconfig without AAD",
+			expectedKubeconfig: "admin",
+			expect: func(m *mock_managedclusters.MockClientMockRecorder) {
+				m.GetCredentials(context.TODO(), "my-rg", "my-cluster").Return([]byte("admin"), nil)
+			},
+		},
+		{
+			name:               "user kubeconfig with AAD",
+			aadProfile:         &managedclusters.AADProfile{},
+			expectedKubeconfig: "user",
+			expect: func(m *mock_managedclusters.MockClientMockRecorder) {
+				m.GetUserCredentials(context.TODO(), "my-rg", "my-cluster").Return([]byte("user"), nil)
+			},
+		},
+		{
+			name:                 "admin kubeconfig in separate secret on request",
+			aadProfile:           &managedclusters.AADProfile{},
+			storeAdminKubeconfig: to.BoolPtr(true),
+			expectedKubeconfig:   "user",
+			expectedAdmin:        "admin",
+			expect: func(m *mock_managedclusters.MockClientMockRecorder) {
+				m.GetUserCredentials(context.TODO(), "my-rg", "my-cluster").Return([]byte("user"), nil)
+				m.GetCredentials(context.TODO(), "my-rg", "my-cluster").Return([]byte("admin"), nil)
+			},
+		},
+		{
+			name:                "admin kubeconfig secret is deleted when no longer requested",
+			aadProfile:          &managedclusters.AADProfile{},
+			existingAdminSecret: true,
+			expectedKubeconfig:  "user",
+			expect: func(m *mock_managedclusters.MockClientMockRecorder) {
+				m.GetUserCredentials(context.TODO(), "my-rg", "my-cluster").Return([]byte("user"), nil)
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := gomega.NewWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			managedclusterMock := mock_managedclusters.NewMockClient(mockCtrl)
+
+			tc.expect(managedclusterMock.EXPECT())
+
+			scheme := runtime.NewScheme()
+			g.Expect(clientgoscheme.AddToScheme(scheme)).To(gomega.Succeed())
+			var initObjects []runtime.Object
+			if tc.existingAdminSecret {
+				initObjects = append(initObjects, &corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "my-cluster-admin-kubeconfig", Namespace: "default"},
+				})
+			}
+			kubeclient := fake.NewFakeClientWithScheme(scheme, initObjects...)
+
+			r := &azureManagedControlPlaneReconciler{
+				kubeclient:         kubeclient,
+				managedClustersSvc: &managedclusters.Service{Client: managedclusterMock},
+			}
+			s := &scope.ManagedControlPlaneScope{
+				Logger: klogr.New(),
+				Cluster: &clusterv1.Cluster{
+					ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Namespace: "default"},
+				},
+				ControlPlane: &infrav1exp.AzureManagedControlPlane{
+					ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Namespace: "default"},
+					Spec: infrav1exp.AzureManagedControlPlaneSpec{
+						StoreAdminKubeconfig: tc.storeAdminKubeconfig,
+					},
+				},
+			}
+			spec := &managedclusters.Spec{
+				Name:          "my-cluster",
+				ResourceGroup: "my-rg",
+				AADProfile:    tc.aadProfile,
+			}
+
+			g.Expect(r.reconcileKubeconfig(context.TODO(), s, spec)).To(gomega.Succeed())
+
+			kubeconfig := &corev1.Secret{}
+			g.Expect(kubeclient.Get(context.TODO(), client.ObjectKey{Namespace: "default", Name: "my-cluster-kubeconfig"}, kubeconfig)).To(gomega.Succeed())
+			g.Expect(string(kubeconfig.Data[secret.KubeconfigDataName])).To(gomega.Equal(tc.expectedKubeconfig))
+
+			adminKubeconfig := &corev1.Secret{}
+			err := kubeclient.Get(context.TODO(), client.ObjectKey{Namespace: "default", Name: "my-cluster-admin-kubeconfig"}, adminKubeconfig)
+			if tc.expectedAdmin == "" {
+				g.Expect(apierrors.IsNotFound(err)).To(gomega.BeTrue())
+			} else {
+				g.Expect(err).NotTo(gomega.HaveOccurred())
+				g.Expect(string(adminKubeconfig.Data[secret.KubeconfigDataName])).To(gomega.Equal(tc.expectedAdmin))
+			}
+		})
+	}
+}
+
+func TestAzureManagedControlPlaneReconcileAdminKubeconfigWithoutLocalAccounts(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	r := &azureManagedControlPlaneReconciler{}
+	s := &scope.ManagedControlPlaneScope{
+		Logger:  klogr.New(),
+		Cluster: &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Namespace: "default"}},
+		ControlPlane: &infrav1exp.AzureManagedControlPlane{
+			ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Namespace: "default"},
+			Spec: infrav1exp.AzureManagedControlPlaneSpec{
+				ResourceGroup:        "my-rg",
+				AADProfile:           &infrav1exp.AADProfile{},
+				DisableLocalAccounts: to.BoolPtr(true),
+				StoreAdminKubeconfig: to.BoolPtr(true),
+			},
+		},
+	}
+
+	g.Expect(r.Reconcile(context.TODO(), s)).To(gomega.MatchError("storeAdminKubeconfig is not supported when local accounts are disabled"))
+}
+
+func TestGetAddonProfileStatuses(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	statuses := getAddonProfileStatuses(map[string]*containerservice.ManagedClusterAddonProfile{
+		"omsagent": {
+			Enabled: to.BoolPtr(true),
+			Identity: &containerservice.ManagedClusterAddonProfileIdentity{
+				ResourceID: to.StringPtr("/subscriptions/123/resourceGroups/MC_my-rg_my-cluster_westus2/providers/Microsoft.ManagedIdentity/userAssignedIdentities/omsagent-my-cluster"),
+				ClientID:   to.StringPtr("11111111-1111-1111-1111-111111111111"),
+				ObjectID:   to.StringPtr("22222222-2222-2222-2222-222222222222"),
+			},
+		},
+		"azurepolicy": {
+			Enabled: to.BoolPtr(true),
+		},
+		"kubeDashboard": {
+			Enabled: to.BoolPtr(false),
+		},
+	})
+	g.Expect(statuses).To(gomega.Equal([]infrav1exp.AddonProfileStatus{
+		{
+			Name: "azurepolicy",
+		},
+		{
+			Name: "omsagent",
+			Identity: &infrav1exp.AddonIdentity{
+				ResourceID: "/subscriptions/123/resourceGroups/MC_my-rg_my-cluster_westus2/providers/Microsoft.ManagedIdentity/userAssignedIdentities/omsagent-my-cluster",
+				ClientID:   "11111111-1111-1111-1111-111111111111",
+				ObjectID:   "22222222-2222-2222-2222-222222222222",
+			},
+		},
+	}))
+}
+
+func TestGetLogAnalyticsAddonProfile(t *testing.T) {
+	workspaceID := "/subscriptions/123/resourceGroups/my-rg/providers/Microsoft.OperationalInsights/workspaces/my-workspace"
+	testcases := []struct {
+		name          string
+		infraCluster  *infrav1exp.AzureManagedCluster
+		addonProfiles []infrav1exp.AddonProfile
+		expected      *managedclusters.AddonProfile
+	}{
+		{
+			name: "no AzureManagedCluster",
+		},
+		{
+			name:         "no Log Analytics workspace",
+			infraCluster: &infrav1exp.AzureManagedCluster{},
+		},
+		{
+			name: "enables omsagent add-on",
+			infraCluster: &infrav1exp.AzureManagedCluster{
+				Spec: infrav1exp.AzureManagedClusterSpec{LogAnalyticsWorkspaceID: workspaceID},
+			},
+			expected: &managedclusters.AddonProfile{
+				Name:    "omsagent",
+				Enabled: true,
+				Config:  map[string]string{"logAnalyticsWorkspaceResourceID": workspaceID},
+			},
+		},
+		{
+			name: "omsagent add-on configured by the control plane",
+			infraCluster: &infrav1exp.AzureManagedCluster{
+				Spec: infrav1exp.AzureManagedClusterSpec{LogAnalyticsWorkspaceID: workspaceID},
+			},
+			addonProfiles: []infrav1exp.AddonProfile{
+				{Name: "omsAgent", Enabled: false},
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			g := gomega.NewWithT(t)
+			s := &scope.ManagedControlPlaneScope{
+				ControlPlane: &infrav1exp.AzureManagedControlPlane{
+					Spec: infrav1exp.AzureManagedControlPlaneSpec{AddonProfiles: tc.addonProfiles},
+				},
+				InfraCluster: tc.infraCluster,
+			}
+			g.Expect(getLogAnalyticsAddonProfile(s)).To(gomega.Equal(tc.expected))
+		})
+	}
+}
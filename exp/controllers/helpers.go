@@ -190,6 +190,53 @@ func AzureManagedClusterToAzureManagedControlPlaneMapper(c client.Client, log lo
 	}), nil
 }
 
+// AzureManagedControlPlaneToAzureManagedClusterMapper creates a mapping handler to transform AzureManagedControlPlanes
+// into AzureManagedClusters. The transform requires AzureManagedControlPlane to map to the owning Cluster, then from
+// the Cluster, collect the infrastructure reference.
+func AzureManagedControlPlaneToAzureManagedClusterMapper(c client.Client, log logr.Logger) (handler.Mapper, error) {
+	return handler.ToRequestsFunc(func(o handler.MapObject) []ctrl.Request {
+		ctx, cancel := context.WithTimeout(context.Background(), reconciler.DefaultMappingTimeout)
+		defer cancel()
+
+		azControlPlane, ok := o.Object.(*infrav1exp.AzureManagedControlPlane)
+		if !ok {
+			log.Error(errors.Errorf("expected an AzureManagedControlPlane, got %T instead", o.Object), "failed to map AzureManagedControlPlane")
+			return nil
+		}
+
+		log = log.WithValues("AzureManagedControlPlane", azControlPlane.Name, "Namespace", azControlPlane.Namespace)
+
+		// Don't handle deleted AzureManagedControlPlanes
+		if !azControlPlane.ObjectMeta.DeletionTimestamp.IsZero() {
+			log.V(4).Info("AzureManagedControlPlane has a deletion timestamp, skipping mapping.")
+			return nil
+		}
+
+		cluster, err := util.GetOwnerCluster(ctx, c, azControlPlane.ObjectMeta)
+		if err != nil {
+			log.Error(err, "failed to get the owning cluster")
+			return nil
+		}
+		if cluster == nil {
+			return nil
+		}
+
+		ref := cluster.Spec.InfrastructureRef
+		if ref == nil || ref.Name == "" {
+			return nil
+		}
+
+		return []ctrl.Request{
+			{
+				NamespacedName: types.NamespacedName{
+					Namespace: ref.Namespace,
+					Name:      ref.Name,
+				},
+			},
+		}
+	}), nil
+}
+
 // MachinePoolToInfrastructureMapFunc returns a handler.ToRequestsFunc that watches for
 // MachinePool events and returns reconciliation requests for an infrastructure provider object.
 func MachinePoolToInfrastructureMapFunc(gvk schema.GroupVersionKind) handler.ToRequestsFunc {
@@ -178,6 +178,53 @@ func TestAzureManagedClusterToAzureManagedControlPlaneMapper(t *testing.T) {
 	}))
 }
 
+func TestAzureManagedControlPlaneToAzureManagedClusterMapper(t *testing.T) {
+	g := NewWithT(t)
+	scheme := newScheme(g)
+	cpName := "my-managed-cp"
+	cluster := newCluster("my-cluster")
+	cluster.Spec.InfrastructureRef = &corev1.ObjectReference{
+		APIVersion: infrav1exp.GroupVersion.String(),
+		Kind:       "AzureManagedCluster",
+		Name:       "az-" + cluster.Name,
+		Namespace:  cluster.Namespace,
+	}
+
+	initObjects := []runtime.Object{
+		cluster,
+	}
+	client := fake.NewFakeClientWithScheme(scheme, initObjects...)
+
+	log := mock_log.NewMockLogger(gomock.NewController(t))
+	log.EXPECT().WithValues("AzureManagedControlPlane", cpName, "Namespace", "default")
+
+	mapper, err := AzureManagedControlPlaneToAzureManagedClusterMapper(client, log)
+	g.Expect(err).NotTo(HaveOccurred())
+	requests := mapper.Map(handler.MapObject{
+		Object: &infrav1exp.AzureManagedControlPlane{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      cpName,
+				Namespace: "default",
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						Name:       cluster.Name,
+						Kind:       "Cluster",
+						APIVersion: clusterv1.GroupVersion.String(),
+					},
+				},
+			},
+		},
+	})
+	g.Expect(requests).To(Equal([]reconcile.Request{
+		{
+			NamespacedName: types.NamespacedName{
+				Name:      "az-" + cluster.Name,
+				Namespace: cluster.Namespace,
+			},
+		},
+	}))
+}
+
 func newAzureManagedControlPlane(cpName string) *infrav1exp.AzureManagedControlPlane {
 	return &infrav1exp.AzureManagedControlPlane{
 		ObjectMeta: metav1.ObjectMeta{
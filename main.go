@@ -82,6 +82,7 @@ var (
 	healthAddr                  string
 	webhookPort                 int
 	reconcileTimeout            time.Duration
+	bootLogCaptureTimeout       time.Duration
 )
 
 func InitFlags(fs *pflag.FlagSet) {
@@ -154,6 +155,12 @@ func InitFlags(fs *pflag.FlagSet) {
 		"The maximum duration a reconcile loop can run (e.g. 90m)",
 	)
 
+	fs.DurationVar(&bootLogCaptureTimeout,
+		"boot-log-capture-timeout",
+		controllers.DefaultBootLogCaptureTimeout,
+		"The duration after which the serial console log of an AzureMachine or an AzureMachinePool instance with boot diagnostics enabled is captured if it has not become a Node (e.g. 20m). Set to 0 to disable.",
+	)
+
 	feature.MutableGates.AddFlag(fs)
 }
 
@@ -202,9 +209,10 @@ func main() {
 
 	if webhookPort == 0 {
 		if err = (&controllers.AzureMachineReconciler{
-			Client:   mgr.GetClient(),
-			Log:      ctrl.Log.WithName("controllers").WithName("AzureMachine"),
-			Recorder: mgr.GetEventRecorderFor("azuremachine-reconciler"),
+			Client:                mgr.GetClient(),
+			Log:                   ctrl.Log.WithName("controllers").WithName("AzureMachine"),
+			Recorder:              mgr.GetEventRecorderFor("azuremachine-reconciler"),
+			BootLogCaptureTimeout: bootLogCaptureTimeout,
 		}).SetupWithManager(mgr, controller.Options{MaxConcurrentReconciles: azureMachineConcurrency}); err != nil {
 			setupLog.Error(err, "unable to create controller", "controller", "AzureMachine")
 			os.Exit(1)
@@ -221,9 +229,10 @@ func main() {
 		setupLog.V(1).Info(fmt.Sprintf("%+v\n", feature.Gates))
 		if feature.Gates.Enabled(capifeature.MachinePool) {
 			if err = (&infrav1controllersexp.AzureMachinePoolReconciler{
-				Client:   mgr.GetClient(),
-				Log:      ctrl.Log.WithName("controllers").WithName("AzureMachinePool"),
-				Recorder: mgr.GetEventRecorderFor("azurecluster-reconciler"),
+				Client:                mgr.GetClient(),
+				Log:                   ctrl.Log.WithName("controllers").WithName("AzureMachinePool"),
+				Recorder:              mgr.GetEventRecorderFor("azurecluster-reconciler"),
+				BootLogCaptureTimeout: bootLogCaptureTimeout,
 			}).SetupWithManager(mgr, controller.Options{MaxConcurrentReconciles: azureMachinePoolConcurrency}); err != nil {
 				setupLog.Error(err, "unable to create controller", "controller", "AzureMachinePool")
 				os.Exit(1)